@@ -0,0 +1,174 @@
+// Package apitest runs the HTTP API in-process for tests. New gives each
+// test the full router over its own SQLite database, with blobs in a
+// temporary directory, the fake payment provider and a mailbox in place of
+// SMTP. Requests go through httptest as a signed-in user, responses are
+// checked against golden files, and factories create the courses and users
+// a test needs.
+//
+// A new endpoint's test usually reads:
+//
+//	func TestGetThing(t *testing.T) {
+//	    s := apitest.New(t)
+//	    course := s.Course()
+//	    s.GET("/api/courses/"+course.ID+"/thing").
+//	        Status(http.StatusOK).
+//	        Golden("get_thing")
+//	}
+package apitest
+
+import (
+    "context"
+    "net/http"
+    "path/filepath"
+    "sync"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/glebarez/sqlite"
+    "go-webservice/config"
+    "go-webservice/flags"
+    "go-webservice/middleware"
+    "go-webservice/notify"
+    "go-webservice/payment"
+    "go-webservice/router"
+    "go-webservice/storage"
+    "go-webservice/tenant"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+// Secret signs the tokens of users made by the factories, and the fake
+// payment provider's webhooks.
+const Secret = "apitest-secret"
+
+// Server is the API under test. It replaces the service's package-level
+// connections while the test runs, so tests using it must not run in
+// parallel with each other.
+type Server struct {
+    t       testing.TB
+    Handler http.Handler
+    DB      *gorm.DB
+    Mailbox *Mailbox
+    Payment *payment.Fake
+    // Admin is an admin of the default tenant, the user requests are sent
+    // as unless As says otherwise.
+    Admin User
+
+    mu   sync.Mutex
+    seq  map[string]int
+    tags map[string]string
+}
+
+// New starts a server over an empty database holding only the seeded
+// default tenant and its "Go Basics" course (ID "1").
+func New(t testing.TB) *Server {
+    t.Helper()
+    gin.SetMode(gin.TestMode)
+    restore(t)
+
+    dsn := filepath.Join(t.TempDir(), "api.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+    db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatalf("open database: %v", err)
+    }
+    t.Cleanup(func() {
+        if sqlDB, err := db.DB(); err == nil {
+            sqlDB.Close()
+        }
+    })
+    if err := config.UseDatabase(db); err != nil {
+        t.Fatalf("migrate database: %v", err)
+    }
+    blobs, err := storage.NewLocalStore(t.TempDir())
+    if err != nil {
+        t.Fatalf("open blob store: %v", err)
+    }
+    config.Blobs = blobs
+    mailbox := &Mailbox{}
+    config.Notifier = mailbox
+    pay := payment.NewFake(Secret)
+    config.Payments = pay
+    config.Flags = flags.NewStore()
+    middleware.JWTSecret = Secret
+
+    s := &Server{
+        t:       t,
+        Handler: router.SetupRouter(),
+        DB:      db,
+        Mailbox: mailbox,
+        Payment: pay,
+        seq:     make(map[string]int),
+        tags:    make(map[string]string),
+    }
+    s.Admin = s.User(Role("admin"))
+    return s
+}
+
+// restore puts back the package-level connections and settings New
+// replaces once the test is over.
+func restore(t testing.TB) {
+    db, reads, search, cache := config.DB, config.Reads, config.Search, config.Cache
+    blobs, notifier, payments, store := config.Blobs, config.Notifier, config.Payments, config.Flags
+    secret := middleware.JWTSecret
+    t.Cleanup(func() {
+        config.DB, config.Reads, config.Search, config.Cache = db, reads, search, cache
+        config.Blobs, config.Notifier, config.Payments, config.Flags = blobs, notifier, payments, store
+        middleware.JWTSecret = secret
+    })
+}
+
+// Context is a background context scoped to tenantID, for calling the
+// service layer directly.
+func Context(tenantID string) context.Context {
+    return tenant.WithTenant(context.Background(), tenantID)
+}
+
+// Flags replaces the flag definitions, as if they came from FLAGS_FILE,
+// until the test ends.
+func (s *Server) Flags(defs ...flags.Flag) {
+    config.Flags = flags.NewStore(defs...)
+}
+
+// WithoutPayments takes the payment provider away, as when
+// PAYMENT_PROVIDER is unset, until the test ends.
+func (s *Server) WithoutPayments() {
+    s.Payment = nil
+    config.Payments = nil
+}
+
+// next numbers the things of one kind a test makes, from 1, for factory
+// defaults.
+func (s *Server) next(kind string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.seq[kind]++
+    return s.seq[kind]
+}
+
+// Tag names a generated value, such as a course ID, so golden files show
+// the name instead of the value, which changes from run to run.
+func (s *Server) Tag(value, name string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.tags[value] = "<" + name + ">"
+}
+
+// Mailbox is a notify.Notifier that keeps what it is asked to send.
+type Mailbox struct {
+    mu       sync.Mutex
+    messages []notify.Message
+}
+
+func (m *Mailbox) Send(ctx context.Context, msg notify.Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.messages = append(m.messages, msg)
+    return nil
+}
+
+// Messages returns everything sent so far, oldest first.
+func (m *Mailbox) Messages() []notify.Message {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return append([]notify.Message(nil), m.messages...)
+}
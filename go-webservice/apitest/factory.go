@@ -0,0 +1,142 @@
+package apitest
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "go-webservice/config"
+    "go-webservice/jobs"
+    "go-webservice/middleware"
+    "go-webservice/model"
+    "go-webservice/service"
+    "go-webservice/tenant"
+)
+
+// User is a signed-in caller. Users aren't stored by the service, so a
+// user is just a subject, a role, a tenant and a token carrying them.
+type User struct {
+    ID     string
+    Role   string
+    Tenant string
+    Token  string
+}
+
+// UserOption customises a user made by Server.User.
+type UserOption func(*User)
+
+// Role gives the user a role other than "user".
+func Role(role string) UserOption {
+    return func(u *User) { u.Role = role }
+}
+
+// InTenant puts the user in a tenant other than the default.
+func InTenant(id string) UserOption {
+    return func(u *User) { u.Tenant = id }
+}
+
+// User makes a user of the default tenant with the "user" role and a
+// unique subject, signed in for an hour.
+func (s *Server) User(opts ...UserOption) User {
+    s.t.Helper()
+    u := User{ID: fmt.Sprintf("user-%d", s.next("user")), Role: "user", Tenant: tenant.Default}
+    for _, opt := range opts {
+        opt(&u)
+    }
+    token, err := middleware.IssueToken(u.ID, u.Role, u.Tenant, time.Hour)
+    if err != nil {
+        s.t.Fatalf("issue token: %v", err)
+    }
+    u.Token = token
+    return u
+}
+
+// Tenant creates a tenant with the given slug.
+func (s *Server) Tenant(id string) model.Tenant {
+    s.t.Helper()
+    t, err := service.CreateTenant(tenant.System(context.Background()), id, id)
+    if err != nil {
+        s.t.Fatalf("create tenant %s: %v", id, err)
+    }
+    return t
+}
+
+// CourseOption customises a course made by Server.Course.
+type CourseOption func(*model.Course)
+
+// Title sets the course title.
+func Title(title string) CourseOption {
+    return func(c *model.Course) { c.Title = title }
+}
+
+// Published makes the course published.
+func Published() CourseOption {
+    return func(c *model.Course) { c.Published = true }
+}
+
+// Price makes the course paid: amount is in minor units of currency.
+func Price(amount int64, currency string) CourseOption {
+    return func(c *model.Course) {
+        c.Price = amount
+        c.Currency = currency
+    }
+}
+
+// OwnedBy puts the course in a tenant other than the default.
+func OwnedBy(tenantID string) CourseOption {
+    return func(c *model.Course) { c.TenantID = tenantID }
+}
+
+// Course stores a draft, free course in the default tenant, titled
+// "Course N", and indexes it for search. It is written directly rather
+// than through the API, so it records no course events.
+func (s *Server) Course(opts ...CourseOption) model.Course {
+    s.t.Helper()
+    n := s.next("course")
+    c := model.Course{
+        ID:          uuid.NewString(),
+        TenantID:    tenant.Default,
+        Title:       fmt.Sprintf("Course %d", n),
+        Description: fmt.Sprintf("Description of course %d", n),
+    }
+    for _, opt := range opts {
+        opt(&c)
+    }
+    if err := config.DB.WithContext(Context(c.TenantID)).Create(&c).Error; err != nil {
+        s.t.Fatalf("create course: %v", err)
+    }
+    config.Search.Index(c.TenantID, c)
+    s.Tag(c.ID, fmt.Sprintf("course-%d", n))
+    return c
+}
+
+// Module appends a module titled "Module N" to a course.
+func (s *Server) Module(course model.Course) model.Module {
+    s.t.Helper()
+    m, err := service.CreateModule(Context(course.TenantID), course.ID, model.Module{Title: fmt.Sprintf("Module %d", s.next("module"))})
+    if err != nil {
+        s.t.Fatalf("create module: %v", err)
+    }
+    return m
+}
+
+// RunJobs runs due background jobs one at a time, as the worker pool
+// would, until none are left, and returns how many ran. Failed jobs are
+// rescheduled with backoff and so are not retried within the same call.
+func (s *Server) RunJobs() int {
+    s.t.Helper()
+    pool := jobs.NewPool(config.DB)
+    pool.ID = "apitest"
+    ran := 0
+    for {
+        ok, err := pool.RunOnce(context.Background())
+        if err != nil {
+            s.t.Fatalf("run jobs: %v", err)
+        }
+        if !ok {
+            return ran
+        }
+        ran++
+    }
+}
@@ -0,0 +1,93 @@
+package apitest
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+)
+
+// Option adjusts a request before it is sent.
+type Option func(*http.Request)
+
+// As sends the request as u instead of the admin.
+func As(u User) Option {
+    return func(r *http.Request) {
+        r.Header.Set("Authorization", "Bearer "+u.Token)
+    }
+}
+
+// Anonymous sends the request without credentials.
+func Anonymous() Option {
+    return func(r *http.Request) {
+        r.Header.Del("Authorization")
+    }
+}
+
+// Header sets a request header.
+func Header(name, value string) Option {
+    return func(r *http.Request) {
+        r.Header.Set(name, value)
+    }
+}
+
+// Do sends a request to the router. body is sent as is when it is a
+// string or []byte, and as JSON otherwise; a nil body sends none.
+func (s *Server) Do(method, path string, body interface{}, opts ...Option) *Response {
+    s.t.Helper()
+    var r io.Reader
+    contentType := ""
+    switch b := body.(type) {
+    case nil:
+    case string:
+        r = bytes.NewBufferString(b)
+        contentType = "application/json"
+    case []byte:
+        r = bytes.NewReader(b)
+        contentType = "application/json"
+    default:
+        data, err := json.Marshal(b)
+        if err != nil {
+            s.t.Fatalf("%s %s: encode body: %v", method, path, err)
+        }
+        r = bytes.NewReader(data)
+        contentType = "application/json"
+    }
+    req := httptest.NewRequest(method, path, r)
+    if contentType != "" {
+        req.Header.Set("Content-Type", contentType)
+    }
+    req.Header.Set("Authorization", "Bearer "+s.Admin.Token)
+    for _, opt := range opts {
+        opt(req)
+    }
+    w := httptest.NewRecorder()
+    s.Handler.ServeHTTP(w, req)
+    return &Response{s: s, Request: req, Code: w.Code, Header: w.Header(), Body: w.Body.Bytes()}
+}
+
+func (s *Server) GET(path string, opts ...Option) *Response {
+    s.t.Helper()
+    return s.Do(http.MethodGet, path, nil, opts...)
+}
+
+func (s *Server) POST(path string, body interface{}, opts ...Option) *Response {
+    s.t.Helper()
+    return s.Do(http.MethodPost, path, body, opts...)
+}
+
+func (s *Server) PUT(path string, body interface{}, opts ...Option) *Response {
+    s.t.Helper()
+    return s.Do(http.MethodPut, path, body, opts...)
+}
+
+func (s *Server) PATCH(path string, body interface{}, opts ...Option) *Response {
+    s.t.Helper()
+    return s.Do(http.MethodPatch, path, body, opts...)
+}
+
+func (s *Server) DELETE(path string, opts ...Option) *Response {
+    s.t.Helper()
+    return s.Do(http.MethodDelete, path, nil, opts...)
+}
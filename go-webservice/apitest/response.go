@@ -0,0 +1,121 @@
+package apitest
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "net/http"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the responses received")
+
+// Response is a recorded response. Its assertion methods fail the test and
+// return the response, so they chain.
+type Response struct {
+    s       *Server
+    Request *http.Request
+    Code    int
+    Header  http.Header
+    Body    []byte
+}
+
+func (r *Response) describe() string {
+    return r.Request.Method + " " + r.Request.URL.String()
+}
+
+// Status checks the status code.
+func (r *Response) Status(want int) *Response {
+    r.s.t.Helper()
+    if r.Code != want {
+        r.s.t.Fatalf("%s: status %d, want %d: %s", r.describe(), r.Code, want, r.Body)
+    }
+    return r
+}
+
+// HasHeader checks a response header's value.
+func (r *Response) HasHeader(name, want string) *Response {
+    r.s.t.Helper()
+    if got := r.Header.Get(name); got != want {
+        r.s.t.Fatalf("%s: header %s is %q, want %q", r.describe(), name, got, want)
+    }
+    return r
+}
+
+// JSON decodes the body into v.
+func (r *Response) JSON(v interface{}) *Response {
+    r.s.t.Helper()
+    if err := json.Unmarshal(r.Body, v); err != nil {
+        r.s.t.Fatalf("%s: decode body: %v: %s", r.describe(), err, r.Body)
+    }
+    return r
+}
+
+// Object decodes a JSON object body.
+func (r *Response) Object() map[string]interface{} {
+    r.s.t.Helper()
+    var v map[string]interface{}
+    r.JSON(&v)
+    return v
+}
+
+// Error checks the body is the service's error shape with the given
+// message.
+func (r *Response) Error(message string) *Response {
+    r.s.t.Helper()
+    body := r.Object()
+    if body["error"] != true || body["message"] != message {
+        r.s.t.Fatalf("%s: error body %s, want message %q", r.describe(), r.Body, message)
+    }
+    return r
+}
+
+var (
+    uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+    timePattern = regexp.MustCompile(`\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`)
+)
+
+// Golden compares the JSON body with testdata/<name>.golden.json in the
+// package under test. Tagged values, then any other UUIDs and timestamps,
+// are replaced with placeholders first, and the body is indented, so the
+// file stays stable and readable. Run the tests with -update to write the
+// files from the current responses.
+func (r *Response) Golden(name string) *Response {
+    r.s.t.Helper()
+    body := string(r.Body)
+    r.s.mu.Lock()
+    for value, tag := range r.s.tags {
+        body = strings.ReplaceAll(body, value, tag)
+    }
+    r.s.mu.Unlock()
+    body = uuidPattern.ReplaceAllString(body, "<uuid>")
+    body = timePattern.ReplaceAllString(body, "<time>")
+
+    var out bytes.Buffer
+    if err := json.Indent(&out, []byte(body), "", "  "); err != nil {
+        r.s.t.Fatalf("%s: body is not JSON: %v: %s", r.describe(), err, r.Body)
+    }
+    out.WriteByte('\n')
+
+    path := filepath.Join("testdata", name+".golden.json")
+    if *update {
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            r.s.t.Fatal(err)
+        }
+        if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+            r.s.t.Fatal(err)
+        }
+        return r
+    }
+    want, err := os.ReadFile(path)
+    if err != nil {
+        r.s.t.Fatalf("%s: %v (run with -update to create it)", r.describe(), err)
+    }
+    if !bytes.Equal(want, out.Bytes()) {
+        r.s.t.Fatalf("%s: body does not match %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", r.describe(), path, out.Bytes(), want)
+    }
+    return r
+}
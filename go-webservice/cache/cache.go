@@ -0,0 +1,122 @@
+// Package cache provides a read-through cache for service reads. Values
+// are stored as bytes so the in-memory LRU can be swapped for a shared
+// cache without touching callers.
+package cache
+
+import (
+    "bytes"
+    "context"
+    "encoding/gob"
+    "sync/atomic"
+    "time"
+
+    "golang.org/x/sync/singleflight"
+)
+
+// Cache stores opaque values with a time to live. Implementations treat
+// failures as misses; the data always comes from the database in the end.
+type Cache interface {
+    Get(ctx context.Context, key string) ([]byte, bool)
+    Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+    Delete(ctx context.Context, keys ...string)
+}
+
+type Stats struct {
+    Hits    uint64 `json:"hits"`
+    Misses  uint64 `json:"misses"`
+    Shared  uint64 `json:"shared"`
+    Entries int    `json:"entries,omitempty"`
+}
+
+// Group loads values through a Cache. Concurrent misses on the same key
+// share a single load, and an invalidation during a load stops the stale
+// result from being stored.
+type Group struct {
+    Cache Cache
+    TTL   time.Duration
+
+    flight singleflight.Group
+    epoch  atomic.Uint64
+    hits   atomic.Uint64
+    misses atomic.Uint64
+    shared atomic.Uint64
+}
+
+func NewGroup(c Cache, ttl time.Duration) *Group {
+    return &Group{Cache: c, TTL: ttl}
+}
+
+// Fetch returns the cached value for key, or calls load and caches its
+// result. Errors from load are returned and not cached.
+//
+// A load may be shared by several callers, so it runs with ctx's values
+// but without its deadline or cancellation: the first caller giving up
+// must not fail the others. A caller whose ctx ends stops waiting and gets
+// ctx.Err() while the load carries on for the rest.
+func Fetch[T any](ctx context.Context, g *Group, key string, load func(ctx context.Context) (T, error)) (T, error) {
+    var value T
+    if raw, ok := g.Cache.Get(ctx, key); ok {
+        if gob.NewDecoder(bytes.NewReader(raw)).Decode(&value) == nil {
+            g.hits.Add(1)
+            return value, nil
+        }
+    }
+    g.misses.Add(1)
+
+    loadCtx := withoutCancel{ctx}
+    ch := g.flight.DoChan(key, func() (interface{}, error) {
+        epoch := g.epoch.Load()
+        value, err := load(loadCtx)
+        if err != nil {
+            return value, err
+        }
+        var buf bytes.Buffer
+        if gob.NewEncoder(&buf).Encode(value) == nil && g.epoch.Load() == epoch {
+            g.Cache.Set(loadCtx, key, buf.Bytes(), g.TTL)
+        }
+        return value, nil
+    })
+    select {
+    case res := <-ch:
+        if res.Shared {
+            g.shared.Add(1)
+        }
+        if res.Err != nil {
+            return value, res.Err
+        }
+        return res.Val.(T), nil
+    case <-ctx.Done():
+        return value, ctx.Err()
+    }
+}
+
+// withoutCancel is context.WithoutCancel, which needs Go 1.21: it keeps
+// the values of the wrapped context and drops its deadline and
+// cancellation.
+type withoutCancel struct {
+    context.Context
+}
+
+func (withoutCancel) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (withoutCancel) Done() <-chan struct{} { return nil }
+
+func (withoutCancel) Err() error { return nil }
+
+// Invalidate removes keys after a write. Loads already in flight finish
+// but their results are not cached.
+func (g *Group) Invalidate(ctx context.Context, keys ...string) {
+    g.epoch.Add(1)
+    for _, key := range keys {
+        g.flight.Forget(key)
+    }
+    g.Cache.Delete(ctx, keys...)
+}
+
+func (g *Group) Stats() Stats {
+    stats := Stats{Hits: g.hits.Load(), Misses: g.misses.Load(), Shared: g.shared.Load()}
+    if l, ok := g.Cache.(interface{ Len() int }); ok {
+        stats.Entries = l.Len()
+    }
+    return stats
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+    ctx := context.Background()
+    c := NewLRU(2)
+    c.Set(ctx, "a", []byte("1"), 0)
+    c.Set(ctx, "b", []byte("2"), 0)
+    c.Get(ctx, "a")
+    c.Set(ctx, "c", []byte("3"), 0)
+
+    if _, ok := c.Get(ctx, "b"); ok {
+        t.Error("b was used least recently and should be evicted")
+    }
+    for _, key := range []string{"a", "c"} {
+        if _, ok := c.Get(ctx, key); !ok {
+            t.Errorf("%s evicted", key)
+        }
+    }
+    if c.Len() != 2 {
+        t.Errorf("len %d, want 2", c.Len())
+    }
+
+    c.Delete(ctx, "a", "missing")
+    if _, ok := c.Get(ctx, "a"); ok || c.Len() != 1 {
+        t.Errorf("a still cached after delete")
+    }
+}
+
+func TestLRUExpires(t *testing.T) {
+    ctx := context.Background()
+    now := time.Now()
+    c := NewLRU(10)
+    c.now = func() time.Time { return now }
+    c.Set(ctx, "short", []byte("x"), time.Second)
+    c.Set(ctx, "forever", []byte("y"), 0)
+
+    now = now.Add(999 * time.Millisecond)
+    if _, ok := c.Get(ctx, "short"); !ok {
+        t.Fatal("expired early")
+    }
+    now = now.Add(time.Millisecond)
+    if _, ok := c.Get(ctx, "short"); ok {
+        t.Fatal("served after its ttl")
+    }
+    if _, ok := c.Get(ctx, "forever"); !ok {
+        t.Fatal("entry without a ttl expired")
+    }
+    if c.Len() != 1 {
+        t.Fatalf("expired entry not dropped, len %d", c.Len())
+    }
+}
+
+func TestFetch(t *testing.T) {
+    ctx := context.Background()
+    g := NewGroup(NewLRU(10), time.Minute)
+    loads := 0
+    load := func(context.Context) (string, error) {
+        loads++
+        return "value", nil
+    }
+    for i := 0; i < 3; i++ {
+        v, err := Fetch(ctx, g, "k", load)
+        if err != nil || v != "value" {
+            t.Fatalf("fetch: %q, %v", v, err)
+        }
+    }
+    if loads != 1 {
+        t.Fatalf("loaded %d times, want 1", loads)
+    }
+    if s := g.Stats(); s.Hits != 2 || s.Misses != 1 || s.Entries != 1 {
+        t.Fatalf("stats %+v", s)
+    }
+
+    g.Invalidate(ctx, "k")
+    Fetch(ctx, g, "k", load)
+    if loads != 2 {
+        t.Fatalf("loaded %d times after invalidation, want 2", loads)
+    }
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+    ctx := context.Background()
+    g := NewGroup(NewLRU(10), time.Minute)
+    boom := errors.New("boom")
+    if _, err := Fetch(ctx, g, "k", func(context.Context) (int, error) { return 0, boom }); err != boom {
+        t.Fatalf("error %v, want boom", err)
+    }
+    v, err := Fetch(ctx, g, "k", func(context.Context) (int, error) { return 7, nil })
+    if err != nil || v != 7 {
+        t.Fatalf("fetch after error: %d, %v", v, err)
+    }
+}
+
+func TestFetchSharesConcurrentLoads(t *testing.T) {
+    ctx := context.Background()
+    g := NewGroup(NewLRU(10), time.Minute)
+    release := make(chan struct{})
+    started := make(chan struct{})
+    var once sync.Once
+    var mu sync.Mutex
+    loads := 0
+    load := func(context.Context) (int, error) {
+        mu.Lock()
+        loads++
+        mu.Unlock()
+        once.Do(func() { close(started) })
+        <-release
+        return 42, nil
+    }
+
+    var wg sync.WaitGroup
+    results := make([]int, 5)
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        results[0], _ = Fetch(ctx, g, "k", load)
+    }()
+    <-started
+    for i := 1; i < len(results); i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            results[i], _ = Fetch(ctx, g, "k", load)
+        }(i)
+    }
+    // let the followers reach the in-flight load before it finishes
+    for g.Stats().Misses < uint64(len(results)) {
+        time.Sleep(time.Millisecond)
+    }
+    time.Sleep(10 * time.Millisecond)
+    close(release)
+    wg.Wait()
+
+    if loads != 1 {
+        t.Fatalf("loaded %d times, want 1", loads)
+    }
+    for i, v := range results {
+        if v != 42 {
+            t.Fatalf("result %d is %d", i, v)
+        }
+    }
+    if s := g.Stats(); s.Shared == 0 {
+        t.Fatalf("no shared loads recorded: %+v", s)
+    }
+}
+
+func TestInvalidateDuringLoadSkipsCaching(t *testing.T) {
+    ctx := context.Background()
+    g := NewGroup(NewLRU(10), time.Minute)
+    v, _ := Fetch(ctx, g, "k", func(context.Context) (string, error) {
+        // a write lands while the stale value is being read
+        g.Invalidate(ctx, "k")
+        return "stale", nil
+    })
+    if v != "stale" {
+        t.Fatalf("fetch returned %q", v)
+    }
+    if _, ok := g.Cache.Get(ctx, "k"); ok {
+        t.Fatal("value loaded across an invalidation was cached")
+    }
+}
+
+func TestFetchOutlivesCancelledCaller(t *testing.T) {
+    type key struct{}
+    g := NewGroup(NewLRU(10), time.Minute)
+    first, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "first"))
+    release := make(chan struct{})
+    started := make(chan struct{})
+    load := func(ctx context.Context) (string, error) {
+        close(started)
+        <-release
+        if ctx.Err() != nil {
+            return "", ctx.Err()
+        }
+        return ctx.Value(key{}).(string), nil
+    }
+
+    errs := make(chan error)
+    go func() {
+        _, err := Fetch(first, g, "k", load)
+        errs <- err
+    }()
+    <-started
+    values := make(chan string)
+    go func() {
+        v, _ := Fetch(context.Background(), g, "k", load)
+        values <- v
+    }()
+    for g.Stats().Misses < 2 {
+        time.Sleep(time.Millisecond)
+    }
+
+    // the first caller gives up without failing the load it started
+    cancel()
+    if err := <-errs; err != context.Canceled {
+        t.Fatalf("cancelled caller got %v", err)
+    }
+    close(release)
+    if v := <-values; v != "first" {
+        t.Fatalf("waiting caller got %q", v)
+    }
+    if v, ok := g.Cache.Get(context.Background(), "k"); !ok || len(v) == 0 {
+        t.Fatal("load finished after its caller left was not cached")
+    }
+}
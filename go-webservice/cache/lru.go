@@ -0,0 +1,84 @@
+package cache
+
+import (
+    "container/list"
+    "context"
+    "sync"
+    "time"
+)
+
+// LRU is an in-process Cache holding at most Size entries. The least
+// recently used entry is evicted first; expired entries are dropped when
+// they are next read.
+type LRU struct {
+    mu    sync.Mutex
+    size  int
+    ll    *list.List
+    items map[string]*list.Element
+    now   func() time.Time
+}
+
+type entry struct {
+    key     string
+    value   []byte
+    expires time.Time
+}
+
+func NewLRU(size int) *LRU {
+    return &LRU{size: size, ll: list.New(), items: make(map[string]*list.Element), now: time.Now}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    el, ok := c.items[key]
+    if !ok {
+        return nil, false
+    }
+    e := el.Value.(*entry)
+    if !e.expires.IsZero() && !c.now().Before(e.expires) {
+        c.remove(el)
+        return nil, false
+    }
+    c.ll.MoveToFront(el)
+    return e.value, true
+}
+
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    var expires time.Time
+    if ttl > 0 {
+        expires = c.now().Add(ttl)
+    }
+    if el, ok := c.items[key]; ok {
+        el.Value = &entry{key: key, value: value, expires: expires}
+        c.ll.MoveToFront(el)
+        return
+    }
+    c.items[key] = c.ll.PushFront(&entry{key: key, value: value, expires: expires})
+    for c.ll.Len() > c.size {
+        c.remove(c.ll.Back())
+    }
+}
+
+func (c *LRU) Delete(_ context.Context, keys ...string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for _, key := range keys {
+        if el, ok := c.items[key]; ok {
+            c.remove(el)
+        }
+    }
+}
+
+func (c *LRU) Len() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.ll.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+    c.ll.Remove(el)
+    delete(c.items, el.Value.(*entry).key)
+}
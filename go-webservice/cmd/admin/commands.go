@@ -0,0 +1,282 @@
+package main
+
+import (
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "go-webservice/config"
+    "go-webservice/middleware"
+    "go-webservice/model"
+    "go-webservice/search"
+    "go-webservice/service"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// parse parses a command's flags, reporting bad flags as a usage error.
+func parse(fs *flag.FlagSet, args []string) error {
+    fs.SetOutput(io.Discard)
+    if err := fs.Parse(args); err != nil {
+        fmt.Fprintln(stderr, "admin:", err)
+        return errUsage
+    }
+    return nil
+}
+
+func runTenantCreate(ctx context.Context, out *output, args []string) error {
+    if len(args) != 2 {
+        return errUsage
+    }
+    t, err := service.CreateTenant(ctx, args[0], args[1])
+    if err != nil {
+        return err
+    }
+    return out.print(t, []string{"ID", "NAME"}, [][]string{{t.ID, t.Name}})
+}
+
+// runTokenIssue signs a bearer token. Users are not stored by the
+// service, so the token is all there is to create.
+func runTokenIssue(_ context.Context, out *output, args []string) error {
+    fs := flag.NewFlagSet("token issue", flag.ContinueOnError)
+    subject := fs.String("subject", "", "user name or ID")
+    role := fs.String("role", "user", "user or admin")
+    ttl := fs.Duration("ttl", 30*24*time.Hour, "token lifetime")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if *subject == "" || fs.NArg() > 0 {
+        return errUsage
+    }
+    token, err := middleware.IssueToken(*subject, *role, out.tenant, *ttl)
+    if err != nil {
+        return err
+    }
+    expires := time.Now().Add(*ttl).UTC().Truncate(time.Second)
+    result := map[string]interface{}{"subject": *subject, "role": *role, "tenant": out.tenant, "expires_at": expires, "token": token}
+    return out.print(result, []string{"SUBJECT", "ROLE", "TENANT", "EXPIRES", "TOKEN"},
+        [][]string{{*subject, *role, out.tenant, expires.Format(time.RFC3339), token}})
+}
+
+func runKeyCreate(ctx context.Context, out *output, args []string) error {
+    fs := flag.NewFlagSet("key create", flag.ContinueOnError)
+    name := fs.String("name", "", "what the key is for")
+    role := fs.String("role", "user", "user or admin")
+    scopes := fs.String("scopes", strings.Join(model.Scopes, ","), "comma separated scopes")
+    expires := fs.Duration("expires", 0, "lifetime; 0 never expires")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if *name == "" || fs.NArg() > 0 {
+        return errUsage
+    }
+    var expiresAt *time.Time
+    if *expires > 0 {
+        t := time.Now().Add(*expires)
+        expiresAt = &t
+    }
+    key, raw, err := service.CreateAPIKey(ctx, service.FullGrant, *name, *role, strings.Split(*scopes, ","), expiresAt)
+    if err != nil {
+        return err
+    }
+    result := struct {
+        model.APIKey
+        Key string `json:"key"`
+    }{key, raw}
+    return out.print(result, []string{"ID", "NAME", "ROLE", "SCOPES", "KEY"},
+        [][]string{{strconv.FormatUint(uint64(key.ID), 10), key.Name, key.Role, key.Scopes, raw}})
+}
+
+func runKeyList(ctx context.Context, out *output, args []string) error {
+    if len(args) > 0 {
+        return errUsage
+    }
+    keys, err := service.GetAllAPIKeys(ctx)
+    if err != nil {
+        return err
+    }
+    var rows [][]string
+    for _, k := range keys {
+        rows = append(rows, []string{strconv.FormatUint(uint64(k.ID), 10), k.Name, k.Prefix, k.Role, k.Scopes,
+            formatTime(k.ExpiresAt), formatTime(k.RevokedAt), formatTime(k.LastUsedAt)})
+    }
+    return out.print(keys, []string{"ID", "NAME", "PREFIX", "ROLE", "SCOPES", "EXPIRES", "REVOKED", "LAST USED"}, rows)
+}
+
+func runKeyRevoke(ctx context.Context, out *output, args []string) error {
+    if len(args) != 1 {
+        return errUsage
+    }
+    if err := service.RevokeAPIKey(ctx, args[0]); err != nil {
+        return err
+    }
+    return out.print(map[string]string{"revoked": args[0]}, []string{"REVOKED"}, [][]string{{args[0]}})
+}
+
+var sampleCourses = []model.Course{
+    {Title: "Go Fundamentals", Description: "Types, functions, packages and the standard toolchain."},
+    {Title: "Concurrency in Go", Description: "Goroutines, channels, select and the sync package."},
+    {Title: "Building REST APIs", Description: "Routing, validation and error handling with Gin."},
+    {Title: "Databases with GORM", Description: "Models, migrations, transactions and query tuning."},
+    {Title: "Testing Go Code", Description: "Table-driven tests, fakes, benchmarks and fuzzing."},
+    {Title: "gRPC Services", Description: "Protocol buffers, streaming and interceptors."},
+    {Title: "Observability", Description: "Structured logging, metrics and tracing for services."},
+    {Title: "Deploying with Docker", Description: "Images, compose files and production settings."},
+    {Title: "Securing Web Services", Description: "Authentication, TLS, CORS and secure headers."},
+    {Title: "Performance Profiling", Description: "pprof, allocation tuning and caching strategies."},
+}
+
+func runSeed(ctx context.Context, out *output, args []string) error {
+    fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+    count := fs.Int("count", len(sampleCourses), "number of courses to create")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if *count < 1 || fs.NArg() > 0 {
+        return errUsage
+    }
+    var created []model.Course
+    var rows [][]string
+    for i := 0; i < *count; i++ {
+        sample := sampleCourses[i%len(sampleCourses)]
+        if round := i / len(sampleCourses); round > 0 {
+            sample.Title = fmt.Sprintf("%s %d", sample.Title, round+1)
+        }
+        course, err := service.CreateCourse(ctx, sample)
+        if err != nil {
+            return err
+        }
+        created = append(created, course)
+        rows = append(rows, []string{course.ID, course.Title})
+    }
+    return out.print(created, []string{"ID", "TITLE"}, rows)
+}
+
+func runExport(ctx context.Context, _ *output, args []string) error {
+    fs := flag.NewFlagSet("export", flag.ContinueOnError)
+    format := fs.String("format", "csv", "csv or ndjson")
+    file := fs.String("file", "", "write to this file instead of stdout")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() > 0 || (*format != "csv" && *format != "ndjson") {
+        return errUsage
+    }
+
+    w := stdout
+    if *file != "" {
+        f, err := os.Create(*file)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        w = f
+    }
+
+    if *format == "ndjson" {
+        enc := json.NewEncoder(w)
+        return service.ExportCourses(ctx, func(course model.Course) error {
+            return enc.Encode(course)
+        })
+    }
+    cw := csv.NewWriter(w)
+    cw.Write(service.ImportColumns)
+    err := service.ExportCourses(ctx, func(course model.Course) error {
+        return cw.Write(service.ExportRecord(course))
+    })
+    cw.Flush()
+    if err != nil {
+        return err
+    }
+    return cw.Error()
+}
+
+func runImport(ctx context.Context, out *output, args []string) error {
+    fs := flag.NewFlagSet("import", flag.ContinueOnError)
+    format := fs.String("format", "", "csv or ndjson (default from the file extension)")
+    dryRun := fs.Bool("dry-run", false, "validate without saving")
+    if err := parse(fs, args); err != nil {
+        return err
+    }
+    if fs.NArg() != 1 {
+        return errUsage
+    }
+    name := fs.Arg(0)
+    if *format == "" {
+        *format = "csv"
+        if strings.HasSuffix(name, ".ndjson") || strings.HasSuffix(name, ".jsonl") {
+            *format = "ndjson"
+        }
+    }
+
+    r := io.Reader(os.Stdin)
+    if name != "-" {
+        f, err := os.Open(name)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        r = f
+    }
+
+    var rows []service.ImportRow
+    var parseErrs []service.RowError
+    var err error
+    switch *format {
+    case "csv":
+        rows, parseErrs, err = service.ParseCSV(r)
+    case "ndjson":
+        rows, parseErrs, err = service.ParseNDJSON(r)
+    default:
+        return errUsage
+    }
+    if err != nil {
+        return err
+    }
+
+    report, err := service.ImportCourses(ctx, rows, parseErrs, *dryRun)
+    if err != nil && !errors.Is(err, service.ErrImportInvalid) {
+        return err
+    }
+    table := [][]string{{strconv.FormatBool(report.DryRun), strconv.Itoa(report.Total), strconv.Itoa(report.Created),
+        strconv.Itoa(report.Updated), strconv.Itoa(len(report.Errors))}}
+    if perr := out.print(report, []string{"DRY RUN", "TOTAL", "CREATED", "UPDATED", "ERRORS"}, table); perr != nil {
+        return perr
+    }
+    if out.format == "table" {
+        for _, e := range report.Errors {
+            fmt.Fprintf(stderr, "line %d: %s %s\n", e.Line, e.Field, e.Message)
+        }
+    }
+    return err
+}
+
+func runReindex(ctx context.Context, out *output, args []string) error {
+    if len(args) > 0 {
+        return errUsage
+    }
+    // the in-memory index lives in each server process; rebuilding this
+    // process's copy would report success and change nothing
+    if _, ok := config.Search.(*search.Memory); ok {
+        return errors.New("reindex: the search index is kept in memory by each server and rebuilt when it restarts")
+    }
+    start := time.Now()
+    if err := service.ReindexCourses(ctx); err != nil {
+        return err
+    }
+    took := time.Since(start).Round(time.Millisecond)
+    return out.print(map[string]string{"reindexed": "courses", "took": took.String()},
+        []string{"REINDEXED", "TOOK"}, [][]string{{"courses", took.String()}})
+}
+
+func formatTime(t *time.Time) string {
+    if t == nil {
+        return "-"
+    }
+    return t.UTC().Format(time.RFC3339)
+}
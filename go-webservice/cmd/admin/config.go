@@ -0,0 +1,103 @@
+package main
+
+import (
+    "context"
+    "net/url"
+    "os"
+    "regexp"
+)
+
+type setting struct {
+    Name   string `json:"name"`
+    Value  string `json:"value"`
+    Source string `json:"source"`
+}
+
+// settings lists every environment variable the server reads, with the
+// default it uses when the variable is unset.
+var settings = []struct {
+    name, def string
+    secret    bool
+}{
+    {name: "DB_DSN", secret: true},
+    {name: "DB_REPLICA_DSNS", secret: true},
+    {name: "DB_MAX_OPEN_CONNS", def: "25"},
+    {name: "DB_MAX_IDLE_CONNS", def: "10"},
+    {name: "DB_CONN_MAX_LIFETIME", def: "30m"},
+    {name: "DB_CONN_MAX_IDLE_TIME", def: "5m"},
+    {name: "GRPC_ADDR", def: ":9090"},
+    {name: "JWT_SECRET", secret: true},
+    {name: "TENANT_BASE_DOMAIN"},
+    {name: "BLOB_STORE", def: "local"},
+    {name: "BLOB_DIR", def: "data/blobs"},
+    {name: "S3_ENDPOINT"},
+    {name: "S3_BUCKET"},
+    {name: "S3_REGION"},
+    {name: "S3_ACCESS_KEY"},
+    {name: "S3_SECRET_KEY", secret: true},
+    {name: "MEDIA_URL_SECRET", def: "(random per process)", secret: true},
+    {name: "CACHE_SIZE", def: "1000"},
+    {name: "CACHE_TTL", def: "30s"},
+    {name: "CORS_ALLOWED_ORIGINS"},
+    {name: "CORS_ALLOWED_METHODS", def: "GET, POST, PUT, PATCH, DELETE"},
+    {name: "CORS_ALLOWED_HEADERS", def: "Authorization, Content-Type, X-API-Key, Last-Event-ID"},
+    {name: "CORS_EXPOSED_HEADERS", def: "Deprecation, Sunset, Link, Location"},
+    {name: "CORS_ALLOW_CREDENTIALS", def: "false"},
+    {name: "CORS_MAX_AGE", def: "10m"},
+    {name: "HSTS_MAX_AGE", def: "8760h"},
+    {name: "CONTENT_SECURITY_POLICY", def: "default-src 'none'; frame-ancestors 'none'"},
+    {name: "FRAME_OPTIONS", def: "DENY"},
+    {name: "TLS_CERT_FILE"},
+    {name: "TLS_KEY_FILE"},
+    {name: "TLS_CLIENT_CA_FILE"},
+    {name: "TLS_CLIENT_AUTH", def: "require"},
+    {name: "TLS_CLIENT_ROLES"},
+    {name: "JOB_WORKERS", def: "4"},
+    {name: "SMTP_HOST"},
+    {name: "SMTP_PORT", def: "587"},
+    {name: "SMTP_SECURITY", def: "starttls"},
+    {name: "SMTP_USERNAME"},
+    {name: "SMTP_PASSWORD", secret: true},
+    {name: "SMTP_FROM", def: "no-reply@<SMTP_HOST>"},
+    {name: "SITE_URL", def: "http://localhost:8080"},
+    {name: "PAYMENT_PROVIDER", def: "(none; paid checkouts disabled)"},
+    {name: "FLAGS_FILE"},
+    {name: "PAYMENT_WEBHOOK_SECRET", def: "(random per process)", secret: true},
+}
+
+var dsnPassword = regexp.MustCompile(`(password=)(\S+)`)
+
+// runConfig prints the effective configuration with secrets masked.
+func runConfig(_ context.Context, out *output, args []string) error {
+    if len(args) > 0 {
+        return errUsage
+    }
+    var result []setting
+    var rows [][]string
+    for _, s := range settings {
+        value, ok := os.LookupEnv(s.name)
+        source := "env"
+        switch {
+        case !ok:
+            value, source = s.def, "default"
+        case s.name == "DB_DSN":
+            value = redactDSN(value)
+        case s.secret && value != "":
+            value = "********"
+        }
+        result = append(result, setting{Name: s.name, Value: value, Source: source})
+        rows = append(rows, []string{s.name, value, source})
+    }
+    return out.print(result, []string{"NAME", "VALUE", "SOURCE"}, rows)
+}
+
+// redactDSN hides the password in either DSN form Postgres accepts.
+func redactDSN(dsn string) string {
+    if u, err := url.Parse(dsn); err == nil && u.User != nil {
+        if _, ok := u.User.Password(); ok {
+            u.User = url.UserPassword(u.User.Username(), "********")
+            return u.String()
+        }
+    }
+    return dsnPassword.ReplaceAllString(dsn, "${1}********")
+}
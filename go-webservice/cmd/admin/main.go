@@ -0,0 +1,130 @@
+// Command admin performs operator tasks against the same database and
+// service layer as the server.
+//
+//	admin [-output table|json] [-tenant id] <command> [flags] [args]
+//
+// It exits with status 1 when a command fails and 2 on a usage error.
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "go-webservice/config"
+    "go-webservice/service"
+    "go-webservice/tenant"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// errUsage makes main print the command's usage and exit with status 2.
+var errUsage = errors.New("usage")
+
+// Output goes to stdout and stderr, and needsDB commands call connect;
+// tests replace them.
+var (
+    stdout  io.Writer = os.Stdout
+    stderr  io.Writer = os.Stderr
+    connect           = func() { config.ConnectDatabase(os.Getenv("DB_DSN")) }
+)
+
+type command struct {
+    usage string
+    // needsDB commands run after connecting to DB_DSN, in the tenant
+    // selected by -tenant.
+    needsDB bool
+    run     func(ctx context.Context, out *output, args []string) error
+}
+
+var commands = map[string]command{
+    "config":        {usage: "config", run: runConfig},
+    "tenant create": {usage: "tenant create <id> <name>", needsDB: true, run: runTenantCreate},
+    "token issue":   {usage: "token issue -subject <name> [-role user] [-ttl 720h]", run: runTokenIssue},
+    "key create":    {usage: "key create -name <name> [-role user] [-scopes a,b] [-expires 720h]", needsDB: true, run: runKeyCreate},
+    "key list":      {usage: "key list", needsDB: true, run: runKeyList},
+    "key revoke":    {usage: "key revoke <id>", needsDB: true, run: runKeyRevoke},
+    "seed":          {usage: "seed [-count 10]", needsDB: true, run: runSeed},
+    "export":        {usage: "export [-format csv|ndjson] [-file path]", needsDB: true, run: runExport},
+    "import":        {usage: "import [-format csv|ndjson] [-dry-run] <file|->", needsDB: true, run: runImport},
+    "reindex":       {usage: "reindex", needsDB: true, run: runReindex},
+}
+
+func main() {
+    os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+    flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+    format := flags.String("output", "table", "output format: table or json")
+    tenantID := flags.String("tenant", tenant.Default, "tenant to operate on")
+    flags.Usage = usage
+    if err := flags.Parse(args); err != nil {
+        return 2
+    }
+    if *format != "table" && *format != "json" {
+        fmt.Fprintln(stderr, "admin: -output must be table or json")
+        return 2
+    }
+
+    name, cmd, rest, ok := lookup(flags.Args())
+    if !ok {
+        usage()
+        return 2
+    }
+
+    ctx := context.Background()
+    if cmd.needsDB {
+        connect()
+        ctx = tenant.WithTenant(ctx, *tenantID)
+        if name != "tenant create" {
+            if _, err := service.GetTenant(ctx, *tenantID); err != nil {
+                fmt.Fprintln(stderr, "admin:", err)
+                return 1
+            }
+        }
+    }
+
+    err := cmd.run(ctx, &output{format: *format, tenant: *tenantID}, rest)
+    switch {
+    case errors.Is(err, errUsage):
+        fmt.Fprintln(stderr, "usage: admin", cmd.usage)
+        return 2
+    case err != nil:
+        fmt.Fprintln(stderr, "admin:", err)
+        return 1
+    }
+    return 0
+}
+
+// lookup finds the command named by the first one or two arguments.
+func lookup(args []string) (string, command, []string, bool) {
+    if len(args) >= 2 {
+        if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+            return args[0] + " " + args[1], cmd, args[2:], true
+        }
+    }
+    if len(args) >= 1 {
+        if cmd, ok := commands[args[0]]; ok {
+            return args[0], cmd, args[1:], true
+        }
+    }
+    return "", command{}, nil, false
+}
+
+func usage() {
+    var lines []string
+    for _, cmd := range commands {
+        lines = append(lines, "  "+cmd.usage)
+    }
+    sort.Strings(lines)
+    fmt.Fprintf(stderr, `usage: admin [-output table|json] [-tenant id] <command>
+
+commands:
+%s
+
+DB_DSN selects the database, as for the server.
+`, strings.Join(lines, "\n"))
+}
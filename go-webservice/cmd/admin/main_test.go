@@ -0,0 +1,144 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "go-webservice/apitest"
+    "go-webservice/middleware"
+)
+
+// admin runs the command line against the database apitest.New set up
+// and returns the exit status and what was written to stdout and stderr.
+func admin(t *testing.T, args ...string) (int, string, string) {
+    t.Helper()
+    var out, errOut bytes.Buffer
+    saved := connect
+    stdout, stderr, connect = &out, &errOut, func() {}
+    t.Cleanup(func() {
+        stdout, stderr, connect = os.Stdout, os.Stderr, saved
+    })
+    code := run(args)
+    return code, out.String(), errOut.String()
+}
+
+func TestExitStatus(t *testing.T) {
+    apitest.New(t)
+    tests := []struct {
+        args   []string
+        code   int
+        stderr string
+    }{
+        {nil, 2, "usage: admin [-output table|json]"},
+        {[]string{"frobnicate"}, 2, "commands:"},
+        {[]string{"-output", "yaml", "config"}, 2, "-output must be table or json"},
+        {[]string{"key", "revoke"}, 2, "usage: admin key revoke <id>"},
+        {[]string{"key", "create", "-bogus"}, 2, "flag provided but not defined: -bogus"},
+        {[]string{"token", "issue"}, 2, "usage: admin token issue"},
+        {[]string{"user", "create", "-subject", "ada"}, 2, "commands:"},
+        {[]string{"key", "revoke", "999"}, 1, "admin: api key not found"},
+        {[]string{"-tenant", "missing", "key", "list"}, 1, "admin: "},
+        {[]string{"tenant", "create", "Not A Slug", "x"}, 1, "admin: "},
+        {[]string{"config"}, 0, ""},
+    }
+    for _, tt := range tests {
+        code, _, stderr := admin(t, tt.args...)
+        if code != tt.code || !strings.Contains(stderr, tt.stderr) || (tt.stderr == "" && stderr != "") {
+            t.Errorf("admin %v: status %d, stderr %q; want %d and %q", tt.args, code, stderr, tt.code, tt.stderr)
+        }
+    }
+}
+
+func TestReindexNeedsPostgres(t *testing.T) {
+    apitest.New(t)
+    code, stdout, stderr := admin(t, "reindex")
+    if code != 1 || stdout != "" || !strings.Contains(stderr, "kept in memory") {
+        t.Fatalf("reindex on the memory engine: status %d, stdout %q, stderr %q", code, stdout, stderr)
+    }
+}
+
+func TestTokenIssue(t *testing.T) {
+    apitest.New(t)
+    code, stdout, stderr := admin(t, "-output", "json", "-tenant", "acme", "token", "issue", "-subject", "ada", "-role", "admin")
+    if code != 0 {
+        t.Fatalf("status %d: %s", code, stderr)
+    }
+    var issued struct {
+        Subject, Role, Tenant, Token string
+    }
+    if err := json.Unmarshal([]byte(stdout), &issued); err != nil {
+        t.Fatalf("output %q: %v", stdout, err)
+    }
+    p, err := middleware.Authenticate(context.Background(), "Bearer "+issued.Token, "", nil)
+    if err != nil || p.Subject != "ada" || p.Role != "admin" || p.Tenant != "acme" {
+        t.Fatalf("token for %+v authenticates as %+v, %v", issued, p, err)
+    }
+}
+
+func TestKeyCreateAndList(t *testing.T) {
+    apitest.New(t)
+    code, stdout, stderr := admin(t, "key", "create", "-name", "ci", "-scopes", "courses:read")
+    if code != 0 {
+        t.Fatalf("key create: status %d: %s", code, stderr)
+    }
+    lines := strings.Split(strings.TrimSpace(stdout), "\n")
+    if len(lines) != 2 || strings.Fields(lines[0])[0] != "ID" || !strings.Contains(lines[1], "ci") ||
+        !strings.Contains(lines[1], "gwk_") {
+        t.Fatalf("key create printed %q", stdout)
+    }
+
+    code, stdout, _ = admin(t, "-output", "json", "key", "list")
+    var keys []struct {
+        Name   string `json:"name"`
+        Scopes string `json:"scopes"`
+    }
+    if err := json.Unmarshal([]byte(stdout), &keys); code != 0 || err != nil {
+        t.Fatalf("key list: status %d, %v: %q", code, err, stdout)
+    }
+    if len(keys) != 1 || keys[0].Name != "ci" || keys[0].Scopes != "courses:read" {
+        t.Fatalf("keys %+v", keys)
+    }
+}
+
+func TestImport(t *testing.T) {
+    apitest.New(t)
+    dir := t.TempDir()
+    valid := filepath.Join(dir, "valid.csv")
+    invalid := filepath.Join(dir, "invalid.csv")
+    os.WriteFile(valid, []byte("external_id,title\na,Alpha\nb,Beta\n"), 0o600)
+    os.WriteFile(invalid, []byte("external_id,title\nc,Gamma\nd,\n"), 0o600)
+
+    code, stdout, stderr := admin(t, "-output", "json", "import", valid)
+    var report struct {
+        Created int `json:"created"`
+    }
+    json.Unmarshal([]byte(stdout), &report)
+    if code != 0 || report.Created != 2 {
+        t.Fatalf("valid import: status %d, %q, %q", code, stdout, stderr)
+    }
+
+    // invalid rows fail the command but the report is still printed
+    code, stdout, stderr = admin(t, "import", invalid)
+    if code != 1 || !strings.Contains(stdout, "ERRORS") || !strings.Contains(stderr, "line 3: title is required") ||
+        !strings.Contains(stderr, "admin: import contains invalid rows") {
+        t.Fatalf("invalid import: status %d, stdout %q, stderr %q", code, stdout, stderr)
+    }
+}
+
+func TestSeedAndExport(t *testing.T) {
+    apitest.New(t)
+    if code, _, stderr := admin(t, "seed", "-count", "3"); code != 0 {
+        t.Fatalf("seed: status %d: %s", code, stderr)
+    }
+    code, stdout, stderr := admin(t, "export")
+    lines := strings.Split(strings.TrimSpace(stdout), "\n")
+    // the header, the default tenant's seeded course and three more
+    if code != 0 || len(lines) != 5 || lines[0] != "external_id,title,description,published" {
+        t.Fatalf("export: status %d, %q, %q", code, stdout, stderr)
+    }
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "text/tabwriter"
+)
+
+type output struct {
+    format string
+    tenant string
+}
+
+// print writes v as indented JSON, or header and rows as an aligned table.
+func (o *output) print(v interface{}, header []string, rows [][]string) error {
+    if o.format == "json" {
+        enc := json.NewEncoder(stdout)
+        enc.SetIndent("", "  ")
+        return enc.Encode(v)
+    }
+    w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, strings.Join(header, "\t"))
+    for _, row := range rows {
+        fmt.Fprintln(w, strings.Join(row, "\t"))
+    }
+    return w.Flush()
+}
@@ -0,0 +1,36 @@
+// Command compat replays the recorded v1 requests against a running server
+// and exits non-zero if any response no longer matches.
+package main
+
+import (
+    "flag"
+    "fmt"
+    "go-webservice/compat"
+    "log"
+    "net/http/httputil"
+    "net/url"
+    "os"
+)
+
+func main() {
+    base := flag.String("url", "http://localhost:8080", "server to replay against")
+    token := flag.String("authorization", "Bearer demo", "Authorization header for an admin")
+    flag.Parse()
+
+    target, err := url.Parse(*base)
+    if err != nil {
+        log.Fatal(err)
+    }
+    cases, err := compat.V1()
+    if err != nil {
+        log.Fatal(err)
+    }
+    failures := compat.Replay(httputil.NewSingleHostReverseProxy(target), *token, cases)
+    for _, f := range failures {
+        fmt.Println("FAIL", f)
+    }
+    if len(failures) > 0 {
+        os.Exit(1)
+    }
+    fmt.Printf("ok: %d v1 cases\n", len(cases))
+}
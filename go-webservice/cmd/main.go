@@ -1,16 +1,56 @@
 package main
 
 import (
-    "github.com/gin-gonic/gin"
+    "context"
     "go-webservice/config"
+    "go-webservice/grpcapi"
+    "go-webservice/jobs"
+    "go-webservice/middleware"
     "go-webservice/router"
+    "go-webservice/service"
+    "go-webservice/webhook"
+    "log"
+    "net/http"
     "os"
+    "time"
 )
 
 func main() {
     dsn := os.Getenv("DB_DSN")
     config.ConnectDatabase(dsn)
+    config.ConnectBlobStore()
+    config.LoadMediaURLSecret()
+    config.ConnectNotifier()
+    config.ConnectPayments()
+    config.LoadFlags()
 
-    r := router.SetupRouter()
-    r.Run(":8080")
-}
\ No newline at end of file
+    go webhook.NewDispatcher(config.DB).Run(context.Background())
+    go jobs.NewPool(config.DB).Run(context.Background())
+    go service.WatchFlags(context.Background(), 10*time.Second)
+    go config.Reads.Run(context.Background(), 5*time.Second)
+
+    grpcAddr := os.Getenv("GRPC_ADDR")
+    if grpcAddr == "" {
+        grpcAddr = ":9090"
+    }
+    tlsConfig := config.LoadTLS(context.Background())
+    certRoles, err := middleware.ParseCertRoles(os.Getenv("TLS_CLIENT_ROLES"))
+    if err != nil {
+        log.Fatal("Invalid TLS_CLIENT_ROLES:", err)
+    }
+    middleware.ClientCertRoles = certRoles
+    go func() {
+        log.Fatal(grpcapi.Serve(grpcAddr, tlsConfig))
+    }()
+
+    srv := &http.Server{
+        Addr:      ":8080",
+        Handler:   router.SetupRouter(),
+        TLSConfig: tlsConfig,
+    }
+    if tlsConfig != nil {
+        // the certificate comes from TLSConfig; HTTP/2 is negotiated via ALPN
+        log.Fatal(srv.ListenAndServeTLS("", ""))
+    }
+    log.Fatal(srv.ListenAndServe())
+}
@@ -0,0 +1,146 @@
+// Package compat replays recorded API requests and checks that the
+// responses still have the status, headers and fields clients rely on.
+// The v1 cases guard the frozen v1 API against accidental changes.
+package compat
+
+import (
+    "bytes"
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+)
+
+//go:embed v1.json
+var v1Cases []byte
+
+// Case is one recorded request and what its response must contain.
+// {{name}} in Path or Body is replaced by a value captured earlier.
+type Case struct {
+    Name   string          `json:"name"`
+    Method string          `json:"method"`
+    Path   string          `json:"path"`
+    Body   json.RawMessage `json:"body,omitempty"`
+    Status int             `json:"status"`
+    // Headers must be present; "*" accepts any value.
+    Headers map[string]string `json:"headers,omitempty"`
+    // Fields must be present in the object, or in every element of an
+    // array response.
+    Fields []string `json:"fields,omitempty"`
+    // Equals checks top-level fields for exact values.
+    Equals map[string]interface{} `json:"equals,omitempty"`
+    // Capture saves top-level response fields for later cases.
+    Capture map[string]string `json:"capture,omitempty"`
+}
+
+// Failure describes a case whose response did not match.
+type Failure struct {
+    Case   string
+    Reason string
+}
+
+func (f Failure) String() string {
+    return f.Case + ": " + f.Reason
+}
+
+// V1 returns the recorded v1 cases.
+func V1() ([]Case, error) {
+    var cases []Case
+    err := json.Unmarshal(v1Cases, &cases)
+    return cases, err
+}
+
+// Replay runs cases in order against h, sending the given Authorization
+// header, and returns every mismatch.
+func Replay(h http.Handler, authorization string, cases []Case) []Failure {
+    vars := make(map[string]string)
+    var failures []Failure
+    for _, tc := range cases {
+        if reason := replay(h, authorization, tc, vars); reason != "" {
+            failures = append(failures, Failure{Case: tc.Name, Reason: reason})
+        }
+    }
+    return failures
+}
+
+func replay(h http.Handler, authorization string, tc Case, vars map[string]string) string {
+    var body io.Reader
+    if len(tc.Body) > 0 {
+        body = strings.NewReader(expand(string(tc.Body), vars))
+    }
+    req := httptest.NewRequest(tc.Method, expand(tc.Path, vars), body)
+    req.Header.Set("Authorization", authorization)
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+
+    if w.Code != tc.Status {
+        return fmt.Sprintf("status %d, want %d: %s", w.Code, tc.Status, w.Body.String())
+    }
+    for name, want := range tc.Headers {
+        got := w.Header().Get(name)
+        if got == "" || (want != "*" && got != want) {
+            return fmt.Sprintf("header %s is %q, want %q", name, got, want)
+        }
+    }
+    if len(tc.Fields) == 0 && len(tc.Equals) == 0 && len(tc.Capture) == 0 {
+        return ""
+    }
+
+    var decoded interface{}
+    if err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&decoded); err != nil {
+        return "response is not JSON: " + err.Error()
+    }
+    objects := []map[string]interface{}{}
+    switch v := decoded.(type) {
+    case map[string]interface{}:
+        objects = append(objects, v)
+    case []interface{}:
+        for _, el := range v {
+            obj, ok := el.(map[string]interface{})
+            if !ok {
+                return "array element is not an object"
+            }
+            objects = append(objects, obj)
+        }
+    default:
+        return "response is neither an object nor an array"
+    }
+    for _, obj := range objects {
+        for _, field := range tc.Fields {
+            if _, ok := obj[field]; !ok {
+                return "missing field " + field
+            }
+        }
+    }
+
+    obj, _ := decoded.(map[string]interface{})
+    for field, want := range tc.Equals {
+        if s, ok := want.(string); ok {
+            want = expand(s, vars)
+        }
+        if got, ok := obj[field]; !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+            return fmt.Sprintf("field %s is %v, want %v", field, got, want)
+        }
+    }
+    for name, field := range tc.Capture {
+        v, ok := obj[field].(string)
+        if !ok {
+            return "cannot capture " + field
+        }
+        vars[name] = v
+    }
+    return ""
+}
+
+func expand(s string, vars map[string]string) string {
+    for name, v := range vars {
+        s = strings.ReplaceAll(s, "{{"+name+"}}", v)
+    }
+    return s
+}
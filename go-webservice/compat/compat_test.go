@@ -0,0 +1,19 @@
+package compat_test
+
+import (
+    "testing"
+
+    "go-webservice/apitest"
+    "go-webservice/compat"
+)
+
+func TestV1Replay(t *testing.T) {
+    s := apitest.New(t)
+    cases, err := compat.V1()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, f := range compat.Replay(s.Handler, "Bearer "+s.Admin.Token, cases) {
+        t.Error(f)
+    }
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+    "go-webservice/cache"
+    "log"
+    "os"
+    "strconv"
+    "time"
+)
+
+// Cache fronts course reads. UseDatabase starts it empty with an in-memory
+// LRU sized by CACHE_SIZE (default 1000 entries) and a CACHE_TTL lifetime
+// (default 30s); replace Cache.Cache to share it between instances.
+var Cache *cache.Group
+
+func newCache() *cache.Group {
+    size := 1000
+    if v := os.Getenv("CACHE_SIZE"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 {
+            log.Fatal("Invalid CACHE_SIZE:", v)
+        }
+        size = n
+    }
+    ttl := 30 * time.Second
+    if v := os.Getenv("CACHE_TTL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            log.Fatal("Invalid CACHE_TTL:", v)
+        }
+        ttl = d
+    }
+    return cache.NewGroup(cache.NewLRU(size), ttl)
+}
@@ -1,17 +1,194 @@
 package config
 
 import (
+    "context"
+    "go-webservice/model"
+    "go-webservice/replica"
+    "go-webservice/search"
+    "go-webservice/tenant"
     "gorm.io/driver/postgres"
     "gorm.io/gorm"
     "log"
+    "os"
+    "strconv"
+    "strings"
+    "time"
 )
 
+// DB is the primary connection; every write goes through it.
 var DB *gorm.DB
 
+// Reads routes read-only queries to the replicas, falling back to DB.
+// UseDatabase points it at DB alone; ConnectDatabase adds DB_REPLICA_DSNS.
+var Reads *replica.Router
+
+// Search indexes courses for DB.
+var Search search.Engine
+
 func ConnectDatabase(dsn string) {
+    pool := poolFromEnv()
     database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
     if err != nil {
         log.Fatal("Failed to connect to database:", err)
     }
-    DB = database
-}
\ No newline at end of file
+    if err := pool.apply(database); err != nil {
+        log.Fatal("Failed to configure database pool:", err)
+    }
+    if err := UseDatabase(database); err != nil {
+        log.Fatal("Failed to migrate database:", err)
+    }
+
+    var replicas []*gorm.DB
+    for _, rdsn := range strings.Split(os.Getenv("DB_REPLICA_DSNS"), ",") {
+        if rdsn = strings.TrimSpace(rdsn); rdsn == "" {
+            continue
+        }
+        // A replica that is down at startup joins the rotation once a
+        // health check reaches it, instead of stopping the service.
+        rdb, err := gorm.Open(postgres.Open(rdsn), &gorm.Config{DisableAutomaticPing: true})
+        if err != nil {
+            log.Fatal("Failed to open replica:", err)
+        }
+        if err := pool.apply(rdb); err != nil {
+            log.Fatal("Failed to configure replica pool:", err)
+        }
+        if err := rdb.Use(tenant.Plugin{}); err != nil {
+            log.Fatal("Failed to configure replica:", err)
+        }
+        replicas = append(replicas, rdb)
+    }
+    if Reads, err = replica.New(database, replicas...); err != nil {
+        log.Fatal("Failed to configure replicas:", err)
+    }
+    Reads.Check(context.Background())
+    if healthy, total := Reads.Healthy(); total > 0 {
+        log.Printf("Read replicas: %d of %d healthy", healthy, total)
+    }
+}
+
+// poolSettings size the connection pool of the primary and of each replica.
+type poolSettings struct {
+    maxOpen     int
+    maxIdle     int
+    maxLifetime time.Duration
+    maxIdleTime time.Duration
+}
+
+// poolFromEnv reads DB_MAX_OPEN_CONNS (default 25), DB_MAX_IDLE_CONNS
+// (default 10), DB_CONN_MAX_LIFETIME (default 30m) and DB_CONN_MAX_IDLE_TIME
+// (default 5m). A zero limit or duration means no limit.
+func poolFromEnv() poolSettings {
+    return poolSettings{
+        maxOpen:     envInt("DB_MAX_OPEN_CONNS", 25),
+        maxIdle:     envInt("DB_MAX_IDLE_CONNS", 10),
+        maxLifetime: envDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+        maxIdleTime: envDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+    }
+}
+
+func (p poolSettings) apply(db *gorm.DB) error {
+    sqlDB, err := db.DB()
+    if err != nil {
+        return err
+    }
+    sqlDB.SetMaxOpenConns(p.maxOpen)
+    sqlDB.SetMaxIdleConns(p.maxIdle)
+    sqlDB.SetConnMaxLifetime(p.maxLifetime)
+    sqlDB.SetConnMaxIdleTime(p.maxIdleTime)
+    return nil
+}
+
+func envInt(name string, def int) int {
+    v := os.Getenv(name)
+    if v == "" {
+        return def
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n < 0 {
+        log.Fatalf("Invalid %s: %s", name, v)
+    }
+    return n
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+    v := os.Getenv(name)
+    if v == "" {
+        return def
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil || d < 0 {
+        log.Fatalf("Invalid %s: %s", name, v)
+    }
+    return d
+}
+
+// UseDatabase installs the tenant scoping and replica session plugins,
+// migrates the schema and makes db the connection used by the service layer,
+// with a matching search engine and an empty cache. Reads go to db too.
+func UseDatabase(db *gorm.DB) error {
+    if err := db.Use(tenant.Plugin{}); err != nil {
+        return err
+    }
+    if err := db.Use(replica.Plugin{}); err != nil {
+        return err
+    }
+    if err := Migrate(db); err != nil {
+        return err
+    }
+    reads, err := replica.New(db)
+    if err != nil {
+        return err
+    }
+    DB = db
+    Reads = reads
+    Search = search.New(db)
+    Cache = newCache()
+    return nil
+}
+
+func Migrate(db *gorm.DB) error {
+    err := db.AutoMigrate(
+        &model.Tenant{},
+        &model.Course{},
+        &model.Module{},
+        &model.OutboxEvent{},
+        &model.Webhook{},
+        &model.WebhookDelivery{},
+        &model.APIKey{},
+        &model.Media{},
+        &model.MediaBlob{},
+        &model.Job{},
+        &model.Review{},
+        &model.ReviewFlag{},
+        &model.Coupon{},
+        &model.Order{},
+        &model.Enrollment{},
+        &model.FeatureFlag{},
+    )
+    if err != nil {
+        return err
+    }
+    if err := search.Migrate(db); err != nil {
+        return err
+    }
+    return seed(db)
+}
+
+func seed(db *gorm.DB) error {
+    err := db.Where(model.Tenant{ID: tenant.Default}).
+        Attrs(model.Tenant{Name: "Default"}).
+        FirstOrCreate(&model.Tenant{}).Error
+    if err != nil {
+        return err
+    }
+
+    db = db.WithContext(tenant.WithTenant(context.Background(), tenant.Default))
+    var count int64
+    if err := db.Model(&model.Course{}).Count(&count).Error; err != nil {
+        return err
+    }
+    if count > 0 {
+        return nil
+    }
+    return db.Create(&model.Course{ID: "1", Title: "Go Basics", Description: "Learn Go"}).Error
+}
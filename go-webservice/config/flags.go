@@ -0,0 +1,21 @@
+package config
+
+import (
+    "go-webservice/flags"
+    "log"
+    "os"
+)
+
+var Flags = flags.NewStore()
+
+// LoadFlags reads the flags file named by FLAGS_FILE, if any. Definitions
+// in the database are loaded by the service layer and take precedence.
+func LoadFlags() {
+    path := os.Getenv("FLAGS_FILE")
+    if path == "" {
+        return
+    }
+    if err := Flags.LoadFile(path); err != nil {
+        log.Fatal("Failed to load feature flags:", err)
+    }
+}
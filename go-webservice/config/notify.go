@@ -0,0 +1,56 @@
+package config
+
+import (
+    "go-webservice/notify"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+)
+
+var Notifier notify.Notifier = notify.Log{}
+
+// SiteURL is where links in emails point, without a trailing slash.
+var SiteURL = "http://localhost:8080"
+
+// ConnectNotifier sends mail through SMTP_HOST when it is set; otherwise
+// messages are only logged. SMTP_SECURITY is "starttls" (default), "tls"
+// or "none". SITE_URL sets SiteURL.
+func ConnectNotifier() {
+    if v := os.Getenv("SITE_URL"); v != "" {
+        SiteURL = strings.TrimRight(v, "/")
+    }
+    host := os.Getenv("SMTP_HOST")
+    if host == "" {
+        log.Println("SMTP_HOST not set; emails will be logged, not sent")
+        return
+    }
+    port := 587
+    if s := os.Getenv("SMTP_PORT"); s != "" {
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 1 || n > 65535 {
+            log.Fatalf("invalid SMTP_PORT %q", s)
+        }
+        port = n
+    }
+    security := os.Getenv("SMTP_SECURITY")
+    switch security {
+    case "":
+        security = notify.SMTPStartTLS
+    case notify.SMTPStartTLS, notify.SMTPTLS, notify.SMTPPlain:
+    default:
+        log.Fatalf("invalid SMTP_SECURITY %q", security)
+    }
+    from := os.Getenv("SMTP_FROM")
+    if from == "" {
+        from = "no-reply@" + host
+    }
+    Notifier = &notify.SMTP{
+        Host:     host,
+        Port:     port,
+        Username: os.Getenv("SMTP_USERNAME"),
+        Password: os.Getenv("SMTP_PASSWORD"),
+        From:     from,
+        Security: security,
+    }
+}
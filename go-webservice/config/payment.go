@@ -0,0 +1,35 @@
+package config
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "go-webservice/payment"
+    "log"
+    "os"
+)
+
+var Payments payment.Provider
+
+// ConnectPayments selects the payment provider from PAYMENT_PROVIDER.
+// Only "fake" is built in: it takes no money and lets payments be
+// completed through /payments/fake/:id, so it must be asked for. Without
+// a provider, only free orders can be placed.
+func ConnectPayments() {
+    switch p := os.Getenv("PAYMENT_PROVIDER"); p {
+    case "":
+        log.Println("PAYMENT_PROVIDER not set; paid checkouts are disabled")
+    case "fake":
+        secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+        if secret == "" {
+            buf := make([]byte, 32)
+            if _, err := rand.Read(buf); err != nil {
+                log.Fatal(err)
+            }
+            secret = hex.EncodeToString(buf)
+        }
+        log.Println("using the fake payment provider; no real payments are taken")
+        Payments = payment.NewFake(secret)
+    default:
+        log.Fatalf("unknown PAYMENT_PROVIDER %q", p)
+    }
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+    "crypto/rand"
+    "go-webservice/storage"
+    "log"
+    "os"
+)
+
+var Blobs storage.BlobStore
+
+// MediaURLSecret signs media download links.
+var MediaURLSecret []byte
+
+// ConnectBlobStore selects the blob store from BLOB_STORE ("local", the
+// default, or "s3").
+func ConnectBlobStore() {
+    switch os.Getenv("BLOB_STORE") {
+    case "s3":
+        Blobs = storage.NewS3Store(
+            os.Getenv("S3_ENDPOINT"),
+            os.Getenv("S3_BUCKET"),
+            os.Getenv("S3_REGION"),
+            os.Getenv("S3_ACCESS_KEY"),
+            os.Getenv("S3_SECRET_KEY"),
+        )
+    default:
+        dir := os.Getenv("BLOB_DIR")
+        if dir == "" {
+            dir = "data/blobs"
+        }
+        store, err := storage.NewLocalStore(dir)
+        if err != nil {
+            log.Fatal("Failed to open blob store:", err)
+        }
+        Blobs = store
+    }
+}
+
+// LoadMediaURLSecret reads MEDIA_URL_SECRET. Only demo mode (no
+// JWT_SECRET) may run without it, on a random key: links signed with one
+// stop working on restart and are rejected by every other replica.
+func LoadMediaURLSecret() {
+    if s := os.Getenv("MEDIA_URL_SECRET"); s != "" {
+        MediaURLSecret = []byte(s)
+        return
+    }
+    if os.Getenv("JWT_SECRET") != "" {
+        log.Fatal("MEDIA_URL_SECRET must be set when JWT_SECRET is")
+    }
+    MediaURLSecret = make([]byte, 32)
+    if _, err := rand.Read(MediaURLSecret); err != nil {
+        log.Fatal(err)
+    }
+}
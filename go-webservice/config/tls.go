@@ -0,0 +1,38 @@
+package config
+
+import (
+    "context"
+    "crypto/tls"
+    "go-webservice/tlsutil"
+    "log"
+    "os"
+    "time"
+)
+
+// LoadTLS returns the server TLS configuration, or nil when TLS_CERT_FILE
+// and TLS_KEY_FILE are unset and the servers should speak plain text.
+// TLS_CLIENT_CA_FILE turns on mutual TLS: clients must present a
+// certificate signed by that CA, or with TLS_CLIENT_AUTH=optional may
+// instead authenticate with a token or API key. The files are re-read
+// when they change until ctx is done.
+func LoadTLS(ctx context.Context) *tls.Config {
+    certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+    if certFile == "" && keyFile == "" {
+        return nil
+    }
+    reloader, err := tlsutil.NewReloader(certFile, keyFile, os.Getenv("TLS_CLIENT_CA_FILE"))
+    if err != nil {
+        log.Fatal("Failed to load TLS certificate:", err)
+    }
+    go reloader.Watch(ctx, 30*time.Second)
+
+    clientAuth := tls.RequireAndVerifyClientCert
+    switch v := os.Getenv("TLS_CLIENT_AUTH"); v {
+    case "", "require":
+    case "optional":
+        clientAuth = tls.VerifyClientCertIfGiven
+    default:
+        log.Fatal("Invalid TLS_CLIENT_AUTH:", v)
+    }
+    return reloader.ServerConfig(clientAuth)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+    "errors"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/middleware"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+type apiKeyRequest struct {
+    Name      string     `json:"name" binding:"required"`
+    Role      string     `json:"role" binding:"omitempty,oneof=admin user"`
+    Scopes    []string   `json:"scopes" binding:"required,min=1"`
+    ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// grant is what the calling user or key may hand on to a new key.
+func grant(c *gin.Context) service.Grant {
+    p, _ := c.Get("principal")
+    principal, _ := p.(middleware.Principal)
+    return service.Grant{Role: principal.Role, Scopes: principal.Scopes}
+}
+
+func CreateAPIKey(c *gin.Context) {
+    var req apiKeyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    key, raw, err := service.CreateAPIKey(c.Request.Context(), grant(c), req.Name, req.Role, req.Scopes, req.ExpiresAt)
+    if errors.Is(err, service.ErrInvalidScope) {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    if errors.Is(err, service.ErrGrantExceeded) {
+        util.HandleError(c, http.StatusForbidden, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    // the plaintext key is only ever returned here
+    c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": raw})
+}
+
+func GetAPIKeys(c *gin.Context) {
+    keys, err := service.GetAllAPIKeys(c.Request.Context())
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, keys)
+}
+
+// RotateAPIKey issues a replacement key. The old key stays valid for
+// ?grace= (a Go duration, default 24h).
+func RotateAPIKey(c *gin.Context) {
+    grace := 24 * time.Hour
+    if g := c.Query("grace"); g != "" {
+        d, err := time.ParseDuration(g)
+        if err != nil || d < 0 {
+            util.HandleError(c, http.StatusBadRequest, "invalid grace duration")
+            return
+        }
+        grace = d
+    }
+    key, raw, err := service.RotateAPIKey(c.Request.Context(), grant(c), c.Param("id"), grace)
+    if errors.Is(err, service.ErrAPIKeyNotFound) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    if errors.Is(err, service.ErrGrantExceeded) {
+        util.HandleError(c, http.StatusForbidden, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": raw})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+    err := service.RevokeAPIKey(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, service.ErrAPIKeyNotFound) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
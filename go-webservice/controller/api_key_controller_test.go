@@ -0,0 +1,71 @@
+package controller_test
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+
+    "go-webservice/apitest"
+    "go-webservice/config"
+    "go-webservice/model"
+    "go-webservice/replica"
+    "go-webservice/service"
+    "go-webservice/tenant"
+)
+
+type createdKey struct {
+    APIKey struct {
+        ID uint `json:"id"`
+    } `json:"api_key"`
+    Key string `json:"key"`
+}
+
+func TestAPIKeysCannotExceedTheirIssuer(t *testing.T) {
+    s := apitest.New(t)
+    var manager, writer createdKey
+    s.POST("/api/v1/keys", `{"name": "manager", "role": "admin", "scopes": ["keys:manage", "courses:read"]}`).
+        Status(http.StatusCreated).
+        JSON(&manager)
+    s.POST("/api/v1/keys", `{"name": "writer", "scopes": ["courses:write"]}`).
+        Status(http.StatusCreated).
+        JSON(&writer)
+    asManager := []apitest.Option{apitest.Anonymous(), apitest.Header("X-API-Key", manager.Key)}
+
+    s.POST("/api/v1/keys", `{"name": "reader", "scopes": ["courses:read"]}`, asManager...).
+        Status(http.StatusCreated)
+    s.POST("/api/v1/keys", `{"name": "escalated", "scopes": ["courses:read", "courses:write"]}`, asManager...).
+        Status(http.StatusForbidden)
+    s.POST("/api/v1/keys/"+fmt.Sprint(writer.APIKey.ID)+"/rotate", nil, asManager...).
+        Status(http.StatusForbidden)
+
+    // holding every scope is not enough to mint an admin key
+    _, _, err := service.CreateAPIKey(apitest.Context(tenant.Default), service.Grant{Role: "user"},
+        "admin", "admin", []string{model.ScopeCoursesRead}, nil)
+    if !errors.Is(err, service.ErrGrantExceeded) {
+        t.Fatalf("user grant creating an admin key: %v", err)
+    }
+}
+
+func TestAPIKeyUseStaysOffThePrimary(t *testing.T) {
+    s := apitest.New(t)
+    var created createdKey
+    s.POST("/api/v1/keys", `{"name": "reader", "scopes": ["courses:read"]}`).
+        Status(http.StatusCreated).
+        JSON(&created)
+
+    ctx := replica.WithSession(context.Background())
+    if _, err := service.AuthenticateAPIKey(ctx, created.Key); err != nil {
+        t.Fatal(err)
+    }
+    if replica.Wrote(ctx) {
+        t.Fatal("recording the key's last use pinned the session to the primary")
+    }
+    var key model.APIKey
+    config.DB.WithContext(tenant.System(context.Background())).First(&key, "id = ?", created.APIKey.ID)
+    if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > time.Minute {
+        t.Fatalf("last used at %v", key.LastUsedAt)
+    }
+}
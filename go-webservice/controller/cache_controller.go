@@ -0,0 +1,21 @@
+package controller
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+)
+
+// cacheCourses lets the client reuse a course read for as long as the
+// server would serve it from cache. Responses depend on the caller's
+// credentials, so shared caches must not store them.
+func cacheCourses(c *gin.Context) {
+    c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(service.CacheTTL().Seconds())))
+    c.Writer.Header().Add("Vary", "Authorization, X-API-Key")
+}
+
+func GetCacheStats(c *gin.Context) {
+    c.JSON(http.StatusOK, service.CacheStats())
+}
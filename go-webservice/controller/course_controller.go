@@ -1,22 +1,118 @@
 package controller
 
 import (
+    "errors"
     "net/http"
+
     "github.com/gin-gonic/gin"
+    "go-webservice/model"
+    "go-webservice/negotiate"
     "go-webservice/service"
     "go-webservice/util"
 )
 
+type courseRequest struct {
+    Title       string `json:"title" binding:"required"`
+    Description string `json:"description"`
+}
+
+// GetCourses lists every course, in creation order unless ?sort= says
+// otherwise (see courseSort), as JSON unless the Accept header asks for
+// another encoding.
 func GetCourses(c *gin.Context) {
-    c.JSON(http.StatusOK, service.GetAllCourses())
+    enc := v1ResponseEncoder(c)
+    sort, ok := courseSort(c)
+    if !ok {
+        return
+    }
+    courses, err := service.GetAllCourses(c.Request.Context())
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    service.SortCourses(courses, sort)
+    cacheCourses(c)
+    renderList(c, enc, negotiate.List{Name: "courses", Item: "course", Len: len(courses)}, func(i int) interface{} {
+        return courses[i]
+    })
 }
 
 func GetCourse(c *gin.Context) {
+    enc := v1ResponseEncoder(c)
     id := c.Param("id")
-    course, err := service.GetCourseByID(id)
+    course, err := service.GetCourseByID(c.Request.Context(), id)
     if err != nil {
-        util.HandleError(c, http.StatusNotFound, err.Error())
+        handleCourseError(c, err)
+        return
+    }
+    cacheCourses(c)
+    render(c, enc, http.StatusOK, "course", course)
+}
+
+func CreateCourse(c *gin.Context) {
+    var req courseRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    course, err := service.CreateCourse(c.Request.Context(), model.Course{
+        Title:       req.Title,
+        Description: req.Description,
+        AuthorID:    c.GetString("user_id"),
+    })
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusCreated, course)
+}
+
+func UpdateCourse(c *gin.Context) {
+    var req courseRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    course, err := service.UpdateCourse(c.Request.Context(), c.Param("id"), model.Course{Title: req.Title, Description: req.Description})
+    if err != nil {
+        handleCourseError(c, err)
         return
     }
     c.JSON(http.StatusOK, course)
-}
\ No newline at end of file
+}
+
+func PublishCourse(c *gin.Context) {
+    course, err := service.PublishCourse(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, course)
+}
+
+func DeleteCourse(c *gin.Context) {
+    if err := service.DeleteCourse(c.Request.Context(), c.Param("id")); err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// courseSort parses ?sort=created_at|title|rating, with a leading - for
+// descending order, writing a 400 response when it is invalid.
+func courseSort(c *gin.Context) (service.CourseSort, bool) {
+    sort, err := service.ParseCourseSort(c.Query("sort"))
+    if err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return sort, false
+    }
+    return sort, true
+}
+
+func handleCourseError(c *gin.Context, err error) {
+    if errors.Is(err, service.ErrCourseNotFound) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    util.HandleError(c, http.StatusInternalServerError, err.Error())
+}
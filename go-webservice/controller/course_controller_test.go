@@ -0,0 +1,102 @@
+package controller_test
+
+import (
+    "net/http"
+    "testing"
+
+    "go-webservice/apitest"
+)
+
+func TestGetCourses(t *testing.T) {
+    s := apitest.New(t)
+    s.Course(apitest.Title("Advanced Go"))
+
+    s.GET("/api/v1/courses").
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "application/json; charset=utf-8").
+        Golden("v1_courses")
+}
+
+func TestCreateCourse(t *testing.T) {
+    s := apitest.New(t)
+    user := s.User(apitest.Role("admin"))
+
+    var created struct {
+        ID    string `json:"id"`
+        Title string `json:"title"`
+    }
+    s.POST("/api/v1/courses", `{"title": "Testing", "description": "With Go"}`, apitest.As(user)).
+        Status(http.StatusCreated).
+        JSON(&created)
+    if created.Title != "Testing" {
+        t.Fatalf("title %q, want Testing", created.Title)
+    }
+    s.GET("/api/v1/courses/" + created.ID).
+        Status(http.StatusOK).
+        Golden("v1_created_course")
+
+    s.POST("/api/v1/courses", `{"description": "no title"}`).
+        Status(http.StatusBadRequest)
+}
+
+func TestCourseNotFound(t *testing.T) {
+    s := apitest.New(t)
+    s.GET("/api/v1/courses/missing").
+        Status(http.StatusNotFound).
+        Error("course not found")
+}
+
+func TestUpdateAndDeleteCourse(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course()
+
+    s.PUT("/api/v1/courses/"+course.ID, `{"title": "Renamed", "description": "New"}`).
+        Status(http.StatusOK).
+        Golden("v1_updated_course")
+    s.DELETE("/api/v1/courses/" + course.ID).
+        Status(http.StatusNoContent)
+    s.GET("/api/v1/courses/" + course.ID).
+        Status(http.StatusNotFound)
+}
+
+func TestCourseWritesNeedAdmin(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course()
+    user := s.User()
+
+    s.PUT("/api/v1/courses/"+course.ID, `{"title": "Mine now"}`, apitest.As(user)).
+        Status(http.StatusForbidden)
+    s.GET("/api/v1/courses", apitest.Anonymous()).
+        Status(http.StatusUnauthorized)
+}
+
+func TestCourseReadsAreCached(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Title("Cached"))
+    path := "/api/v1/courses/" + course.ID
+
+    s.GET(path).
+        Status(http.StatusOK).
+        HasHeader("Cache-Control", "private, max-age=30")
+    s.GET(path).Status(http.StatusOK)
+
+    var stats struct{ Hits, Misses uint64 }
+    s.GET("/api/v1/cache/stats").Status(http.StatusOK).JSON(&stats)
+    if stats.Hits != 1 || stats.Misses != 1 {
+        t.Fatalf("cache stats %+v, want one miss then one hit", stats)
+    }
+
+    s.PUT(path, `{"title": "Fresh"}`).Status(http.StatusOK)
+    var got struct{ Title string }
+    s.GET(path).Status(http.StatusOK).JSON(&got)
+    if got.Title != "Fresh" {
+        t.Fatalf("read after update returned %q, the cached title", got.Title)
+    }
+}
+
+func TestWritesAreNotCacheable(t *testing.T) {
+    s := apitest.New(t)
+    s.POST("/api/v1/courses", `{"title": "New"}`).
+        Status(http.StatusCreated).
+        HasHeader("Cache-Control", "no-store")
+}
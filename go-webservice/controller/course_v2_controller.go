@@ -0,0 +1,314 @@
+package controller
+
+import (
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/model"
+    "go-webservice/negotiate"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+// The v2 course representation replaces the published flag with a status,
+// always includes external_id, the price and the rating, links related
+// resources and pages lists. Reads take fields and include (see
+// courseV2Fields) to trim the payload and embed related resources.
+// It is built from the same service calls as v1.
+
+const (
+    v2Prefix         = "/api/v2"
+    defaultPageLimit = 20
+    maxPageLimit     = 100
+)
+
+type courseV2 struct {
+    ID          string       `json:"id"`
+    ExternalID  *string      `json:"external_id"`
+    Title       string       `json:"title"`
+    Description string       `json:"description"`
+    Status      string       `json:"status"`
+    Price       *coursePrice `json:"price"`
+    Rating      courseRating `json:"rating"`
+    CreatedAt   time.Time    `json:"created_at"`
+    UpdatedAt   time.Time    `json:"updated_at"`
+    Links       courseLinks  `json:"links"`
+}
+
+// coursePrice is null for free courses.
+type coursePrice struct {
+    Amount   int64  `json:"amount"`
+    Currency string `json:"currency"`
+}
+
+type courseRating struct {
+    Average float64 `json:"average"`
+    Count   int     `json:"count"`
+}
+
+// courseAuthor is embedded with include=author; null when the course has
+// no recorded author. Authors are token subjects, not rows this service
+// stores, so the ID is all there is to return.
+type courseAuthor struct {
+    ID string `json:"id"`
+}
+
+type courseLinks struct {
+    Self    string `json:"self"`
+    Modules string `json:"modules"`
+    Media   string `json:"media"`
+    Reviews string `json:"reviews"`
+}
+
+type pageMeta struct {
+    Total  int64 `json:"total"`
+    Limit  int   `json:"limit"`
+    Offset int   `json:"offset"`
+}
+
+// courseV2Fields is the allow-list for fields and include on v2 course
+// reads, with the columns behind each.
+var courseV2Fields = resourceFields{
+    fields: map[string][]string{
+        "id":          {"id"},
+        "external_id": {"external_id"},
+        "title":       {"title"},
+        "description": {"description"},
+        "status":      {"published"},
+        "price":       {"price", "currency"},
+        "rating":      {"rating_average", "rating_count"},
+        "created_at":  {"created_at"},
+        "updated_at":  {"updated_at"},
+        "links":       {"id"},
+    },
+    includes: map[string][]string{
+        "modules": {"id"},
+        "author":  {"author_id"},
+    },
+    always: []string{"id"},
+}
+
+type courseRequestV2 struct {
+    Title       string `json:"title" binding:"required,max=200"`
+    Description string `json:"description" binding:"max=5000"`
+}
+
+func toCourseV2(course model.Course) courseV2 {
+    status := "draft"
+    if course.Published {
+        status = "published"
+    }
+    self := v2Prefix + "/courses/" + course.ID
+    var price *coursePrice
+    if course.Price > 0 {
+        price = &coursePrice{Amount: course.Price, Currency: course.Currency}
+    }
+    return courseV2{
+        ID:          course.ID,
+        ExternalID:  course.ExternalID,
+        Title:       course.Title,
+        Description: course.Description,
+        Status:      status,
+        Price:       price,
+        Rating: courseRating{
+            Average: math.Round(course.RatingAverage*100) / 100,
+            Count:   course.RatingCount,
+        },
+        CreatedAt: course.CreatedAt,
+        UpdatedAt: course.UpdatedAt,
+        Links:     courseLinks{Self: self, Modules: self + "/modules", Media: self + "/media", Reviews: self + "/reviews"},
+    }
+}
+
+// fields returns the DTO as a field map, for projection.
+func (dto courseV2) fields() map[string]interface{} {
+    return map[string]interface{}{
+        "id":          dto.ID,
+        "external_id": dto.ExternalID,
+        "title":       dto.Title,
+        "description": dto.Description,
+        "status":      dto.Status,
+        "price":       dto.Price,
+        "rating":      dto.Rating,
+        "created_at":  dto.CreatedAt,
+        "updated_at":  dto.UpdatedAt,
+        "links":       dto.Links,
+    }
+}
+
+// renderCourseV2 returns the plain DTO unless p narrows the fields or
+// embeds relations, in which case it returns just the requested fields
+// and the embedded modules and author.
+func renderCourseV2(course model.Course, p projection, modules map[string][]model.Module) interface{} {
+    dto := toCourseV2(course)
+    if p.fields == nil && len(p.includes) == 0 {
+        return dto
+    }
+    out := gin.H{}
+    for field, value := range dto.fields() {
+        if p.has(field) {
+            out[field] = value
+        }
+    }
+    if p.embeds("modules") {
+        list := modules[course.ID]
+        if list == nil {
+            list = []model.Module{}
+        }
+        out["modules"] = list
+    }
+    if p.embeds("author") {
+        var author *courseAuthor
+        if course.AuthorID != "" {
+            author = &courseAuthor{ID: course.AuthorID}
+        }
+        out["author"] = author
+    }
+    return out
+}
+
+// includedModules loads the modules of courses in one query when p embeds
+// them, grouped by course.
+func includedModules(c *gin.Context, p projection, courses []model.Course) (map[string][]model.Module, bool) {
+    if !p.embeds("modules") || len(courses) == 0 {
+        return nil, true
+    }
+    ids := make([]string, len(courses))
+    for i, course := range courses {
+        ids[i] = course.ID
+    }
+    modules, err := service.GetModulesByCourseIDs(c.Request.Context(), ids)
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return nil, false
+    }
+    byCourse := make(map[string][]model.Module, len(courses))
+    for _, module := range modules {
+        byCourse[module.CourseID] = append(byCourse[module.CourseID], module)
+    }
+    return byCourse, true
+}
+
+func GetCoursesV2(c *gin.Context) {
+    enc, ok := responseEncoder(c)
+    if !ok {
+        return
+    }
+    limit, ok := queryInt(c, "limit", defaultPageLimit, 1, maxPageLimit)
+    if !ok {
+        return
+    }
+    offset, ok := queryInt(c, "offset", 0, 0, -1)
+    if !ok {
+        return
+    }
+    sort, ok := courseSort(c)
+    if !ok {
+        return
+    }
+    p, ok := courseV2Fields.parseProjection(c)
+    if !ok {
+        return
+    }
+    courses, total, err := service.GetCoursesPage(c.Request.Context(), limit, offset, sort, p.columns...)
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    modules, ok := includedModules(c, p, courses)
+    if !ok {
+        return
+    }
+    list := negotiate.List{
+        Name: "courses",
+        Item: "course",
+        Len:  len(courses),
+        Meta: pageMeta{Total: total, Limit: limit, Offset: offset},
+    }
+    renderList(c, enc, list, func(i int) interface{} {
+        return renderCourseV2(courses[i], p, modules)
+    })
+}
+
+func GetCourseV2(c *gin.Context) {
+    enc, ok := responseEncoder(c)
+    if !ok {
+        return
+    }
+    p, ok := courseV2Fields.parseProjection(c)
+    if !ok {
+        return
+    }
+    course, err := service.GetCourseColumns(c.Request.Context(), c.Param("id"), p.columns)
+    if err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    modules, ok := includedModules(c, p, []model.Course{course})
+    if !ok {
+        return
+    }
+    cacheCourses(c)
+    render(c, enc, http.StatusOK, "course", renderCourseV2(course, p, modules))
+}
+
+func CreateCourseV2(c *gin.Context) {
+    var req courseRequestV2
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    course, err := service.CreateCourse(c.Request.Context(), model.Course{
+        Title:       req.Title,
+        Description: req.Description,
+        AuthorID:    c.GetString("user_id"),
+    })
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    dto := toCourseV2(course)
+    c.Header("Location", dto.Links.Self)
+    c.JSON(http.StatusCreated, dto)
+}
+
+func UpdateCourseV2(c *gin.Context) {
+    var req courseRequestV2
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    course, err := service.UpdateCourse(c.Request.Context(), c.Param("id"), model.Course{Title: req.Title, Description: req.Description})
+    if err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, toCourseV2(course))
+}
+
+func PublishCourseV2(c *gin.Context) {
+    course, err := service.PublishCourse(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, toCourseV2(course))
+}
+
+// queryInt parses an optional integer query parameter within [min, max]
+// (max < 0 means unbounded), writing a 400 response when it is invalid.
+func queryInt(c *gin.Context, name string, def, min, max int) (int, bool) {
+    raw := c.Query(name)
+    if raw == "" {
+        return def, true
+    }
+    n, err := strconv.Atoi(raw)
+    if err != nil || n < min || (max >= 0 && n > max) {
+        util.HandleError(c, http.StatusBadRequest, "invalid "+name)
+        return 0, false
+    }
+    return n, true
+}
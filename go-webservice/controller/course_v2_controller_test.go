@@ -0,0 +1,117 @@
+package controller_test
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+
+    "go-webservice/apitest"
+    "gorm.io/gorm"
+)
+
+func TestGetCoursesV2(t *testing.T) {
+    s := apitest.New(t)
+    s.Course(apitest.Title("Free"), apitest.Published())
+    s.Course(apitest.Title("Paid"), apitest.Price(1999, "USD"))
+
+    s.GET("/api/v2/courses").
+        Status(http.StatusOK).
+        Golden("v2_courses")
+}
+
+func TestSparseFields(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Title("Go"), apitest.Price(500, "EUR"))
+    s.Module(course)
+    s.Module(course)
+
+    s.GET("/api/v2/courses?fields=id,title,price&include=modules").
+        Status(http.StatusOK).
+        Golden("v2_courses_fields")
+    s.GET("/api/v2/courses/" + course.ID + "?fields=title,links&include=author").
+        Status(http.StatusOK).
+        Golden("v2_course_fields")
+
+    // without fields, includes are added to the full representation
+    var full map[string]interface{}
+    s.GET("/api/v2/courses/" + course.ID + "?include=modules").Status(http.StatusOK).JSON(&full)
+    if len(full) != 11 || full["modules"] == nil {
+        t.Fatalf("full course with modules has keys %v", full)
+    }
+}
+
+func TestIncludeAuthor(t *testing.T) {
+    s := apitest.New(t)
+    var created struct {
+        ID string `json:"id"`
+    }
+    s.POST("/api/v2/courses", `{"title": "Authored", "description": "d"}`).
+        Status(http.StatusCreated).
+        JSON(&created)
+
+    var course struct {
+        Author *struct {
+            ID string `json:"id"`
+        } `json:"author"`
+    }
+    s.GET("/api/v2/courses/" + created.ID + "?fields=id&include=author").Status(http.StatusOK).JSON(&course)
+    if course.Author == nil || course.Author.ID != s.Admin.ID {
+        t.Fatalf("author %+v, want %s", course.Author, s.Admin.ID)
+    }
+
+    seeded := s.Course()
+    body := s.GET("/api/v2/courses/" + seeded.ID + "?fields=id&include=author").Status(http.StatusOK).Object()
+    if author, ok := body["author"]; !ok || author != nil {
+        t.Fatalf("author of a seeded course is %v, want null", author)
+    }
+}
+
+func TestSparseFieldsErrors(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course()
+
+    s.GET("/api/v2/courses?fields=id,secret").
+        Status(http.StatusBadRequest).
+        Error("unknown field secret; allowed: created_at, description, external_id, id, links, price, rating, status, title, updated_at")
+    s.GET("/api/v2/courses/" + course.ID + "?fields=title,author").
+        Status(http.StatusBadRequest).
+        Error("unknown field author; allowed: created_at, description, external_id, id, links, price, rating, status, title, updated_at")
+    s.GET("/api/v2/courses/" + course.ID + "?include=reviews").
+        Status(http.StatusBadRequest).
+        Error("unknown include reviews; allowed: author, modules")
+    s.GET("/api/v2/courses?fields=,").
+        Status(http.StatusBadRequest)
+
+    // v1 ignores both parameters
+    s.GET("/api/v1/courses/" + course.ID + "?fields=secret").
+        Status(http.StatusOK)
+}
+
+// TestSparseFieldsSelect checks the projection reaches the database: only
+// the columns behind the requested fields and includes are selected.
+func TestSparseFieldsSelect(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Price(500, "EUR"))
+
+    var selects []string
+    err := s.DB.Callback().Query().After("gorm:query").Register("test:selects", func(db *gorm.DB) {
+        if db.Statement.Table == "courses" {
+            selects = append(selects, db.Statement.SQL.String())
+        }
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    selected := func(path, want string) {
+        t.Helper()
+        selects = nil
+        s.GET(path).Status(http.StatusOK)
+        if len(selects) == 0 || !strings.HasPrefix(selects[len(selects)-1], want) {
+            t.Errorf("GET %s ran %q, want a query starting %q", path, selects, want)
+        }
+    }
+
+    selected("/api/v2/courses?fields=title,price", "SELECT `currency`,`id`,`price`,`title` FROM `courses`")
+    selected("/api/v2/courses/"+course.ID+"?fields=status&include=author", "SELECT `author_id`,`id`,`published` FROM `courses`")
+    selected("/api/v2/courses?include=modules", "SELECT * FROM `courses`")
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/notify"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+type emailRequest struct {
+    To       []string               `json:"to"`
+    Template string                 `json:"template" binding:"required"`
+    Locale   string                 `json:"locale"`
+    Data     map[string]interface{} `json:"data"`
+}
+
+func GetEmailTemplates(c *gin.Context) {
+    c.JSON(http.StatusOK, notify.Templates())
+}
+
+// PreviewEmail renders a template with the posted data, or sample data if
+// none is given. ?format=html or ?format=text returns just that part so it
+// can be viewed directly.
+func PreviewEmail(c *gin.Context) {
+    var req emailRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    msg, err := service.PreviewEmail(req.Template, req.Locale, req.Data)
+    if err != nil {
+        handleEmailError(c, err)
+        return
+    }
+    switch c.Query("format") {
+    case "html":
+        c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+    case "text":
+        c.String(http.StatusOK, msg.Text)
+    default:
+        c.JSON(http.StatusOK, msg)
+    }
+}
+
+// SendEmail queues a templated email, e.g. to check the SMTP settings.
+// It returns 202 with the delivery job.
+func SendEmail(c *gin.Context) {
+    var req emailRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    job, err := service.SendEmail(c.Request.Context(), nil, req.To, req.Template, req.Locale, req.Data)
+    if err != nil {
+        handleEmailError(c, err)
+        return
+    }
+    c.JSON(http.StatusAccepted, job)
+}
+
+func handleEmailError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, notify.ErrUnknownTemplate):
+        util.HandleError(c, http.StatusNotFound, err.Error())
+    case errors.Is(err, notify.ErrInvalidAddress):
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+    default:
+        // missing or mistyped template data
+        util.HandleError(c, http.StatusUnprocessableEntity, err.Error())
+    }
+}
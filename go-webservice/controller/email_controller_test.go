@@ -0,0 +1,63 @@
+package controller_test
+
+import (
+    "net/http"
+    "strconv"
+    "testing"
+
+    "go-webservice/apitest"
+)
+
+func TestPreviewEmail(t *testing.T) {
+    s := apitest.New(t)
+    s.POST("/api/emails/preview", `{"template": "enrollment_confirmation", "locale": "es"}`).
+        Status(http.StatusOK).
+        Golden("email_preview")
+    s.POST("/api/emails/preview", `{"template": "nope"}`).
+        Status(http.StatusNotFound)
+}
+
+func TestSendEmail(t *testing.T) {
+    s := apitest.New(t)
+    var job struct {
+        ID     uint
+        Status string
+    }
+    s.POST("/api/emails/send", map[string]interface{}{
+        "to":       []string{"ada@example.com"},
+        "template": "enrollment_confirmation",
+        "data":     map[string]string{"Name": "Ada", "CourseTitle": "Go Basics", "CourseURL": "https://x.example/c"},
+    }).Status(http.StatusAccepted).JSON(&job)
+    if job.Status != "queued" {
+        t.Fatalf("job status %q", job.Status)
+    }
+    if len(s.Mailbox.Messages()) != 0 {
+        t.Fatal("email sent before the job ran")
+    }
+
+    if n := s.RunJobs(); n != 1 {
+        t.Fatalf("ran %d jobs", n)
+    }
+    sent := s.Mailbox.Messages()
+    if len(sent) != 1 || sent[0].To[0] != "ada@example.com" || sent[0].Subject != "You're enrolled in Go Basics" {
+        t.Fatalf("sent %+v", sent)
+    }
+    var done struct {
+        Status   string
+        Attempts int
+    }
+    s.GET("/api/jobs/" + strconv.FormatUint(uint64(job.ID), 10)).Status(http.StatusOK).JSON(&done)
+    if done.Status != "succeeded" || done.Attempts != 1 {
+        t.Fatalf("job %+v, want succeeded on the first attempt", done)
+    }
+}
+
+func TestSendEmailRejectsBadInput(t *testing.T) {
+    s := apitest.New(t)
+    s.POST("/api/emails/send", `{"to": ["not an address"], "template": "enrollment_confirmation"}`).
+        Status(http.StatusBadRequest)
+    s.POST("/api/emails/send", `{"to": ["ada@example.com"], "template": "enrollment_confirmation", "data": {"Name": "Ada"}}`).
+        Status(http.StatusUnprocessableEntity)
+    s.POST("/api/emails/send", `{"to": ["ada@example.com"], "template": "enrollment_confirmation"}`, apitest.As(s.User())).
+        Status(http.StatusForbidden)
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+    "net/http"
+    "sort"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/util"
+)
+
+// resourceFields is the allow-list behind a resource's fields and include
+// query parameters. Each field and include names the columns it is built
+// from, so a projection can be pushed down to the SELECT.
+type resourceFields struct {
+    fields   map[string][]string
+    includes map[string][]string
+    // always lists columns every projection needs, such as the key.
+    always []string
+}
+
+// projection is a parsed fields/include pair. A nil fields set means every
+// field; columns is nil in that case too, meaning SELECT *.
+type projection struct {
+    fields   map[string]bool
+    includes map[string]bool
+    columns  []string
+}
+
+func (p projection) has(field string) bool {
+    return p.fields == nil || p.fields[field]
+}
+
+func (p projection) embeds(name string) bool {
+    return p.includes[name]
+}
+
+// parseProjection reads fields=a,b and include=x,y, writing a 400 response
+// naming the allowed values when either lists something unknown.
+func (r resourceFields) parseProjection(c *gin.Context) (projection, bool) {
+    var p projection
+    includes, ok := parseList(c, "include", "include", r.includes)
+    if !ok {
+        return p, false
+    }
+    p.includes = includes
+    fields, ok := parseList(c, "fields", "field", r.fields)
+    if !ok || fields == nil {
+        return p, ok
+    }
+    p.fields = fields
+
+    seen := map[string]bool{}
+    add := func(cols []string) {
+        for _, col := range cols {
+            if !seen[col] {
+                seen[col] = true
+                p.columns = append(p.columns, col)
+            }
+        }
+    }
+    add(r.always)
+    for field := range fields {
+        add(r.fields[field])
+    }
+    for name := range includes {
+        add(r.includes[name])
+    }
+    sort.Strings(p.columns)
+    return p, true
+}
+
+func parseList(c *gin.Context, param, noun string, allowed map[string][]string) (map[string]bool, bool) {
+    raw, ok := c.GetQuery(param)
+    if !ok {
+        return nil, true
+    }
+    set := map[string]bool{}
+    for _, name := range strings.Split(raw, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+        if _, ok := allowed[name]; !ok {
+            util.HandleError(c, http.StatusBadRequest, "unknown "+noun+" "+name+"; allowed: "+allowedNames(allowed))
+            return nil, false
+        }
+        set[name] = true
+    }
+    if len(set) == 0 {
+        util.HandleError(c, http.StatusBadRequest, param+" must name at least one of: "+allowedNames(allowed))
+        return nil, false
+    }
+    return set, true
+}
+
+func allowedNames(allowed map[string][]string) string {
+    names := make([]string, 0, len(allowed))
+    for name := range allowed {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return strings.Join(names, ", ")
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/flags"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+type flagRequest struct {
+    Description string   `json:"description"`
+    Enabled     *bool    `json:"enabled" binding:"required"`
+    Rollout     int      `json:"rollout" binding:"min=0,max=100"`
+    Users       []string `json:"users"`
+    Roles       []string `json:"roles"`
+    Tenants     []string `json:"tenants"`
+}
+
+// GetFeatures returns the value of every flag for the caller, for clients
+// that adapt their UI.
+func GetFeatures(c *gin.Context) {
+    c.JSON(http.StatusOK, service.EvaluateFlags(c.Request.Context()))
+}
+
+func GetFlags(c *gin.Context) {
+    c.JSON(http.StatusOK, service.GetFlags())
+}
+
+// SetFlag defines or replaces the runtime definition of a flag.
+func SetFlag(c *gin.Context) {
+    var req flagRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    entry, err := service.SetFlag(c.Request.Context(), flags.Flag{
+        Key:         c.Param("key"),
+        Description: req.Description,
+        Enabled:     *req.Enabled,
+        Rollout:     req.Rollout,
+        Users:       req.Users,
+        Roles:       req.Roles,
+        Tenants:     req.Tenants,
+    }, c.GetString("user_id"))
+    if errors.Is(err, flags.ErrInvalidFlag) {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, entry)
+}
+
+// DeleteFlag removes the runtime definition; the flags file applies again.
+func DeleteFlag(c *gin.Context) {
+    err := service.DeleteFlag(c.Request.Context(), c.Param("key"))
+    if errors.Is(err, service.ErrFlagNotFound) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
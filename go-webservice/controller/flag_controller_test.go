@@ -0,0 +1,66 @@
+package controller_test
+
+import (
+    "net/http"
+    "testing"
+
+    "go-webservice/apitest"
+    "go-webservice/flags"
+)
+
+func features(t *testing.T, s *apitest.Server, opts ...apitest.Option) map[string]bool {
+    t.Helper()
+    var got map[string]bool
+    s.GET("/api/features", opts...).Status(http.StatusOK).JSON(&got)
+    return got
+}
+
+func TestFlags(t *testing.T) {
+    s := apitest.New(t)
+    s.Flags(flags.Flag{Key: "search", Enabled: true, Rollout: 100})
+    user := s.User()
+    s.Tenant("acme")
+    other := s.User(apitest.Role("admin"), apitest.InTenant("acme"))
+
+    s.PUT("/api/flags/reports", `{"enabled": true, "roles": ["admin"], "rollout": 100, "description": "Admin reports"}`).
+        Status(http.StatusOK).
+        Golden("flag_set")
+    s.PUT("/api/flags/beta", `{"enabled": true, "users": ["`+user.ID+`"]}`).
+        Status(http.StatusOK)
+    s.GET("/api/flags").
+        Status(http.StatusOK).
+        Golden("flags")
+
+    if got := features(t, s, apitest.As(user)); !got["search"] || got["reports"] || !got["beta"] {
+        t.Errorf("user features %v", got)
+    }
+    if got := features(t, s); !got["search"] || !got["reports"] || got["beta"] {
+        t.Errorf("admin features %v", got)
+    }
+
+    // runtime definitions override the file and removing them restores it
+    s.PUT("/api/flags/search", `{"enabled": false}`).
+        Status(http.StatusOK)
+    if got := features(t, s, apitest.As(user)); got["search"] {
+        t.Error("search still on after it was switched off")
+    }
+    s.DELETE("/api/flags/search").
+        Status(http.StatusNoContent)
+    if got := features(t, s, apitest.As(user)); !got["search"] {
+        t.Error("search off after the override was deleted")
+    }
+    s.DELETE("/api/flags/search").
+        Status(http.StatusNotFound).
+        Error("flag has no runtime definition")
+
+    s.PUT("/api/flags/bad", `{"enabled": true, "rollout": 150}`).
+        Status(http.StatusBadRequest)
+    s.PUT("/api/flags/bad", `{"rollout": 50}`).
+        Status(http.StatusBadRequest)
+    s.GET("/api/flags", apitest.As(user)).
+        Status(http.StatusForbidden)
+    // flags apply to every tenant, so only the default tenant's admins
+    // manage them
+    s.GET("/api/flags", apitest.As(other)).
+        Status(http.StatusForbidden)
+}
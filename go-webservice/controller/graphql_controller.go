@@ -0,0 +1,24 @@
+package controller
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/graph"
+    "go-webservice/util"
+)
+
+type graphqlRequest struct {
+    Query         string                 `json:"query" form:"query" binding:"required"`
+    OperationName string                 `json:"operationName" form:"operationName"`
+    Variables     map[string]interface{} `json:"variables"`
+}
+
+func GraphQL(c *gin.Context) {
+    var req graphqlRequest
+    if err := c.ShouldBind(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, graph.Execute(c.Request.Context(), req.Query, req.OperationName, req.Variables))
+}
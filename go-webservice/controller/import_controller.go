@@ -0,0 +1,139 @@
+package controller
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "mime"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/model"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+const maxImportSize = 32 << 20
+
+// ImportCourses accepts a CSV (text/csv) or NDJSON (application/x-ndjson)
+// catalog. With ?dry_run=true the rows are validated and counted but not
+// saved. Any invalid row rejects the whole import with 422 and a report.
+// With ?async=true the import runs as a background job: the response is
+// 202 with the job, whose result holds the report once it has run.
+func ImportCourses(c *gin.Context) {
+    dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+    async, _ := strconv.ParseBool(c.Query("async"))
+    body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+    var rows []service.ImportRow
+    var parseErrs []service.RowError
+    var err error
+    switch importFormat(c) {
+    case "csv":
+        rows, parseErrs, err = service.ParseCSV(body)
+    case "ndjson":
+        rows, parseErrs, err = service.ParseNDJSON(body)
+    default:
+        util.HandleError(c, http.StatusUnsupportedMediaType, "use text/csv or application/x-ndjson")
+        return
+    }
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+        util.HandleError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("import is larger than %d bytes", maxImportSize))
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    if async {
+        job, err := service.EnqueueImport(c.Request.Context(), rows, parseErrs, dryRun)
+        if err != nil {
+            util.HandleError(c, http.StatusInternalServerError, err.Error())
+            return
+        }
+        c.Header("Location", "/api/jobs/"+strconv.FormatUint(uint64(job.ID), 10))
+        c.JSON(http.StatusAccepted, job)
+        return
+    }
+
+    report, err := service.ImportCourses(c.Request.Context(), rows, parseErrs, dryRun)
+    if errors.Is(err, service.ErrImportInvalid) {
+        c.JSON(http.StatusUnprocessableEntity, report)
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, report)
+}
+
+func importFormat(c *gin.Context) string {
+    if f := c.Query("format"); f != "" {
+        return f
+    }
+    mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+    switch mediaType {
+    case "text/csv":
+        return "csv"
+    case "application/x-ndjson", "application/ndjson", "application/jsonl":
+        return "ndjson"
+    }
+    return ""
+}
+
+// ExportCourses streams the whole catalog as CSV or NDJSON (?format=,
+// default csv), flushing as it goes.
+func ExportCourses(c *gin.Context) {
+    format := c.DefaultQuery("format", "csv")
+    w := c.Writer
+    var write func(model.Course) error
+    var done func()
+
+    switch format {
+    case "csv":
+        w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+        cw := csv.NewWriter(w)
+        cw.Write(service.ImportColumns)
+        write = func(course model.Course) error {
+            return cw.Write(service.ExportRecord(course))
+        }
+        done = cw.Flush
+    case "ndjson":
+        w.Header().Set("Content-Type", "application/x-ndjson")
+        enc := json.NewEncoder(w)
+        write = func(course model.Course) error {
+            return enc.Encode(course)
+        }
+        done = func() {}
+    default:
+        util.HandleError(c, http.StatusBadRequest, "format must be csv or ndjson")
+        return
+    }
+    w.Header().Set("Content-Disposition", "attachment; filename=courses."+format)
+    w.WriteHeader(http.StatusOK)
+
+    count := 0
+    err := service.ExportCourses(c.Request.Context(), func(course model.Course) error {
+        if err := write(course); err != nil {
+            return err
+        }
+        if count++; count%500 == 0 {
+            done()
+            w.Flush()
+        }
+        return nil
+    })
+    done()
+    if err != nil {
+        // headers are already sent; abort so the client sees a truncated body
+        c.Error(err)
+        c.Abort()
+        return
+    }
+    w.Flush()
+}
@@ -0,0 +1,65 @@
+package controller_test
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+
+    "go-webservice/apitest"
+)
+
+type importReport struct {
+    Created int `json:"created"`
+    Updated int `json:"updated"`
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+    for _, format := range []string{"csv", "ndjson"} {
+        t.Run(format, func(t *testing.T) {
+            s := apitest.New(t)
+            var created struct {
+                ID string `json:"id"`
+            }
+            s.POST("/api/v1/courses", `{"title": "From the API"}`).Status(http.StatusCreated).JSON(&created)
+            s.POST("/api/v1/courses/import?format=csv", "external_id,title\ncat-1,Imported\n").Status(http.StatusOK)
+
+            var list struct {
+                Data []struct {
+                    ID         string `json:"id"`
+                    ExternalID string `json:"external_id"`
+                } `json:"data"`
+            }
+            s.GET("/api/v2/courses").Status(http.StatusOK).JSON(&list)
+            total := len(list.Data)
+
+            export := s.GET("/api/v1/courses/export?format=" + format).Status(http.StatusOK).Body
+            for i := 0; i < 2; i++ {
+                var report importReport
+                s.POST("/api/v1/courses/import?format="+format, export).Status(http.StatusOK).JSON(&report)
+                if report.Created != 0 || report.Updated != total {
+                    t.Fatalf("import %d: %+v, want all %d courses updated", i, report, total)
+                }
+            }
+
+            s.GET("/api/v2/courses").Status(http.StatusOK).JSON(&list)
+            if len(list.Data) != total {
+                t.Fatalf("%d courses after re-importing the export, want %d", len(list.Data), total)
+            }
+            for _, c := range list.Data {
+                if c.ID == created.ID && c.ExternalID != created.ID {
+                    t.Fatalf("API course imported with external id %q, want its id", c.ExternalID)
+                }
+            }
+        })
+    }
+}
+
+func TestImportTooLarge(t *testing.T) {
+    s := apitest.New(t)
+    body := "external_id,title\n" + strings.Repeat("x,Oversized\n", (32<<20)/12+1)
+    for _, contentType := range []string{"text/csv", "application/x-ndjson"} {
+        s.POST("/api/v1/courses/import", body, apitest.Header("Content-Type", contentType)).
+            Status(http.StatusRequestEntityTooLarge).
+            Error("import is larger than 33554432 bytes")
+    }
+}
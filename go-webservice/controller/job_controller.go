@@ -0,0 +1,56 @@
+package controller
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+// GetJobs lists background jobs; ?status= and ?type= filter, ?limit=
+// (1-500, default 100) bounds the page.
+func GetJobs(c *gin.Context) {
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+    if err != nil || limit < 1 || limit > 500 {
+        util.HandleError(c, http.StatusBadRequest, "limit must be between 1 and 500")
+        return
+    }
+    jobList, err := service.GetJobs(c.Request.Context(), c.Query("status"), c.Query("type"), limit)
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, jobList)
+}
+
+func GetJob(c *gin.Context) {
+    job, err := service.GetJob(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        handleJobError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, job)
+}
+
+func RetryJob(c *gin.Context) {
+    job, err := service.RetryJob(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        handleJobError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, job)
+}
+
+func handleJobError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, service.ErrJobNotFound):
+        util.HandleError(c, http.StatusNotFound, err.Error())
+    case errors.Is(err, service.ErrJobNotFailed):
+        util.HandleError(c, http.StatusConflict, err.Error())
+    default:
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+    }
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+    "errors"
+    "io"
+    "mime"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/model"
+    "go-webservice/service"
+    "go-webservice/tenant"
+    "go-webservice/util"
+)
+
+const mediaURLTTL = 15 * time.Minute
+
+// UploadMedia takes a multipart form with a single "file" part and the
+// media kind in ?kind=. The part is streamed straight to storage.
+func UploadMedia(c *gin.Context) {
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxMediaSize+1<<20)
+    reader, err := c.Request.MultipartReader()
+    if err != nil {
+        util.HandleError(c, http.StatusBadRequest, "expected a multipart/form-data body")
+        return
+    }
+    for {
+        part, err := reader.NextPart()
+        if err == io.EOF {
+            util.HandleError(c, http.StatusBadRequest, "missing file part")
+            return
+        }
+        if err != nil {
+            util.HandleError(c, http.StatusBadRequest, err.Error())
+            return
+        }
+        if part.FormName() != "file" {
+            continue
+        }
+        media, err := service.UploadMedia(c.Request.Context(), c.Param("id"), c.Query("kind"), part.FileName(), part)
+        if err != nil {
+            handleMediaError(c, err)
+            return
+        }
+        c.JSON(http.StatusCreated, media)
+        return
+    }
+}
+
+func GetMedia(c *gin.Context) {
+    media, err := service.GetMediaList(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        handleMediaError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, media)
+}
+
+func DeleteMedia(c *gin.Context) {
+    if err := service.DeleteMedia(c.Request.Context(), c.Param("id"), c.Param("mediaId")); err != nil {
+        handleMediaError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// GetMediaURL issues a short-lived download URL that can be handed to a
+// browser or CDN without an API credential.
+func GetMediaURL(c *gin.Context) {
+    media, err := service.GetMedia(c.Request.Context(), c.Param("id"), c.Param("mediaId"))
+    if err != nil {
+        handleMediaError(c, err)
+        return
+    }
+    url, expires := service.SignMediaURL(c.Request.Context(), media, mediaURLTTL)
+    c.JSON(http.StatusOK, gin.H{"url": url, "expires_at": expires})
+}
+
+// DownloadMedia serves a signed media URL. It sits outside the auth
+// middleware: the signature is the credential and names the tenant.
+func DownloadMedia(c *gin.Context) {
+    tenantID := c.Query("tenant")
+    if !service.VerifyMediaURL(tenantID, c.Param("id"), c.Query("expires"), c.Query("sig")) {
+        util.HandleError(c, http.StatusForbidden, "invalid or expired link")
+        return
+    }
+    ctx := tenant.WithTenant(c.Request.Context(), tenantID)
+    media, body, err := service.OpenMedia(ctx, c.Param("id"))
+    if err != nil {
+        handleMediaError(c, err)
+        return
+    }
+    defer body.Close()
+
+    disposition := "inline"
+    if media.Kind != model.MediaThumbnail {
+        disposition = "attachment"
+    }
+    c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": media.Filename}))
+    c.Header("Content-Length", strconv.FormatInt(media.Size, 10))
+    c.Header("Cache-Control", "private, max-age=300")
+    c.DataFromReader(http.StatusOK, media.Size, media.ContentType, body, nil)
+}
+
+func handleMediaError(c *gin.Context, err error) {
+    var maxErr *http.MaxBytesError
+    switch {
+    case errors.Is(err, service.ErrCourseNotFound), errors.Is(err, service.ErrMediaNotFound):
+        util.HandleError(c, http.StatusNotFound, err.Error())
+    case errors.Is(err, service.ErrInvalidMediaKind):
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+    case errors.Is(err, service.ErrMediaTooLarge), errors.As(err, &maxErr):
+        util.HandleError(c, http.StatusRequestEntityTooLarge, service.ErrMediaTooLarge.Error())
+    case errors.Is(err, service.ErrUnsupportedMediaType):
+        util.HandleError(c, http.StatusUnsupportedMediaType, err.Error())
+    default:
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+    }
+}
@@ -0,0 +1,68 @@
+package controller_test
+
+import (
+    "bytes"
+    "context"
+    "mime/multipart"
+    "net/http"
+    "testing"
+
+    "go-webservice/apitest"
+    "go-webservice/config"
+    "go-webservice/model"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+func upload(t *testing.T, s *apitest.Server, courseID string, content []byte) model.Media {
+    t.Helper()
+    var body bytes.Buffer
+    w := multipart.NewWriter(&body)
+    part, _ := w.CreateFormFile("file", "thumb.png")
+    part.Write(content)
+    w.Close()
+    var media model.Media
+    s.POST("/api/v1/courses/"+courseID+"/media?kind=thumbnail", body.Bytes(),
+        apitest.Header("Content-Type", w.FormDataContentType())).
+        Status(http.StatusCreated).
+        JSON(&media)
+    return media
+}
+
+func TestMediaBlobsAreSharedAndReleased(t *testing.T) {
+    s := apitest.New(t)
+    first, second := upload(t, s, "1", png), upload(t, s, "1", png)
+    if first.SHA256 != second.SHA256 {
+        t.Fatalf("same content hashed to %s and %s", first.SHA256, second.SHA256)
+    }
+    var blobs []model.MediaBlob
+    s.DB.Find(&blobs)
+    if len(blobs) != 1 {
+        t.Fatalf("%d blob rows for one file uploaded twice", len(blobs))
+    }
+    key := blobs[0].Key
+
+    s.DELETE("/api/v1/courses/1/media/" + first.ID).Status(http.StatusNoContent)
+    body, err := config.Blobs.Get(context.Background(), key)
+    if err != nil {
+        t.Fatalf("blob still in use by %s was deleted: %v", second.ID, err)
+    }
+    body.Close()
+
+    s.DELETE("/api/v1/courses/1/media/" + second.ID).Status(http.StatusNoContent)
+    if _, err := config.Blobs.Get(context.Background(), key); err == nil {
+        t.Fatal("unreferenced blob kept")
+    }
+    s.DB.Find(&blobs)
+    if len(blobs) != 0 {
+        t.Fatalf("blob rows %+v left after deleting every reference", blobs)
+    }
+
+    // the blob is stored again for a new upload of the same file
+    upload(t, s, "1", png)
+    body, err = config.Blobs.Get(context.Background(), key)
+    if err != nil {
+        t.Fatalf("re-uploaded blob missing: %v", err)
+    }
+    body.Close()
+}
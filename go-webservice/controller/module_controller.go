@@ -0,0 +1,37 @@
+package controller
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/model"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+type moduleRequest struct {
+    Title string `json:"title" binding:"required"`
+}
+
+func GetModules(c *gin.Context) {
+    modules, err := service.GetModules(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, modules)
+}
+
+func CreateModule(c *gin.Context) {
+    var req moduleRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    module, err := service.CreateModule(c.Request.Context(), c.Param("id"), model.Module{Title: req.Title})
+    if err != nil {
+        handleCourseError(c, err)
+        return
+    }
+    c.JSON(http.StatusCreated, module)
+}
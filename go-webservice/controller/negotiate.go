@@ -0,0 +1,64 @@
+package controller
+
+import (
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/negotiate"
+    "go-webservice/util"
+)
+
+// responseEncoder picks the body encoding for the request's Accept header,
+// writing a 406 naming the supported types when none is acceptable.
+// Errors are always JSON.
+func responseEncoder(c *gin.Context) (negotiate.Encoder, bool) {
+    c.Writer.Header().Add("Vary", "Accept")
+    enc, ok := negotiate.Negotiate(c.GetHeader("Accept"))
+    if !ok {
+        util.HandleError(c, http.StatusNotAcceptable, "acceptable types: "+strings.Join(negotiate.Supported(), ", "))
+        return nil, false
+    }
+    return enc, true
+}
+
+// v1ResponseEncoder is responseEncoder for v1, whose clients predate
+// content negotiation: they get JSON unless they explicitly ask for
+// another type, and never a 406.
+func v1ResponseEncoder(c *gin.Context) negotiate.Encoder {
+    c.Writer.Header().Add("Vary", "Accept")
+    if enc, ok := negotiate.Requested(c.GetHeader("Accept")); ok {
+        return enc
+    }
+    return negotiate.JSON{}
+}
+
+// render writes v as the whole response body.
+func render(c *gin.Context, enc negotiate.Encoder, status int, item string, v interface{}) {
+    c.Header("Content-Type", enc.ContentType())
+    c.Status(status)
+    if err := enc.Encode(c.Writer, item, v); err != nil {
+        log.Println("render:", err)
+    }
+}
+
+// renderList streams a list, encoding each element as it is reached, so
+// the encoded body is never held in memory. Once the status is sent an
+// error can only cut the body short.
+func renderList(c *gin.Context, enc negotiate.Encoder, l negotiate.List, item func(i int) interface{}) {
+    c.Header("Content-Type", enc.ContentType())
+    c.Status(http.StatusOK)
+    lw, err := enc.List(c.Writer, l)
+    if err == nil {
+        for i := 0; i < l.Len && err == nil; i++ {
+            err = lw.Write(item(i))
+        }
+    }
+    if err == nil {
+        err = lw.Close()
+    }
+    if err != nil {
+        log.Println("render list:", err)
+    }
+}
@@ -0,0 +1,71 @@
+package controller_test
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+
+    "go-webservice/apitest"
+)
+
+func TestResponseFormats(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Title("Formats"), apitest.Price(1500, "USD"))
+
+    csv := s.GET("/api/v2/courses?fields=id,title,price", apitest.Header("Accept", "text/csv")).
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "text/csv; charset=utf-8").
+        HasHeader("Vary", "Accept")
+    want := "id,price.amount,price.currency,title\n1,,,Go Basics\n" + course.ID + ",1500,USD,Formats\n"
+    if string(csv.Body) != want {
+        t.Errorf("csv\n got %q\nwant %q", csv.Body, want)
+    }
+
+    xml := s.GET("/api/v1/courses/"+course.ID, apitest.Header("Accept", "application/xml")).
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "application/xml; charset=utf-8")
+    if !strings.Contains(string(xml.Body), "<course><id>"+course.ID+"</id><title>Formats</title>") {
+        t.Errorf("xml %s", xml.Body)
+    }
+
+    s.GET("/api/v2/courses/"+course.ID, apitest.Header("Accept", "application/x-msgpack")).
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "application/msgpack")
+    s.GET("/api/v2/courses", apitest.Header("Accept", "*/*, text/csv;q=0")).
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "application/json; charset=utf-8")
+}
+
+func TestV1StaysJSON(t *testing.T) {
+    s := apitest.New(t)
+    // v1 clients get JSON unless they name another type at full quality
+    for _, accept := range []string{
+        "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+        "text/html, application/xml;q=0.9",
+        "text/html",
+        "text/*",
+    } {
+        s.GET("/api/v1/courses/1", apitest.Header("Accept", accept)).
+            Status(http.StatusOK).
+            HasHeader("Content-Type", "application/json; charset=utf-8")
+    }
+    s.GET("/api/v1/courses", apitest.Header("Accept", "text/csv")).
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "text/csv; charset=utf-8")
+    s.GET("/api/v2/courses/1", apitest.Header("Accept", "application/xml;q=0.9, */*;q=0.8")).
+        Status(http.StatusOK).
+        HasHeader("Content-Type", "application/xml; charset=utf-8")
+}
+
+func TestNotAcceptable(t *testing.T) {
+    s := apitest.New(t)
+
+    s.GET("/api/v2/courses", apitest.Header("Accept", "text/html")).
+        Status(http.StatusNotAcceptable).
+        HasHeader("Content-Type", "application/json; charset=utf-8").
+        Error("acceptable types: application/json, text/csv, application/xml, application/msgpack")
+    // errors are JSON whatever the client asked for
+    s.GET("/api/v2/courses/missing", apitest.Header("Accept", "text/csv")).
+        Status(http.StatusNotFound).
+        HasHeader("Content-Type", "application/json; charset=utf-8")
+}
@@ -0,0 +1,245 @@
+package controller
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/config"
+    "go-webservice/model"
+    "go-webservice/payment"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+const maxNotificationSize = 64 << 10
+
+type priceRequest struct {
+    Amount   *int64 `json:"amount" binding:"required"`
+    Currency string `json:"currency"`
+}
+
+type couponRequest struct {
+    Code      string     `json:"code" binding:"required,max=64"`
+    Kind      string     `json:"kind" binding:"required,oneof=percent fixed"`
+    Value     int64      `json:"value" binding:"required"`
+    Currency  string     `json:"currency"`
+    ExpiresAt *time.Time `json:"expires_at"`
+    MaxUses   int        `json:"max_uses" binding:"min=0"`
+}
+
+type checkoutRequest struct {
+    CourseID   string `json:"course_id" binding:"required"`
+    CouponCode string `json:"coupon_code"`
+    Email      string `json:"email" binding:"omitempty,email"`
+    Locale     string `json:"locale" binding:"max=35"`
+}
+
+type checkoutResponse struct {
+    Order   model.Order     `json:"order"`
+    Payment *payment.Intent `json:"payment,omitempty"`
+}
+
+// SetCoursePrice sets a course's price, {"amount": 4999, "currency": "USD"}
+// in minor units. The response is the v2 representation, which shows it.
+func SetCoursePrice(c *gin.Context) {
+    var req priceRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    course, err := service.SetCoursePrice(c.Request.Context(), c.Param("id"), *req.Amount, req.Currency)
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, toCourseV2(course))
+}
+
+func GetCoupons(c *gin.Context) {
+    coupons, err := service.GetCoupons(c.Request.Context())
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, coupons)
+}
+
+func CreateCoupon(c *gin.Context) {
+    var req couponRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    coupon, err := service.CreateCoupon(c.Request.Context(), model.Coupon{
+        Code:      req.Code,
+        Kind:      req.Kind,
+        Value:     req.Value,
+        Currency:  req.Currency,
+        ExpiresAt: req.ExpiresAt,
+        MaxUses:   req.MaxUses,
+    })
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    c.JSON(http.StatusCreated, coupon)
+}
+
+func DeactivateCoupon(c *gin.Context) {
+    if err := service.DeactivateCoupon(c.Request.Context(), c.Param("id")); err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// Checkout places an order. Free orders come back paid (201); otherwise
+// the response is 202 with the payment to complete with the provider.
+// The enrollment confirmation goes to email, or to the subject when it is
+// an address, in locale or the Accept-Language header's first choice.
+func Checkout(c *gin.Context) {
+    var req checkoutRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    if req.Locale == "" {
+        req.Locale = preferredLocale(c.GetHeader("Accept-Language"))
+    }
+    order, intent, err := service.Checkout(c.Request.Context(), c.GetString("user_id"), req.CourseID, req.CouponCode,
+        req.Email, req.Locale)
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    if order.Status == model.OrderPaid {
+        c.JSON(http.StatusCreated, checkoutResponse{Order: order})
+        return
+    }
+    c.JSON(http.StatusAccepted, checkoutResponse{Order: order, Payment: &intent})
+}
+
+func GetOrders(c *gin.Context) {
+    orders, err := service.GetOrders(c.Request.Context(), c.GetString("user_id"))
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, orders)
+}
+
+func GetOrder(c *gin.Context) {
+    order, err := service.GetOrder(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetString("role") == "admin")
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, order)
+}
+
+func GetEnrollments(c *gin.Context) {
+    enrollments, err := service.GetEnrollments(c.Request.Context(), c.GetString("user_id"))
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, enrollments)
+}
+
+// PaymentNotification receives the provider's report on a payment. It is
+// authenticated by the provider's signature, not by our credentials.
+func PaymentNotification(c *gin.Context) {
+    body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxNotificationSize))
+    if err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    order, err := service.HandlePaymentNotification(c.Request.Context(), c.Request.Header, body)
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"order_id": order.ID, "status": order.Status})
+}
+
+// CompleteFakePayment stands in for the fake provider's checkout page:
+// {"succeeded": true} pays, false declines. Only the user who placed the
+// order, or an admin, may complete its payment. The provider's
+// notification goes through the same path as a real one. It is 404
+// unless the fake provider is in use.
+func CompleteFakePayment(c *gin.Context) {
+    fake, ok := config.Payments.(*payment.Fake)
+    if !ok {
+        util.HandleError(c, http.StatusNotFound, "not found")
+        return
+    }
+    var req struct {
+        Succeeded bool `json:"succeeded"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    _, err := service.GetOrderByPayment(c.Request.Context(), c.Param("id"), c.GetString("user_id"),
+        c.GetString("role") == "admin")
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    header, body, err := fake.Complete(c.Param("id"), req.Succeeded)
+    if errors.Is(err, payment.ErrUnknownPayment) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    order, err := service.HandlePaymentNotification(c.Request.Context(), header, body)
+    if err != nil {
+        handleOrderError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, order)
+}
+
+// preferredLocale is the first language tag in an Accept-Language header,
+// ignoring weights; clients list their preference first.
+func preferredLocale(header string) string {
+    tag, _, _ := strings.Cut(header, ",")
+    tag, _, _ = strings.Cut(tag, ";")
+    tag = strings.TrimSpace(tag)
+    if tag == "*" || len(tag) > 35 {
+        return ""
+    }
+    return tag
+}
+
+func handleOrderError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, service.ErrCourseNotFound), errors.Is(err, service.ErrOrderNotFound),
+        errors.Is(err, service.ErrCouponNotFound):
+        util.HandleError(c, http.StatusNotFound, err.Error())
+    case errors.Is(err, service.ErrInvalidPrice), errors.Is(err, service.ErrInvalidCoupon):
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+    case errors.Is(err, service.ErrAlreadyEnrolled), errors.Is(err, service.ErrCouponExists):
+        util.HandleError(c, http.StatusConflict, err.Error())
+    case errors.Is(err, service.ErrNotForSale), errors.Is(err, service.ErrCouponInvalid),
+        errors.Is(err, service.ErrPaymentMismatch):
+        util.HandleError(c, http.StatusUnprocessableEntity, err.Error())
+    case errors.Is(err, service.ErrAnonymousCheckout):
+        util.HandleError(c, http.StatusForbidden, err.Error())
+    case errors.Is(err, payment.ErrInvalidNotification):
+        util.HandleError(c, http.StatusUnauthorized, err.Error())
+    case errors.Is(err, service.ErrPaymentFailed):
+        util.HandleError(c, http.StatusBadGateway, err.Error())
+    case errors.Is(err, service.ErrPaymentsDisabled):
+        util.HandleError(c, http.StatusServiceUnavailable, err.Error())
+    default:
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+    }
+}
@@ -0,0 +1,245 @@
+package controller_test
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "go-webservice/apitest"
+    "go-webservice/service"
+)
+
+type checkout struct {
+    Order struct {
+        ID       string `json:"id"`
+        Status   string `json:"status"`
+        Discount int64  `json:"discount"`
+        Total    int64  `json:"total"`
+    } `json:"order"`
+    Payment *struct {
+        ID          string `json:"id"`
+        CheckoutURL string `json:"checkout_url"`
+    } `json:"payment"`
+}
+
+func enrollments(t *testing.T, s *apitest.Server, user apitest.User) int {
+    t.Helper()
+    var list []interface{}
+    s.GET("/api/enrollments", apitest.As(user)).Status(http.StatusOK).JSON(&list)
+    return len(list)
+}
+
+func TestCheckoutPaid(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Published(), apitest.Price(4999, "USD"))
+    user := s.User()
+
+    var placed checkout
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID}, apitest.As(user)).
+        Status(http.StatusAccepted).
+        JSON(&placed)
+    if placed.Order.Status != "pending" || placed.Order.Total != 4999 || placed.Payment == nil {
+        t.Fatalf("checkout %+v, want a pending order of 4999 with a payment", placed)
+    }
+    if n := enrollments(t, s, user); n != 0 {
+        t.Fatalf("%d enrollments before payment", n)
+    }
+
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": true}`, apitest.As(user)).
+        Status(http.StatusOK)
+    s.Tag(placed.Order.ID, "order-1")
+    s.Tag(placed.Payment.ID, "payment-1")
+    s.GET("/api/orders/"+placed.Order.ID, apitest.As(user)).
+        Status(http.StatusOK).
+        Golden("order_paid")
+    if n := enrollments(t, s, user); n != 1 {
+        t.Fatalf("%d enrollments after payment, want 1", n)
+    }
+
+    // the provider may notify more than once
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": true}`, apitest.As(user)).
+        Status(http.StatusOK)
+    if n := enrollments(t, s, user); n != 1 {
+        t.Fatalf("%d enrollments after a repeated notification, want 1", n)
+    }
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID}, apitest.As(user)).
+        Status(http.StatusConflict).
+        Error("already enrolled in this course")
+}
+
+func TestCheckoutDeclined(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Published(), apitest.Price(1000, "EUR"))
+    user := s.User()
+    s.POST("/api/coupons", `{"code": "once", "kind": "fixed", "value": 300, "currency": "EUR", "max_uses": 1}`).
+        Status(http.StatusCreated)
+
+    var placed checkout
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID, "coupon_code": "ONCE"}, apitest.As(user)).
+        Status(http.StatusAccepted).
+        JSON(&placed)
+    if placed.Order.Discount != 300 || placed.Order.Total != 700 {
+        t.Fatalf("order %+v, want 300 off", placed.Order)
+    }
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID, "coupon_code": "once"}, apitest.As(s.User())).
+        Status(http.StatusUnprocessableEntity).
+        Error("coupon is not valid for this order")
+
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": false}`, apitest.As(user)).
+        Status(http.StatusOK)
+    var order struct {
+        Status     string `json:"status"`
+        FailReason string `json:"fail_reason"`
+    }
+    s.GET("/api/orders/"+placed.Order.ID, apitest.As(user)).Status(http.StatusOK).JSON(&order)
+    if order.Status != "failed" || order.FailReason != "card declined" {
+        t.Fatalf("order %+v after a declined payment", order)
+    }
+    if n := enrollments(t, s, user); n != 0 {
+        t.Fatalf("%d enrollments after a declined payment", n)
+    }
+
+    // the declined order gave its coupon use back
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID, "coupon_code": "once"}, apitest.As(user)).
+        Status(http.StatusAccepted)
+}
+
+func TestCheckoutFree(t *testing.T) {
+    s := apitest.New(t)
+    free := s.Course(apitest.Published())
+    paid := s.Course(apitest.Published(), apitest.Price(2000, "USD"))
+    user := s.User()
+    s.POST("/api/coupons", `{"code": "ALL", "kind": "percent", "value": 100}`).
+        Status(http.StatusCreated)
+
+    var placed checkout
+    s.POST("/api/checkout", map[string]string{"course_id": free.ID}, apitest.As(user)).
+        Status(http.StatusCreated).
+        JSON(&placed)
+    if placed.Order.Status != "paid" || placed.Payment != nil {
+        t.Fatalf("free checkout %+v, want paid without a payment", placed)
+    }
+    s.POST("/api/checkout", map[string]string{"course_id": paid.ID, "coupon_code": "all"}, apitest.As(user)).
+        Status(http.StatusCreated)
+    if n := enrollments(t, s, user); n != 2 {
+        t.Fatalf("%d enrollments, want 2", n)
+    }
+}
+
+func TestCheckoutConfirmsEnrollment(t *testing.T) {
+    s := apitest.New(t)
+    free := s.Course(apitest.Published(), apitest.Title("Go Basics"))
+    paid := s.Course(apitest.Published(), apitest.Price(2000, "USD"))
+    user := s.User()
+
+    s.POST("/api/checkout", map[string]string{"course_id": free.ID, "email": "ada@example.com"}, apitest.As(user),
+        apitest.Header("Accept-Language", "es-MX, en;q=0.5")).
+        Status(http.StatusCreated)
+    var placed checkout
+    s.POST("/api/checkout", map[string]string{"course_id": paid.ID}, apitest.As(user)).
+        Status(http.StatusAccepted).
+        JSON(&placed)
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": true}`, apitest.As(user)).
+        Status(http.StatusOK)
+
+    s.RunJobs()
+    sent := s.Mailbox.Messages()
+    // the second order gave no address and the subject is not one
+    if len(sent) != 1 || sent[0].To[0] != "ada@example.com" || sent[0].Subject != "Te has inscrito en Go Basics" ||
+        !strings.Contains(sent[0].Text, "/courses/"+free.ID) {
+        t.Fatalf("sent %+v, want one confirmation in Spanish", sent)
+    }
+    s.POST("/api/checkout", map[string]string{"course_id": paid.ID, "email": "nope"}, apitest.As(s.User())).
+        Status(http.StatusBadRequest)
+}
+
+func TestCheckoutErrors(t *testing.T) {
+    s := apitest.New(t)
+    draft := s.Course(apitest.Price(500, "USD"))
+    course := s.Course(apitest.Published(), apitest.Price(500, "USD"))
+    user, other := s.User(), s.User()
+
+    s.POST("/api/checkout", map[string]string{"course_id": draft.ID}, apitest.As(user)).
+        Status(http.StatusUnprocessableEntity).
+        Error("course is not published")
+    s.POST("/api/checkout", map[string]string{"course_id": "missing"}, apitest.As(user)).
+        Status(http.StatusNotFound)
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID, "coupon_code": "NOPE"}, apitest.As(user)).
+        Status(http.StatusNotFound).
+        Error("coupon not found")
+    s.POST("/api/checkout", `{}`, apitest.As(user)).
+        Status(http.StatusBadRequest)
+    s.POST("/api/coupons", `{"code": "BAD", "kind": "percent", "value": 150}`).
+        Status(http.StatusBadRequest)
+
+    var placed checkout
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID}, apitest.As(user)).
+        Status(http.StatusAccepted).
+        JSON(&placed)
+    s.GET("/api/orders/"+placed.Order.ID, apitest.As(other)).
+        Status(http.StatusNotFound)
+    s.GET("/api/orders/" + placed.Order.ID).
+        Status(http.StatusOK)
+    s.POST("/payments/fake/unknown", `{"succeeded": true}`, apitest.As(user)).
+        Status(http.StatusNotFound)
+    // only the buyer or an admin completes a payment
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": true}`, apitest.Anonymous()).
+        Status(http.StatusUnauthorized)
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": true}`, apitest.As(other)).
+        Status(http.StatusNotFound)
+    s.POST("/payments/webhook", `{"status": "succeeded"}`, apitest.Anonymous()).
+        Status(http.StatusUnauthorized)
+}
+
+func TestPendingOrdersExpire(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Published(), apitest.Price(1000, "EUR"))
+    user := s.User()
+    s.POST("/api/coupons", `{"code": "ONCE", "kind": "percent", "value": 10, "max_uses": 1}`).
+        Status(http.StatusCreated)
+    var placed checkout
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID, "coupon_code": "once"}, apitest.As(user)).
+        Status(http.StatusAccepted).
+        JSON(&placed)
+
+    if n, err := service.ExpireOrders(context.Background(), time.Now().Add(-time.Hour)); err != nil || n != 0 {
+        t.Fatalf("expired %d recent orders: %v", n, err)
+    }
+    if n, err := service.ExpireOrders(context.Background(), time.Now().Add(time.Second)); err != nil || n != 1 {
+        t.Fatalf("expired %d orders, want 1: %v", n, err)
+    }
+    var order struct {
+        Status     string `json:"status"`
+        FailReason string `json:"fail_reason"`
+    }
+    s.GET("/api/orders/"+placed.Order.ID, apitest.As(user)).Status(http.StatusOK).JSON(&order)
+    if order.Status != "failed" || order.FailReason != "expired" {
+        t.Fatalf("order %+v, want it expired", order)
+    }
+
+    // a late payment does not enroll, and the coupon use is free again
+    s.POST(placed.Payment.CheckoutURL, `{"succeeded": true}`, apitest.As(user)).
+        Status(http.StatusOK)
+    if n := enrollments(t, s, user); n != 0 {
+        t.Fatalf("%d enrollments after paying an expired order", n)
+    }
+    s.POST("/api/checkout", map[string]string{"course_id": course.ID, "coupon_code": "once"}, apitest.As(user)).
+        Status(http.StatusAccepted)
+}
+
+func TestCheckoutWithoutProvider(t *testing.T) {
+    s := apitest.New(t)
+    free := s.Course(apitest.Published())
+    paid := s.Course(apitest.Published(), apitest.Price(1000, "EUR"))
+    s.WithoutPayments()
+
+    s.POST("/api/checkout", map[string]string{"course_id": paid.ID}, apitest.As(s.User())).
+        Status(http.StatusServiceUnavailable).
+        Error("no payment provider is configured")
+    s.POST("/api/checkout", map[string]string{"course_id": free.ID}, apitest.As(s.User())).
+        Status(http.StatusCreated)
+    s.POST("/payments/fake/anything", `{"succeeded": true}`).
+        Status(http.StatusNotFound)
+}
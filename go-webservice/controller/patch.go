@@ -0,0 +1,261 @@
+package controller
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gin-gonic/gin/binding"
+    "go-webservice/jsonpatch"
+    "go-webservice/model"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+const (
+    jsonPatchType  = "application/json-patch+json"
+    mergePatchType = "application/merge-patch+json"
+)
+
+// a patch of a course is small; anything larger is refused unread
+const maxPatchSize = 1 << 20
+
+// patchableCourseFields are the members of a course representation a
+// patch may change. Everything else is read-only and may only be tested.
+var patchableCourseFields = map[string]bool{"title": true, "description": true}
+
+// courseInput is a request body a patched course is validated through.
+type courseInput interface {
+    input() model.Course
+}
+
+func (r *courseRequest) input() model.Course {
+    return model.Course{Title: r.Title, Description: r.Description}
+}
+
+func (r *courseRequestV2) input() model.Course {
+    return model.Course{Title: r.Title, Description: r.Description}
+}
+
+// patchError is a patch that can't be applied, pointing at the operation
+// (JSON Patch only; -1 otherwise) and path it failed on.
+type patchError struct {
+    status  int
+    index   int
+    path    string
+    message string
+}
+
+func (e *patchError) Error() string {
+    return e.message
+}
+
+// patchCourse applies a JSON Patch or JSON Merge Patch to the course's
+// representation as view renders it, then validates the result as req
+// before storing it. The patch is all or nothing.
+func patchCourse(c *gin.Context, view func(model.Course) interface{}, req courseInput) (model.Course, bool) {
+    mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+    body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchSize))
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+        util.HandleError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("patch is larger than %d bytes", maxPatchSize))
+        return model.Course{}, false
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return model.Course{}, false
+    }
+
+    var patch func(doc interface{}) (interface{}, error)
+    switch mediaType {
+    case jsonPatchType:
+        ops, err := jsonpatch.ParsePatch(body)
+        if err != nil {
+            writePatchError(c, err)
+            return model.Course{}, false
+        }
+        if err := checkPatchable(ops); err != nil {
+            writePatchError(c, err)
+            return model.Course{}, false
+        }
+        patch = ops.Apply
+    case mergePatchType:
+        merge, err := jsonpatch.Decode(body)
+        if err != nil {
+            util.HandleError(c, http.StatusBadRequest, "invalid merge patch: "+err.Error())
+            return model.Course{}, false
+        }
+        patch = func(doc interface{}) (interface{}, error) {
+            return jsonpatch.MergePatch(doc, merge), nil
+        }
+    default:
+        c.Header("Accept-Patch", jsonPatchType+", "+mergePatchType)
+        util.HandleError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+jsonPatchType+" or "+mergePatchType)
+        return model.Course{}, false
+    }
+
+    course, err := service.PatchCourse(c.Request.Context(), c.Param("id"), func(course model.Course) (model.Course, error) {
+        doc, err := toDocument(view(course))
+        if err != nil {
+            return course, err
+        }
+        patched, err := patch(doc)
+        if err != nil {
+            return course, err
+        }
+        return patchedCourse(doc, patched, req)
+    })
+    if err != nil {
+        writePatchError(c, err)
+        return model.Course{}, false
+    }
+    return course, true
+}
+
+// checkPatchable rejects operations that would change a read-only member.
+func checkPatchable(ops jsonpatch.Patch) error {
+    for i, op := range ops {
+        if op.Op == "test" {
+            continue
+        }
+        paths := []string{op.Path}
+        if op.Op == "move" {
+            paths = append(paths, op.From)
+        }
+        for _, path := range paths {
+            if !patchableCourseFields[topMember(path)] {
+                return &patchError{
+                    status:  http.StatusUnprocessableEntity,
+                    index:   i,
+                    path:    path,
+                    message: fmt.Sprintf("operation %d (%s %s): %s is read-only", i, op.Op, op.Path, path),
+                }
+            }
+        }
+    }
+    return nil
+}
+
+// topMember is the first token of a JSON Pointer, unescaped.
+func topMember(path string) string {
+    if !strings.HasPrefix(path, "/") {
+        return ""
+    }
+    top, _, _ := strings.Cut(path[1:], "/")
+    return strings.NewReplacer("~1", "/", "~0", "~").Replace(top)
+}
+
+// patchedCourse checks the patch left read-only members alone and decodes
+// and validates the patchable ones through req.
+func patchedCourse(doc, patched interface{}, req courseInput) (model.Course, error) {
+    before, _ := doc.(map[string]interface{})
+    after, ok := patched.(map[string]interface{})
+    if !ok {
+        return model.Course{}, &patchError{status: http.StatusUnprocessableEntity, index: -1, message: "patched course must be an object"}
+    }
+    for name, v := range after {
+        if old, ok := before[name]; !patchableCourseFields[name] && (!ok || !jsonpatch.Equal(old, v)) {
+            return model.Course{}, readOnlyError(name)
+        }
+    }
+    for name := range before {
+        if _, ok := after[name]; !ok && !patchableCourseFields[name] {
+            return model.Course{}, readOnlyError(name)
+        }
+    }
+
+    fields := map[string]interface{}{}
+    for name := range patchableCourseFields {
+        if v, ok := after[name]; ok {
+            fields[name] = v
+        }
+    }
+    data, err := json.Marshal(fields)
+    if err != nil {
+        return model.Course{}, err
+    }
+    if err := json.Unmarshal(data, req); err != nil {
+        var typeErr *json.UnmarshalTypeError
+        path := ""
+        if errors.As(err, &typeErr) {
+            path = "/" + typeErr.Field
+        }
+        return model.Course{}, &patchError{status: http.StatusUnprocessableEntity, index: -1, path: path, message: "invalid patched course: " + err.Error()}
+    }
+    if err := binding.Validator.ValidateStruct(req); err != nil {
+        return model.Course{}, &patchError{status: http.StatusUnprocessableEntity, index: -1, message: "invalid patched course: " + err.Error()}
+    }
+    return req.input(), nil
+}
+
+func readOnlyError(name string) error {
+    path := "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+    return &patchError{status: http.StatusUnprocessableEntity, index: -1, path: path, message: path + " is read-only"}
+}
+
+// toDocument turns a representation into the generic JSON value patches
+// apply to.
+func toDocument(v interface{}) (interface{}, error) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return nil, err
+    }
+    return jsonpatch.Decode(data)
+}
+
+// writePatchError maps a patch failure to its status: 400 for a malformed
+// patch, 409 for a failed test, 422 for a patch that doesn't apply or
+// leaves an invalid course.
+func writePatchError(c *gin.Context, err error) {
+    var pe *patchError
+    var opErr *jsonpatch.OpError
+    switch {
+    case errors.As(err, &pe):
+    case errors.As(err, &opErr):
+        pe = &patchError{status: http.StatusUnprocessableEntity, index: opErr.Index, path: opErr.Op.Path, message: opErr.Error()}
+        if errors.Is(err, jsonpatch.ErrInvalidPatch) {
+            pe.status = http.StatusBadRequest
+        } else if errors.Is(err, jsonpatch.ErrTestFailed) {
+            pe.status = http.StatusConflict
+        }
+    case errors.Is(err, jsonpatch.ErrInvalidPatch):
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    default:
+        handleCourseError(c, err)
+        return
+    }
+    body := gin.H{"error": true, "message": pe.message}
+    if pe.index >= 0 {
+        body["operation"] = pe.index
+    }
+    if pe.path != "" {
+        body["path"] = pe.path
+    }
+    c.AbortWithStatusJSON(pe.status, body)
+}
+
+// PatchCourse applies a JSON Patch or JSON Merge Patch to a course in its
+// v1 representation.
+func PatchCourse(c *gin.Context) {
+    course, ok := patchCourse(c, func(course model.Course) interface{} { return course }, &courseRequest{})
+    if !ok {
+        return
+    }
+    c.JSON(http.StatusOK, course)
+}
+
+// PatchCourseV2 applies a JSON Patch or JSON Merge Patch to a course in
+// its v2 representation.
+func PatchCourseV2(c *gin.Context) {
+    course, ok := patchCourse(c, func(course model.Course) interface{} { return toCourseV2(course) }, &courseRequestV2{})
+    if !ok {
+        return
+    }
+    c.JSON(http.StatusOK, toCourseV2(course))
+}
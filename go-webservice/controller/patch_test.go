@@ -0,0 +1,138 @@
+package controller_test
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+
+    "go-webservice/apitest"
+)
+
+var (
+    jsonPatch  = apitest.Header("Content-Type", "application/json-patch+json")
+    mergePatch = apitest.Header("Content-Type", "application/merge-patch+json")
+)
+
+type patchFailure struct {
+    Message   string `json:"message"`
+    Operation *int   `json:"operation"`
+    Path      string `json:"path"`
+}
+
+func TestJSONPatch(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Title("Go"))
+
+    s.PATCH("/api/v1/courses/"+course.ID, `[
+        {"op": "test", "path": "/title", "value": "Go"},
+        {"op": "replace", "path": "/title", "value": "Go in Depth"},
+        {"op": "copy", "from": "/title", "path": "/description"}
+    ]`, jsonPatch).
+        Status(http.StatusOK).
+        Golden("v1_patched_course")
+
+    var v2 struct {
+        Title       string `json:"title"`
+        Description string `json:"description"`
+    }
+    s.PATCH("/api/v2/courses/"+course.ID, `[{"op": "remove", "path": "/description"}]`, jsonPatch).
+        Status(http.StatusOK).
+        JSON(&v2)
+    if v2.Title != "Go in Depth" || v2.Description != "" {
+        t.Fatalf("v2 course %+v after removing the description", v2)
+    }
+}
+
+func TestMergePatch(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Title("Go"))
+
+    var patched struct {
+        Title       string `json:"title"`
+        Description string `json:"description"`
+        Status      string `json:"status"`
+    }
+    s.PATCH("/api/v2/courses/"+course.ID, `{"description": "Updated", "status": "draft"}`, mergePatch).
+        Status(http.StatusOK).
+        JSON(&patched)
+    if patched.Title != "Go" || patched.Description != "Updated" || patched.Status != "draft" {
+        t.Fatalf("course %+v after merge", patched)
+    }
+
+    // v1 accepts the same patch with its own member names
+    s.PATCH("/api/courses/"+course.ID, `{"title": "Go 2", "description": null}`, mergePatch).
+        Status(http.StatusOK)
+    s.GET("/api/v2/courses/" + course.ID).JSON(&patched)
+    if patched.Title != "Go 2" || patched.Description != "" {
+        t.Fatalf("course %+v after v1 merge", patched)
+    }
+}
+
+func TestPatchErrors(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Title("Go"))
+    path := "/api/v2/courses/" + course.ID
+
+    tests := []struct {
+        name      string
+        body      string
+        opt       apitest.Option
+        status    int
+        operation int
+        path      string
+    }{
+        {"failed test", `[{"op": "replace", "path": "/title", "value": "X"}, {"op": "test", "path": "/title", "value": "Y"}]`,
+            jsonPatch, http.StatusConflict, 1, "/title"},
+        {"read-only member", `[{"op": "replace", "path": "/title", "value": "X"}, {"op": "replace", "path": "/status", "value": "published"}]`,
+            jsonPatch, http.StatusUnprocessableEntity, 1, "/status"},
+        {"move from read-only", `[{"op": "move", "from": "/id", "path": "/title"}]`,
+            jsonPatch, http.StatusUnprocessableEntity, 0, "/id"},
+        {"missing member", `[{"op": "replace", "path": "/title/x", "value": "X"}]`,
+            jsonPatch, http.StatusUnprocessableEntity, 0, "/title/x"},
+        {"malformed", `[{"op": "frob", "path": "/title"}]`,
+            jsonPatch, http.StatusBadRequest, 0, "/title"},
+        {"not an array", `{"title": "X"}`,
+            jsonPatch, http.StatusBadRequest, -1, ""},
+        {"invalid result", `{"title": ""}`,
+            mergePatch, http.StatusUnprocessableEntity, -1, ""},
+        {"wrong type", `{"title": 5}`,
+            mergePatch, http.StatusUnprocessableEntity, -1, "/title"},
+        {"merge read-only", `{"rating": null}`,
+            mergePatch, http.StatusUnprocessableEntity, -1, "/rating"},
+        {"merge non-object", `["title"]`,
+            mergePatch, http.StatusUnprocessableEntity, -1, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got patchFailure
+            s.PATCH(path, tt.body, tt.opt).Status(tt.status).JSON(&got)
+            operation := -1
+            if got.Operation != nil {
+                operation = *got.Operation
+            }
+            if operation != tt.operation || got.Path != tt.path {
+                t.Errorf("failure %+v (operation %d), want operation %d at %q", got, operation, tt.operation, tt.path)
+            }
+        })
+    }
+
+    // none of the failed patches changed anything
+    var current struct {
+        Title string `json:"title"`
+    }
+    s.GET(path).Status(http.StatusOK).JSON(&current)
+    if current.Title != "Go" {
+        t.Fatalf("title %q after failed patches", current.Title)
+    }
+
+    s.PATCH(path, `{"title": "X"}`).
+        Status(http.StatusUnsupportedMediaType).
+        HasHeader("Accept-Patch", "application/json-patch+json, application/merge-patch+json")
+    s.PATCH("/api/v2/courses/missing", `{"title": "X"}`, mergePatch).
+        Status(http.StatusNotFound)
+    s.PATCH(path, `{"title": "X"}`, mergePatch, apitest.As(s.User())).
+        Status(http.StatusForbidden)
+    s.PATCH(path, `{"description": "`+strings.Repeat("x", 1<<20)+`"}`, mergePatch).
+        Status(http.StatusRequestEntityTooLarge).
+        Error("patch is larger than 1048576 bytes")
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+type reviewRequest struct {
+    Rating int    `json:"rating" binding:"required,min=1,max=5"`
+    Body   string `json:"body" binding:"max=5000"`
+}
+
+type moderationRequest struct {
+    Hidden *bool `json:"hidden" binding:"required"`
+}
+
+// GetReviews lists a course's visible reviews, newest first, paged with
+// ?limit= (1-100, default 20) and ?offset=.
+func GetReviews(c *gin.Context) {
+    limit, ok := queryInt(c, "limit", defaultPageLimit, 1, maxPageLimit)
+    if !ok {
+        return
+    }
+    offset, ok := queryInt(c, "offset", 0, 0, -1)
+    if !ok {
+        return
+    }
+    reviews, err := service.GetReviews(c.Request.Context(), c.Param("id"), limit, offset)
+    if err != nil {
+        handleReviewError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, reviews)
+}
+
+func CreateReview(c *gin.Context) {
+    var req reviewRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    review, err := service.CreateReview(c.Request.Context(), c.Param("id"), c.GetString("user_id"),
+        service.ReviewInput{Rating: req.Rating, Body: req.Body})
+    if err != nil {
+        handleReviewError(c, err)
+        return
+    }
+    c.JSON(http.StatusCreated, review)
+}
+
+func UpdateReview(c *gin.Context) {
+    var req reviewRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    review, err := service.UpdateReview(c.Request.Context(), c.Param("id"), c.Param("reviewId"), c.GetString("user_id"),
+        service.ReviewInput{Rating: req.Rating, Body: req.Body})
+    if err != nil {
+        handleReviewError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, review)
+}
+
+func DeleteReview(c *gin.Context) {
+    err := service.DeleteReview(c.Request.Context(), c.Param("id"), c.Param("reviewId"), c.GetString("user_id"),
+        c.GetString("role") == "admin")
+    if err != nil {
+        handleReviewError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// FlagReview reports a review to the admins. Flagging it again is a no-op.
+func FlagReview(c *gin.Context) {
+    _, err := service.FlagReview(c.Request.Context(), c.Param("id"), c.Param("reviewId"), c.GetString("user_id"))
+    if err != nil {
+        handleReviewError(c, err)
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+func GetFlaggedReviews(c *gin.Context) {
+    reviews, err := service.GetFlaggedReviews(c.Request.Context())
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, reviews)
+}
+
+// ModerateReview hides ({"hidden": true}) or restores a review and clears
+// its flags.
+func ModerateReview(c *gin.Context) {
+    var req moderationRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    review, err := service.ModerateReview(c.Request.Context(), c.Param("reviewId"), *req.Hidden)
+    if err != nil {
+        handleReviewError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, review)
+}
+
+func handleReviewError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, service.ErrCourseNotFound), errors.Is(err, service.ErrReviewNotFound):
+        util.HandleError(c, http.StatusNotFound, err.Error())
+    case errors.Is(err, service.ErrReviewExists):
+        util.HandleError(c, http.StatusConflict, err.Error())
+    case errors.Is(err, service.ErrInvalidRating):
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+    case errors.Is(err, service.ErrAnonymous), errors.Is(err, service.ErrNotReviewOwner):
+        util.HandleError(c, http.StatusForbidden, err.Error())
+    default:
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+    }
+}
@@ -0,0 +1,147 @@
+package controller_test
+
+import (
+    "net/http"
+    "testing"
+
+    "go-webservice/apitest"
+    "go-webservice/model"
+    "go-webservice/tenant"
+)
+
+type rating struct {
+    Average float64 `json:"average"`
+    Count   int     `json:"count"`
+}
+
+func courseRating(t *testing.T, s *apitest.Server, id string) rating {
+    t.Helper()
+    var course struct {
+        Rating rating `json:"rating"`
+    }
+    s.GET("/api/v2/courses/" + id).Status(http.StatusOK).JSON(&course)
+    return course.Rating
+}
+
+func TestReviews(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course(apitest.Published())
+    alice, bob := s.User(), s.User()
+    path := "/api/courses/" + course.ID + "/reviews"
+
+    var review struct {
+        ID string `json:"id"`
+    }
+    s.POST(path, `{"rating": 5, "body": "  Great  "}`, apitest.As(alice)).
+        Status(http.StatusCreated).
+        JSON(&review)
+    s.POST(path, `{"rating": 2}`, apitest.As(bob)).
+        Status(http.StatusCreated)
+    if got := courseRating(t, s, course.ID); got != (rating{Average: 3.5, Count: 2}) {
+        t.Fatalf("rating %+v after two reviews", got)
+    }
+
+    s.POST(path, `{"rating": 4}`, apitest.As(alice)).
+        Status(http.StatusConflict).
+        Error("you have already reviewed this course")
+    s.PUT(path+"/"+review.ID, `{"rating": 3, "body": "Fine"}`, apitest.As(bob)).
+        Status(http.StatusForbidden)
+    s.PUT(path+"/"+review.ID, `{"rating": 4, "body": "Good"}`, apitest.As(alice)).
+        Status(http.StatusOK)
+    if got := courseRating(t, s, course.ID); got != (rating{Average: 3, Count: 2}) {
+        t.Fatalf("rating %+v after update", got)
+    }
+
+    var page []interface{}
+    s.GET(path+"?limit=1", apitest.As(bob)).Status(http.StatusOK).JSON(&page)
+    if len(page) != 1 {
+        t.Fatalf("%d reviews with limit=1", len(page))
+    }
+    s.DELETE(path+"/"+review.ID, apitest.As(bob)).
+        Status(http.StatusForbidden)
+    s.DELETE(path+"/"+review.ID, apitest.As(alice)).
+        Status(http.StatusNoContent)
+    if got := courseRating(t, s, course.ID); got != (rating{Average: 2, Count: 1}) {
+        t.Fatalf("rating %+v after delete", got)
+    }
+}
+
+func TestReviewValidation(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course()
+    user := s.User()
+    path := "/api/courses/" + course.ID + "/reviews"
+
+    s.POST(path, `{"rating": 6}`, apitest.As(user)).
+        Status(http.StatusBadRequest)
+    s.POST(path, `{"body": "no rating"}`, apitest.As(user)).
+        Status(http.StatusBadRequest)
+    s.POST("/api/courses/missing/reviews", `{"rating": 3}`, apitest.As(user)).
+        Status(http.StatusNotFound).
+        Error("course not found")
+    s.PUT(path+"/missing", `{"rating": 3}`, apitest.As(user)).
+        Status(http.StatusNotFound).
+        Error("review not found")
+}
+
+func TestReviewModeration(t *testing.T) {
+    s := apitest.New(t)
+    course := s.Course()
+    author, reader := s.User(), s.User()
+    path := "/api/courses/" + course.ID + "/reviews"
+
+    var review struct {
+        ID string `json:"id"`
+    }
+    s.POST(path, `{"rating": 1, "body": "spam"}`, apitest.As(author)).
+        Status(http.StatusCreated).
+        JSON(&review)
+    s.Tag(review.ID, "review-1")
+    s.POST(path+"/"+review.ID+"/flag", nil, apitest.As(reader)).
+        Status(http.StatusNoContent)
+    s.POST(path+"/"+review.ID+"/flag", nil, apitest.As(author)).
+        Status(http.StatusNoContent)
+    // a user's repeated flag counts once
+    s.POST(path+"/"+review.ID+"/flag", nil, apitest.As(reader)).
+        Status(http.StatusNoContent)
+
+    s.GET("/api/reviews/flagged", apitest.As(reader)).
+        Status(http.StatusForbidden)
+    var flagged []struct {
+        ID    string `json:"id"`
+        Flags int    `json:"flags"`
+    }
+    s.GET("/api/reviews/flagged").Status(http.StatusOK).JSON(&flagged)
+    if len(flagged) != 1 || flagged[0].ID != review.ID || flagged[0].Flags != 2 {
+        t.Fatalf("flagged reviews %+v", flagged)
+    }
+
+    s.PUT("/api/reviews/"+review.ID+"/moderation", `{"hidden": true}`).
+        Status(http.StatusOK).
+        Golden("review_hidden")
+    if got := courseRating(t, s, course.ID); got != (rating{}) {
+        t.Fatalf("rating %+v with the only review hidden", got)
+    }
+    var visible []interface{}
+    s.GET(path).Status(http.StatusOK).JSON(&visible)
+    if len(visible) != 0 {
+        t.Fatalf("%d visible reviews, want the hidden one left out", len(visible))
+    }
+    s.GET("/api/reviews/flagged").Status(http.StatusOK).JSON(&flagged)
+    if len(flagged) != 0 {
+        t.Fatalf("flags not cleared by moderation: %+v", flagged)
+    }
+    var flags int64
+    s.DB.WithContext(apitest.Context(tenant.Default)).Model(&model.ReviewFlag{}).Count(&flags)
+    if flags != 0 {
+        t.Fatalf("%d flag records left after moderation", flags)
+    }
+
+    s.PUT("/api/reviews/"+review.ID+"/moderation", `{"hidden": false}`).
+        Status(http.StatusOK)
+    if got := courseRating(t, s, course.ID); got != (rating{Average: 1, Count: 1}) {
+        t.Fatalf("rating %+v after restoring the review", got)
+    }
+    s.PUT("/api/reviews/"+review.ID+"/moderation", `{}`).
+        Status(http.StatusBadRequest)
+}
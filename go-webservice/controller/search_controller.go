@@ -0,0 +1,40 @@
+package controller
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+const (
+    defaultSearchLimit = 20
+    maxSearchLimit     = 100
+)
+
+// SearchCourses handles GET /api/courses/search?q=...&limit=...
+func SearchCourses(c *gin.Context) {
+    q := strings.TrimSpace(c.Query("q"))
+    if q == "" {
+        util.HandleError(c, http.StatusBadRequest, service.ErrEmptyQuery.Error())
+        return
+    }
+    limit := defaultSearchLimit
+    if raw := c.Query("limit"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 1 || n > maxSearchLimit {
+            util.HandleError(c, http.StatusBadRequest, "limit must be between 1 and 100")
+            return
+        }
+        limit = n
+    }
+    results, err := service.SearchCourses(c.Request.Context(), q, limit)
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, results)
+}
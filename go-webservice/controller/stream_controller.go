@@ -0,0 +1,60 @@
+package controller
+
+import (
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/events"
+)
+
+var streamHeartbeat = 15 * time.Second
+
+// StreamCourses serves course changes as Server-Sent Events. Clients that
+// reconnect with Last-Event-ID get the events they missed from the
+// broker's ring buffer; if those were evicted a "reset" event tells them
+// to reload the full listing.
+func StreamCourses(c *gin.Context) {
+    lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+    ch, backlog, complete, cancel := events.Default.Subscribe(c.GetString("tenant"), lastID)
+    defer cancel()
+
+    w := c.Writer
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(200)
+
+    fmt.Fprintf(w, "retry: %d\n\n", 3000)
+    if !complete {
+        fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+    }
+    for _, ev := range backlog {
+        writeEvent(c, ev)
+    }
+    w.Flush()
+
+    heartbeat := time.NewTicker(streamHeartbeat)
+    defer heartbeat.Stop()
+    for {
+        select {
+        case <-c.Request.Context().Done():
+            return
+        case ev, ok := <-ch:
+            if !ok {
+                // dropped for falling behind; the client will resume
+                return
+            }
+            writeEvent(c, ev)
+        case <-heartbeat.C:
+            fmt.Fprint(w, ": heartbeat\n\n")
+        }
+        w.Flush()
+    }
+}
+
+func writeEvent(c *gin.Context, ev events.Event) {
+    fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
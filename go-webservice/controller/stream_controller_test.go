@@ -0,0 +1,124 @@
+package controller_test
+
+import (
+    "bufio"
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "go-webservice/apitest"
+    "go-webservice/events"
+)
+
+type sseEvent struct {
+    id, event, data string
+}
+
+// sseStream is an open connection to the change feed.
+type sseStream struct {
+    t      *testing.T
+    r      *bufio.Reader
+    cancel context.CancelFunc
+}
+
+func openStream(t *testing.T, srv *httptest.Server, token, lastEventID string) *sseStream {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/courses/stream", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+    if lastEventID != "" {
+        req.Header.Set("Last-Event-ID", lastEventID)
+    }
+    resp, err := srv.Client().Do(req)
+    if err != nil {
+        cancel()
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        cancel()
+        resp.Body.Close()
+    })
+    if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+        t.Fatalf("stream responded %s, %s", resp.Status, resp.Header.Get("Content-Type"))
+    }
+    return &sseStream{t: t, r: bufio.NewReader(resp.Body), cancel: cancel}
+}
+
+// next returns the next event, skipping the retry hint and comments.
+func (s *sseStream) next() sseEvent {
+    s.t.Helper()
+    var ev sseEvent
+    for {
+        line, err := s.r.ReadString('\n')
+        if err != nil {
+            s.t.Fatalf("reading stream: %v", err)
+        }
+        line = strings.TrimSuffix(line, "\n")
+        field, value, _ := strings.Cut(line, ": ")
+        switch field {
+        case "id":
+            ev.id = value
+        case "event":
+            ev.event = value
+        case "data":
+            ev.data = value
+        case "":
+            if ev.event != "" {
+                return ev
+            }
+        }
+    }
+}
+
+func waitForSubscribers(t *testing.T, n int) {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for events.Default.Subscribers() != n {
+        if time.Now().After(deadline) {
+            t.Fatalf("%d subscribers, want %d", events.Default.Subscribers(), n)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestStreamReplaysMissedEvents(t *testing.T) {
+    s := apitest.New(t)
+    srv := httptest.NewServer(s.Handler)
+    t.Cleanup(srv.Close)
+    before := events.Default.Subscribers()
+
+    live := openStream(t, srv, s.Admin.Token, "")
+    s.POST("/api/v1/courses", `{"title": "First"}`).Status(http.StatusCreated)
+    s.POST("/api/v1/courses", `{"title": "Second"}`).Status(http.StatusCreated)
+    first, second := live.next(), live.next()
+    if first.event != "course.created" || !strings.Contains(first.data, `"title":"First"`) ||
+        !strings.Contains(second.data, `"title":"Second"`) {
+        t.Fatalf("events %+v, %+v", first, second)
+    }
+
+    // the server notices the client going away and unsubscribes it
+    live.cancel()
+    waitForSubscribers(t, before)
+
+    s.POST("/api/v1/courses", `{"title": "Missed"}`).Status(http.StatusCreated)
+    resumed := openStream(t, srv, s.Admin.Token, first.id)
+    replayed, missed := resumed.next(), resumed.next()
+    if replayed.id != second.id || !strings.Contains(missed.data, `"title":"Missed"`) {
+        t.Fatalf("resumed with %+v, %+v; want %s and the missed course", replayed, missed, second.id)
+    }
+}
+
+func TestStreamResetsStaleEventIDs(t *testing.T) {
+    s := apitest.New(t)
+    srv := httptest.NewServer(s.Handler)
+    t.Cleanup(srv.Close)
+
+    // an id from before a restart
+    stream := openStream(t, srv, s.Admin.Token, "42")
+    if ev := stream.next(); ev.event != "reset" {
+        t.Fatalf("first event %+v, want reset", ev)
+    }
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+    "go-webservice/util"
+    "go-webservice/webhook"
+)
+
+type webhookRequest struct {
+    URL    string `json:"url" binding:"required,url"`
+    Events string `json:"events"`
+    Secret string `json:"secret"`
+}
+
+func CreateWebhook(c *gin.Context) {
+    var req webhookRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    hook, err := service.RegisterWebhook(c.Request.Context(), req.URL, req.Events, req.Secret)
+    if errors.Is(err, webhook.ErrInvalidURL) {
+        util.HandleError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    // the secret is only ever returned on creation
+    c.JSON(http.StatusCreated, gin.H{"webhook": hook, "secret": hook.Secret})
+}
+
+func GetWebhooks(c *gin.Context) {
+    hooks, err := service.GetAllWebhooks(c.Request.Context())
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, hooks)
+}
+
+func DeleteWebhook(c *gin.Context) {
+    err := service.DeleteWebhook(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, service.ErrWebhookNotFound) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+func GetDeadLetters(c *gin.Context) {
+    deliveries, err := service.GetDeadLetters(c.Request.Context())
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, deliveries)
+}
+
+func RetryDelivery(c *gin.Context) {
+    delivery, err := service.RetryDelivery(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, service.ErrDeliveryNotFound) {
+        util.HandleError(c, http.StatusNotFound, err.Error())
+        return
+    }
+    if err != nil {
+        util.HandleError(c, http.StatusInternalServerError, err.Error())
+        return
+    }
+    c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+    "sync"
+    "time"
+)
+
+// Event is a change notification fanned out to live subscribers. IDs are
+// assigned by the broker and increase monotonically, so clients can resume
+// with the last ID they saw. The high 32 bits of an ID are the broker's
+// epoch, so IDs handed out before a restart, or by another replica, are
+// never mistaken for this broker's. Subscribers only ever see events of
+// their own tenant.
+type Event struct {
+    ID     uint64
+    Tenant string
+    Type   string
+    Data   []byte
+}
+
+// Broker keeps the most recent events in a fixed-size ring buffer and
+// pushes new ones to every subscriber of the event's tenant.
+type Broker struct {
+    mu     sync.Mutex
+    ring   []Event
+    start  int
+    size   int
+    epoch  uint64
+    lastID uint64
+    subs   map[chan Event]string
+}
+
+const subscriberBuffer = 64
+
+var Default = NewBroker(1024)
+
+// NewBroker returns a broker keeping the last capacity events, whose epoch
+// is the time it was created in seconds.
+func NewBroker(capacity int) *Broker {
+    return newBroker(capacity, uint64(time.Now().Unix()))
+}
+
+func newBroker(capacity int, epoch uint64) *Broker {
+    return &Broker{
+        ring:   make([]Event, capacity),
+        epoch:  epoch << 32,
+        lastID: epoch << 32,
+        subs:   make(map[chan Event]string),
+    }
+}
+
+func (b *Broker) Publish(tenant, eventType string, data []byte) Event {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.lastID++
+    ev := Event{ID: b.lastID, Tenant: tenant, Type: eventType, Data: data}
+    if b.size < len(b.ring) {
+        b.ring[(b.start+b.size)%len(b.ring)] = ev
+        b.size++
+    } else {
+        b.ring[b.start] = ev
+        b.start = (b.start + 1) % len(b.ring)
+    }
+
+    for ch, subTenant := range b.subs {
+        if subTenant != tenant {
+            continue
+        }
+        select {
+        case ch <- ev:
+        default:
+            // A subscriber that cannot keep up is dropped; it will reconnect
+            // with Last-Event-ID and replay from the ring.
+            delete(b.subs, ch)
+            close(ch)
+        }
+    }
+    return ev
+}
+
+// Subscribe registers a new subscriber for tenant and returns the buffered
+// events of that tenant published after lastID. complete is false when
+// some of those events have already been evicted from the ring. The
+// returned cancel func must be called when the subscriber goes away.
+func (b *Broker) Subscribe(tenant string, lastID uint64) (ch <-chan Event, backlog []Event, complete bool, cancel func()) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    complete = true
+    if lastID > 0 && b.size > 0 && b.ring[b.start].ID > lastID+1 {
+        complete = false
+    }
+    if lastID > 0 && (lastID < b.epoch || lastID > b.lastID) {
+        // an id from another epoch; nothing we can replay
+        complete = false
+        lastID = 0
+    }
+    if lastID > 0 {
+        for i := 0; i < b.size; i++ {
+            ev := b.ring[(b.start+i)%len(b.ring)]
+            if ev.ID > lastID && ev.Tenant == tenant {
+                backlog = append(backlog, ev)
+            }
+        }
+    }
+
+    c := make(chan Event, subscriberBuffer)
+    b.subs[c] = tenant
+    cancel = func() {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+        if _, ok := b.subs[c]; ok {
+            delete(b.subs, c)
+            close(c)
+        }
+    }
+    return c, backlog, complete, cancel
+}
+
+// Subscribers returns the number of live subscribers.
+func (b *Broker) Subscribers() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return len(b.subs)
+}
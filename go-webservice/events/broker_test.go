@@ -0,0 +1,125 @@
+package events
+
+import (
+    "fmt"
+    "testing"
+)
+
+const epoch = 1000
+
+func publish(b *Broker, tenant string, n int) []Event {
+    var published []Event
+    for i := 0; i < n; i++ {
+        published = append(published, b.Publish(tenant, "course.created", []byte(fmt.Sprint(i))))
+    }
+    return published
+}
+
+func ids(events []Event) []uint64 {
+    var out []uint64
+    for _, ev := range events {
+        out = append(out, ev.ID)
+    }
+    return out
+}
+
+func TestIDsCarryTheEpoch(t *testing.T) {
+    b := newBroker(4, epoch)
+    first := b.Publish("acme", "course.created", nil)
+    second := b.Publish("acme", "course.created", nil)
+    if first.ID != epoch<<32+1 || second.ID != first.ID+1 {
+        t.Fatalf("ids %d, %d", first.ID, second.ID)
+    }
+}
+
+func TestReplay(t *testing.T) {
+    b := newBroker(4, epoch)
+    published := publish(b, "acme", 3)
+    b.Publish("globex", "course.created", nil)
+
+    _, backlog, complete, cancel := b.Subscribe("acme", published[0].ID)
+    defer cancel()
+    if !complete || fmt.Sprint(ids(backlog)) != fmt.Sprint(ids(published[1:])) {
+        t.Fatalf("backlog %v (complete %v), want %v", ids(backlog), complete, ids(published[1:]))
+    }
+
+    _, backlog, complete, cancel = b.Subscribe("acme", 0)
+    defer cancel()
+    if !complete || len(backlog) != 0 {
+        t.Fatalf("new subscriber got backlog %v (complete %v)", ids(backlog), complete)
+    }
+}
+
+func TestReplayAfterEviction(t *testing.T) {
+    b := newBroker(4, epoch)
+    published := publish(b, "acme", 6)
+
+    // the ring holds the last four; the one after published[0] is gone
+    _, backlog, complete, cancel := b.Subscribe("acme", published[0].ID)
+    defer cancel()
+    if complete || fmt.Sprint(ids(backlog)) != fmt.Sprint(ids(published[2:])) {
+        t.Fatalf("backlog %v (complete %v), want incomplete %v", ids(backlog), complete, ids(published[2:]))
+    }
+
+    _, backlog, complete, cancel = b.Subscribe("acme", published[1].ID)
+    defer cancel()
+    if !complete || len(backlog) != 4 {
+        t.Fatalf("backlog %v (complete %v), want the whole ring", ids(backlog), complete)
+    }
+}
+
+func TestReplayFromAnotherEpoch(t *testing.T) {
+    old := newBroker(4, epoch-1)
+    stale := publish(old, "acme", 3)
+    b := newBroker(4, epoch)
+    publish(b, "acme", 2)
+
+    for _, id := range []uint64{stale[2].ID, epoch<<32 + 100, (epoch+1)<<32 + 1} {
+        _, backlog, complete, cancel := b.Subscribe("acme", id)
+        cancel()
+        if complete || len(backlog) != 0 {
+            t.Errorf("id %d: backlog %v (complete %v), want a reset", id, ids(backlog), complete)
+        }
+    }
+}
+
+func TestSubscribersSeeTheirTenant(t *testing.T) {
+    b := newBroker(4, epoch)
+    ch, _, _, cancel := b.Subscribe("acme", 0)
+    defer cancel()
+
+    b.Publish("globex", "course.created", nil)
+    ev := b.Publish("acme", "course.updated", nil)
+    if got := <-ch; got.ID != ev.ID || got.Tenant != "acme" {
+        t.Fatalf("got %+v, want %+v", got, ev)
+    }
+    select {
+    case got := <-ch:
+        t.Fatalf("unexpected %+v", got)
+    default:
+    }
+}
+
+func TestCancelAndSlowSubscribers(t *testing.T) {
+    b := newBroker(4, epoch)
+    ch, _, _, cancel := b.Subscribe("acme", 0)
+    slow, _, _, cancelSlow := b.Subscribe("acme", 0)
+    if b.Subscribers() != 2 {
+        t.Fatalf("%d subscribers", b.Subscribers())
+    }
+
+    cancel()
+    cancel()
+    if _, ok := <-ch; ok || b.Subscribers() != 1 {
+        t.Fatal("cancelled subscriber still registered")
+    }
+
+    publish(b, "acme", subscriberBuffer+1)
+    for i := 0; i < subscriberBuffer; i++ {
+        <-slow
+    }
+    if _, ok := <-slow; ok || b.Subscribers() != 0 {
+        t.Fatal("subscriber that fell behind was not dropped")
+    }
+    cancelSlow()
+}
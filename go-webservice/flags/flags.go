@@ -0,0 +1,86 @@
+// Package flags evaluates feature flags. A flag is switched on for a
+// subject (user, role and tenant) by explicit user lists, role and tenant
+// restrictions and a percentage rollout. Evaluation is a pure function of
+// the flag and the subject: the rollout bucket is a hash of the flag key
+// and the subject, so a subject always gets the same answer and tests can
+// rely on it.
+package flags
+
+import (
+    "context"
+    "hash/fnv"
+)
+
+// Flag is a flag definition, as read from the flags file or stored in the
+// database.
+type Flag struct {
+    Key         string `json:"key"`
+    Description string `json:"description,omitempty"`
+    // Enabled is the master switch; when false the flag is off for all.
+    Enabled bool `json:"enabled"`
+    // Rollout is the percentage (0-100) of subjects the flag is on for,
+    // among those that pass the role and tenant restrictions.
+    Rollout int `json:"rollout"`
+    // Users always get the flag while it is enabled.
+    Users []string `json:"users,omitempty"`
+    // Roles and Tenants restrict the flag when not empty.
+    Roles   []string `json:"roles,omitempty"`
+    Tenants []string `json:"tenants,omitempty"`
+}
+
+// Subject is who a flag is evaluated for.
+type Subject struct {
+    UserID string
+    Role   string
+    Tenant string
+}
+
+// On reports whether the flag is on for s.
+func (f Flag) On(s Subject) bool {
+    switch {
+    case !f.Enabled:
+        return false
+    case s.UserID != "" && contains(f.Users, s.UserID):
+        return true
+    case len(f.Roles) > 0 && !contains(f.Roles, s.Role):
+        return false
+    case len(f.Tenants) > 0 && !contains(f.Tenants, s.Tenant):
+        return false
+    }
+    return Bucket(f.Key, s) < f.Rollout
+}
+
+// Bucket places s in one of 100 buckets for key. Subjects without a user
+// ID are bucketed by tenant, so anonymous callers of one tenant agree.
+func Bucket(key string, s Subject) int {
+    id := "user:" + s.UserID
+    if s.UserID == "" {
+        id = "tenant:" + s.Tenant
+    }
+    h := fnv.New32a()
+    h.Write([]byte(key + "/" + id))
+    return int(h.Sum32() % 100)
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+type subjectKey struct{}
+
+// WithSubject stores the subject flags are evaluated for in ctx.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+    return context.WithValue(ctx, subjectKey{}, s)
+}
+
+// SubjectFrom returns the subject stored by WithSubject, or the zero
+// subject.
+func SubjectFrom(ctx context.Context) Subject {
+    s, _ := ctx.Value(subjectKey{}).(Subject)
+    return s
+}
@@ -0,0 +1,94 @@
+package flags
+
+import (
+    "context"
+    "fmt"
+    "testing"
+)
+
+func TestOn(t *testing.T) {
+    alice := Subject{UserID: "alice", Role: "user", Tenant: "acme"}
+    admin := Subject{UserID: "root", Role: "admin", Tenant: "default"}
+    tests := []struct {
+        name string
+        flag Flag
+        s    Subject
+        want bool
+    }{
+        {"disabled", Flag{Key: "f", Rollout: 100}, alice, false},
+        {"everyone", Flag{Key: "f", Enabled: true, Rollout: 100}, alice, true},
+        {"no one", Flag{Key: "f", Enabled: true}, alice, false},
+        {"listed user", Flag{Key: "f", Enabled: true, Users: []string{"alice"}}, alice, true},
+        {"listed user, disabled", Flag{Key: "f", Users: []string{"alice"}}, alice, false},
+        {"listed user skips restrictions", Flag{Key: "f", Enabled: true, Users: []string{"alice"}, Roles: []string{"admin"}}, alice, true},
+        {"role", Flag{Key: "f", Enabled: true, Rollout: 100, Roles: []string{"admin"}}, admin, true},
+        {"other role", Flag{Key: "f", Enabled: true, Rollout: 100, Roles: []string{"admin"}}, alice, false},
+        {"tenant", Flag{Key: "f", Enabled: true, Rollout: 100, Tenants: []string{"acme"}}, alice, true},
+        {"other tenant", Flag{Key: "f", Enabled: true, Rollout: 100, Tenants: []string{"acme"}}, admin, false},
+        {"anonymous", Flag{Key: "f", Enabled: true, Rollout: 100}, Subject{Tenant: "acme"}, true},
+        {"anonymous is not a listed user", Flag{Key: "f", Enabled: true, Users: []string{""}}, Subject{Tenant: "acme"}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := tt.flag.On(tt.s); got != tt.want {
+                t.Errorf("On(%+v) = %v, want %v", tt.s, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestRollout(t *testing.T) {
+    const subjects = 2000
+    on := func(rollout int) map[string]bool {
+        f := Flag{Key: "new-checkout", Enabled: true, Rollout: rollout}
+        got := make(map[string]bool)
+        for i := 0; i < subjects; i++ {
+            id := fmt.Sprintf("user-%d", i)
+            if f.On(Subject{UserID: id}) {
+                got[id] = true
+            }
+        }
+        return got
+    }
+    ten, fifty := on(10), on(50)
+    for rollout, got := range map[int]map[string]bool{10: ten, 50: fifty} {
+        want := subjects * rollout / 100
+        if n := len(got); n < want*8/10 || n > want*12/10 {
+            t.Errorf("rollout %d%%: on for %d of %d subjects", rollout, n, subjects)
+        }
+    }
+    // raising the rollout only adds subjects
+    for id := range ten {
+        if !fifty[id] {
+            t.Fatalf("%s has the flag at 10%% but not at 50%%", id)
+        }
+    }
+}
+
+func TestBucket(t *testing.T) {
+    s := Subject{UserID: "alice", Tenant: "acme"}
+    if Bucket("a", s) != Bucket("a", s) {
+        t.Fatal("bucket is not stable")
+    }
+    differs := false
+    for i := 0; i < 20 && !differs; i++ {
+        differs = Bucket(fmt.Sprintf("key-%d", i), s) != Bucket("key", s)
+    }
+    if !differs {
+        t.Error("bucket does not depend on the flag key")
+    }
+    // anonymous callers of a tenant share a bucket
+    if Bucket("a", Subject{Tenant: "acme", Role: "x"}) != Bucket("a", Subject{Tenant: "acme"}) {
+        t.Error("anonymous subjects of one tenant are bucketed apart")
+    }
+}
+
+func TestSubjectContext(t *testing.T) {
+    if got := SubjectFrom(context.Background()); got != (Subject{}) {
+        t.Fatalf("SubjectFrom(empty) = %+v", got)
+    }
+    s := Subject{UserID: "alice", Role: "user", Tenant: "acme"}
+    if got := SubjectFrom(WithSubject(context.Background(), s)); got != s {
+        t.Fatalf("SubjectFrom = %+v, want %+v", got, s)
+    }
+}
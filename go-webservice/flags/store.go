@@ -0,0 +1,130 @@
+package flags
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "sort"
+    "sync"
+)
+
+const (
+    SourceFile     = "file"
+    SourceDatabase = "database"
+)
+
+var ErrInvalidFlag = errors.New("flag needs a key and a rollout between 0 and 100")
+
+// Store holds the current flag definitions. Flags come from a file read
+// at startup and from the database; a database definition overrides the
+// file's for the same key, so flags can be changed at runtime. Unknown
+// flags are off.
+type Store struct {
+    mu       sync.RWMutex
+    file     map[string]Flag
+    database map[string]Flag
+}
+
+func NewStore(defs ...Flag) *Store {
+    s := &Store{file: make(map[string]Flag), database: make(map[string]Flag)}
+    for _, f := range defs {
+        s.file[f.Key] = f
+    }
+    return s
+}
+
+// Validate checks a definition.
+func Validate(f Flag) error {
+    if f.Key == "" || f.Rollout < 0 || f.Rollout > 100 {
+        return ErrInvalidFlag
+    }
+    return nil
+}
+
+// LoadFile reads a JSON array of flags as the file source.
+func (s *Store) LoadFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var defs []Flag
+    if err := json.Unmarshal(data, &defs); err != nil {
+        return fmt.Errorf("%s: %w", path, err)
+    }
+    file := make(map[string]Flag, len(defs))
+    for _, f := range defs {
+        if err := Validate(f); err != nil {
+            return fmt.Errorf("%s: %q: %w", path, f.Key, err)
+        }
+        file[f.Key] = f
+    }
+    s.mu.Lock()
+    s.file = file
+    s.mu.Unlock()
+    return nil
+}
+
+// SetDatabase replaces the database source.
+func (s *Store) SetDatabase(defs []Flag) {
+    database := make(map[string]Flag, len(defs))
+    for _, f := range defs {
+        database[f.Key] = f
+    }
+    s.mu.Lock()
+    s.database = database
+    s.mu.Unlock()
+}
+
+// Get returns the effective definition of key and where it came from.
+func (s *Store) Get(key string) (Flag, string, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    if f, ok := s.database[key]; ok {
+        return f, SourceDatabase, true
+    }
+    if f, ok := s.file[key]; ok {
+        return f, SourceFile, true
+    }
+    return Flag{}, "", false
+}
+
+// Enabled evaluates key for the subject in ctx.
+func (s *Store) Enabled(ctx context.Context, key string) bool {
+    f, _, ok := s.Get(key)
+    return ok && f.On(SubjectFrom(ctx))
+}
+
+// Entry is an effective flag with its source.
+type Entry struct {
+    Flag
+    Source string `json:"source"`
+}
+
+// All lists the effective flags by key.
+func (s *Store) All() []Entry {
+    s.mu.RLock()
+    entries := make([]Entry, 0, len(s.file)+len(s.database))
+    for key, f := range s.file {
+        if _, ok := s.database[key]; !ok {
+            entries = append(entries, Entry{Flag: f, Source: SourceFile})
+        }
+    }
+    for _, f := range s.database {
+        entries = append(entries, Entry{Flag: f, Source: SourceDatabase})
+    }
+    s.mu.RUnlock()
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+    return entries
+}
+
+// Evaluate returns every flag's value for the subject in ctx.
+func (s *Store) Evaluate(ctx context.Context) map[string]bool {
+    subject := SubjectFrom(ctx)
+    values := make(map[string]bool)
+    for _, e := range s.All() {
+        values[e.Key] = e.On(subject)
+    }
+    return values
+}
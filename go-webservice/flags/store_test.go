@@ -0,0 +1,85 @@
+package flags
+
+import (
+    "context"
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "flags.json")
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestStore(t *testing.T) {
+    s := NewStore(Flag{Key: "beta", Enabled: true, Rollout: 100})
+    err := s.LoadFile(writeFile(t, `[
+        {"key": "search", "enabled": true, "rollout": 100},
+        {"key": "reports", "enabled": true, "roles": ["admin"], "rollout": 100}
+    ]`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, _, ok := s.Get("beta"); ok {
+        t.Error("loading the file kept the definitions it replaced")
+    }
+
+    s.SetDatabase([]Flag{{Key: "search", Enabled: false}, {Key: "dark-mode", Enabled: true, Rollout: 100}})
+    if _, source, _ := s.Get("search"); source != SourceDatabase {
+        t.Errorf("search comes from %q, want the database override", source)
+    }
+    if _, source, _ := s.Get("reports"); source != SourceFile {
+        t.Errorf("reports comes from %q, want the file", source)
+    }
+
+    var keys []string
+    for _, e := range s.All() {
+        keys = append(keys, e.Key+"/"+e.Source)
+    }
+    if want := []string{"dark-mode/database", "reports/file", "search/database"}; !reflect.DeepEqual(keys, want) {
+        t.Errorf("All() = %v, want %v", keys, want)
+    }
+
+    ctx := WithSubject(context.Background(), Subject{UserID: "alice", Role: "user"})
+    want := map[string]bool{"dark-mode": true, "reports": false, "search": false}
+    if got := s.Evaluate(ctx); !reflect.DeepEqual(got, want) {
+        t.Errorf("Evaluate() = %v, want %v", got, want)
+    }
+    if s.Enabled(ctx, "unknown") {
+        t.Error("unknown flag is on")
+    }
+
+    // dropping the override brings the file definition back
+    s.SetDatabase(nil)
+    if !s.Enabled(ctx, "search") {
+        t.Error("search still off after its override was removed")
+    }
+}
+
+func TestLoadFileErrors(t *testing.T) {
+    s := NewStore(Flag{Key: "kept", Enabled: true, Rollout: 100})
+    for name, content := range map[string]string{
+        "syntax":  `[{"key": "a",`,
+        "no key":  `[{"enabled": true}]`,
+        "rollout": `[{"key": "a", "rollout": 101}]`,
+    } {
+        if err := s.LoadFile(writeFile(t, content)); err == nil {
+            t.Errorf("%s: loaded", name)
+        } else if name != "syntax" && !errors.Is(err, ErrInvalidFlag) {
+            t.Errorf("%s: %v, want ErrInvalidFlag", name, err)
+        }
+    }
+    if err := s.LoadFile(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("missing file: %v", err)
+    }
+    if _, _, ok := s.Get("kept"); !ok {
+        t.Error("a failed load replaced the flags")
+    }
+}
@@ -0,0 +1,24 @@
+package graph
+
+import (
+    "context"
+
+    "github.com/graphql-go/graphql"
+    "github.com/graphql-go/graphql/gqlerrors"
+)
+
+// Execute checks the query limits and runs it with fresh per-request
+// loaders. The schema is read-only; the router already requires the
+// courses:read scope, so resolvers need no further authorization.
+func Execute(ctx context.Context, query, operationName string, variables map[string]interface{}) *graphql.Result {
+    if err := CheckLimits(query, variables); err != nil {
+        return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())}}
+    }
+    return graphql.Do(graphql.Params{
+        Schema:         Schema,
+        RequestString:  query,
+        OperationName:  operationName,
+        VariableValues: variables,
+        Context:        withLoaders(ctx),
+    })
+}
@@ -0,0 +1,48 @@
+package graph_test
+
+import (
+    "sync/atomic"
+    "testing"
+
+    "go-webservice/apitest"
+    "go-webservice/graph"
+    "go-webservice/tenant"
+    "gorm.io/gorm"
+)
+
+// queries runs a query over n courses of two modules each and returns the
+// number of SQL queries it took.
+func queries(t *testing.T, n int) int64 {
+    t.Helper()
+    s := apitest.New(t)
+    for i := 0; i < n; i++ {
+        course := s.Course()
+        s.Module(course)
+        s.Module(course)
+    }
+
+    var count int64
+    err := s.DB.Callback().Query().After("gorm:query").Register("test:count", func(*gorm.DB) {
+        atomic.AddInt64(&count, 1)
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    result := graph.Execute(apitest.Context(tenant.Default),
+        `{ courses(first: 10) { id modules { id course { id title } } } }`, "", nil)
+    if len(result.Errors) > 0 {
+        t.Fatal(result.Errors)
+    }
+    if courses := result.Data.(map[string]interface{})["courses"].([]interface{}); len(courses) != n+1 {
+        t.Fatalf("%d courses, want %d", len(courses), n+1)
+    }
+    return atomic.LoadInt64(&count)
+}
+
+func TestBatchingAvoidsNPlusOne(t *testing.T) {
+    few, many := queries(t, 2), queries(t, 6)
+    // counting the page, then fetching it, its modules and their courses
+    if few == 0 || few != many || many > 4 {
+        t.Fatalf("%d queries for 3 courses, %d for 7; want the same few", few, many)
+    }
+}
@@ -0,0 +1,117 @@
+package graph
+
+import (
+    "fmt"
+
+    "github.com/graphql-go/graphql/language/ast"
+    "github.com/graphql-go/graphql/language/parser"
+)
+
+const (
+    MaxDepth      = 6
+    MaxComplexity = 1000
+    maxListSize   = 100
+)
+
+// listFields are the fields that return lists; the cost of their
+// selection set is multiplied by the number of items they may return. For
+// unpaginated lists the value is an estimate.
+var listFields = map[string]int{
+    "courses": defaultListSize,
+    "modules": 10,
+}
+
+// CheckLimits rejects queries that nest deeper than MaxDepth or whose
+// estimated cost exceeds MaxComplexity, before any resolver runs.
+func CheckLimits(query string, variables map[string]interface{}) error {
+    doc, err := parser.Parse(parser.ParseParams{Source: query})
+    if err != nil {
+        // let the executor report syntax errors in the usual format
+        return nil
+    }
+    fragments := map[string]*ast.FragmentDefinition{}
+    for _, def := range doc.Definitions {
+        if frag, ok := def.(*ast.FragmentDefinition); ok {
+            fragments[frag.Name.Value] = frag
+        }
+    }
+    for _, def := range doc.Definitions {
+        op, ok := def.(*ast.OperationDefinition)
+        if !ok {
+            continue
+        }
+        w := walker{fragments: fragments, variables: variables}
+        cost, depth := w.selectionSet(op.SelectionSet, 1, map[string]bool{})
+        if depth > MaxDepth {
+            return fmt.Errorf("query depth %d exceeds the limit of %d", depth, MaxDepth)
+        }
+        if cost > MaxComplexity {
+            return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, MaxComplexity)
+        }
+    }
+    return nil
+}
+
+type walker struct {
+    fragments map[string]*ast.FragmentDefinition
+    variables map[string]interface{}
+}
+
+// selectionSet returns the cost and maximum depth of a selection set.
+// visited guards against fragment cycles, which validation rejects later.
+func (w walker) selectionSet(set *ast.SelectionSet, depth int, visited map[string]bool) (int, int) {
+    if set == nil {
+        return 0, depth - 1
+    }
+    cost, maxDepth := 0, depth
+    for _, sel := range set.Selections {
+        var c, d int
+        switch sel := sel.(type) {
+        case *ast.Field:
+            c, d = w.selectionSet(sel.SelectionSet, depth+1, visited)
+            if size, ok := listFields[sel.Name.Value]; ok {
+                c *= w.listSize(sel, size)
+            }
+            c++
+        case *ast.InlineFragment:
+            c, d = w.selectionSet(sel.SelectionSet, depth, visited)
+        case *ast.FragmentSpread:
+            name := sel.Name.Value
+            frag, ok := w.fragments[name]
+            if !ok || visited[name] {
+                continue
+            }
+            visited[name] = true
+            c, d = w.selectionSet(frag.SelectionSet, depth, visited)
+            delete(visited, name)
+        }
+        cost += c
+        if d > maxDepth {
+            maxDepth = d
+        }
+    }
+    return cost, maxDepth
+}
+
+func (w walker) listSize(field *ast.Field, size int) int {
+    for _, arg := range field.Arguments {
+        if arg.Name.Value != "first" {
+            continue
+        }
+        switch v := arg.Value.(type) {
+        case *ast.IntValue:
+            fmt.Sscan(v.Value, &size)
+        case *ast.Variable:
+            if n, ok := w.variables[v.Name.Value].(float64); ok {
+                size = int(n)
+            }
+        }
+    }
+    if size > maxListSize {
+        size = maxListSize
+    }
+    if size < 1 {
+        size = 1
+    }
+    return size
+}
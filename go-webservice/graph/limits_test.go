@@ -0,0 +1,53 @@
+package graph
+
+import (
+    "context"
+    "strings"
+    "testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+    tests := []struct {
+        name      string
+        query     string
+        variables map[string]interface{}
+        err       string
+    }{
+        {"shallow", `{ courses { id title modules { title } } }`, nil, ""},
+        {"at the depth limit", `{ course(id: "1") { modules { course { modules { course { id } } } } } }`, nil, ""},
+        {"too deep", `{ course(id: "1") { modules { course { modules { course { modules { id } } } } } } }`, nil,
+            "query depth 7 exceeds the limit of 6"},
+        {"too deep through fragments", `
+            query { course(id: "1") { ...c } }
+            fragment c on Course { modules { course { modules { course { modules { id } } } } } }`, nil,
+            "query depth 7 exceeds the limit of 6"},
+        {"cyclic fragments", `
+            query { courses { ...a } }
+            fragment a on Course { modules { course { ...a } } }`, nil, ""},
+        // 100 courses of 10 modules of 1 course: 100 * (1 + 10 * (1 + 1)) + 1
+        {"too complex", `{ courses(first: 100) { id modules { course { id } } } }`, nil,
+            "query complexity 2201 exceeds the limit of 1000"},
+        {"list size from a variable", `query($n: Int) { courses(first: $n) { id modules { course { id } } } }`,
+            map[string]interface{}{"n": float64(40)}, ""},
+        {"list size capped", `query($n: Int) { courses(first: $n) { id modules { id } } }`,
+            map[string]interface{}{"n": float64(5000)}, "query complexity 1201 exceeds the limit of 1000"},
+        {"syntax error left to the executor", `{ courses {`, nil, ""},
+    }
+    for _, tt := range tests {
+        err := CheckLimits(tt.query, tt.variables)
+        switch {
+        case tt.err == "" && err != nil:
+            t.Errorf("%s: %v", tt.name, err)
+        case tt.err != "" && (err == nil || err.Error() != tt.err):
+            t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+        }
+    }
+}
+
+func TestExecuteRejectsBeforeResolving(t *testing.T) {
+    // withLoaders is never reached, so no database is needed
+    result := Execute(context.Background(), `{ courses(first: 100) { modules { course { modules { course { modules { id } } } } } } }`, "", nil)
+    if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "exceeds the limit") || result.Data != nil {
+        t.Fatalf("result %+v", result)
+    }
+}
@@ -0,0 +1,109 @@
+package graph
+
+import (
+    "context"
+    "go-webservice/model"
+    "go-webservice/service"
+    "sync"
+)
+
+// Loader batches lookups made while resolving one level of a query. Load
+// only records the key and returns a thunk; graphql-go runs the thunks after
+// the whole level has been resolved, so the first one to run fetches every
+// pending key with a single query. A failed fetch fails the keys of that
+// batch only; they are fetched again if loaded again.
+type Loader[V any] struct {
+    fetch   func(keys []string) (map[string]V, error)
+    mu      sync.Mutex
+    pending []string
+    queued  map[string]bool
+    cache   map[string]V
+    failed  map[string]error
+}
+
+func NewLoader[V any](fetch func(keys []string) (map[string]V, error)) *Loader[V] {
+    return &Loader[V]{
+        fetch:  fetch,
+        queued: make(map[string]bool),
+        cache:  make(map[string]V),
+        failed: make(map[string]error),
+    }
+}
+
+func (l *Loader[V]) Load(key string) func() (interface{}, error) {
+    l.mu.Lock()
+    if _, ok := l.cache[key]; !ok && !l.queued[key] {
+        l.pending = append(l.pending, key)
+        l.queued[key] = true
+        delete(l.failed, key)
+    }
+    l.mu.Unlock()
+
+    return func() (interface{}, error) {
+        l.mu.Lock()
+        defer l.mu.Unlock()
+        if len(l.pending) > 0 {
+            keys := l.pending
+            l.pending = nil
+            found, err := l.fetch(keys)
+            for _, k := range keys {
+                delete(l.queued, k)
+                if err != nil {
+                    l.failed[k] = err
+                } else {
+                    l.cache[k] = found[k]
+                }
+            }
+        }
+        if err, ok := l.failed[key]; ok {
+            return nil, err
+        }
+        return l.cache[key], nil
+    }
+}
+
+type loaders struct {
+    courses *Loader[*model.Course]
+    modules *Loader[[]model.Module]
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context) context.Context {
+    return context.WithValue(ctx, loadersKey{}, &loaders{
+        courses: NewLoader(func(ids []string) (map[string]*model.Course, error) {
+            return fetchCourses(ctx, ids)
+        }),
+        modules: NewLoader(func(ids []string) (map[string][]model.Module, error) {
+            return fetchModules(ctx, ids)
+        }),
+    })
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+    return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func fetchCourses(ctx context.Context, ids []string) (map[string]*model.Course, error) {
+    courses, err := service.GetCoursesByIDs(ctx, ids)
+    if err != nil {
+        return nil, err
+    }
+    byID := make(map[string]*model.Course, len(courses))
+    for i := range courses {
+        byID[courses[i].ID] = &courses[i]
+    }
+    return byID, nil
+}
+
+func fetchModules(ctx context.Context, courseIDs []string) (map[string][]model.Module, error) {
+    modules, err := service.GetModulesByCourseIDs(ctx, courseIDs)
+    if err != nil {
+        return nil, err
+    }
+    byCourse := make(map[string][]model.Module, len(courseIDs))
+    for _, m := range modules {
+        byCourse[m.CourseID] = append(byCourse[m.CourseID], m)
+    }
+    return byCourse, nil
+}
@@ -0,0 +1,77 @@
+package graph
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "reflect"
+    "testing"
+
+    "github.com/graphql-go/graphql"
+    "go-webservice/model"
+    "go-webservice/service"
+)
+
+func TestLoaderBatches(t *testing.T) {
+    var batches [][]string
+    l := NewLoader(func(keys []string) (map[string]string, error) {
+        batches = append(batches, keys)
+        found := map[string]string{}
+        for _, k := range keys {
+            found[k] = "v" + k
+        }
+        return found, nil
+    })
+
+    a, b, again := l.Load("a"), l.Load("b"), l.Load("a")
+    for key, thunk := range map[string]func() (interface{}, error){"a": a, "b": b, "a again": again} {
+        if v, err := thunk(); err != nil || v != "v"+key[:1] {
+            t.Fatalf("%s = %v, %v", key, v, err)
+        }
+    }
+    l.Load("a")()
+    if !reflect.DeepEqual(batches, [][]string{{"a", "b"}}) {
+        t.Fatalf("batches %v, want one for a and b", batches)
+    }
+}
+
+func TestLoaderErrorsArePerBatch(t *testing.T) {
+    fail := true
+    l := NewLoader(func(keys []string) (map[string]int, error) {
+        if fail {
+            return nil, errors.New("database down")
+        }
+        return map[string]int{"a": 1, "b": 2}, nil
+    })
+
+    if _, err := l.Load("a")(); err == nil {
+        t.Fatal("failed batch reported no error")
+    }
+    fail = false
+    // a later batch is unaffected, and the failed key is fetched again
+    if v, err := l.Load("b")(); err != nil || v != 2 {
+        t.Fatalf("b = %v, %v", v, err)
+    }
+    if v, err := l.Load("a")(); err != nil || v != 1 {
+        t.Fatalf("a after a failed batch = %v, %v", v, err)
+    }
+}
+
+func TestMissingModuleCourse(t *testing.T) {
+    ctx := context.WithValue(context.Background(), loadersKey{}, &loaders{
+        courses: NewLoader(func(ids []string) (map[string]*model.Course, error) {
+            return map[string]*model.Course{}, nil
+        }),
+    })
+    thunk, err := resolveModuleCourse(graphql.ResolveParams{Context: ctx, Source: &model.Module{CourseID: "gone"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    course, err := thunk.(func() (interface{}, error))()
+    if !errors.Is(err, service.ErrCourseNotFound) || course != nil {
+        t.Fatalf("missing course resolved to %v, %v", course, err)
+    }
+    if want := fmt.Sprintf("course gone: %v", service.ErrCourseNotFound); err.Error() != want {
+        t.Fatalf("error %q, want %q", err, want)
+    }
+}
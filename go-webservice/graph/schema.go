@@ -0,0 +1,149 @@
+package graph
+
+import (
+    "errors"
+    "fmt"
+    "go-webservice/model"
+    "go-webservice/service"
+
+    "github.com/graphql-go/graphql"
+)
+
+const defaultListSize = 20
+
+var courseType, moduleType *graphql.Object
+
+var Schema graphql.Schema
+
+func init() {
+    courseType = graphql.NewObject(graphql.ObjectConfig{
+        Name: "Course",
+        Fields: (graphql.FieldsThunk)(func() graphql.Fields {
+            return graphql.Fields{
+                "id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+                "title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+                "description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+                "published":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+                "createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveCourseCreatedAt},
+                "updatedAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveCourseUpdatedAt},
+                "modules": &graphql.Field{
+                    Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(moduleType))),
+                    Resolve: resolveCourseModules,
+                },
+            }
+        }),
+    })
+
+    moduleType = graphql.NewObject(graphql.ObjectConfig{
+        Name: "Module",
+        Fields: (graphql.FieldsThunk)(func() graphql.Fields {
+            return graphql.Fields{
+                "id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+                "title":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+                "position": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+                "course": &graphql.Field{
+                    Type:    graphql.NewNonNull(courseType),
+                    Resolve: resolveModuleCourse,
+                },
+            }
+        }),
+    })
+
+    queryType := graphql.NewObject(graphql.ObjectConfig{
+        Name: "Query",
+        Fields: graphql.Fields{
+            "course": &graphql.Field{
+                Type: courseType,
+                Args: graphql.FieldConfigArgument{
+                    "id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+                },
+                Resolve: resolveCourse,
+            },
+            "courses": &graphql.Field{
+                Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(courseType))),
+                Args: graphql.FieldConfigArgument{
+                    "first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListSize},
+                    "offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+                },
+                Resolve: resolveCourses,
+            },
+        },
+    })
+
+    var err error
+    Schema, err = graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+    if err != nil {
+        panic(err)
+    }
+}
+
+func resolveCourse(p graphql.ResolveParams) (interface{}, error) {
+    course, err := service.GetCourseByID(p.Context, p.Args["id"].(string))
+    if errors.Is(err, service.ErrCourseNotFound) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &course, nil
+}
+
+func resolveCourses(p graphql.ResolveParams) (interface{}, error) {
+    first, offset := p.Args["first"].(int), p.Args["offset"].(int)
+    if first < 0 || offset < 0 {
+        return nil, errors.New("first and offset must not be negative")
+    }
+    if first > maxListSize {
+        first = maxListSize
+    }
+    courses, _, err := service.GetCoursesPage(p.Context, first, offset, service.CourseSort{})
+    if err != nil {
+        return nil, err
+    }
+    result := make([]*model.Course, len(courses))
+    for i := range courses {
+        result[i] = &courses[i]
+    }
+    return result, nil
+}
+
+func resolveCourseCreatedAt(p graphql.ResolveParams) (interface{}, error) {
+    return p.Source.(*model.Course).CreatedAt, nil
+}
+
+func resolveCourseUpdatedAt(p graphql.ResolveParams) (interface{}, error) {
+    return p.Source.(*model.Course).UpdatedAt, nil
+}
+
+func resolveCourseModules(p graphql.ResolveParams) (interface{}, error) {
+    course := p.Source.(*model.Course)
+    thunk := loadersFrom(p.Context).modules.Load(course.ID)
+    return func() (interface{}, error) {
+        modules, err := thunk()
+        if err != nil {
+            return nil, err
+        }
+        list := modules.([]model.Module)
+        result := make([]*model.Module, len(list))
+        for i := range list {
+            result[i] = &list[i]
+        }
+        return result, nil
+    }, nil
+}
+
+func resolveModuleCourse(p graphql.ResolveParams) (interface{}, error) {
+    module := p.Source.(*model.Module)
+    thunk := loadersFrom(p.Context).courses.Load(module.CourseID)
+    return func() (interface{}, error) {
+        course, err := thunk()
+        if err != nil {
+            return nil, err
+        }
+        // course is non-null; a nil *model.Course would not read as null
+        if course := course.(*model.Course); course != nil {
+            return course, nil
+        }
+        return nil, fmt.Errorf("course %s: %w", module.CourseID, service.ErrCourseNotFound)
+    }, nil
+}
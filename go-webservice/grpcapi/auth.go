@@ -0,0 +1,111 @@
+package grpcapi
+
+import (
+    "context"
+    "crypto/tls"
+
+    "go-webservice/middleware"
+    "go-webservice/model"
+    coursev1 "go-webservice/proto/course/v1"
+    "go-webservice/service"
+    "go-webservice/tenant"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/peer"
+    "google.golang.org/grpc/status"
+)
+
+type principalKey struct{}
+
+// adminMethods mirrors the routes guarded by middleware.AdminOnly.
+var adminMethods = map[string]bool{
+    coursev1.CourseService_CreateCourse_FullMethodName: true,
+    coursev1.CourseService_UpdateCourse_FullMethodName: true,
+    coursev1.CourseService_DeleteCourse_FullMethodName: true,
+}
+
+// methodScopes mirrors the middleware.RequireScope checks on the routes.
+var methodScopes = map[string]string{
+    coursev1.CourseService_GetCourse_FullMethodName:    model.ScopeCoursesRead,
+    coursev1.CourseService_ListCourses_FullMethodName:  model.ScopeCoursesRead,
+    coursev1.CourseService_WatchCourses_FullMethodName: model.ScopeCoursesRead,
+    coursev1.CourseService_CreateCourse_FullMethodName: model.ScopeCoursesWrite,
+    coursev1.CourseService_UpdateCourse_FullMethodName: model.ScopeCoursesWrite,
+    coursev1.CourseService_DeleteCourse_FullMethodName: model.ScopeCoursesWrite,
+}
+
+// principalFrom returns the caller the auth interceptors authenticated,
+// as c.GetString("user_id") and friends do for the REST handlers.
+func principalFrom(ctx context.Context) middleware.Principal {
+    p, _ := ctx.Value(principalKey{}).(middleware.Principal)
+    return p
+}
+
+// authorize applies the same checks as middleware.AuthMiddleware,
+// middleware.TenantMiddleware and middleware.AdminOnly.
+func authorize(ctx context.Context, method string) (context.Context, error) {
+    var token, apiKey, host string
+    if md, ok := metadata.FromIncomingContext(ctx); ok {
+        if values := md.Get("authorization"); len(values) > 0 {
+            token = values[0]
+        }
+        if values := md.Get("x-api-key"); len(values) > 0 {
+            apiKey = values[0]
+        }
+        if values := md.Get(":authority"); len(values) > 0 {
+            host = values[0]
+        }
+    }
+    var state *tls.ConnectionState
+    if pr, ok := peer.FromContext(ctx); ok {
+        if info, ok := pr.AuthInfo.(credentials.TLSInfo); ok {
+            state = &info.State
+        }
+    }
+    p, err := middleware.Authenticate(ctx, token, apiKey, state)
+    if err != nil {
+        return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+    }
+    if adminMethods[method] && p.Role != "admin" {
+        return nil, status.Error(codes.PermissionDenied, "forbidden")
+    }
+    if scope, ok := methodScopes[method]; ok && !p.HasScope(scope) {
+        return nil, status.Error(codes.PermissionDenied, "missing scope "+scope)
+    }
+    id, err := middleware.ResolveTenant(p, host)
+    if err != nil {
+        return nil, status.Error(codes.PermissionDenied, err.Error())
+    }
+    ctx = tenant.WithTenant(ctx, id)
+    if _, err := service.GetTenant(ctx, id); err != nil {
+        return nil, status.Error(codes.NotFound, "unknown tenant")
+    }
+    return context.WithValue(ctx, principalKey{}, p), nil
+}
+
+func UnaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    ctx, err := authorize(ctx, info.FullMethod)
+    if err != nil {
+        return nil, err
+    }
+    return handler(ctx, req)
+}
+
+func StreamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    ctx, err := authorize(ss.Context(), info.FullMethod)
+    if err != nil {
+        return err
+    }
+    return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
+
+type authStream struct {
+    grpc.ServerStream
+    ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+    return s.ctx
+}
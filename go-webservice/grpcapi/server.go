@@ -0,0 +1,214 @@
+package grpcapi
+
+import (
+    "context"
+    "crypto/tls"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "net"
+    "strconv"
+    "strings"
+
+    "go-webservice/events"
+    "go-webservice/model"
+    coursev1 "go-webservice/proto/course/v1"
+    "go-webservice/replica"
+    "go-webservice/service"
+    "go-webservice/tenant"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/types/known/emptypb"
+    "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+    defaultPageSize = 50
+    maxPageSize     = 500
+)
+
+type courseServer struct {
+    coursev1.UnimplementedCourseServiceServer
+}
+
+// NewServer returns a gRPC server with CourseService registered behind the
+// auth interceptors. Each call gets its own replica session.
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+    opts = append(opts,
+        grpc.ChainUnaryInterceptor(unarySession, UnaryAuthInterceptor),
+        grpc.ChainStreamInterceptor(streamSession, StreamAuthInterceptor),
+    )
+    s := grpc.NewServer(opts...)
+    coursev1.RegisterCourseServiceServer(s, &courseServer{})
+    return s
+}
+
+func unarySession(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    return handler(replica.WithSession(ctx), req)
+}
+
+func streamSession(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    return handler(srv, &authStream{ServerStream: ss, ctx: replica.WithSession(ss.Context())})
+}
+
+// Serve listens on addr and blocks serving gRPC requests, over TLS when
+// tlsConfig is not nil.
+func Serve(addr string, tlsConfig *tls.Config) error {
+    lis, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    var opts []grpc.ServerOption
+    if tlsConfig != nil {
+        opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+    }
+    return NewServer(opts...).Serve(lis)
+}
+
+func (s *courseServer) GetCourse(ctx context.Context, req *coursev1.GetCourseRequest) (*coursev1.Course, error) {
+    course, err := service.GetCourseByID(ctx, req.GetId())
+    if err != nil {
+        return nil, toStatus(err)
+    }
+    return toProto(course), nil
+}
+
+func (s *courseServer) ListCourses(ctx context.Context, req *coursev1.ListCoursesRequest) (*coursev1.ListCoursesResponse, error) {
+    size := int(req.GetPageSize())
+    if size <= 0 {
+        size = defaultPageSize
+    }
+    if size > maxPageSize {
+        size = maxPageSize
+    }
+    offset, err := decodePageToken(req.GetPageToken())
+    if err != nil {
+        return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+    }
+    courses, total, err := service.GetCoursesPage(ctx, size, offset, service.CourseSort{})
+    if err != nil {
+        return nil, toStatus(err)
+    }
+    resp := &coursev1.ListCoursesResponse{TotalSize: total}
+    for _, course := range courses {
+        resp.Courses = append(resp.Courses, toProto(course))
+    }
+    if next := offset + len(courses); int64(next) < total && len(courses) > 0 {
+        resp.NextPageToken = encodePageToken(next)
+    }
+    return resp, nil
+}
+
+func (s *courseServer) CreateCourse(ctx context.Context, req *coursev1.CreateCourseRequest) (*coursev1.Course, error) {
+    if strings.TrimSpace(req.GetTitle()) == "" {
+        return nil, status.Error(codes.InvalidArgument, "title is required")
+    }
+    course, err := service.CreateCourse(ctx, model.Course{
+        Title:       req.GetTitle(),
+        Description: req.GetDescription(),
+        AuthorID:    principalFrom(ctx).Subject,
+    })
+    if err != nil {
+        return nil, toStatus(err)
+    }
+    return toProto(course), nil
+}
+
+func (s *courseServer) UpdateCourse(ctx context.Context, req *coursev1.UpdateCourseRequest) (*coursev1.Course, error) {
+    if strings.TrimSpace(req.GetTitle()) == "" {
+        return nil, status.Error(codes.InvalidArgument, "title is required")
+    }
+    course, err := service.UpdateCourse(ctx, req.GetId(), model.Course{Title: req.GetTitle(), Description: req.GetDescription()})
+    if err != nil {
+        return nil, toStatus(err)
+    }
+    return toProto(course), nil
+}
+
+func (s *courseServer) DeleteCourse(ctx context.Context, req *coursev1.DeleteCourseRequest) (*emptypb.Empty, error) {
+    if err := service.DeleteCourse(ctx, req.GetId()); err != nil {
+        return nil, toStatus(err)
+    }
+    return &emptypb.Empty{}, nil
+}
+
+func (s *courseServer) WatchCourses(req *coursev1.WatchCoursesRequest, stream coursev1.CourseService_WatchCoursesServer) error {
+    tenantID, _ := tenant.FromContext(stream.Context())
+    ch, backlog, complete, cancel := events.Default.Subscribe(tenantID, req.GetLastEventId())
+    defer cancel()
+
+    if !complete {
+        if err := stream.Send(&coursev1.CourseEvent{MissedEvents: true}); err != nil {
+            return err
+        }
+    }
+    for _, ev := range backlog {
+        if err := sendEvent(stream, ev); err != nil {
+            return err
+        }
+    }
+    for {
+        select {
+        case <-stream.Context().Done():
+            return nil
+        case ev, ok := <-ch:
+            if !ok {
+                return status.Error(codes.ResourceExhausted, "subscriber fell behind, resume with last_event_id")
+            }
+            if err := sendEvent(stream, ev); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+func sendEvent(stream coursev1.CourseService_WatchCoursesServer, ev events.Event) error {
+    var envelope model.EventEnvelope
+    if err := json.Unmarshal(ev.Data, &envelope); err != nil {
+        return status.Error(codes.Internal, err.Error())
+    }
+    var course model.Course
+    if err := json.Unmarshal(envelope.Data, &course); err != nil {
+        return status.Error(codes.Internal, err.Error())
+    }
+    return stream.Send(&coursev1.CourseEvent{Id: ev.ID, Type: ev.Type, Course: toProto(course)})
+}
+
+func toProto(course model.Course) *coursev1.Course {
+    return &coursev1.Course{
+        Id:          course.ID,
+        Title:       course.Title,
+        Description: course.Description,
+        Published:   course.Published,
+        CreatedAt:   timestamppb.New(course.CreatedAt),
+        UpdatedAt:   timestamppb.New(course.UpdatedAt),
+    }
+}
+
+func toStatus(err error) error {
+    if errors.Is(err, service.ErrCourseNotFound) {
+        return status.Error(codes.NotFound, err.Error())
+    }
+    return status.Error(codes.Internal, err.Error())
+}
+
+func encodePageToken(offset int) string {
+    return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+    if token == "" {
+        return 0, nil
+    }
+    raw, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil {
+        return 0, err
+    }
+    offset, err := strconv.Atoi(string(raw))
+    if err != nil || offset < 0 {
+        return 0, errors.New("invalid offset")
+    }
+    return offset, nil
+}
@@ -0,0 +1,204 @@
+package grpcapi_test
+
+import (
+    "context"
+    "net"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/glebarez/sqlite"
+    "go-webservice/config"
+    "go-webservice/events"
+    "go-webservice/grpcapi"
+    "go-webservice/middleware"
+    "go-webservice/model"
+    coursev1 "go-webservice/proto/course/v1"
+    "go-webservice/tenant"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/grpc/test/bufconn"
+    "gorm.io/gorm"
+)
+
+// dial serves CourseService over an in-memory connection, backed by a fresh
+// SQLite database, for the test.
+func dial(t *testing.T) coursev1.CourseServiceClient {
+    t.Helper()
+    db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    oldDB, oldSearch := config.DB, config.Search
+    t.Cleanup(func() { config.DB, config.Search = oldDB, oldSearch })
+    if err := config.UseDatabase(db); err != nil {
+        t.Fatal(err)
+    }
+
+    lis := bufconn.Listen(1 << 20)
+    srv := grpcapi.NewServer()
+    go srv.Serve(lis)
+    t.Cleanup(srv.Stop)
+
+    conn, err := grpc.Dial("bufnet",
+        grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+            return lis.DialContext(ctx)
+        }),
+        grpc.WithTransportCredentials(insecure.NewCredentials()),
+    )
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return coursev1.NewCourseServiceClient(conn)
+}
+
+func authed() context.Context {
+    return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+}
+
+func wantCode(t *testing.T, err error, code codes.Code) {
+    t.Helper()
+    if status.Code(err) != code {
+        t.Fatalf("error %v, want code %s", err, code)
+    }
+}
+
+func TestCourseLifecycle(t *testing.T) {
+    client := dial(t)
+    ctx := authed()
+
+    created, err := client.CreateCourse(ctx, &coursev1.CreateCourseRequest{Title: "gRPC", Description: "Over bufconn"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    got, err := client.GetCourse(ctx, &coursev1.GetCourseRequest{Id: created.Id})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Title != "gRPC" || got.Description != "Over bufconn" {
+        t.Fatalf("got %v", got)
+    }
+
+    updated, err := client.UpdateCourse(ctx, &coursev1.UpdateCourseRequest{Id: created.Id, Title: "gRPC 2"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if updated.Title != "gRPC 2" {
+        t.Fatalf("updated title %q", updated.Title)
+    }
+    if _, err := client.DeleteCourse(ctx, &coursev1.DeleteCourseRequest{Id: created.Id}); err != nil {
+        t.Fatal(err)
+    }
+    _, err = client.GetCourse(ctx, &coursev1.GetCourseRequest{Id: created.Id})
+    wantCode(t, err, codes.NotFound)
+
+    _, err = client.CreateCourse(ctx, &coursev1.CreateCourseRequest{Title: " "})
+    wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestCreateCourseRecordsAuthor(t *testing.T) {
+    client := dial(t)
+    old := middleware.JWTSecret
+    middleware.JWTSecret = "grpc-test"
+    t.Cleanup(func() { middleware.JWTSecret = old })
+
+    token, err := middleware.IssueToken("alice", "admin", "", time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+    created, err := client.CreateCourse(ctx, &coursev1.CreateCourseRequest{Title: "Authored"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    var course model.Course
+    err = config.DB.WithContext(tenant.WithTenant(context.Background(), tenant.Default)).
+        First(&course, "id = ?", created.Id).Error
+    if err != nil || course.AuthorID != "alice" {
+        t.Fatalf("course %+v, %v; want author alice", course, err)
+    }
+
+    user, _ := middleware.IssueToken("bob", "user", "", time.Hour)
+    ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+user)
+    _, err = client.CreateCourse(ctx, &coursev1.CreateCourseRequest{Title: "Nope"})
+    wantCode(t, err, codes.PermissionDenied)
+}
+
+func TestListCoursesPages(t *testing.T) {
+    client := dial(t)
+    ctx := authed()
+    for i := 0; i < 4; i++ {
+        if _, err := client.CreateCourse(ctx, &coursev1.CreateCourseRequest{Title: "Course"}); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    seen := map[string]bool{}
+    token := ""
+    for pages := 0; ; pages++ {
+        if pages > 3 {
+            t.Fatal("too many pages")
+        }
+        resp, err := client.ListCourses(ctx, &coursev1.ListCoursesRequest{PageSize: 2, PageToken: token})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if resp.TotalSize != 5 {
+            t.Fatalf("total %d, want 5", resp.TotalSize)
+        }
+        for _, c := range resp.Courses {
+            seen[c.Id] = true
+        }
+        if token = resp.NextPageToken; token == "" {
+            break
+        }
+    }
+    if len(seen) != 5 {
+        t.Fatalf("saw %d courses, want 5", len(seen))
+    }
+
+    _, err := client.ListCourses(ctx, &coursev1.ListCoursesRequest{PageToken: "!"})
+    wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestUnauthenticated(t *testing.T) {
+    client := dial(t)
+
+    _, err := client.GetCourse(context.Background(), &coursev1.GetCourseRequest{Id: "1"})
+    wantCode(t, err, codes.Unauthenticated)
+    _, err = client.CreateCourse(context.Background(), &coursev1.CreateCourseRequest{Title: "Nope"})
+    wantCode(t, err, codes.Unauthenticated)
+}
+
+func TestWatchCourses(t *testing.T) {
+    client := dial(t)
+    ctx, cancel := context.WithTimeout(authed(), 5*time.Second)
+    defer cancel()
+
+    before := events.Default.Subscribers()
+    stream, err := client.WatchCourses(ctx, &coursev1.WatchCoursesRequest{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for events.Default.Subscribers() == before {
+        if ctx.Err() != nil {
+            t.Fatal("watcher never subscribed")
+        }
+        time.Sleep(time.Millisecond)
+    }
+
+    if _, err := client.CreateCourse(ctx, &coursev1.CreateCourseRequest{Title: "Watched"}); err != nil {
+        t.Fatal(err)
+    }
+    ev, err := stream.Recv()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if ev.Type != "course.created" || ev.Course.GetTitle() != "Watched" {
+        t.Fatalf("event %v", ev)
+    }
+}
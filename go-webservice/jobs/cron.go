@@ -0,0 +1,185 @@
+package jobs
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// schedule computes the next run after a time. All times are UTC.
+type schedule interface {
+    Next(after time.Time) time.Time
+}
+
+type scheduled struct {
+    name     string
+    schedule schedule
+    jobType  string
+    payload  interface{}
+}
+
+var (
+    schedulesMu sync.Mutex
+    schedules   []scheduled
+)
+
+// Schedule enqueues a job of jobType with payload whenever spec comes due.
+// spec is a five-field cron expression (minute hour day-of-month month
+// day-of-week, in UTC), one of @hourly, @daily, @weekly and @monthly, or
+// "@every <duration>". Scheduled jobs belong to the default tenant. Like
+// regexp.MustCompile it panics on a bad spec, since schedules are fixed
+// at startup.
+func Schedule(name, spec, jobType string, payload interface{}) {
+    s, err := parseSchedule(spec)
+    if err != nil {
+        panic(fmt.Sprintf("jobs: schedule %s: %v", name, err))
+    }
+    schedulesMu.Lock()
+    defer schedulesMu.Unlock()
+    schedules = append(schedules, scheduled{name: name, schedule: s, jobType: jobType, payload: payload})
+}
+
+func registeredSchedules() []scheduled {
+    schedulesMu.Lock()
+    defer schedulesMu.Unlock()
+    return append([]scheduled(nil), schedules...)
+}
+
+// every runs at multiples of an interval since the epoch, so every
+// instance agrees on when a run is due.
+type every time.Duration
+
+func (e every) Next(after time.Time) time.Time {
+    d := time.Duration(e)
+    return after.UTC().Truncate(d).Add(d)
+}
+
+type cron struct {
+    minute, hour, dom, month, dow uint64
+    domAny, dowAny                bool
+}
+
+var descriptors = map[string]string{
+    "@hourly":  "0 * * * *",
+    "@daily":   "0 0 * * *",
+    "@weekly":  "0 0 * * 0",
+    "@monthly": "0 0 1 * *",
+}
+
+func parseSchedule(spec string) (schedule, error) {
+    spec = strings.TrimSpace(spec)
+    if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+        d, err := time.ParseDuration(strings.TrimSpace(rest))
+        if err != nil || d < time.Second {
+            return nil, fmt.Errorf("bad interval %q", rest)
+        }
+        return every(d), nil
+    }
+    if expr, ok := descriptors[spec]; ok {
+        spec = expr
+    }
+    fields := strings.Fields(spec)
+    if len(fields) != 5 {
+        return nil, fmt.Errorf("want 5 fields, got %d", len(fields))
+    }
+    var c cron
+    var err error
+    if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+        return nil, err
+    }
+    if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+        return nil, err
+    }
+    if c.dom, err = parseField(fields[2], 1, 31); err != nil {
+        return nil, err
+    }
+    if c.month, err = parseField(fields[3], 1, 12); err != nil {
+        return nil, err
+    }
+    if c.dow, err = parseField(fields[4], 0, 7); err != nil {
+        return nil, err
+    }
+    if c.dow&(1<<7) != 0 {
+        c.dow |= 1 // 7 is Sunday too
+    }
+    c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+    return c, nil
+}
+
+// parseField turns a comma separated list of values, ranges (a-b) and
+// steps (*/n, a-b/n) into a bit set.
+func parseField(field string, min, max int) (uint64, error) {
+    var bits uint64
+    for _, part := range strings.Split(field, ",") {
+        rng, stepStr, hasStep := strings.Cut(part, "/")
+        step := 1
+        if hasStep {
+            n, err := strconv.Atoi(stepStr)
+            if err != nil || n < 1 {
+                return 0, fmt.Errorf("bad step in %q", part)
+            }
+            step = n
+        }
+        lo, hi := min, max
+        if rng != "*" {
+            a, b, isRange := strings.Cut(rng, "-")
+            var err error
+            if lo, err = strconv.Atoi(a); err != nil {
+                return 0, fmt.Errorf("bad value in %q", part)
+            }
+            hi = lo
+            if isRange {
+                if hi, err = strconv.Atoi(b); err != nil {
+                    return 0, fmt.Errorf("bad value in %q", part)
+                }
+            } else if hasStep {
+                hi = max
+            }
+        }
+        if lo < min || hi > max || lo > hi {
+            return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+        }
+        for v := lo; v <= hi; v += step {
+            bits |= 1 << uint(v)
+        }
+    }
+    return bits, nil
+}
+
+func (c cron) Next(after time.Time) time.Time {
+    t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+    limit := t.Year() + 5
+    for t.Year() <= limit {
+        switch {
+        case c.month&(1<<uint(t.Month())) == 0:
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+        case !c.dayMatches(t):
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+        case c.hour&(1<<uint(t.Hour())) == 0:
+            t = t.Truncate(time.Hour).Add(time.Hour)
+        case c.minute&(1<<uint(t.Minute())) == 0:
+            t = t.Add(time.Minute)
+        default:
+            return t
+        }
+    }
+    return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted, either
+// may match.
+func (c cron) dayMatches(t time.Time) bool {
+    dom := c.dom&(1<<uint(t.Day())) != 0
+    dow := c.dow&(1<<uint(t.Weekday())) != 0
+    switch {
+    case c.domAny && c.dowAny:
+        return true
+    case c.domAny:
+        return dow
+    case c.dowAny:
+        return dom
+    }
+    return dom || dow
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+    "testing"
+    "time"
+)
+
+func TestScheduleNext(t *testing.T) {
+    // a Wednesday
+    from := time.Date(2026, time.January, 14, 10, 30, 15, 0, time.UTC)
+    tests := []struct {
+        spec string
+        want time.Time
+    }{
+        {"* * * * *", time.Date(2026, 1, 14, 10, 31, 0, 0, time.UTC)},
+        {"@hourly", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+        {"@daily", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+        {"@weekly", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+        {"@monthly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+        {"*/15 * * * *", time.Date(2026, 1, 14, 10, 45, 0, 0, time.UTC)},
+        {"0 9-17/4 * * *", time.Date(2026, 1, 14, 13, 0, 0, 0, time.UTC)},
+        {"30 3 * * 1-5", time.Date(2026, 1, 15, 3, 30, 0, 0, time.UTC)},
+        {"0 0 * * 7", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+        {"0 0 31 * *", time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)},
+        {"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+        {"5,10 12 * 3 *", time.Date(2026, 3, 1, 12, 5, 0, 0, time.UTC)},
+        // both day fields restricted: either may match
+        {"0 0 20 * 5", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+        {"@every 1h", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+        {"@every 90s", time.Date(2026, 1, 14, 10, 31, 30, 0, time.UTC)},
+    }
+    for _, tt := range tests {
+        s, err := parseSchedule(tt.spec)
+        if err != nil {
+            t.Errorf("%s: %v", tt.spec, err)
+            continue
+        }
+        if got := s.Next(from); !got.Equal(tt.want) {
+            t.Errorf("%s: next %s, want %s", tt.spec, got, tt.want)
+        }
+    }
+}
+
+func TestScheduleNeverDue(t *testing.T) {
+    s, err := parseSchedule("0 0 30 2 *")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if next := s.Next(time.Now()); !next.IsZero() {
+        t.Fatalf("February 30th came due at %s", next)
+    }
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+    for _, spec := range []string{
+        "",
+        "* * * *",
+        "* * * * * *",
+        "60 * * * *",
+        "* 24 * * *",
+        "* * 0 * *",
+        "* * * 13 *",
+        "* * * * 8",
+        "*/0 * * * *",
+        "5-1 * * * *",
+        "a * * * *",
+        "@yearly",
+        "@every",
+        "@every 500ms",
+        "@every soon",
+    } {
+        if _, err := parseSchedule(spec); err == nil {
+            t.Errorf("%q parsed", spec)
+        }
+    }
+}
+
+func TestSchedulePanicsOnBadSpec(t *testing.T) {
+    defer func() {
+        if recover() == nil {
+            t.Fatal("no panic")
+        }
+    }()
+    Schedule("broken", "not a spec", "noop", nil)
+}
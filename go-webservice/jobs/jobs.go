@@ -0,0 +1,103 @@
+// Package jobs runs background work stored in the jobs table. Handlers are
+// registered per job type with a typed payload; Enqueue stores a job in
+// the caller's transaction, and a Pool of workers claims and runs due jobs,
+// retrying failures with backoff.
+package jobs
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "go-webservice/model"
+    "sync"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+const DefaultMaxAttempts = 5
+
+// Handler runs one job. The result, if not nil, is stored on the job as
+// JSON for whoever enqueued it. The context carries the job's tenant.
+type Handler[T any] func(ctx context.Context, payload T) (interface{}, error)
+
+type rawHandler func(ctx context.Context, payload []byte) (interface{}, error)
+
+var (
+    mu       sync.RWMutex
+    handlers = make(map[string]rawHandler)
+)
+
+// Register installs fn for jobs of jobType, decoding payloads into T.
+func Register[T any](jobType string, fn Handler[T]) {
+    mu.Lock()
+    defer mu.Unlock()
+    handlers[jobType] = func(ctx context.Context, raw []byte) (interface{}, error) {
+        var payload T
+        if err := json.Unmarshal(raw, &payload); err != nil {
+            return nil, Permanent(fmt.Errorf("decode payload: %w", err))
+        }
+        return fn(ctx, payload)
+    }
+}
+
+func lookup(jobType string) (rawHandler, bool) {
+    mu.RLock()
+    defer mu.RUnlock()
+    h, ok := handlers[jobType]
+    return h, ok
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying; the job fails at once.
+func Permanent(err error) error {
+    return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+    var p permanentError
+    return errors.As(err, &p)
+}
+
+// Option adjusts a job before it is enqueued.
+type Option func(*model.Job)
+
+// At delays the job until t.
+func At(t time.Time) Option {
+    return func(j *model.Job) { j.RunAt = t.UTC() }
+}
+
+// MaxAttempts sets how many times the job may run before it fails.
+func MaxAttempts(n int) Option {
+    return func(j *model.Job) { j.MaxAttempts = n }
+}
+
+func uniqueKey(key string) Option {
+    return func(j *model.Job) { j.UniqueKey = &key }
+}
+
+// Enqueue stores a job using tx, so it is only queued if the caller's
+// transaction commits. The job belongs to the tenant of tx's context.
+func Enqueue(tx *gorm.DB, jobType string, payload interface{}, opts ...Option) (model.Job, error) {
+    raw, err := json.Marshal(payload)
+    if err != nil {
+        return model.Job{}, err
+    }
+    job := model.Job{
+        Type:        jobType,
+        Payload:     string(raw),
+        Status:      model.JobQueued,
+        RunAt:       time.Now().UTC(),
+        MaxAttempts: DefaultMaxAttempts,
+    }
+    for _, opt := range opts {
+        opt(&job)
+    }
+    err = tx.Create(&job).Error
+    return job, err
+}
@@ -0,0 +1,279 @@
+package jobs
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "log"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// Pool runs registered handlers for due jobs. Several pools, in one
+// process or many, can share the table: on Postgres a job is claimed with
+// SELECT ... FOR UPDATE SKIP LOCKED so workers never wait on each other.
+type Pool struct {
+    DB           *gorm.DB
+    Workers      int
+    PollInterval time.Duration
+    // Timeout bounds a single run of a handler.
+    Timeout time.Duration
+    // LockTimeout is how long a job may stay running before it is
+    // assumed lost with its worker and queued again.
+    LockTimeout time.Duration
+    BaseBackoff time.Duration
+    MaxBackoff  time.Duration
+    Now         func() time.Time
+    ID          string
+}
+
+func NewPool(db *gorm.DB) *Pool {
+    workers := 4
+    if s := os.Getenv("JOB_WORKERS"); s != "" {
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 1 {
+            log.Fatalf("invalid JOB_WORKERS %q", s)
+        }
+        workers = n
+    }
+    host, _ := os.Hostname()
+    return &Pool{
+        DB:           db,
+        Workers:      workers,
+        PollInterval: time.Second,
+        Timeout:      10 * time.Minute,
+        LockTimeout:  30 * time.Minute,
+        BaseBackoff:  10 * time.Second,
+        MaxBackoff:   time.Hour,
+        Now:          func() time.Time { return time.Now().UTC() },
+        ID:           host + "-" + uuid.NewString()[:8],
+    }
+}
+
+// Run starts the workers and the scheduler and blocks until ctx is
+// cancelled and every running job has finished.
+func (p *Pool) Run(ctx context.Context) {
+    var wg sync.WaitGroup
+    for i := 0; i < p.Workers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            p.work(ctx)
+        }()
+    }
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        p.maintain(ctx)
+    }()
+    wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+    for ctx.Err() == nil {
+        ran, err := p.RunOnce(ctx)
+        if err != nil {
+            log.Println("jobs:", err)
+        }
+        if ran && err == nil {
+            continue
+        }
+        select {
+        case <-ctx.Done():
+        case <-time.After(p.PollInterval):
+        }
+    }
+}
+
+// maintain enqueues scheduled jobs and requeues jobs whose worker died.
+func (p *Pool) maintain(ctx context.Context) {
+    next := make(map[string]time.Time)
+    ticker := time.NewTicker(p.PollInterval)
+    defer ticker.Stop()
+    for {
+        if err := p.enqueueScheduled(ctx, next); err != nil {
+            log.Println("jobs: schedule:", err)
+        }
+        if err := p.reap(ctx); err != nil {
+            log.Println("jobs: reap:", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// RunOnce claims one due job and runs it, reporting whether there was one.
+// It is exported so tests can drive the pool step by step.
+func (p *Pool) RunOnce(ctx context.Context) (bool, error) {
+    job, err := p.claim(ctx)
+    if err != nil || job == nil {
+        return false, err
+    }
+    result, runErr := p.execute(ctx, *job)
+    return true, p.finish(*job, result, runErr)
+}
+
+func (p *Pool) claim(ctx context.Context) (*model.Job, error) {
+    var job model.Job
+    now := p.Now()
+    err := p.DB.WithContext(tenant.System(ctx)).Transaction(func(tx *gorm.DB) error {
+        q := tx.Where("status = ? AND run_at <= ?", model.JobQueued, now).Order("run_at").Order("id").Limit(1)
+        if tx.Dialector.Name() == "postgres" {
+            q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+        }
+        if err := q.Find(&job).Error; err != nil || job.ID == 0 {
+            return err
+        }
+        // the status check keeps databases without row locks from
+        // handing the same job to two workers
+        res := tx.Model(&model.Job{}).Where("id = ? AND status = ?", job.ID, model.JobQueued).Updates(map[string]interface{}{
+            "status":    model.JobRunning,
+            "attempts":  gorm.Expr("attempts + 1"),
+            "locked_by": p.ID,
+            "locked_at": now,
+        })
+        if res.Error == nil && res.RowsAffected == 0 {
+            job = model.Job{}
+        }
+        return res.Error
+    })
+    if err != nil || job.ID == 0 {
+        return nil, err
+    }
+    job.Status = model.JobRunning
+    job.Attempts++
+    job.LockedBy = p.ID
+    job.LockedAt = &now
+    return &job, nil
+}
+
+func (p *Pool) execute(ctx context.Context, job model.Job) (result interface{}, err error) {
+    handler, ok := lookup(job.Type)
+    if !ok {
+        return nil, Permanent(fmt.Errorf("no handler registered for %q", job.Type))
+    }
+    ctx, cancel := context.WithTimeout(tenant.WithTenant(ctx, job.TenantID), p.Timeout)
+    defer cancel()
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("panic: %v", r)
+        }
+    }()
+    return handler(ctx, []byte(job.Payload))
+}
+
+// finish records the outcome. It uses a fresh context so a job that ran
+// during shutdown is still recorded, and only touches the job while this
+// pool holds the lock, in case the reaper gave it to someone else.
+func (p *Pool) finish(job model.Job, result interface{}, runErr error) error {
+    now := p.Now()
+    updates := map[string]interface{}{
+        "locked_by": "",
+        "locked_at": nil,
+    }
+    if result != nil {
+        raw, err := json.Marshal(result)
+        if err != nil {
+            return err
+        }
+        updates["result"] = string(raw)
+    }
+    switch {
+    case runErr == nil:
+        updates["status"] = model.JobSucceeded
+        updates["last_error"] = ""
+        updates["finished_at"] = now
+    case isPermanent(runErr) || job.Attempts >= job.MaxAttempts:
+        updates["status"] = model.JobFailed
+        updates["last_error"] = runErr.Error()
+        updates["finished_at"] = now
+    default:
+        updates["status"] = model.JobQueued
+        updates["last_error"] = runErr.Error()
+        updates["run_at"] = now.Add(p.backoff(job.Attempts))
+    }
+    return p.DB.WithContext(tenant.System(context.Background())).Model(&model.Job{}).
+        Where("id = ? AND locked_by = ?", job.ID, p.ID).Updates(updates).Error
+}
+
+// backoff doubles the delay for each failed attempt, capped at MaxBackoff.
+func (p *Pool) backoff(attempts int) time.Duration {
+    delay := p.BaseBackoff
+    for i := 1; i < attempts; i++ {
+        delay *= 2
+        if delay >= p.MaxBackoff {
+            return p.MaxBackoff
+        }
+    }
+    return delay
+}
+
+// reap handles jobs left running past LockTimeout: they are queued again,
+// or failed once they have used up their attempts.
+func (p *Pool) reap(ctx context.Context) error {
+    db := p.DB.WithContext(tenant.System(ctx))
+    now := p.Now()
+    stale := db.Model(&model.Job{}).Where("status = ? AND locked_at < ?", model.JobRunning, now.Add(-p.LockTimeout))
+    err := stale.Session(&gorm.Session{}).Where("attempts >= max_attempts").Updates(map[string]interface{}{
+        "status":      model.JobFailed,
+        "last_error":  "worker lost",
+        "locked_by":   "",
+        "locked_at":   nil,
+        "finished_at": now,
+    }).Error
+    if err != nil {
+        return err
+    }
+    return stale.Session(&gorm.Session{}).Updates(map[string]interface{}{
+        "status":     model.JobQueued,
+        "last_error": "worker lost",
+        "locked_by":  "",
+        "locked_at":  nil,
+        "run_at":     now,
+    }).Error
+}
+
+// enqueueScheduled enqueues each schedule whose next run has come. The
+// run's unique key lets every instance try without doubling up.
+func (p *Pool) enqueueScheduled(ctx context.Context, next map[string]time.Time) error {
+    now := p.Now()
+    db := p.DB.WithContext(tenant.System(ctx))
+    for _, s := range registeredSchedules() {
+        due, ok := next[s.name]
+        if !ok {
+            due = s.schedule.Next(now)
+            next[s.name] = due
+        }
+        if due.IsZero() || due.After(now) {
+            continue
+        }
+        key := fmt.Sprintf("cron:%s:%d", s.name, due.Unix())
+        _, err := Enqueue(db, s.jobType, s.payload, At(due), uniqueKey(key), func(j *model.Job) {
+            j.TenantID = tenant.Default
+        })
+        if err != nil && !isDuplicate(db, key) {
+            return err
+        }
+        next[s.name] = s.schedule.Next(now)
+    }
+    return nil
+}
+
+// isDuplicate reports whether a job with key already exists, which is
+// how a lost race between schedulers shows up.
+func isDuplicate(db *gorm.DB, key string) bool {
+    var count int64
+    db.Model(&model.Job{}).Where("unique_key = ?", key).Count(&count)
+    return count > 0
+}
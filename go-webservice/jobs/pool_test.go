@@ -0,0 +1,231 @@
+package jobs
+
+import (
+    "context"
+    "errors"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/glebarez/sqlite"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+// testPool returns a pool over a fresh database whose clock only moves when
+// the test moves it.
+func testPool(t *testing.T) (*Pool, *time.Time) {
+    t.Helper()
+    dsn := filepath.Join(t.TempDir(), "jobs.db") + "?_pragma=busy_timeout(5000)"
+    db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := db.Use(tenant.Plugin{}); err != nil {
+        t.Fatal(err)
+    }
+    if err := db.AutoMigrate(&model.Job{}); err != nil {
+        t.Fatal(err)
+    }
+    now := time.Date(2026, time.January, 14, 10, 0, 0, 0, time.UTC)
+    p := NewPool(db)
+    p.Now = func() time.Time { return now }
+    p.ID = "test"
+    return p, &now
+}
+
+func enqueue(t *testing.T, p *Pool, jobType string, payload interface{}, opts ...Option) model.Job {
+    t.Helper()
+    opts = append(opts, func(j *model.Job) { j.RunAt = p.Now() })
+    job, err := Enqueue(p.DB.WithContext(tenant.WithTenant(context.Background(), "acme")), jobType, payload, opts...)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return job
+}
+
+func load(t *testing.T, p *Pool, id uint) model.Job {
+    t.Helper()
+    var job model.Job
+    if err := p.DB.WithContext(tenant.System(context.Background())).First(&job, id).Error; err != nil {
+        t.Fatal(err)
+    }
+    return job
+}
+
+func runOnce(t *testing.T, p *Pool) bool {
+    t.Helper()
+    ran, err := p.RunOnce(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return ran
+}
+
+func TestRunSucceeds(t *testing.T) {
+    p, _ := testPool(t)
+    type greeting struct{ Name string }
+    var gotTenant string
+    Register("test.greet", func(ctx context.Context, g greeting) (interface{}, error) {
+        gotTenant, _ = tenant.FromContext(ctx)
+        return map[string]string{"greeting": "hello " + g.Name}, nil
+    })
+    job := enqueue(t, p, "test.greet", greeting{Name: "ada"})
+
+    if !runOnce(t, p) {
+        t.Fatal("due job not run")
+    }
+    if runOnce(t, p) {
+        t.Fatal("ran a job twice")
+    }
+    job = load(t, p, job.ID)
+    if job.Status != model.JobSucceeded || job.Attempts != 1 || job.Result != `{"greeting":"hello ada"}` || job.FinishedAt == nil {
+        t.Fatalf("job %+v", job)
+    }
+    if gotTenant != "acme" {
+        t.Fatalf("handler ran in tenant %q, want the job's", gotTenant)
+    }
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+    p, now := testPool(t)
+    p.BaseBackoff, p.MaxBackoff = 10*time.Second, 25*time.Second
+    calls := 0
+    Register("test.flaky", func(ctx context.Context, _ struct{}) (interface{}, error) {
+        calls++
+        return nil, errors.New("try again")
+    })
+    job := enqueue(t, p, "test.flaky", struct{}{}, MaxAttempts(4))
+
+    for i, wait := range []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second} {
+        runOnce(t, p)
+        got := load(t, p, job.ID)
+        if got.Status != model.JobQueued || got.LastError != "try again" || !got.RunAt.Equal(now.Add(wait)) {
+            t.Fatalf("after attempt %d: %+v, want a retry in %s", i+1, got, wait)
+        }
+        if runOnce(t, p) {
+            t.Fatal("retried before the backoff was over")
+        }
+        *now = now.Add(wait)
+    }
+    runOnce(t, p)
+    if got := load(t, p, job.ID); got.Status != model.JobFailed || got.Attempts != 4 {
+        t.Fatalf("after the last attempt: %+v", got)
+    }
+    if calls != 4 {
+        t.Fatalf("handler ran %d times, want 4", calls)
+    }
+}
+
+func TestPermanentFailures(t *testing.T) {
+    p, _ := testPool(t)
+    Register("test.permanent", func(ctx context.Context, _ struct{}) (interface{}, error) {
+        return nil, Permanent(errors.New("bad input"))
+    })
+    Register("test.panics", func(ctx context.Context, _ struct{}) (interface{}, error) {
+        panic("boom")
+    })
+    Register("test.typed", func(ctx context.Context, _ struct{ N int }) (interface{}, error) {
+        return nil, nil
+    })
+
+    for jobType, payload := range map[string]interface{}{
+        "test.permanent": struct{}{},
+        "test.unknown":   struct{}{},
+        "test.typed":     map[string]string{"N": "not a number"},
+    } {
+        job := enqueue(t, p, jobType, payload)
+        runOnce(t, p)
+        if got := load(t, p, job.ID); got.Status != model.JobFailed || got.Attempts != 1 {
+            t.Errorf("%s: %+v, want failed after one attempt", jobType, got)
+        }
+    }
+
+    job := enqueue(t, p, "test.panics", struct{}{})
+    runOnce(t, p)
+    if got := load(t, p, job.ID); got.Status != model.JobQueued || got.LastError != "panic: boom" {
+        t.Errorf("panicking job: %+v, want a retry", got)
+    }
+}
+
+func TestEnqueueFollowsTransaction(t *testing.T) {
+    p, _ := testPool(t)
+    db := p.DB.WithContext(tenant.WithTenant(context.Background(), "acme"))
+    db.Transaction(func(tx *gorm.DB) error {
+        if _, err := Enqueue(tx, "test.noop", nil); err != nil {
+            t.Fatal(err)
+        }
+        return errors.New("roll back")
+    })
+    var n int64
+    db.Model(&model.Job{}).Count(&n)
+    if n != 0 {
+        t.Fatalf("%d jobs queued by a rolled back transaction", n)
+    }
+}
+
+func TestReapRequeuesLostJobs(t *testing.T) {
+    p, now := testPool(t)
+    p.LockTimeout = time.Minute
+    retry := enqueue(t, p, "test.lost", struct{}{})
+    last := enqueue(t, p, "test.lost", struct{}{}, MaxAttempts(1))
+    // claim both as a worker that then dies
+    for _, id := range []uint{retry.ID, last.ID} {
+        if job, err := p.claim(context.Background()); err != nil || job.ID != id {
+            t.Fatalf("claim: %v, %v", job, err)
+        }
+    }
+
+    *now = now.Add(59 * time.Second)
+    p.reap(context.Background())
+    if got := load(t, p, retry.ID); got.Status != model.JobRunning {
+        t.Fatalf("reaped a job still within its lock timeout: %+v", got)
+    }
+    *now = now.Add(2 * time.Second)
+    if err := p.reap(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if got := load(t, p, retry.ID); got.Status != model.JobQueued || got.LastError != "worker lost" || got.LockedBy != "" {
+        t.Fatalf("lost job: %+v, want queued again", got)
+    }
+    if got := load(t, p, last.ID); got.Status != model.JobFailed {
+        t.Fatalf("lost job out of attempts: %+v, want failed", got)
+    }
+}
+
+func TestScheduledJobsAreEnqueuedOnce(t *testing.T) {
+    p, now := testPool(t)
+    Schedule("test-every-minute", "* * * * *", "test.scheduled", map[string]int{"n": 1})
+    other := *p
+    other.ID = "other"
+
+    next, otherNext := map[string]time.Time{}, map[string]time.Time{}
+    count := func() int64 {
+        var n int64
+        p.DB.WithContext(tenant.System(context.Background())).Model(&model.Job{}).Where("type = ?", "test.scheduled").Count(&n)
+        return n
+    }
+    for _, step := range []struct {
+        advance time.Duration
+        want    int64
+    }{{0, 0}, {30 * time.Second, 0}, {30 * time.Second, 1}, {10 * time.Second, 1}, {50 * time.Second, 2}} {
+        *now = now.Add(step.advance)
+        if err := p.enqueueScheduled(context.Background(), next); err != nil {
+            t.Fatal(err)
+        }
+        if err := other.enqueueScheduled(context.Background(), otherNext); err != nil {
+            t.Fatal(err)
+        }
+        if got := count(); got != step.want {
+            t.Fatalf("at %s: %d scheduled jobs, want %d", now.Format(time.TimeOnly), got, step.want)
+        }
+    }
+
+    var job model.Job
+    p.DB.WithContext(tenant.System(context.Background())).Where("type = ?", "test.scheduled").First(&job)
+    if job.TenantID != tenant.Default || job.Payload != `{"n":1}` || !job.RunAt.Equal(time.Date(2026, 1, 14, 10, 1, 0, 0, time.UTC)) {
+        t.Fatalf("scheduled job %+v", job)
+    }
+}
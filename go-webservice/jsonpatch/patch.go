@@ -0,0 +1,259 @@
+// Package jsonpatch applies JSON Patch (RFC 6902) and JSON Merge Patch
+// (RFC 7386) documents to decoded JSON values: maps, slices, strings,
+// bools, nil and json.Number, as produced by Decode.
+package jsonpatch
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+var (
+    ErrInvalidPatch = errors.New("invalid patch")
+    ErrPathNotFound = errors.New("path not found")
+    ErrTestFailed   = errors.New("test failed")
+)
+
+// Operation is one step of a JSON Patch.
+type Operation struct {
+    Op    string          `json:"op"`
+    Path  string          `json:"path"`
+    From  string          `json:"from,omitempty"`
+    Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a JSON Patch: operations applied in order.
+type Patch []Operation
+
+// OpError reports the operation that failed, by its index in the patch.
+type OpError struct {
+    Index int
+    Op    Operation
+    Err   error
+}
+
+func (e *OpError) Error() string {
+    return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Op.Op, e.Op.Path, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+    return e.Err
+}
+
+// Decode parses JSON into a value this package can patch, keeping numbers
+// exact.
+func Decode(data []byte) (interface{}, error) {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    var v interface{}
+    if err := dec.Decode(&v); err != nil {
+        return nil, err
+    }
+    if dec.More() {
+        return nil, errors.New("unexpected data after JSON value")
+    }
+    return v, nil
+}
+
+// ParsePatch parses a JSON Patch document and checks each operation has
+// the members its op needs.
+func ParsePatch(data []byte) (Patch, error) {
+    var raw []map[string]json.RawMessage
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return nil, fmt.Errorf("%w: a JSON Patch is an array of operations", ErrInvalidPatch)
+    }
+    patch := make(Patch, len(raw))
+    for i, members := range raw {
+        op := &patch[i]
+        fail := func(msg string) error {
+            return &OpError{Index: i, Op: *op, Err: fmt.Errorf("%w: %s", ErrInvalidPatch, msg)}
+        }
+        for name, dst := range map[string]*string{"op": &op.Op, "path": &op.Path, "from": &op.From} {
+            if v, ok := members[name]; ok {
+                if err := json.Unmarshal(v, dst); err != nil {
+                    return nil, fail(name + " must be a string")
+                }
+            }
+        }
+        if _, ok := members["path"]; !ok {
+            return nil, fail("missing path")
+        }
+        if _, err := parsePointer(op.Path); err != nil {
+            return nil, fail(err.Error())
+        }
+        switch op.Op {
+        case "add", "replace", "test":
+            v, ok := members["value"]
+            if !ok {
+                return nil, fail("missing value")
+            }
+            op.Value = v
+        case "remove":
+        case "move", "copy":
+            if _, ok := members["from"]; !ok {
+                return nil, fail("missing from")
+            }
+            if _, err := parsePointer(op.From); err != nil {
+                return nil, fail(err.Error())
+            }
+            if op.Op == "move" && (op.Path == op.From || strings.HasPrefix(op.Path, op.From+"/")) {
+                return nil, fail("cannot move a value into itself")
+            }
+        default:
+            return nil, fail("unknown op " + strconv.Quote(op.Op))
+        }
+    }
+    return patch, nil
+}
+
+// Apply returns doc with the patch applied. doc itself is not modified, so
+// a patch that fails part way leaves nothing half applied.
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+    doc = deepCopy(doc)
+    for i, op := range p {
+        var err error
+        doc, err = apply(doc, op)
+        if err != nil {
+            return nil, &OpError{Index: i, Op: op, Err: err}
+        }
+    }
+    return doc, nil
+}
+
+func apply(doc interface{}, op Operation) (interface{}, error) {
+    path, err := parsePointer(op.Path)
+    if err != nil {
+        return nil, err
+    }
+    switch op.Op {
+    case "add", "replace", "test":
+        value, err := Decode(op.Value)
+        if err != nil {
+            return nil, fmt.Errorf("%w: value: %v", ErrInvalidPatch, err)
+        }
+        switch op.Op {
+        case "add":
+            return add(doc, path, value)
+        case "replace":
+            if _, err := get(doc, path); err != nil {
+                return nil, err
+            }
+            return set(doc, path, value, false)
+        }
+        current, err := get(doc, path)
+        if err != nil {
+            return nil, err
+        }
+        if !Equal(current, value) {
+            return nil, ErrTestFailed
+        }
+        return doc, nil
+    case "remove":
+        return remove(doc, path)
+    case "move", "copy":
+        from, err := parsePointer(op.From)
+        if err != nil {
+            return nil, err
+        }
+        value, err := get(doc, from)
+        if err != nil {
+            return nil, fmt.Errorf("from: %w", err)
+        }
+        if op.Op == "move" {
+            if doc, err = remove(doc, from); err != nil {
+                return nil, err
+            }
+        } else {
+            value = deepCopy(value)
+        }
+        return add(doc, path, value)
+    }
+    return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+// MergePatch applies a JSON Merge Patch: members of an object patch
+// replace those of doc, null members remove them, and any other patch
+// replaces doc whole. doc is not modified.
+func MergePatch(doc, patch interface{}) interface{} {
+    obj, ok := patch.(map[string]interface{})
+    if !ok {
+        return deepCopy(patch)
+    }
+    target, ok := doc.(map[string]interface{})
+    if !ok {
+        target = map[string]interface{}{}
+    }
+    out := make(map[string]interface{}, len(target))
+    for k, v := range target {
+        out[k] = v
+    }
+    for k, v := range obj {
+        if v == nil {
+            delete(out, k)
+            continue
+        }
+        out[k] = MergePatch(out[k], v)
+    }
+    return out
+}
+
+// Equal compares decoded JSON values, numbers by value, so 1 equals 1.0.
+func Equal(a, b interface{}) bool {
+    switch a := a.(type) {
+    case map[string]interface{}:
+        b, ok := b.(map[string]interface{})
+        if !ok || len(a) != len(b) {
+            return false
+        }
+        for k, v := range a {
+            w, ok := b[k]
+            if !ok || !Equal(v, w) {
+                return false
+            }
+        }
+        return true
+    case []interface{}:
+        b, ok := b.([]interface{})
+        if !ok || len(a) != len(b) {
+            return false
+        }
+        for i := range a {
+            if !Equal(a[i], b[i]) {
+                return false
+            }
+        }
+        return true
+    case json.Number:
+        b, ok := b.(json.Number)
+        if !ok {
+            return false
+        }
+        x, okx := new(big.Rat).SetString(a.String())
+        y, oky := new(big.Rat).SetString(b.String())
+        return okx && oky && x.Cmp(y) == 0
+    }
+    return a == b
+}
+
+func deepCopy(v interface{}) interface{} {
+    switch v := v.(type) {
+    case map[string]interface{}:
+        out := make(map[string]interface{}, len(v))
+        for k, e := range v {
+            out[k] = deepCopy(e)
+        }
+        return out
+    case []interface{}:
+        out := make([]interface{}, len(v))
+        for i, e := range v {
+            out[i] = deepCopy(e)
+        }
+        return out
+    }
+    return v
+}
@@ -0,0 +1,208 @@
+package jsonpatch
+
+import (
+    "errors"
+    "testing"
+)
+
+func mustDecode(t *testing.T, s string) interface{} {
+    t.Helper()
+    v, err := Decode([]byte(s))
+    if err != nil {
+        t.Fatalf("decode %s: %v", s, err)
+    }
+    return v
+}
+
+// The examples of RFC 6902, appendix A, and a few more.
+func TestApply(t *testing.T) {
+    tests := []struct {
+        name, doc, patch, want string
+    }{
+        {"add member", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`},
+        {"add element", `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+        {"remove member", `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+        {"remove element", `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+        {"replace", `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`},
+        {"move member",
+            `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+            `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+            `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`},
+        {"move element", `{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+        {"test", `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+            `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+            `{"baz": "qux", "foo": ["a", 2, "c"]}`},
+        {"add nested", `{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar", "child": {"grandchild": {}}}`},
+        {"ignore unknown members", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`, `{"foo": "bar", "baz": "qux"}`},
+        {"escaped pointer", `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}, {"op": "replace", "path": "/~1", "value": 1}]`, `{"/": 1, "~1": 10}`},
+        {"add array", `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`},
+        {"append at length", `[1]`, `[{"op": "add", "path": "/1", "value": 2}]`, `[1, 2]`},
+        {"replace whole document", `{"a": 1}`, `[{"op": "replace", "path": "", "value": [true]}]`, `[true]`},
+        {"copy", `{"a": {"b": [1]}}`, `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "add", "path": "/c/b/-", "value": 2}]`, `{"a": {"b": [1]}, "c": {"b": [1, 2]}}`},
+        {"test numbers by value", `{"n": 1}`, `[{"op": "test", "path": "/n", "value": 1.0}]`, `{"n": 1}`},
+        {"null value", `{"a": 1}`, `[{"op": "replace", "path": "/a", "value": null}]`, `{"a": null}`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            patch, err := ParsePatch([]byte(tt.patch))
+            if err != nil {
+                t.Fatal(err)
+            }
+            doc := mustDecode(t, tt.doc)
+            got, err := patch.Apply(doc)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if want := mustDecode(t, tt.want); !Equal(got, want) {
+                t.Errorf("got %v, want %v", got, want)
+            }
+            if !Equal(doc, mustDecode(t, tt.doc)) {
+                t.Errorf("Apply modified its input: %v", doc)
+            }
+        })
+    }
+}
+
+func TestApplyErrors(t *testing.T) {
+    tests := []struct {
+        name, doc, patch string
+        index            int
+        want             error
+    }{
+        {"missing member", `{"foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": 1}]`, 0, ErrPathNotFound},
+        {"missing parent", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, 0, ErrPathNotFound},
+        {"index out of range", `{"foo": [1]}`, `[{"op": "add", "path": "/foo/2", "value": 0}]`, 0, ErrPathNotFound},
+        {"remove missing", `{"foo": "bar"}`, `[{"op": "test", "path": "/foo", "value": "bar"}, {"op": "remove", "path": "/nope"}]`, 1, ErrPathNotFound},
+        {"bad index", `{"foo": [1, 2]}`, `[{"op": "replace", "path": "/foo/01", "value": 0}]`, 0, errIndex},
+        {"test failed", `{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`, 0, ErrTestFailed},
+        {"test type", `{"n": "1"}`, `[{"op": "test", "path": "/n", "value": 1}]`, 0, ErrTestFailed},
+        {"move from missing", `{}`, `[{"op": "move", "from": "/a", "path": "/b"}]`, 0, ErrPathNotFound},
+        {"remove root", `{}`, `[{"op": "remove", "path": ""}]`, 0, ErrInvalidPatch},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            patch, err := ParsePatch([]byte(tt.patch))
+            if err != nil {
+                t.Fatal(err)
+            }
+            _, err = patch.Apply(mustDecode(t, tt.doc))
+            var opErr *OpError
+            if !errors.As(err, &opErr) || opErr.Index != tt.index || !errors.Is(err, tt.want) {
+                t.Fatalf("error %v, want %v at operation %d", err, tt.want, tt.index)
+            }
+        })
+    }
+}
+
+func TestApplyIsAtomic(t *testing.T) {
+    doc := mustDecode(t, `{"a": [1, 2], "b": {"c": 1}}`)
+    patch, err := ParsePatch([]byte(`[
+        {"op": "add", "path": "/a/0", "value": 0},
+        {"op": "remove", "path": "/b/c"},
+        {"op": "test", "path": "/a/0", "value": 99}
+    ]`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := patch.Apply(doc); !errors.Is(err, ErrTestFailed) {
+        t.Fatalf("Apply: %v", err)
+    }
+    if !Equal(doc, mustDecode(t, `{"a": [1, 2], "b": {"c": 1}}`)) {
+        t.Fatalf("failed patch changed the document: %v", doc)
+    }
+}
+
+func TestParsePatch(t *testing.T) {
+    tests := []struct {
+        name, patch string
+        index       int
+    }{
+        {"not an array", `{"op": "add"}`, -1},
+        {"not json", `[`, -1},
+        {"missing path", `[{"op": "remove"}]`, 0},
+        {"missing value", `[{"op": "remove", "path": "/a"}, {"op": "add", "path": "/a"}]`, 1},
+        {"missing from", `[{"op": "copy", "path": "/a"}]`, 0},
+        {"unknown op", `[{"op": "delete", "path": "/a"}]`, 0},
+        {"path not a string", `[{"op": "remove", "path": 1}]`, 0},
+        {"relative pointer", `[{"op": "remove", "path": "a"}]`, 0},
+        {"bad escape", `[{"op": "remove", "path": "/a~2"}]`, 0},
+        {"move into itself", `[{"op": "move", "from": "/a", "path": "/a/b"}]`, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := ParsePatch([]byte(tt.patch))
+            if !errors.Is(err, ErrInvalidPatch) {
+                t.Fatalf("error %v, want ErrInvalidPatch", err)
+            }
+            index := -1
+            var opErr *OpError
+            if errors.As(err, &opErr) {
+                index = opErr.Index
+            }
+            if index != tt.index {
+                t.Fatalf("error %v at operation %d, want %d", err, index, tt.index)
+            }
+        })
+    }
+
+    _, err := ParsePatch([]byte(`[{"op": "test", "path": "/a/b", "value": 1}, {"op": "frob", "path": "/x"}]`))
+    if want := `operation 1 (frob /x): invalid patch: unknown op "frob"`; err == nil || err.Error() != want {
+        t.Fatalf("error %q, want %q", err, want)
+    }
+}
+
+// The examples of RFC 7386, appendix A.
+func TestMergePatch(t *testing.T) {
+    tests := []struct{ doc, patch, want string }{
+        {`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+        {`{"a":"b"}`, `{"a":null}`, `{}`},
+        {`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+        {`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+        {`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+        {`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+        {`["a","b"]`, `["c","d"]`, `["c","d"]`},
+        {`{"a":"b"}`, `["c"]`, `["c"]`},
+        {`{"a":"foo"}`, `null`, `null`},
+        {`{"a":"foo"}`, `"bar"`, `"bar"`},
+        {`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+        {`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+        {`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+    }
+    for _, tt := range tests {
+        doc := mustDecode(t, tt.doc)
+        got := MergePatch(doc, mustDecode(t, tt.patch))
+        if !Equal(got, mustDecode(t, tt.want)) {
+            t.Errorf("merge %s into %s = %v, want %s", tt.patch, tt.doc, got, tt.want)
+        }
+        if !Equal(doc, mustDecode(t, tt.doc)) {
+            t.Errorf("merge %s modified %s", tt.patch, tt.doc)
+        }
+    }
+}
+
+func TestEqual(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want bool
+    }{
+        {`1`, `1.0`, true},
+        {`1e2`, `100`, true},
+        {`0.1`, `0.10000000000000001`, false},
+        {`12345678901234567890`, `12345678901234567891`, false},
+        {`{"a": [1, {"b": null}]}`, `{"a": [1.0, {"b": null}]}`, true},
+        {`{"a": 1}`, `{"a": 1, "b": 2}`, false},
+        {`[1, 2]`, `[2, 1]`, false},
+        {`"1"`, `1`, false},
+        {`null`, `false`, false},
+    }
+    for _, tt := range tests {
+        if got := Equal(mustDecode(t, tt.a), mustDecode(t, tt.b)); got != tt.want {
+            t.Errorf("Equal(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+        }
+    }
+    if _, err := Decode([]byte(`{} {}`)); err == nil {
+        t.Error("Decode accepted trailing data")
+    }
+}
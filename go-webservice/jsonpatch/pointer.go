@@ -0,0 +1,129 @@
+package jsonpatch
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens.
+func parsePointer(p string) ([]string, error) {
+    if p == "" {
+        return nil, nil
+    }
+    if p[0] != '/' {
+        return nil, fmt.Errorf("%w: pointer %q must start with /", ErrInvalidPatch, p)
+    }
+    tokens := strings.Split(p[1:], "/")
+    for i, t := range tokens {
+        if strings.Contains(strings.ReplaceAll(strings.ReplaceAll(t, "~0", ""), "~1", ""), "~") {
+            return nil, fmt.Errorf("%w: bad escape in pointer %q", ErrInvalidPatch, p)
+        }
+        tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+    }
+    return tokens, nil
+}
+
+var errIndex = errors.New("bad array index")
+
+// index parses an array index token for an array of length n. With
+// appendOK, "-" and n name the position after the last element.
+func index(token string, n int, appendOK bool) (int, error) {
+    if appendOK && token == "-" {
+        return n, nil
+    }
+    if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+        return 0, fmt.Errorf("%w %q", errIndex, token)
+    }
+    i, err := strconv.Atoi(token)
+    if err != nil || i > n || (i == n && !appendOK) {
+        return 0, fmt.Errorf("%w: index %s out of range", ErrPathNotFound, token)
+    }
+    return i, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+    cur := doc
+    for _, t := range path {
+        switch c := cur.(type) {
+        case map[string]interface{}:
+            v, ok := c[t]
+            if !ok {
+                return nil, ErrPathNotFound
+            }
+            cur = v
+        case []interface{}:
+            i, err := index(t, len(c), false)
+            if err != nil {
+                return nil, err
+            }
+            cur = c[i]
+        default:
+            return nil, ErrPathNotFound
+        }
+    }
+    return cur, nil
+}
+
+// set puts value at path, whose parent must exist. For arrays, insert
+// shifts later elements up, as add does; otherwise the element is
+// replaced.
+func set(doc interface{}, path []string, value interface{}, insert bool) (interface{}, error) {
+    if len(path) == 0 {
+        return value, nil
+    }
+    parent, err := get(doc, path[:len(path)-1])
+    if err != nil {
+        return nil, err
+    }
+    last := path[len(path)-1]
+    switch p := parent.(type) {
+    case map[string]interface{}:
+        p[last] = value
+        return doc, nil
+    case []interface{}:
+        i, err := index(last, len(p), insert)
+        if err != nil {
+            return nil, err
+        }
+        if !insert {
+            p[i] = value
+            return doc, nil
+        }
+        grown := append(p[:i:i], append([]interface{}{value}, p[i:]...)...)
+        return set(doc, path[:len(path)-1], grown, false)
+    }
+    return nil, ErrPathNotFound
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+    return set(doc, path, value, true)
+}
+
+func remove(doc interface{}, path []string) (interface{}, error) {
+    if len(path) == 0 {
+        return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+    }
+    parent, err := get(doc, path[:len(path)-1])
+    if err != nil {
+        return nil, err
+    }
+    last := path[len(path)-1]
+    switch p := parent.(type) {
+    case map[string]interface{}:
+        if _, ok := p[last]; !ok {
+            return nil, ErrPathNotFound
+        }
+        delete(p, last)
+        return doc, nil
+    case []interface{}:
+        i, err := index(last, len(p), false)
+        if err != nil {
+            return nil, err
+        }
+        shrunk := append(p[:i:i], p[i+1:]...)
+        return set(doc, path[:len(path)-1], shrunk, false)
+    }
+    return nil, ErrPathNotFound
+}
@@ -1,19 +1,152 @@
 package middleware
 
 import (
-    "github.com/gin-gonic/gin"
+    "context"
+    "crypto/tls"
+    "errors"
+    "fmt"
     "net/http"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v5"
+    "go-webservice/service"
+    "go-webservice/tenant"
 )
 
+// JWTSecret enables real bearer token validation when set. Without it any
+// non-empty Authorization header is accepted as an admin (demo mode).
+var JWTSecret = os.Getenv("JWT_SECRET")
+
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// Principal is the authenticated caller. Tenant is empty in demo mode,
+// where the caller is not bound to any tenant. Scopes is nil for users,
+// whose access is governed by role alone, and lists the granted scopes for
+// API keys.
+type Principal struct {
+    Subject string
+    Role    string
+    Tenant  string
+    Scopes  []string
+}
+
+// HasScope reports whether the principal may use an endpoint that needs scope.
+func (p Principal) HasScope(scope string) bool {
+    if p.Scopes == nil {
+        return true
+    }
+    for _, s := range p.Scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+type claims struct {
+    Role   string `json:"role"`
+    Tenant string `json:"tenant"`
+    jwt.RegisteredClaims
+}
+
+var ErrNoJWTSecret = errors.New("JWT_SECRET is not set")
+
+// IssueToken signs a bearer token for a user. Users are not stored by the
+// service; a valid token is what makes someone a user.
+func IssueToken(subject, role, tenantID string, ttl time.Duration) (string, error) {
+    if JWTSecret == "" {
+        return "", ErrNoJWTSecret
+    }
+    now := time.Now()
+    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+        Role:   role,
+        Tenant: tenantID,
+        RegisteredClaims: jwt.RegisteredClaims{
+            Subject:   subject,
+            IssuedAt:  jwt.NewNumericDate(now),
+            ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+        },
+    })
+    return token.SignedString([]byte(JWTSecret))
+}
+
+// Authenticate maps the Authorization and X-API-Key header values, or
+// failing those a verified client certificate, to a principal. It is
+// shared by the gin middleware and the gRPC interceptors so both APIs
+// authenticate the same way. API keys are accepted in either header.
+func Authenticate(ctx context.Context, header, apiKey string, state *tls.ConnectionState) (Principal, error) {
+    raw := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+    if apiKey == "" && service.IsAPIKey(raw) {
+        apiKey = raw
+    }
+    if apiKey != "" {
+        key, err := service.AuthenticateAPIKey(ctx, apiKey)
+        if err != nil {
+            return Principal{}, ErrUnauthorized
+        }
+        return Principal{
+            Subject: fmt.Sprintf("apikey:%d", key.ID),
+            Role:    key.Role,
+            Tenant:  key.TenantID,
+            Scopes:  key.ScopeList(),
+        }, nil
+    }
+
+    if header == "" {
+        if p, ok := certificatePrincipal(state); ok {
+            return p, nil
+        }
+        return Principal{}, ErrUnauthorized
+    }
+    if JWTSecret == "" {
+        // Simulated auth for demo
+        return Principal{Role: "admin"}, nil
+    }
+
+    var c claims
+    _, err := jwt.ParseWithClaims(raw, &c, func(t *jwt.Token) (interface{}, error) {
+        return []byte(JWTSecret), nil
+    }, jwt.WithValidMethods([]string{"HS256"}))
+    if err != nil {
+        return Principal{}, ErrUnauthorized
+    }
+    p := Principal{Subject: c.Subject, Role: c.Role, Tenant: c.Tenant}
+    if p.Role == "" {
+        p.Role = "user"
+    }
+    if p.Tenant == "" {
+        p.Tenant = tenant.Default
+    }
+    return p, nil
+}
+
 func AuthMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
-        // Simulated auth for demo
-        token := c.GetHeader("Authorization")
-        if token == "" {
+        p, err := Authenticate(c.Request.Context(), c.GetHeader("Authorization"), c.GetHeader("X-API-Key"), c.Request.TLS)
+        if err != nil {
             c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
             return
         }
-        c.Set("role", "admin") // Simulated role
+        c.Set("role", p.Role)
+        c.Set("user_id", p.Subject)
+        c.Set("principal", p)
+        c.Next()
+    }
+}
+
+// RequireScope rejects API keys that were not granted scope. Users are
+// not affected.
+func RequireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        p, _ := c.Get("principal")
+        principal, _ := p.(Principal)
+        if !principal.HasScope(scope) {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing scope " + scope})
+            return
+        }
         c.Next()
     }
 }
@@ -27,4 +160,4 @@ func AdminOnly() gin.HandlerFunc {
         }
         c.Next()
     }
-}
\ No newline at end of file
+}
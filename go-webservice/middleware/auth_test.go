@@ -0,0 +1,118 @@
+package middleware
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+func withSecret(t *testing.T, secret string) {
+    old := JWTSecret
+    JWTSecret = secret
+    t.Cleanup(func() { JWTSecret = old })
+}
+
+func TestAuthenticateToken(t *testing.T) {
+    withSecret(t, "s3cret")
+    ctx := context.Background()
+
+    token, err := IssueToken("alice", "admin", "acme", time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    p, err := Authenticate(ctx, "Bearer "+token, "", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if p.Subject != "alice" || p.Role != "admin" || p.Tenant != "acme" || p.Scopes != nil {
+        t.Fatalf("principal %+v", p)
+    }
+
+    defaults, _ := IssueToken("bob", "", "", time.Hour)
+    if p, err := Authenticate(ctx, "Bearer "+defaults, "", nil); err != nil || p.Role != "user" || p.Tenant != "default" {
+        t.Fatalf("token without role or tenant: %+v, %v", p, err)
+    }
+
+    expired, _ := IssueToken("alice", "admin", "acme", -time.Minute)
+    none := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "mallory", "role": "admin"})
+    unsigned, _ := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+    withSecret(t, "other")
+    forged, _ := IssueToken("mallory", "admin", "acme", time.Hour)
+    withSecret(t, "s3cret")
+
+    for name, header := range map[string]string{
+        "missing":      "",
+        "expired":      "Bearer " + expired,
+        "wrong secret": "Bearer " + forged,
+        "alg none":     "Bearer " + unsigned,
+        "garbage":      "Bearer not-a-token",
+    } {
+        if p, err := Authenticate(ctx, header, "", nil); err != ErrUnauthorized {
+            t.Errorf("%s token: %+v, %v; want ErrUnauthorized", name, p, err)
+        }
+    }
+}
+
+func TestAuthenticateDemoMode(t *testing.T) {
+    withSecret(t, "")
+    if _, err := IssueToken("alice", "admin", "", time.Hour); err != ErrNoJWTSecret {
+        t.Fatalf("issue without a secret: %v", err)
+    }
+    p, err := Authenticate(context.Background(), "anything", "", nil)
+    if err != nil || p.Role != "admin" || p.Tenant != "" {
+        t.Fatalf("demo principal %+v, %v", p, err)
+    }
+}
+
+func certRoles(t *testing.T, s string) map[string]CertGrant {
+    t.Helper()
+    roles, err := ParseCertRoles(s)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return roles
+}
+
+func TestParseCertRolesRejectsMalformedEntries(t *testing.T) {
+    for _, s := range []string{"billing", "=admin", "billing=", "billing=@acme"} {
+        if _, err := ParseCertRoles(s); err == nil {
+            t.Errorf("ParseCertRoles(%q) accepted a malformed entry", s)
+        }
+    }
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+    withSecret(t, "s3cret")
+    old := ClientCertRoles
+    t.Cleanup(func() { ClientCertRoles = old })
+    ClientCertRoles = certRoles(t, "billing=admin@acme, *=user")
+
+    verified := func(name string) *tls.ConnectionState {
+        cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+        return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+    }
+    p, err := Authenticate(context.Background(), "", "", verified("billing"))
+    if err != nil || p.Subject != "cert:billing" || p.Role != "admin" || p.Tenant != "acme" {
+        t.Fatalf("billing certificate: %+v, %v", p, err)
+    }
+    p, err = Authenticate(context.Background(), "", "", verified("reports"))
+    if err != nil || p.Role != "user" || p.Tenant != "default" {
+        t.Fatalf("wildcard certificate: %+v, %v", p, err)
+    }
+
+    // an unverified certificate is no credential at all
+    unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "billing"}}}}
+    if _, err := Authenticate(context.Background(), "", "", unverified); err != ErrUnauthorized {
+        t.Fatalf("unverified certificate: %v", err)
+    }
+
+    ClientCertRoles = certRoles(t, "billing=admin")
+    if _, err := Authenticate(context.Background(), "", "", verified("reports")); err != ErrUnauthorized {
+        t.Fatalf("unmapped certificate: %v", err)
+    }
+}
@@ -0,0 +1,12 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// NoStore marks responses as uncacheable. Handlers serving data that may
+// be reused override the header with their own policy.
+func NoStore() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Header("Cache-Control", "no-store")
+        c.Next()
+    }
+}
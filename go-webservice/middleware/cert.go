@@ -0,0 +1,58 @@
+package middleware
+
+import (
+    "crypto/tls"
+    "fmt"
+    "strings"
+
+    "go-webservice/tenant"
+)
+
+// CertGrant is what a client certificate is allowed to act as.
+type CertGrant struct {
+    Role   string
+    Tenant string
+}
+
+// ClientCertRoles maps the common name of a verified client certificate to
+// what it may act as. The name "*" matches any other certificate. It is
+// empty, rejecting every certificate, until main sets it from
+// TLS_CLIENT_ROLES.
+var ClientCertRoles = map[string]CertGrant{}
+
+// ParseCertRoles reads certificate grants as "name=role" or
+// "name=role@tenant" pairs separated by commas, the format of
+// TLS_CLIENT_ROLES.
+func ParseCertRoles(s string) (map[string]CertGrant, error) {
+    roles := make(map[string]CertGrant)
+    for _, entry := range splitList(s) {
+        name, grant, ok := strings.Cut(entry, "=")
+        role, tenantID, _ := strings.Cut(strings.TrimSpace(grant), "@")
+        if !ok || strings.TrimSpace(name) == "" || role == "" {
+            return nil, fmt.Errorf("invalid entry %q, want name=role or name=role@tenant", entry)
+        }
+        if tenantID == "" {
+            tenantID = tenant.Default
+        }
+        roles[strings.TrimSpace(name)] = CertGrant{Role: role, Tenant: tenantID}
+    }
+    return roles, nil
+}
+
+// certificatePrincipal identifies a caller by the client certificate the
+// TLS handshake verified. Certificates without a role mapping are not
+// accepted.
+func certificatePrincipal(state *tls.ConnectionState) (Principal, bool) {
+    if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+        return Principal{}, false
+    }
+    name := state.VerifiedChains[0][0].Subject.CommonName
+    grant, ok := ClientCertRoles[name]
+    if !ok {
+        grant, ok = ClientCertRoles["*"]
+    }
+    if !ok {
+        return Principal{}, false
+    }
+    return Principal{Subject: "cert:" + name, Role: grant.Role, Tenant: grant.Tenant}, true
+}
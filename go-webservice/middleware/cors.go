@@ -0,0 +1,169 @@
+package middleware
+
+import (
+    "log"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// CORSConfig controls which browser origins may call the API. An origin
+// pattern may contain one "*" wildcard, as in "https://*.example.com";
+// "*" alone allows any origin.
+type CORSConfig struct {
+    AllowedOrigins   []string
+    AllowedMethods   []string
+    AllowedHeaders   []string
+    ExposedHeaders   []string
+    AllowCredentials bool
+    MaxAge           time.Duration
+}
+
+// CORSConfigFromEnv reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS,
+// CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS (comma separated),
+// CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE. With no allowed origins CORS is
+// off and browsers keep their same-origin policy.
+func CORSConfigFromEnv() CORSConfig {
+    cfg := CORSConfig{
+        AllowedOrigins: splitList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+        AllowedMethods: splitList(envOr("CORS_ALLOWED_METHODS", "GET, POST, PUT, PATCH, DELETE")),
+        AllowedHeaders: splitList(envOr("CORS_ALLOWED_HEADERS", "Authorization, Content-Type, X-API-Key, Last-Event-ID")),
+        ExposedHeaders: splitList(envOr("CORS_EXPOSED_HEADERS", "Deprecation, Sunset, Link, Location")),
+        MaxAge:         10 * time.Minute,
+    }
+    if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            log.Fatal("Invalid CORS_ALLOW_CREDENTIALS:", v)
+        }
+        cfg.AllowCredentials = b
+    }
+    if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            log.Fatal("Invalid CORS_MAX_AGE:", v)
+        }
+        cfg.MaxAge = d
+    }
+    return cfg
+}
+
+// CORS answers preflight requests itself, before any route middleware, so
+// they never reach authentication. Actual requests from an allowed origin
+// get the headers the browser needs to expose the response.
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+    methods := strings.Join(cfg.AllowedMethods, ", ")
+    headers := strings.Join(cfg.AllowedHeaders, ", ")
+    exposed := strings.Join(cfg.ExposedHeaders, ", ")
+    anyHeader := contains(cfg.AllowedHeaders, "*")
+    maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+    return func(c *gin.Context) {
+        origin := c.GetHeader("Origin")
+        if origin == "" || len(cfg.AllowedOrigins) == 0 {
+            c.Next()
+            return
+        }
+        h := c.Writer.Header()
+        h.Add("Vary", "Origin")
+        preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+        if preflight {
+            h.Add("Vary", "Access-Control-Request-Method")
+            h.Add("Vary", "Access-Control-Request-Headers")
+        }
+
+        if !originAllowed(cfg.AllowedOrigins, origin) {
+            if preflight {
+                c.AbortWithStatus(http.StatusForbidden)
+                return
+            }
+            c.Next()
+            return
+        }
+        if preflight && !containsFold(cfg.AllowedMethods, c.GetHeader("Access-Control-Request-Method")) {
+            c.AbortWithStatus(http.StatusForbidden)
+            return
+        }
+
+        if contains(cfg.AllowedOrigins, "*") && !cfg.AllowCredentials {
+            h.Set("Access-Control-Allow-Origin", "*")
+        } else {
+            h.Set("Access-Control-Allow-Origin", origin)
+        }
+        if cfg.AllowCredentials {
+            h.Set("Access-Control-Allow-Credentials", "true")
+        }
+
+        if !preflight {
+            if exposed != "" {
+                h.Set("Access-Control-Expose-Headers", exposed)
+            }
+            c.Next()
+            return
+        }
+
+        h.Set("Access-Control-Allow-Methods", methods)
+        if requested := c.GetHeader("Access-Control-Request-Headers"); anyHeader && requested != "" {
+            h.Set("Access-Control-Allow-Headers", requested)
+        } else if headers != "" {
+            h.Set("Access-Control-Allow-Headers", headers)
+        }
+        if cfg.MaxAge > 0 {
+            h.Set("Access-Control-Max-Age", maxAge)
+        }
+        c.AbortWithStatus(http.StatusNoContent)
+    }
+}
+
+func originAllowed(patterns []string, origin string) bool {
+    for _, p := range patterns {
+        if p == "*" || strings.EqualFold(p, origin) {
+            return true
+        }
+        if prefix, suffix, ok := strings.Cut(p, "*"); ok &&
+            len(origin) > len(prefix)+len(suffix) &&
+            strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+            return true
+        }
+    }
+    return false
+}
+
+func splitList(s string) []string {
+    var out []string
+    for _, v := range strings.Split(s, ",") {
+        if v = strings.TrimSpace(v); v != "" {
+            out = append(out, v)
+        }
+    }
+    return out
+}
+
+func envOr(key, fallback string) string {
+    if v, ok := os.LookupEnv(key); ok {
+        return v
+    }
+    return fallback
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+func containsFold(list []string, s string) bool {
+    for _, v := range list {
+        if strings.EqualFold(v, s) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+func corsEngine(cfg CORSConfig) *gin.Engine {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(CORS(cfg))
+    r.GET("/thing", func(c *gin.Context) { c.Status(http.StatusOK) })
+    r.OPTIONS("/thing", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+    return r
+}
+
+func TestCORS(t *testing.T) {
+    cfg := CORSConfig{
+        AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"},
+        AllowedMethods: []string{"GET", "POST"},
+        AllowedHeaders: []string{"Authorization", "Content-Type"},
+        ExposedHeaders: []string{"Location"},
+        MaxAge:         10 * time.Minute,
+    }
+    tests := []struct {
+        name    string
+        method  string
+        origin  string
+        request string // Access-Control-Request-Method
+        status  int
+        headers map[string]string
+    }{
+        {
+            name: "same origin", method: "GET", status: http.StatusOK,
+            headers: map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+        },
+        {
+            name: "allowed origin", method: "GET", origin: "https://app.example.com", status: http.StatusOK,
+            headers: map[string]string{
+                "Access-Control-Allow-Origin":   "https://app.example.com",
+                "Access-Control-Expose-Headers": "Location",
+                "Vary":                          "Origin",
+            },
+        },
+        {
+            name: "wildcard origin", method: "GET", origin: "https://pr-12.preview.example.com", status: http.StatusOK,
+            headers: map[string]string{"Access-Control-Allow-Origin": "https://pr-12.preview.example.com"},
+        },
+        {
+            name: "wildcard needs a subdomain", method: "GET", origin: "https://.preview.example.com", status: http.StatusOK,
+            headers: map[string]string{"Access-Control-Allow-Origin": ""},
+        },
+        {
+            name: "other origin", method: "GET", origin: "https://evil.example", status: http.StatusOK,
+            headers: map[string]string{"Access-Control-Allow-Origin": ""},
+        },
+        {
+            name: "preflight", method: "OPTIONS", origin: "https://app.example.com", request: "POST", status: http.StatusNoContent,
+            headers: map[string]string{
+                "Access-Control-Allow-Origin":  "https://app.example.com",
+                "Access-Control-Allow-Methods": "GET, POST",
+                "Access-Control-Allow-Headers": "Authorization, Content-Type",
+                "Access-Control-Max-Age":       "600",
+            },
+        },
+        {
+            name: "preflight from other origin", method: "OPTIONS", origin: "https://evil.example", request: "POST",
+            status: http.StatusForbidden,
+        },
+        {
+            name: "preflight for disallowed method", method: "OPTIONS", origin: "https://app.example.com", request: "DELETE",
+            status: http.StatusForbidden,
+        },
+        {
+            name: "plain OPTIONS reaches the route", method: "OPTIONS", origin: "https://app.example.com",
+            status: http.StatusTeapot,
+        },
+    }
+    r := corsEngine(cfg)
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(tt.method, "/thing", nil)
+            if tt.origin != "" {
+                req.Header.Set("Origin", tt.origin)
+            }
+            if tt.request != "" {
+                req.Header.Set("Access-Control-Request-Method", tt.request)
+            }
+            w := httptest.NewRecorder()
+            r.ServeHTTP(w, req)
+            if w.Code != tt.status {
+                t.Fatalf("status %d, want %d", w.Code, tt.status)
+            }
+            for name, want := range tt.headers {
+                if got := w.Header().Get(name); got != want {
+                    t.Errorf("%s is %q, want %q", name, got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestCORSCredentials(t *testing.T) {
+    r := corsEngine(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+    req := httptest.NewRequest("GET", "/thing", nil)
+    req.Header.Set("Origin", "https://app.example.com")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    // a credentialed response must name the origin; browsers reject "*"
+    if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+        t.Errorf("Access-Control-Allow-Origin is %q", got)
+    }
+    if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+        t.Errorf("Access-Control-Allow-Credentials is %q", got)
+    }
+
+    r = corsEngine(CORSConfig{AllowedOrigins: []string{"*"}})
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+        t.Errorf("Access-Control-Allow-Origin is %q, want *", got)
+    }
+}
+
+func TestSecurityHeaders(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(SecurityHeaders(SecurityConfig{HSTSMaxAge: time.Hour, FrameOptions: "DENY"}))
+    r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+    want := map[string]string{
+        "Strict-Transport-Security": "max-age=3600; includeSubDomains",
+        "X-Frame-Options":           "DENY",
+        "X-Content-Type-Options":    "nosniff",
+        "Referrer-Policy":           "no-referrer",
+        "Content-Security-Policy":   "",
+    }
+    for name, v := range want {
+        if got := w.Header().Get(name); got != v {
+            t.Errorf("%s is %q, want %q", name, got, v)
+        }
+    }
+}
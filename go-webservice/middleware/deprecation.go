@@ -0,0 +1,25 @@
+package middleware
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Deprecated marks every response of a route group as deprecated since the
+// given date (the Deprecation header, RFC 9745), announces when it stops
+// working (Sunset, RFC 8594) and links to the version that replaces it.
+func Deprecated(since, sunset time.Time, successor string) gin.HandlerFunc {
+    deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+    sunsetDate := sunset.UTC().Format(http.TimeFormat)
+    link := "<" + successor + `>; rel="successor-version"`
+    return func(c *gin.Context) {
+        h := c.Writer.Header()
+        h.Set("Deprecation", deprecation)
+        h.Set("Sunset", sunsetDate)
+        h.Add("Link", link)
+        c.Next()
+    }
+}
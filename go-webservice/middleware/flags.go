@@ -0,0 +1,48 @@
+package middleware
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/flags"
+    "go-webservice/service"
+    "go-webservice/tenant"
+)
+
+// FeatureFlags stores the caller in the request context as the subject
+// feature flags are evaluated for. It must run after TenantMiddleware.
+func FeatureFlags() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        ctx := flags.WithSubject(c.Request.Context(), flags.Subject{
+            UserID: c.GetString("user_id"),
+            Role:   c.GetString("role"),
+            Tenant: c.GetString("tenant"),
+        })
+        c.Request = c.Request.WithContext(ctx)
+        c.Next()
+    }
+}
+
+// RequireFlag hides an endpoint behind a feature flag: callers it is off
+// for get 404, as if the endpoint did not exist.
+func RequireFlag(key string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !service.FlagEnabled(c.Request.Context(), key) {
+            c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+            return
+        }
+        c.Next()
+    }
+}
+
+// OperatorOnly admits admins of the default tenant, who manage settings
+// that apply to every tenant. It must run after AdminOnly.
+func OperatorOnly() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if c.GetString("tenant") != tenant.Default {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+            return
+        }
+        c.Next()
+    }
+}
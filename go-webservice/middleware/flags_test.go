@@ -0,0 +1,37 @@
+package middleware_test
+
+import (
+    "net/http"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/apitest"
+    "go-webservice/flags"
+    "go-webservice/middleware"
+)
+
+func TestRequireFlag(t *testing.T) {
+    s := apitest.New(t)
+    r := gin.New()
+    r.GET("/beta", func(c *gin.Context) {
+        c.Set("user_id", "7")
+        c.Set("tenant", "default")
+    }, middleware.FeatureFlags(), middleware.RequireFlag("beta"), func(c *gin.Context) {
+        c.JSON(http.StatusOK, gin.H{"ok": true})
+    })
+
+    s.Flags(flags.Flag{Key: "beta", Enabled: true, Rollout: 100})
+    if w := serve(r, http.MethodGet, "/beta"); w.Code != http.StatusOK {
+        t.Errorf("flag on: status %d, want 200", w.Code)
+    }
+
+    s.Flags(flags.Flag{Key: "beta", Enabled: false})
+    if w := serve(r, http.MethodGet, "/beta"); w.Code != http.StatusNotFound {
+        t.Errorf("flag off: status %d, want 404", w.Code)
+    }
+
+    s.Flags()
+    if w := serve(r, http.MethodGet, "/beta"); w.Code != http.StatusNotFound {
+        t.Errorf("undefined flag: status %d, want 404", w.Code)
+    }
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "go-webservice/replica"
+)
+
+// ReadYourWrites gives each request its own replica session, so reads made
+// after the request writes go to the primary and see the write.
+func ReadYourWrites() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Request = c.Request.WithContext(replica.WithSession(c.Request.Context()))
+        c.Next()
+    }
+}
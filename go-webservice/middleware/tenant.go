@@ -0,0 +1,77 @@
+package middleware
+
+import (
+    "errors"
+    "net"
+    "net/http"
+    "os"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+    "go-webservice/tenant"
+)
+
+// TenantBaseDomain enables tenant resolution from the Host header: with
+// "example.com" a request to acme.example.com resolves to tenant "acme".
+var TenantBaseDomain = os.Getenv("TENANT_BASE_DOMAIN")
+
+var ErrTenantMismatch = errors.New("token is not valid for this tenant")
+
+// ResolveTenant picks the tenant for a request from the principal's tenant
+// claim and the subdomain of host. When both are present they must agree,
+// so a token issued for one tenant cannot be replayed against another.
+func ResolveTenant(p Principal, host string) (string, error) {
+    sub := subdomain(host)
+    id := p.Tenant
+    switch {
+    case id != "" && sub != "" && id != sub:
+        return "", ErrTenantMismatch
+    case id == "" && sub != "":
+        id = sub
+    case id == "":
+        id = tenant.Default
+    }
+    if !tenant.ValidSlug(id) {
+        return "", tenant.ErrInvalidTenant
+    }
+    return id, nil
+}
+
+func subdomain(host string) string {
+    if TenantBaseDomain == "" {
+        return ""
+    }
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    host = strings.ToLower(host)
+    suffix := "." + strings.ToLower(TenantBaseDomain)
+    if !strings.HasSuffix(host, suffix) {
+        return ""
+    }
+    return strings.TrimSuffix(host, suffix)
+}
+
+// TenantMiddleware resolves the tenant for the request and stores it in the
+// request context, where the service layer picks it up. It must run after
+// AuthMiddleware.
+func TenantMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        p, _ := c.Get("principal")
+        principal, _ := p.(Principal)
+        id, err := ResolveTenant(principal, c.Request.Host)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+            return
+        }
+        ctx := tenant.WithTenant(c.Request.Context(), id)
+        if _, err := service.GetTenant(ctx, id); err != nil {
+            c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown tenant"})
+            return
+        }
+        c.Set("tenant", id)
+        c.Request = c.Request.WithContext(ctx)
+        c.Next()
+    }
+}
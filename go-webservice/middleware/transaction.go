@@ -0,0 +1,133 @@
+package middleware
+
+import (
+    "bytes"
+    "log"
+    "mime"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go-webservice/service"
+    "go-webservice/util"
+)
+
+// Transaction runs each POST, PUT, PATCH and DELETE request in one unit of
+// work, so the service calls a handler makes commit or roll back together.
+// The unit commits when the handler responds with a 2xx status and rolls
+// back on any other status or a panic. The response is held back until the
+// commit succeeds; if it fails the client gets a 500 instead.
+//
+// Uploads and bulk imports are left out: they are read for as long as the
+// client takes to send them, and their reports can be large, so holding a
+// transaction and the whole response for them would cost too much. Their
+// services commit in transactions of their own.
+func Transaction() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        switch c.Request.Method {
+        case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+        default:
+            c.Next()
+            return
+        }
+        if isUpload(c.Request) {
+            c.Next()
+            return
+        }
+
+        ctx, unit := service.BeginUnit(c.Request.Context())
+        c.Request = c.Request.WithContext(ctx)
+        out := c.Writer
+        buf := &bufferedWriter{ResponseWriter: out, status: http.StatusOK}
+        c.Writer = buf
+        defer func() {
+            if r := recover(); r != nil {
+                unit.Rollback()
+                c.Writer = out
+                panic(r)
+            }
+        }()
+
+        c.Next()
+
+        c.Writer = out
+        if buf.status >= 200 && buf.status < 300 {
+            if err := unit.Commit(); err != nil {
+                log.Println("transaction: commit:", err)
+                out.Header().Del("Location")
+                util.HandleError(c, http.StatusInternalServerError, "could not commit changes")
+                return
+            }
+        } else {
+            unit.Rollback()
+        }
+        buf.flush()
+    }
+}
+
+// isUpload reports whether the request body is a file upload or a bulk
+// import, going by its Content-Type.
+func isUpload(r *http.Request) bool {
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    switch mediaType {
+    case "text/csv", "application/x-ndjson", "application/ndjson", "application/jsonl":
+        return true
+    }
+    return strings.HasPrefix(mediaType, "multipart/")
+}
+
+// bufferedWriter holds a response in memory until the request's unit of
+// work has finished.
+type bufferedWriter struct {
+    gin.ResponseWriter
+    status  int
+    written bool
+    body    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+    if code > 0 && !w.written {
+        w.status = code
+    }
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+    w.written = true
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+    w.written = true
+    return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+    w.written = true
+    return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+    return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+    if !w.written {
+        return -1
+    }
+    return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+    return w.written
+}
+
+// Flush is a no-op: nothing reaches the client before the unit finishes.
+func (w *bufferedWriter) Flush() {}
+
+func (w *bufferedWriter) flush() {
+    w.ResponseWriter.WriteHeader(w.status)
+    if w.body.Len() > 0 {
+        w.ResponseWriter.Write(w.body.Bytes())
+    } else {
+        w.ResponseWriter.WriteHeaderNow()
+    }
+}
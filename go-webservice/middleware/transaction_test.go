@@ -0,0 +1,182 @@
+package middleware_test
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/glebarez/sqlite"
+    "go-webservice/config"
+    "go-webservice/middleware"
+    "go-webservice/model"
+    "go-webservice/service"
+    "go-webservice/tenant"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+func count(t *testing.T, title string) int64 {
+    t.Helper()
+    var n int64
+    err := config.DB.WithContext(tenant.System(context.Background())).
+        Model(&model.Course{}).Where("title = ?", title).Count(&n).Error
+    if err != nil {
+        t.Fatal(err)
+    }
+    return n
+}
+
+// transactionRouter serves handlers in a unit of work, in the default
+// tenant of a fresh SQLite database, without the rest of the API's
+// middleware.
+func transactionRouter(t *testing.T) *gin.Engine {
+    t.Helper()
+    db, reads, search, cache := config.DB, config.Reads, config.Search, config.Cache
+    t.Cleanup(func() { config.DB, config.Reads, config.Search, config.Cache = db, reads, search, cache })
+
+    dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+    conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := config.UseDatabase(conn); err != nil {
+        t.Fatal(err)
+    }
+
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        c.Request = c.Request.WithContext(tenant.WithTenant(c.Request.Context(), tenant.Default))
+    }, middleware.Transaction())
+    return r
+}
+
+func serve(r http.Handler, method, path string) *httptest.ResponseRecorder {
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+    return w
+}
+
+func create(t *testing.T, c *gin.Context, title string) {
+    t.Helper()
+    if _, err := service.CreateCourse(c.Request.Context(), model.Course{Title: title}); err != nil {
+        t.Errorf("create %s: %v", title, err)
+    }
+}
+
+func TestTransactionCommits(t *testing.T) {
+    r := transactionRouter(t)
+    r.POST("/ok", func(c *gin.Context) {
+        create(t, c, "first")
+        create(t, c, "second")
+        c.Header("Location", "/courses/1")
+        c.JSON(http.StatusCreated, gin.H{"ok": true})
+    })
+
+    w := serve(r, http.MethodPost, "/ok")
+    if w.Code != http.StatusCreated || w.Body.String() != `{"ok":true}` || w.Header().Get("Location") == "" {
+        t.Fatalf("response %d %q %v", w.Code, w.Body, w.Header())
+    }
+    if count(t, "first") != 1 || count(t, "second") != 1 {
+        t.Fatal("changes of a successful request were not committed")
+    }
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+    r := transactionRouter(t)
+    r.POST("/conflict", func(c *gin.Context) {
+        create(t, c, "rolled back")
+        c.JSON(http.StatusConflict, gin.H{"error": "conflict"})
+    })
+    r.POST("/panic", func(c *gin.Context) {
+        create(t, c, "panicked")
+        panic("boom")
+    })
+
+    if w := serve(r, http.MethodPost, "/conflict"); w.Code != http.StatusConflict || w.Body.String() != `{"error":"conflict"}` {
+        t.Fatalf("response %d %q", w.Code, w.Body)
+    }
+    func() {
+        defer func() {
+            if recover() == nil {
+                t.Error("panic was swallowed")
+            }
+        }()
+        serve(r, http.MethodPost, "/panic")
+    }()
+    if n := count(t, "rolled back") + count(t, "panicked"); n != 0 {
+        t.Fatalf("%d courses left by failed requests", n)
+    }
+}
+
+func TestTransactionSavepoints(t *testing.T) {
+    r := transactionRouter(t)
+    r.POST("/nested", func(c *gin.Context) {
+        ctx := c.Request.Context()
+        create(t, c, "outer")
+        err := service.InTransaction(ctx, func(ctx context.Context) error {
+            service.CreateCourse(ctx, model.Course{Title: "inner failed"})
+            return errors.New("failed")
+        })
+        if err == nil {
+            t.Error("failed savepoint reported success")
+        }
+        err = service.InTransaction(ctx, func(ctx context.Context) error {
+            _, err := service.CreateCourse(ctx, model.Course{Title: "inner"})
+            return err
+        })
+        if err != nil {
+            t.Error(err)
+        }
+        c.Status(http.StatusNoContent)
+    })
+
+    if w := serve(r, http.MethodPost, "/nested"); w.Code != http.StatusNoContent {
+        t.Fatalf("status %d", w.Code)
+    }
+    if count(t, "outer") != 1 || count(t, "inner") != 1 || count(t, "inner failed") != 0 {
+        t.Fatal("savepoints did not commit and roll back independently")
+    }
+}
+
+func TestTransactionSkipsReads(t *testing.T) {
+    r := transactionRouter(t)
+    r.GET("/read", func(c *gin.Context) {
+        create(t, c, "written by a read")
+        c.Status(http.StatusBadRequest)
+    })
+
+    // a failed GET has nothing to roll back: its writes, if any, stand
+    serve(r, http.MethodGet, "/read")
+    if count(t, "written by a read") != 1 {
+        t.Fatal("GET ran in a unit of work")
+    }
+}
+
+func TestTransactionSkipsUploads(t *testing.T) {
+    r := transactionRouter(t)
+    r.POST("/import", func(c *gin.Context) {
+        create(t, c, "imported")
+        c.Writer.WriteHeader(http.StatusUnprocessableEntity)
+        c.Writer.Flush()
+    })
+
+    for _, contentType := range []string{"text/csv; charset=utf-8", "application/x-ndjson", "multipart/form-data; boundary=x"} {
+        w := httptest.NewRecorder()
+        req := httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(""))
+        req.Header.Set("Content-Type", contentType)
+        r.ServeHTTP(w, req)
+        if !w.Flushed {
+            t.Errorf("%s: response was buffered", contentType)
+        }
+    }
+    // the service committed each import on its own
+    if n := count(t, "imported"); n != 3 {
+        t.Fatalf("%d imported courses, want 3", n)
+    }
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+    "strings"
+    "time"
+)
+
+const (
+    ScopeCoursesRead    = "courses:read"
+    ScopeCoursesWrite   = "courses:write"
+    ScopeWebhooksManage = "webhooks:manage"
+    ScopeKeysManage     = "keys:manage"
+    ScopeJobsManage     = "jobs:manage"
+    ScopeReviewsWrite   = "reviews:write"
+    ScopeOrdersWrite    = "orders:write"
+    ScopeFlagsManage    = "flags:manage"
+)
+
+var Scopes = []string{ScopeCoursesRead, ScopeCoursesWrite, ScopeWebhooksManage, ScopeKeysManage, ScopeJobsManage,
+    ScopeReviewsWrite, ScopeOrdersWrite, ScopeFlagsManage}
+
+// APIKey lets machine clients authenticate without interactive login. The
+// full key is "<prefix>.<secret>"; only the prefix and a SHA-256 hash of
+// the secret are stored.
+type APIKey struct {
+    ID         uint       `json:"id" gorm:"primaryKey"`
+    TenantID   string     `json:"-" gorm:"index;not null;default:'default'"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix" gorm:"uniqueIndex"`
+    SecretHash string     `json:"-"`
+    Role       string     `json:"role"`
+    Scopes     string     `json:"scopes"` // comma separated
+    ExpiresAt  *time.Time `json:"expires_at"`
+    RevokedAt  *time.Time `json:"revoked_at"`
+    LastUsedAt *time.Time `json:"last_used_at"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k APIKey) ScopeList() []string {
+    var scopes []string
+    for _, s := range strings.Split(k.Scopes, ",") {
+        if s = strings.TrimSpace(s); s != "" {
+            scopes = append(scopes, s)
+        }
+    }
+    return scopes
+}
+
+// Active reports whether the key may be used at time now.
+func (k APIKey) Active(now time.Time) bool {
+    if k.RevokedAt != nil {
+        return false
+    }
+    return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -1,7 +1,26 @@
 package model
 
+import "time"
+
 type Course struct {
-    ID          string `json:"id"`
-    Title       string `json:"title"`
-    Description string `json:"description"`
-}
\ No newline at end of file
+    ID       string `json:"id" gorm:"primaryKey"`
+    TenantID string `json:"-" gorm:"index;not null;default:'default';uniqueIndex:idx_courses_tenant_external"`
+    // ExternalID is the key used by catalog imports; unique per tenant.
+    ExternalID  *string `json:"external_id,omitempty" gorm:"uniqueIndex:idx_courses_tenant_external"`
+    Title       string  `json:"title"`
+    Description string  `json:"description"`
+    Published   bool    `json:"published"`
+    // AuthorID is the subject that created the course over the API; empty
+    // for imported, seeded and older courses.
+    AuthorID  string    `json:"-" gorm:"index"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    // Price is in minor units of Currency (cents for USD); 0 is free.
+    Price    int64  `json:"-" gorm:"not null;default:0"`
+    Currency string `json:"-" gorm:"size:3"`
+    // Rating aggregates over visible reviews, kept up to date as reviews
+    // change so lists can sort by them. v1 bodies leave these fields out.
+    RatingCount   int     `json:"-" gorm:"not null;default:0"`
+    RatingSum     int     `json:"-" gorm:"not null;default:0"`
+    RatingAverage float64 `json:"-" gorm:"not null;default:0;index"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+    "encoding/json"
+    "time"
+)
+
+const (
+    EventCourseCreated   = "course.created"
+    EventCourseUpdated   = "course.updated"
+    EventCourseDeleted   = "course.deleted"
+    EventCoursePublished = "course.published"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the
+// change it describes. The webhook dispatcher picks up rows that have not
+// been dispatched yet.
+type OutboxEvent struct {
+    ID           uint       `json:"-" gorm:"primaryKey"`
+    TenantID     string     `json:"-" gorm:"index;not null;default:'default'"`
+    EventID      string     `json:"id" gorm:"uniqueIndex"`
+    Type         string     `json:"type" gorm:"index"`
+    AggregateID  string     `json:"aggregate_id" gorm:"index"`
+    Payload      string     `json:"payload" gorm:"type:text"`
+    CreatedAt    time.Time  `json:"created_at"`
+    DispatchedAt *time.Time `json:"dispatched_at" gorm:"index"`
+}
+
+// EventEnvelope is the JSON body stored in the outbox and sent to webhooks.
+type EventEnvelope struct {
+    ID         string          `json:"id"`
+    Type       string          `json:"type"`
+    OccurredAt time.Time       `json:"occurred_at"`
+    Data       json.RawMessage `json:"data"`
+}
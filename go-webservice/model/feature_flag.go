@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// FeatureFlag is a flag definition changed at runtime. Flags apply to the
+// whole deployment, so the table is not tenant scoped; Tenants targets
+// tenants instead. The lists are comma separated.
+type FeatureFlag struct {
+    Key         string    `json:"key" gorm:"primaryKey"`
+    Description string    `json:"description"`
+    Enabled     bool      `json:"enabled"`
+    Rollout     int       `json:"rollout"`
+    Users       string    `json:"users"`
+    Roles       string    `json:"roles"`
+    Tenants     string    `json:"tenants"`
+    UpdatedBy   string    `json:"updated_by"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+    JobQueued    = "queued"
+    JobRunning   = "running"
+    JobSucceeded = "succeeded"
+    JobFailed    = "failed"
+)
+
+// Job is a unit of background work. Workers claim queued jobs whose RunAt
+// has passed; a job that keeps failing ends up failed after MaxAttempts.
+type Job struct {
+    ID          uint       `json:"id" gorm:"primaryKey"`
+    TenantID    string     `json:"-" gorm:"index;not null;default:'default'"`
+    Type        string     `json:"type" gorm:"index"`
+    Payload     string     `json:"payload" gorm:"type:text"`
+    Result      string     `json:"result,omitempty" gorm:"type:text"`
+    Status      string     `json:"status" gorm:"index:idx_jobs_due,priority:1"`
+    RunAt       time.Time  `json:"run_at" gorm:"index:idx_jobs_due,priority:2"`
+    Attempts    int        `json:"attempts"`
+    MaxAttempts int        `json:"max_attempts"`
+    LastError   string     `json:"last_error,omitempty"`
+    LockedBy    string     `json:"locked_by,omitempty"`
+    LockedAt    *time.Time `json:"locked_at,omitempty"`
+    // UniqueKey stops the same scheduled run being enqueued twice when
+    // several instances run the scheduler.
+    UniqueKey  *string    `json:"-" gorm:"uniqueIndex"`
+    FinishedAt *time.Time `json:"finished_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    UpdatedAt  time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+    MediaThumbnail  = "thumbnail"
+    MediaAttachment = "attachment"
+)
+
+// Media is a file attached to a course. Identical content uploaded twice
+// within a tenant shares one blob, found by SHA256.
+type Media struct {
+    ID          string    `json:"id" gorm:"primaryKey"`
+    TenantID    string    `json:"-" gorm:"index;not null;default:'default'"`
+    CourseID    string    `json:"course_id" gorm:"index"`
+    Kind        string    `json:"kind"`
+    Filename    string    `json:"filename"`
+    ContentType string    `json:"content_type"`
+    Size        int64     `json:"size"`
+    SHA256      string    `json:"sha256" gorm:"index"`
+    StorageKey  string    `json:"-"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// MediaBlob records that a blob is stored. Uploads and cleanup lock its row
+// while they decide whether to put or delete the blob, so a blob is never
+// deleted while a new upload is starting to share it. The key begins with
+// the tenant, so the row needs no TenantID of its own.
+type MediaBlob struct {
+    Key       string `gorm:"primaryKey"`
+    CreatedAt time.Time
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// Module is an ordered section of a course.
+type Module struct {
+    ID        string    `json:"id" gorm:"primaryKey"`
+    TenantID  string    `json:"-" gorm:"index;not null;default:'default'"`
+    CourseID  string    `json:"course_id" gorm:"index"`
+    Title     string    `json:"title"`
+    Position  int       `json:"position"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+const (
+    CouponPercent = "percent"
+    CouponFixed   = "fixed"
+)
+
+// Coupon discounts an order by a percentage (Value 1-100) or by a fixed
+// amount in minor units of Currency. MaxUses 0 means unlimited; Uses counts
+// orders that hold the coupon, released again if their payment fails.
+type Coupon struct {
+    ID        uint       `json:"id" gorm:"primaryKey"`
+    TenantID  string     `json:"-" gorm:"index;not null;default:'default';uniqueIndex:idx_coupons_tenant_code"`
+    Code      string     `json:"code" gorm:"uniqueIndex:idx_coupons_tenant_code"`
+    Kind      string     `json:"kind"`
+    Value     int64      `json:"value"`
+    Currency  string     `json:"currency,omitempty" gorm:"size:3"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+    MaxUses   int        `json:"max_uses"`
+    Uses      int        `json:"uses"`
+    Active    bool       `json:"active"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+const (
+    OrderPending = "pending"
+    OrderPaid    = "paid"
+    OrderFailed  = "failed"
+)
+
+// Order is a purchase of a course. The price and discount are copied when
+// the order is placed, so later price changes do not affect it. Amounts
+// are in minor units of Currency. Email, when given, receives the
+// enrollment confirmation in Locale.
+type Order struct {
+    ID         string     `json:"id" gorm:"primaryKey"`
+    TenantID   string     `json:"-" gorm:"index;not null;default:'default'"`
+    UserID     string     `json:"user_id" gorm:"index"`
+    CourseID   string     `json:"course_id" gorm:"index"`
+    Email      string     `json:"email,omitempty"`
+    Locale     string     `json:"-" gorm:"size:35"`
+    Status     string     `json:"status"`
+    Currency   string     `json:"currency" gorm:"size:3"`
+    ListPrice  int64      `json:"list_price"`
+    Discount   int64      `json:"discount"`
+    Total      int64      `json:"total"`
+    CouponID   *uint      `json:"-"`
+    CouponCode string     `json:"coupon_code,omitempty"`
+    PaymentID  string     `json:"payment_id,omitempty" gorm:"index"`
+    FailReason string     `json:"fail_reason,omitempty"`
+    PaidAt     *time.Time `json:"paid_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Enrollment gives a user access to a course.
+type Enrollment struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    TenantID  string    `json:"-" gorm:"index;not null;default:'default'"`
+    UserID    string    `json:"user_id" gorm:"uniqueIndex:idx_enrollments_user_course"`
+    CourseID  string    `json:"course_id" gorm:"uniqueIndex:idx_enrollments_user_course"`
+    OrderID   string    `json:"order_id"`
+    CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Review is a learner's rating of a course. Each user reviews a course at
+// most once. Reviews that users flag are left for an admin to hide or
+// clear; hidden reviews are not listed and do not count towards the
+// course's rating.
+type Review struct {
+    ID        string    `json:"id" gorm:"primaryKey"`
+    TenantID  string    `json:"-" gorm:"index;not null;default:'default'"`
+    CourseID  string    `json:"course_id" gorm:"uniqueIndex:idx_reviews_course_user"`
+    UserID    string    `json:"user_id" gorm:"uniqueIndex:idx_reviews_course_user"`
+    Rating    int       `json:"rating"`
+    Body      string    `json:"body" gorm:"type:text"`
+    Flags     int       `json:"flags"`
+    Hidden    bool      `json:"hidden"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReviewFlag records that a user flagged a review, so each user counts
+// once towards the review's Flags. Moderation deletes a review's flags.
+type ReviewFlag struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    TenantID  string    `json:"-" gorm:"index;not null;default:'default'"`
+    ReviewID  string    `json:"review_id" gorm:"uniqueIndex:idx_review_flags_review_user"`
+    UserID    string    `json:"user_id" gorm:"uniqueIndex:idx_review_flags_review_user"`
+    CreatedAt time.Time `json:"created_at"`
+}
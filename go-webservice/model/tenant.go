@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Tenant is an organisation hosted on the deployment. Its ID is a slug that
+// also serves as its subdomain.
+type Tenant struct {
+    ID        string    `json:"id" gorm:"primaryKey"`
+    Name      string    `json:"name"`
+    CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+    "strings"
+    "time"
+)
+
+const (
+    DeliveryPending   = "pending"
+    DeliveryDelivered = "delivered"
+    DeliveryDead      = "dead"
+)
+
+type Webhook struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    TenantID  string    `json:"-" gorm:"index;not null;default:'default'"`
+    URL       string    `json:"url"`
+    Secret    string    `json:"-"`
+    Events    string    `json:"events"` // comma separated, empty means all
+    Active    bool      `json:"active"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether the webhook is subscribed to the given event type.
+func (w Webhook) Wants(eventType string) bool {
+    if strings.TrimSpace(w.Events) == "" {
+        return true
+    }
+    for _, e := range strings.Split(w.Events, ",") {
+        if strings.TrimSpace(e) == eventType {
+            return true
+        }
+    }
+    return false
+}
+
+type WebhookDelivery struct {
+    ID             uint       `json:"id" gorm:"primaryKey"`
+    TenantID       string     `json:"-" gorm:"index;not null;default:'default'"`
+    WebhookID      uint       `json:"webhook_id" gorm:"index"`
+    EventID        string     `json:"event_id" gorm:"index"`
+    EventType      string     `json:"event_type"`
+    Payload        string     `json:"payload" gorm:"type:text"`
+    Status         string     `json:"status" gorm:"index"`
+    Attempts       int        `json:"attempts"`
+    NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+    LastStatusCode int        `json:"last_status_code"`
+    LastError      string     `json:"last_error"`
+    DeliveredAt    *time.Time `json:"delivered_at"`
+    CreatedAt      time.Time  `json:"created_at"`
+    UpdatedAt      time.Time  `json:"updated_at"`
+}
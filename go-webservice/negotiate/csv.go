@@ -0,0 +1,108 @@
+package negotiate
+
+import (
+    "encoding/csv"
+    "io"
+    "reflect"
+)
+
+// CSV writes text/csv with a header row. Nested records become dotted
+// columns, such as price.amount; lists are written as JSON in one cell.
+// The columns come from the first item; later items fill the columns they
+// share with it.
+type CSV struct{}
+
+func (CSV) MediaType() string {
+    return "text/csv"
+}
+
+func (CSV) ContentType() string {
+    return "text/csv; charset=utf-8"
+}
+
+func (e CSV) Encode(w io.Writer, item string, v interface{}) error {
+    lw, err := e.List(w, List{Item: item, Len: 1})
+    if err != nil {
+        return err
+    }
+    if err := lw.Write(v); err != nil {
+        return err
+    }
+    return lw.Close()
+}
+
+func (CSV) List(w io.Writer, l List) (ListWriter, error) {
+    return &csvList{w: csv.NewWriter(w)}, nil
+}
+
+type csvList struct {
+    w      *csv.Writer
+    header []string
+}
+
+type cell struct {
+    name, value string
+}
+
+func (l *csvList) Write(item interface{}) error {
+    var cells []cell
+    if err := flatten("", reflect.ValueOf(item), false, &cells); err != nil {
+        return err
+    }
+    if l.header == nil {
+        l.header = make([]string, len(cells))
+        for i, c := range cells {
+            l.header[i] = c.name
+        }
+        if err := l.w.Write(l.header); err != nil {
+            return err
+        }
+    }
+    values := make(map[string]string, len(cells))
+    for _, c := range cells {
+        values[c.name] = c.value
+    }
+    row := make([]string, len(l.header))
+    for i, name := range l.header {
+        row[i] = values[name]
+    }
+    return l.w.Write(row)
+}
+
+func (l *csvList) Close() error {
+    l.w.Flush()
+    return l.w.Error()
+}
+
+// flatten appends the cells of v under prefix. Fields of an absent record,
+// and fields JSON would omit as empty, are empty cells, so every row keeps
+// the columns of the first.
+func flatten(prefix string, v reflect.Value, absent bool, cells *[]cell) error {
+    if isRecord(v) {
+        rec, null := record(v)
+        for _, f := range fieldsOf(rec) {
+            name := f.name
+            if prefix != "" {
+                name = prefix + "." + name
+            }
+            omitted := f.omitEmpty && isEmpty(f.value)
+            if err := flatten(name, f.value, absent || null || omitted, cells); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    if prefix == "" {
+        prefix = "value"
+    }
+    if absent {
+        *cells = append(*cells, cell{name: prefix})
+        return nil
+    }
+    s, err := text(v)
+    if err != nil {
+        return err
+    }
+    *cells = append(*cells, cell{name: prefix, value: s})
+    return nil
+}
@@ -0,0 +1,182 @@
+package negotiate
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/ugorji/go/codec"
+)
+
+type price struct {
+    Amount   int64  `json:"amount"`
+    Currency string `json:"currency"`
+}
+
+// Record is embedded in course, so its fields are promoted; its ID is
+// hidden by the course's own.
+type Record struct {
+    ID      string    `json:"id"`
+    Created time.Time `json:"created_at"`
+}
+
+type course struct {
+    ID       string            `json:"id"`
+    Title    string            `json:"title"`
+    Summary  string            `json:"summary,omitempty"`
+    Secret   string            `json:"-"`
+    Price    *price            `json:"price"`
+    Tags     []string          `json:"tags"`
+    Extra    map[string]string `json:"extra,omitempty"`
+    Rating   float64           `json:"rating,omitempty"`
+    internal int
+    Record
+}
+
+type meta struct {
+    Total int `json:"total"`
+}
+
+var created = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+var courses = []course{
+    {ID: "1", Title: "Go, \"fast\"", Summary: "Intro", Secret: "x", Price: &price{Amount: 4999, Currency: "USD"},
+        Tags: []string{"go", "web"}, Extra: map[string]string{}, Rating: 4.5, Record: Record{ID: "r1", Created: created}},
+    {ID: "2", Title: "Free", Extra: map[string]string{"level": "beginner"}, Record: Record{ID: "r2", Created: created}},
+}
+
+func encodeList(t *testing.T, e Encoder, l List) string {
+    t.Helper()
+    var buf bytes.Buffer
+    lw, err := e.List(&buf, l)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, c := range courses {
+        if err := lw.Write(c); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := lw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.String()
+}
+
+func TestJSON(t *testing.T) {
+    want, _ := json.Marshal(courses)
+    if got := encodeList(t, JSON{}, List{Len: 2}); got != string(want) {
+        t.Errorf("list\n got %s\nwant %s", got, want)
+    }
+    page, _ := json.Marshal(map[string]interface{}{"data": courses, "meta": meta{Total: 2}})
+    if got := encodeList(t, JSON{}, List{Len: 2, Meta: meta{Total: 2}}); got != string(page) {
+        t.Errorf("page\n got %s\nwant %s", got, page)
+    }
+
+    var buf bytes.Buffer
+    lw, _ := JSON{}.List(&buf, List{})
+    lw.Close()
+    if buf.String() != "[]" {
+        t.Errorf("empty list %q", buf.String())
+    }
+}
+
+func TestCSV(t *testing.T) {
+    // the first course has no extra entries, so there are no extra
+    // columns for the second's to fill; its other empty fields that JSON
+    // omits are empty cells
+    want := "id,title,summary,price.amount,price.currency,tags,rating,created_at\n" +
+        "1,\"Go, \"\"fast\"\"\",Intro,4999,USD,\"[\"\"go\"\",\"\"web\"\"]\",4.5,2024-03-01T12:30:00Z\n" +
+        "2,Free,,,,,,2024-03-01T12:30:00Z\n"
+    if got := encodeList(t, CSV{}, List{Len: 2, Meta: meta{Total: 2}}); got != want {
+        t.Errorf("list\n got %q\nwant %q", got, want)
+    }
+
+    var buf bytes.Buffer
+    if err := (CSV{}).Encode(&buf, "price", price{Amount: 5, Currency: "EUR"}); err != nil {
+        t.Fatal(err)
+    }
+    if want := "amount,currency\n5,EUR\n"; buf.String() != want {
+        t.Errorf("single value %q, want %q", buf.String(), want)
+    }
+}
+
+func TestXML(t *testing.T) {
+    want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+        `<courses>` +
+        `<course><id>1</id><title>Go, &#34;fast&#34;</title><summary>Intro</summary>` +
+        `<price><amount>4999</amount><currency>USD</currency></price>` +
+        `<tags><item>go</item><item>web</item></tags><rating>4.5</rating><created_at>2024-03-01T12:30:00Z</created_at></course>` +
+        `<course><id>2</id><title>Free</title><extra><level>beginner</level></extra><created_at>2024-03-01T12:30:00Z</created_at></course>` +
+        `<meta><total>2</total></meta>` +
+        `</courses>`
+    got := encodeList(t, XML{}, List{Name: "courses", Item: "course", Len: 2, Meta: meta{Total: 2}})
+    if got != want {
+        t.Errorf("list\n got %s\nwant %s", got, want)
+    }
+
+    var buf bytes.Buffer
+    if err := (XML{}).Encode(&buf, "price", &price{Amount: 5, Currency: "EUR"}); err != nil {
+        t.Fatal(err)
+    }
+    if want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n<price><amount>5</amount><currency>EUR</currency></price>"; buf.String() != want {
+        t.Errorf("single value %s", buf.String())
+    }
+}
+
+func TestMsgPack(t *testing.T) {
+    decode := func(b string) interface{} {
+        var v interface{}
+        h := &codec.MsgpackHandle{}
+        h.RawToString = true
+        if err := codec.NewDecoderBytes([]byte(b), h).Decode(&v); err != nil {
+            t.Fatal(err)
+        }
+        return v
+    }
+
+    list, ok := decode(encodeList(t, MsgPack{}, List{Len: 2})).([]interface{})
+    if !ok || len(list) != 2 {
+        t.Fatalf("list decoded as %#v", list)
+    }
+    first := list[0].(map[interface{}]interface{})
+    if first["title"] != `Go, "fast"` || first["Secret"] != nil || first["secret"] != nil {
+        t.Errorf("first course %v", first)
+    }
+    if ts, ok := first["created_at"].(time.Time); !ok || !ts.Equal(created) {
+        t.Errorf("created_at %#v, want the timestamp extension", first["created_at"])
+    }
+
+    page := decode(encodeList(t, MsgPack{}, List{Len: 2, Meta: meta{Total: 2}})).(map[interface{}]interface{})
+    if data, _ := page["data"].([]interface{}); len(data) != 2 {
+        t.Errorf("page data %v", page["data"])
+    }
+    if m, _ := page["meta"].(map[interface{}]interface{}); m["total"] != uint64(2) && m["total"] != int64(2) {
+        t.Errorf("page meta %v", page["meta"])
+    }
+}
+
+func TestMsgPackLength(t *testing.T) {
+    var buf bytes.Buffer
+    lw, _ := MsgPack{}.List(&buf, List{Len: 1})
+    lw.Write(courses[0])
+    if err := lw.Write(courses[1]); !errors.Is(err, errTooMany) {
+        t.Errorf("extra item: %v", err)
+    }
+    lw, _ = MsgPack{}.List(&buf, List{Len: 2})
+    lw.Write(courses[0])
+    if err := lw.Close(); !errors.Is(err, errTooFew) {
+        t.Errorf("missing item: %v", err)
+    }
+
+    for _, n := range []int{0, 15, 16, 0xffff, 0x10000} {
+        var v []interface{}
+        h := &codec.MsgpackHandle{}
+        b := append(arrayHeader(n), bytes.Repeat([]byte{0xc0}, n)...)
+        if err := codec.NewDecoderBytes(b, h).Decode(&v); err != nil || len(v) != n {
+            t.Errorf("array header for %d: decoded %d items, %v", n, len(v), err)
+        }
+    }
+}
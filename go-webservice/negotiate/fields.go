@@ -0,0 +1,209 @@
+package negotiate
+
+import (
+    "encoding"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// field is one named value of a struct or map, as JSON would name it.
+// omitEmpty is set by the omitempty tag option.
+type field struct {
+    name      string
+    value     reflect.Value
+    omitEmpty bool
+}
+
+// fieldsOf lists the fields of a struct by their JSON names, skipping
+// those tagged "-", or the entries of a string-keyed map sorted by key.
+// Other values have no fields.
+func fieldsOf(v reflect.Value) []field {
+    v = indirect(v)
+    switch v.Kind() {
+    case reflect.Struct:
+        return structFields(v)
+    case reflect.Map:
+        if v.Type().Key().Kind() != reflect.String {
+            return nil
+        }
+        keys := v.MapKeys()
+        sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+        fields := make([]field, len(keys))
+        for i, k := range keys {
+            fields[i] = field{name: k.String(), value: v.MapIndex(k)}
+        }
+        return fields
+    }
+    return nil
+}
+
+// structFields lists a struct's fields in order. As in JSON, the fields of
+// an embedded struct without a name of its own are promoted into the outer
+// struct where it is embedded, unless a shallower field has the same name,
+// and a nil embedded pointer adds none. Embedded structs of unexported
+// types are left out, since their values cannot be read through reflection.
+func structFields(v reflect.Value) []field {
+    type promoted struct {
+        field
+        depth int
+    }
+    var all []promoted
+    var walk func(v reflect.Value, depth int)
+    walk = func(v reflect.Value, depth int) {
+        t := v.Type()
+        for i := 0; i < t.NumField(); i++ {
+            sf := t.Field(i)
+            if !sf.IsExported() {
+                continue
+            }
+            name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+            if name == "-" {
+                continue
+            }
+            if sf.Anonymous && name == "" {
+                if embedded := indirect(v.Field(i)); embedded.Kind() == reflect.Struct {
+                    walk(embedded, depth+1)
+                    continue
+                } else if embedded.Kind() == reflect.Pointer && embedded.Type().Elem().Kind() == reflect.Struct {
+                    continue
+                }
+            }
+            if name == "" {
+                name = sf.Name
+            }
+            omitEmpty := strings.Contains(","+opts+",", ",omitempty,")
+            all = append(all, promoted{field{name: name, value: v.Field(i), omitEmpty: omitEmpty}, depth})
+        }
+    }
+    walk(v, 0)
+
+    shallowest := make(map[string]int, len(all))
+    for _, f := range all {
+        if d, ok := shallowest[f.name]; !ok || f.depth < d {
+            shallowest[f.name] = f.depth
+        }
+    }
+    fields := make([]field, 0, len(all))
+    seen := make(map[string]bool, len(all))
+    for _, f := range all {
+        if f.depth == shallowest[f.name] && !seen[f.name] {
+            seen[f.name] = true
+            fields = append(fields, f.field)
+        }
+    }
+    return fields
+}
+
+// isEmpty reports whether omitempty leaves v out of JSON: false, 0, a nil
+// pointer or interface, or an empty string, list or map.
+func isEmpty(v reflect.Value) bool {
+    switch v.Kind() {
+    case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+        return v.Len() == 0
+    case reflect.Bool:
+        return !v.Bool()
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return v.Int() == 0
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return v.Uint() == 0
+    case reflect.Float32, reflect.Float64:
+        return v.Float() == 0
+    case reflect.Interface, reflect.Pointer:
+        return v.IsNil()
+    }
+    return false
+}
+
+// indirect follows pointers and interfaces, stopping at nil.
+func indirect(v reflect.Value) reflect.Value {
+    for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+        v = v.Elem()
+    }
+    return v
+}
+
+var textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isRecord reports whether v should be broken into named fields: a struct
+// or string-keyed map, or a pointer to a struct type even when nil, so
+// that every row of a list gets the same columns. Values that marshal
+// themselves to text, such as times, are scalars.
+func isRecord(v reflect.Value) bool {
+    if !v.IsValid() {
+        return false
+    }
+    t := v.Type()
+    if v.Kind() == reflect.Interface && !v.IsNil() {
+        return isRecord(v.Elem())
+    }
+    for t.Kind() == reflect.Pointer {
+        t = t.Elem()
+    }
+    if t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler) {
+        return false
+    }
+    return t.Kind() == reflect.Struct || (t.Kind() == reflect.Map && t.Key().Kind() == reflect.String)
+}
+
+// record returns the value to take a record's fields from, and whether the
+// record is absent: a nil pointer is replaced by the zero value of its type,
+// so its fields can still be named.
+func record(v reflect.Value) (reflect.Value, bool) {
+    v = indirect(v)
+    if v.Kind() == reflect.Pointer && v.IsNil() {
+        return reflect.Zero(v.Type().Elem()), true
+    }
+    return v, false
+}
+
+// isList reports whether v is a slice or array other than bytes.
+func isList(v reflect.Value) bool {
+    v = indirect(v)
+    return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+// isNil reports whether v is absent: invalid, or a nil pointer, interface,
+// map or slice.
+func isNil(v reflect.Value) bool {
+    if !v.IsValid() {
+        return true
+    }
+    switch v.Kind() {
+    case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+        return v.IsNil()
+    }
+    return false
+}
+
+// text formats a scalar the way JSON would show it, without quotes; nil is
+// empty. Lists and records that reach here are written as JSON.
+func text(v reflect.Value) (string, error) {
+    if isNil(v) {
+        return "", nil
+    }
+    v = indirect(v)
+    if v.Type().Implements(textMarshaler) {
+        b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+        return string(b), err
+    }
+    switch v.Kind() {
+    case reflect.String:
+        return v.String(), nil
+    case reflect.Bool:
+        return strconv.FormatBool(v.Bool()), nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return strconv.FormatInt(v.Int(), 10), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return strconv.FormatUint(v.Uint(), 10), nil
+    case reflect.Float32, reflect.Float64:
+        return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+    case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+        b, err := json.Marshal(v.Interface())
+        return string(b), err
+    }
+    return fmt.Sprint(v.Interface()), nil
+}
@@ -0,0 +1,72 @@
+package negotiate
+
+import (
+    "encoding/json"
+    "io"
+)
+
+// JSON writes application/json exactly as encoding/json marshals it, so a
+// streamed list is byte for byte the marshalled slice.
+type JSON struct{}
+
+func (JSON) MediaType() string {
+    return "application/json"
+}
+
+func (JSON) ContentType() string {
+    return "application/json; charset=utf-8"
+}
+
+func (JSON) Encode(w io.Writer, item string, v interface{}) error {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(b)
+    return err
+}
+
+func (JSON) List(w io.Writer, l List) (ListWriter, error) {
+    open := "["
+    if l.Meta != nil {
+        open = `{"data":[`
+    }
+    if _, err := io.WriteString(w, open); err != nil {
+        return nil, err
+    }
+    return &jsonList{w: w, meta: l.Meta}, nil
+}
+
+type jsonList struct {
+    w     io.Writer
+    meta  interface{}
+    count int
+}
+
+func (l *jsonList) Write(item interface{}) error {
+    b, err := json.Marshal(item)
+    if err != nil {
+        return err
+    }
+    if l.count > 0 {
+        if _, err := io.WriteString(l.w, ","); err != nil {
+            return err
+        }
+    }
+    l.count++
+    _, err = l.w.Write(b)
+    return err
+}
+
+func (l *jsonList) Close() error {
+    if l.meta == nil {
+        _, err := io.WriteString(l.w, "]")
+        return err
+    }
+    meta, err := json.Marshal(l.meta)
+    if err != nil {
+        return err
+    }
+    _, err = io.WriteString(l.w, `],"meta":`+string(meta)+"}")
+    return err
+}
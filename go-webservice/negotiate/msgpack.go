@@ -0,0 +1,80 @@
+package negotiate
+
+import (
+    "io"
+
+    "github.com/ugorji/go/codec"
+)
+
+// MsgPack writes application/msgpack, naming struct fields as JSON does
+// and encoding times with the msgpack timestamp extension.
+type MsgPack struct{}
+
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+func (MsgPack) MediaType() string {
+    return "application/msgpack"
+}
+
+func (MsgPack) ContentType() string {
+    return "application/msgpack"
+}
+
+func (MsgPack) Encode(w io.Writer, item string, v interface{}) error {
+    return codec.NewEncoder(w, msgpackHandle).Encode(v)
+}
+
+func (MsgPack) List(w io.Writer, l List) (ListWriter, error) {
+    enc := codec.NewEncoder(w, msgpackHandle)
+    if l.Meta != nil {
+        if _, err := w.Write([]byte{0x82}); err != nil { // map of 2
+            return nil, err
+        }
+        if err := enc.Encode("data"); err != nil {
+            return nil, err
+        }
+    }
+    if _, err := w.Write(arrayHeader(l.Len)); err != nil {
+        return nil, err
+    }
+    return &msgpackList{w: w, enc: enc, meta: l.Meta, left: l.Len}, nil
+}
+
+// arrayHeader is the msgpack header of an n element array.
+func arrayHeader(n int) []byte {
+    switch {
+    case n < 16:
+        return []byte{0x90 | byte(n)}
+    case n <= 0xffff:
+        return []byte{0xdc, byte(n >> 8), byte(n)}
+    }
+    return []byte{0xdd, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+type msgpackList struct {
+    w    io.Writer
+    enc  *codec.Encoder
+    meta interface{}
+    left int
+}
+
+func (l *msgpackList) Write(item interface{}) error {
+    if l.left == 0 {
+        return errTooMany
+    }
+    l.left--
+    return l.enc.Encode(item)
+}
+
+func (l *msgpackList) Close() error {
+    if l.left != 0 {
+        return errTooFew
+    }
+    if l.meta == nil {
+        return nil
+    }
+    if err := l.enc.Encode("meta"); err != nil {
+        return err
+    }
+    return l.enc.Encode(l.meta)
+}
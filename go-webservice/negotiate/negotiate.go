@@ -0,0 +1,192 @@
+// Package negotiate picks a response encoding from the Accept header and
+// writes values and streamed lists in it. JSON, CSV, XML and MessagePack
+// are registered by default; Register adds others.
+package negotiate
+
+import (
+    "errors"
+    "io"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+var (
+    errTooMany = errors.New("negotiate: more list items than announced")
+    errTooFew  = errors.New("negotiate: fewer list items than announced")
+)
+
+// Encoder writes response bodies in one media type.
+type Encoder interface {
+    // MediaType is the type matched against Accept, such as "text/csv".
+    MediaType() string
+    // ContentType is the Content-Type header value of the responses.
+    ContentType() string
+    // Encode writes a single value; item names it where the format needs
+    // a name, such as the XML element.
+    Encode(w io.Writer, item string, v interface{}) error
+    // List starts a list of l.Len items, written one at a time, so a long
+    // list is never built up in memory as a whole.
+    List(w io.Writer, l List) (ListWriter, error)
+}
+
+// List describes a list response.
+type List struct {
+    // Name names the list and Item each element, for formats that need
+    // names, such as "courses" and "course" in XML.
+    Name, Item string
+    // Len is the number of items that will be written.
+    Len int
+    // Meta, when set, is sent with the list: JSON and MessagePack wrap the
+    // list as {"data": [...], "meta": ...}, XML adds a meta element, and
+    // CSV leaves it out.
+    Meta interface{}
+}
+
+// ListWriter writes the items of a list. Close finishes the body and must
+// be called after the last item.
+type ListWriter interface {
+    Write(item interface{}) error
+    Close() error
+}
+
+var (
+    mu       sync.RWMutex
+    encoders []Encoder
+)
+
+func init() {
+    Register(JSON{})
+    Register(CSV{})
+    Register(XML{})
+    Register(MsgPack{})
+}
+
+// Register adds an encoder, replacing any with the same media type. The
+// first registered encoder is the default for requests that accept
+// anything.
+func Register(e Encoder) {
+    mu.Lock()
+    defer mu.Unlock()
+    for i, existing := range encoders {
+        if existing.MediaType() == e.MediaType() {
+            encoders[i] = e
+            return
+        }
+    }
+    encoders = append(encoders, e)
+}
+
+// Supported lists the registered media types.
+func Supported() []string {
+    mu.RLock()
+    defer mu.RUnlock()
+    types := make([]string, len(encoders))
+    for i, e := range encoders {
+        types[i] = e.MediaType()
+    }
+    return types
+}
+
+// aliases are other names clients use for the registered types.
+var aliases = map[string]string{
+    "application/x-msgpack": "application/msgpack",
+    "text/xml":              "application/xml",
+    "application/csv":       "text/csv",
+}
+
+// Negotiate returns the encoder that best matches an Accept header and
+// false when none is acceptable. Each encoder takes the quality of the most
+// specific range covering it, so "*/*, text/csv;q=0" refuses CSV; the
+// highest quality wins, then the most specific range, then registration
+// order. An empty header accepts anything.
+func Negotiate(accept string) (Encoder, bool) {
+    mu.RLock()
+    defer mu.RUnlock()
+    if len(encoders) == 0 {
+        return nil, false
+    }
+    if strings.TrimSpace(accept) == "" {
+        return encoders[0], true
+    }
+    best, _, _ := negotiate(parseAccept(accept))
+    return best, best != nil
+}
+
+// Requested returns the encoder an Accept header explicitly asks for: the
+// one Negotiate picks, if a range naming exactly its type gives it full
+// quality. Browsers list types such as application/xml;q=0.9 that they
+// would take but did not ask for; those are not requested.
+func Requested(accept string) (Encoder, bool) {
+    mu.RLock()
+    defer mu.RUnlock()
+    best, q, spec := negotiate(parseAccept(accept))
+    return best, best != nil && q == 1 && spec == 2
+}
+
+// negotiate returns the best encoder for ranges with the quality and
+// specificity of the range that matched it.
+func negotiate(ranges []acceptRange) (Encoder, float64, int) {
+    var best Encoder
+    bestQ, bestSpec := 0.0, -1
+    for _, e := range encoders {
+        q, spec := 0.0, -1
+        for _, r := range ranges {
+            if s, ok := r.matches(e.MediaType()); ok && s > spec {
+                q, spec = r.q, s
+            }
+        }
+        if q > bestQ || (q == bestQ && q > 0 && spec > bestSpec) {
+            best, bestQ, bestSpec = e, q, spec
+        }
+    }
+    return best, bestQ, bestSpec
+}
+
+type acceptRange struct {
+    typ, sub string
+    q        float64
+}
+
+// parseAccept splits an Accept header into media ranges. Malformed ranges
+// are skipped.
+func parseAccept(header string) []acceptRange {
+    var ranges []acceptRange
+    for _, part := range strings.Split(header, ",") {
+        params := strings.Split(part, ";")
+        mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+        if alias, ok := aliases[mediaType]; ok {
+            mediaType = alias
+        }
+        typ, sub, ok := strings.Cut(mediaType, "/")
+        if !ok || typ == "" || sub == "" || (typ == "*" && sub != "*") {
+            continue
+        }
+        r := acceptRange{typ: typ, sub: sub, q: 1}
+        for _, p := range params[1:] {
+            k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+            if strings.EqualFold(k, "q") {
+                if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+                    r.q = q
+                }
+            }
+        }
+        ranges = append(ranges, r)
+    }
+    return ranges
+}
+
+// matches reports whether the range covers mediaType, and how specific it
+// is: 2 for an exact type, 1 for type/*, 0 for */*.
+func (r acceptRange) matches(mediaType string) (int, bool) {
+    typ, sub, _ := strings.Cut(mediaType, "/")
+    switch {
+    case r.typ == typ && r.sub == sub:
+        return 2, true
+    case r.typ == typ && r.sub == "*":
+        return 1, true
+    case r.typ == "*":
+        return 0, true
+    }
+    return 0, false
+}
@@ -0,0 +1,106 @@
+package negotiate
+
+import (
+    "io"
+    "reflect"
+    "testing"
+)
+
+func TestNegotiate(t *testing.T) {
+    tests := []struct {
+        accept string
+        want   string
+    }{
+        {"", "application/json"},
+        {"*/*", "application/json"},
+        {"application/json", "application/json"},
+        {"text/csv", "text/csv"},
+        {"TEXT/CSV", "text/csv"},
+        {"application/csv", "text/csv"},
+        {"text/xml", "application/xml"},
+        {"application/x-msgpack", "application/msgpack"},
+        {"text/*", "text/csv"},
+        {"application/*", "application/json"},
+        {"application/*;q=0.5, text/csv", "text/csv"},
+        {"text/csv;q=0.2, application/xml;q=0.8", "application/xml"},
+        {"*/*, text/csv;q=0", "application/json"},
+        {"*/*;q=0.1, application/msgpack", "application/msgpack"},
+        {"application/*;q=0.9, application/xml", "application/xml"},
+        {"text/html, */*;q=0.8", "application/json"},
+        {"application/json;q=bad", "application/json"},
+        {"garbage, text/csv", "text/csv"},
+        {"*/csv, application/xml", "application/xml"},
+        {"text/html", ""},
+        {"application/json;q=0", ""},
+        {"*/*;q=0", ""},
+    }
+    for _, tt := range tests {
+        e, ok := Negotiate(tt.accept)
+        got := ""
+        if ok {
+            got = e.MediaType()
+        }
+        if got != tt.want {
+            t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+        }
+    }
+}
+
+func TestRequested(t *testing.T) {
+    tests := []struct {
+        accept string
+        want   string
+    }{
+        {"text/csv", "text/csv"},
+        {"application/xml", "application/xml"},
+        {"text/html, application/json", "application/json"},
+        {"application/xml;q=0.9, */*;q=0.8", ""},
+        {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", ""},
+        {"text/*", ""},
+        {"*/*", ""},
+        {"", ""},
+    }
+    for _, tt := range tests {
+        e, ok := Requested(tt.accept)
+        got := ""
+        if ok {
+            got = e.MediaType()
+        }
+        if got != tt.want {
+            t.Errorf("Requested(%q) = %q, want %q", tt.accept, got, tt.want)
+        }
+    }
+}
+
+type plain struct{}
+
+func (plain) MediaType() string   { return "text/plain" }
+func (plain) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (plain) Encode(w io.Writer, item string, v interface{}) error {
+    return nil
+}
+
+func (plain) List(w io.Writer, l List) (ListWriter, error) {
+    return nil, nil
+}
+
+func TestRegister(t *testing.T) {
+    saved := append([]Encoder(nil), encoders...)
+    t.Cleanup(func() { encoders = saved })
+
+    Register(plain{})
+    Register(JSON{})
+    want := []string{"application/json", "text/csv", "application/xml", "application/msgpack", "text/plain"}
+    if got := Supported(); !reflect.DeepEqual(got, want) {
+        t.Fatalf("Supported() = %v, want %v", got, want)
+    }
+    if e, ok := Negotiate("text/plain"); !ok || e.MediaType() != "text/plain" {
+        t.Fatal("registered encoder is not negotiated")
+    }
+
+    encoders = nil
+    if _, ok := Negotiate(""); ok {
+        t.Fatal("negotiated with no encoders")
+    }
+}
@@ -0,0 +1,110 @@
+package negotiate
+
+import (
+    "encoding/xml"
+    "io"
+    "reflect"
+)
+
+// XML writes application/xml with elements named after the JSON fields.
+// Nested records become nested elements, list elements are named item,
+// and absent values are left out.
+type XML struct{}
+
+func (XML) MediaType() string {
+    return "application/xml"
+}
+
+func (XML) ContentType() string {
+    return "application/xml; charset=utf-8"
+}
+
+func (XML) Encode(w io.Writer, item string, v interface{}) error {
+    enc, err := startXML(w)
+    if err != nil {
+        return err
+    }
+    if err := writeElement(enc, item, reflect.ValueOf(v)); err != nil {
+        return err
+    }
+    return enc.Flush()
+}
+
+func (XML) List(w io.Writer, l List) (ListWriter, error) {
+    enc, err := startXML(w)
+    if err != nil {
+        return nil, err
+    }
+    root := xml.StartElement{Name: xml.Name{Local: l.Name}}
+    if err := enc.EncodeToken(root); err != nil {
+        return nil, err
+    }
+    return &xmlList{enc: enc, root: root, item: l.Item, meta: l.Meta}, nil
+}
+
+func startXML(w io.Writer) (*xml.Encoder, error) {
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return nil, err
+    }
+    return xml.NewEncoder(w), nil
+}
+
+type xmlList struct {
+    enc  *xml.Encoder
+    root xml.StartElement
+    item string
+    meta interface{}
+}
+
+func (l *xmlList) Write(item interface{}) error {
+    return writeElement(l.enc, l.item, reflect.ValueOf(item))
+}
+
+func (l *xmlList) Close() error {
+    if l.meta != nil {
+        if err := writeElement(l.enc, "meta", reflect.ValueOf(l.meta)); err != nil {
+            return err
+        }
+    }
+    if err := l.enc.EncodeToken(l.root.End()); err != nil {
+        return err
+    }
+    return l.enc.Flush()
+}
+
+func writeElement(enc *xml.Encoder, name string, v reflect.Value) error {
+    if isNil(v) {
+        return nil
+    }
+    start := xml.StartElement{Name: xml.Name{Local: name}}
+    if err := enc.EncodeToken(start); err != nil {
+        return err
+    }
+    switch {
+    case isRecord(v):
+        for _, f := range fieldsOf(v) {
+            if f.omitEmpty && isEmpty(f.value) {
+                continue
+            }
+            if err := writeElement(enc, f.name, f.value); err != nil {
+                return err
+            }
+        }
+    case isList(v):
+        list := indirect(v)
+        for i := 0; i < list.Len(); i++ {
+            if err := writeElement(enc, "item", list.Index(i)); err != nil {
+                return err
+            }
+        }
+    default:
+        s, err := text(v)
+        if err != nil {
+            return err
+        }
+        if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+            return err
+        }
+    }
+    return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,121 @@
+// Package notify renders and sends email. Templates are embedded with the
+// binary and come in per-locale variants; a Notifier delivers the result.
+package notify
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "log"
+    "mime"
+    "mime/multipart"
+    "mime/quotedprintable"
+    "net/mail"
+    "net/textproto"
+    "strings"
+    "time"
+)
+
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Message is a rendered email. HTML is optional; Text is always sent.
+type Message struct {
+    To      []string `json:"to,omitempty"`
+    Subject string   `json:"subject"`
+    Text    string   `json:"text"`
+    HTML    string   `json:"html,omitempty"`
+}
+
+// Notifier delivers messages.
+type Notifier interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// Log writes messages to the log instead of sending them. It is used when
+// no SMTP server is configured.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+    log.Printf("email to %s: %q (SMTP not configured, not sent)", strings.Join(msg.To, ", "), msg.Subject)
+    return nil
+}
+
+// ValidateAddresses rejects anything that is not a plain address, which
+// also keeps header injection out of To.
+func ValidateAddresses(addrs []string) error {
+    if len(addrs) == 0 {
+        return fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+    }
+    for _, a := range addrs {
+        parsed, err := mail.ParseAddress(a)
+        if err != nil || parsed.Address != a {
+            return fmt.Errorf("%w: %q", ErrInvalidAddress, a)
+        }
+    }
+    return nil
+}
+
+// Encode builds the RFC 5322 message: text only, or multipart/alternative
+// when there is an HTML part.
+func Encode(from string, msg Message, now time.Time) ([]byte, error) {
+    var buf bytes.Buffer
+    id := make([]byte, 16)
+    if _, err := rand.Read(id); err != nil {
+        return nil, err
+    }
+    domain := "localhost"
+    if at := strings.LastIndex(from, "@"); at >= 0 {
+        domain = strings.TrimSuffix(from[at+1:], ">")
+    }
+    header := []string{
+        "From: " + from,
+        "To: " + strings.Join(msg.To, ", "),
+        "Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+        "Date: " + now.Format(time.RFC1123Z),
+        "Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">",
+        "MIME-Version: 1.0",
+    }
+    for _, h := range header {
+        buf.WriteString(h + "\r\n")
+    }
+
+    if msg.HTML == "" {
+        buf.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+        if err := writeQP(&buf, msg.Text); err != nil {
+            return nil, err
+        }
+        return buf.Bytes(), nil
+    }
+    mw := multipart.NewWriter(&buf)
+    fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+    for _, part := range []struct{ contentType, body string }{
+        {"text/plain; charset=utf-8", msg.Text},
+        {"text/html; charset=utf-8", msg.HTML},
+    } {
+        w, err := mw.CreatePart(textproto.MIMEHeader{
+            "Content-Type":              {part.contentType},
+            "Content-Transfer-Encoding": {"quoted-printable"},
+        })
+        if err != nil {
+            return nil, err
+        }
+        if err := writeQP(w, part.body); err != nil {
+            return nil, err
+        }
+    }
+    if err := mw.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func writeQP(w interface{ Write([]byte) (int, error) }, s string) error {
+    qp := quotedprintable.NewWriter(w)
+    if _, err := qp.Write([]byte(s)); err != nil {
+        return err
+    }
+    return qp.Close()
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+    "context"
+    "crypto/tls"
+    "errors"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "net/textproto"
+    "strconv"
+    "time"
+)
+
+// SMTP modes for the connection security.
+const (
+    SMTPStartTLS = "starttls" // upgrade if the server offers STARTTLS
+    SMTPTLS      = "tls"      // implicit TLS, usually port 465
+    SMTPPlain    = "none"
+)
+
+// SMTP sends mail through a relay. Credentials are only sent over TLS or
+// to localhost, as net/smtp enforces.
+type SMTP struct {
+    Host     string
+    Port     int
+    Username string
+    Password string
+    From     string
+    Security string
+    Timeout  time.Duration
+    // TLSConfig overrides the client TLS settings, e.g. to trust a test CA.
+    TLSConfig *tls.Config
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+    if err := ValidateAddresses(msg.To); err != nil {
+        return err
+    }
+    body, err := Encode(s.From, msg, time.Now())
+    if err != nil {
+        return err
+    }
+
+    timeout := s.Timeout
+    if timeout == 0 {
+        timeout = 30 * time.Second
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+    dialer := &net.Dialer{}
+    conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+    if err != nil {
+        return err
+    }
+    deadline, _ := ctx.Deadline()
+    conn.SetDeadline(deadline)
+    if s.Security == SMTPTLS {
+        conn = tls.Client(conn, s.tlsConfig())
+    }
+    c, err := smtp.NewClient(conn, s.Host)
+    if err != nil {
+        conn.Close()
+        return err
+    }
+    defer c.Close()
+
+    if s.Security != SMTPTLS && s.Security != SMTPPlain {
+        if ok, _ := c.Extension("STARTTLS"); ok {
+            if err := c.StartTLS(s.tlsConfig()); err != nil {
+                return err
+            }
+        }
+    }
+    if s.Username != "" {
+        if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+            return err
+        }
+    }
+    if err := c.Mail(addressOnly(s.From)); err != nil {
+        return err
+    }
+    for _, to := range msg.To {
+        if err := c.Rcpt(to); err != nil {
+            return err
+        }
+    }
+    w, err := c.Data()
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(body); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return c.Quit()
+}
+
+func (s *SMTP) tlsConfig() *tls.Config {
+    if s.TLSConfig != nil {
+        return s.TLSConfig
+    }
+    return &tls.Config{ServerName: s.Host}
+}
+
+// Rejected reports whether err is a permanent (5xx) SMTP rejection, which
+// a retry will not fix.
+func Rejected(err error) bool {
+    var te *textproto.Error
+    return errors.As(err, &te) && te.Code >= 500
+}
+
+// addressOnly strips a display name: "Courses <no-reply@x>" -> "no-reply@x".
+func addressOnly(from string) string {
+    if a, err := mail.ParseAddress(from); err == nil {
+        return a.Address
+    }
+    return from
+}
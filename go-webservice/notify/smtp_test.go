@@ -0,0 +1,304 @@
+package notify
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/base64"
+    "errors"
+    "io"
+    "mime"
+    "mime/multipart"
+    "mime/quotedprintable"
+    "net"
+    "net/http/httptest"
+    "net/mail"
+    "net/textproto"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// envelope is one message accepted by fakeSMTP.
+type envelope struct {
+    From string
+    To   []string
+    Data string
+    Auth string // "user:password" when the client authenticated
+    TLS  bool
+}
+
+// fakeSMTP is a minimal mail relay on localhost. It speaks enough ESMTP
+// for net/smtp: EHLO, optional STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA and
+// QUIT, and refuses recipients at reject.example.
+type fakeSMTP struct {
+    ln       net.Listener
+    tls      *tls.Config
+    startTLS bool
+
+    mu   sync.Mutex
+    sent []envelope
+}
+
+func newFakeSMTP(t *testing.T, startTLS bool) *fakeSMTP {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // borrow httptest's certificate, which is valid for 127.0.0.1
+    srv := httptest.NewUnstartedServer(nil)
+    srv.StartTLS()
+    srv.Close()
+    f := &fakeSMTP{ln: ln, tls: srv.TLS, startTLS: startTLS}
+    t.Cleanup(func() { ln.Close() })
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go f.serve(conn)
+        }
+    }()
+    return f
+}
+
+func (f *fakeSMTP) port() int {
+    return f.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) clientTLS() *tls.Config {
+    pool := x509.NewCertPool()
+    cert, _ := x509.ParseCertificate(f.tls.Certificates[0].Certificate[0])
+    pool.AddCert(cert)
+    return &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+}
+
+func (f *fakeSMTP) messages() []envelope {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([]envelope(nil), f.sent...)
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(10 * time.Second))
+    tp := textproto.NewConn(conn)
+    var env envelope
+    tp.PrintfLine("220 fake ESMTP")
+    for {
+        line, err := tp.ReadLine()
+        if err != nil {
+            return
+        }
+        verb, arg, _ := strings.Cut(line, " ")
+        switch strings.ToUpper(verb) {
+        case "EHLO":
+            exts := []string{"250-fake", "250-AUTH PLAIN"}
+            if f.startTLS && !env.TLS {
+                exts = append(exts, "250-STARTTLS")
+            }
+            for _, e := range exts {
+                tp.PrintfLine("%s", e)
+            }
+            tp.PrintfLine("250 8BITMIME")
+        case "STARTTLS":
+            tp.PrintfLine("220 go ahead")
+            tlsConn := tls.Server(conn, f.tls)
+            if tlsConn.Handshake() != nil {
+                return
+            }
+            conn = tlsConn
+            tp = textproto.NewConn(conn)
+            env = envelope{TLS: true}
+        case "AUTH":
+            raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+            parts := strings.Split(string(raw), "\x00")
+            if len(parts) != 3 || parts[2] != "secret" {
+                tp.PrintfLine("535 bad credentials")
+                continue
+            }
+            env.Auth = parts[1] + ":" + parts[2]
+            tp.PrintfLine("235 ok")
+        case "MAIL":
+            from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:"), " ")
+            env.From = strings.Trim(from, "<>")
+            tp.PrintfLine("250 ok")
+        case "RCPT":
+            to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+            if strings.HasSuffix(to, "@reject.example") {
+                tp.PrintfLine("550 no such user")
+                continue
+            }
+            env.To = append(env.To, to)
+            tp.PrintfLine("250 ok")
+        case "DATA":
+            tp.PrintfLine("354 go on")
+            data, err := tp.ReadDotBytes()
+            if err != nil {
+                return
+            }
+            env.Data = string(data)
+            f.mu.Lock()
+            f.sent = append(f.sent, env)
+            f.mu.Unlock()
+            tp.PrintfLine("250 queued")
+        case "RSET":
+            env = envelope{TLS: env.TLS}
+            tp.PrintfLine("250 ok")
+        case "QUIT":
+            tp.PrintfLine("221 bye")
+            return
+        default:
+            tp.PrintfLine("502 not implemented")
+        }
+    }
+}
+
+// parse splits a sent message into its headers and its text and HTML
+// bodies.
+func parse(t *testing.T, data string) (*mail.Message, string, string) {
+    t.Helper()
+    msg, err := mail.ReadMessage(strings.NewReader(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if mediaType == "text/plain" {
+        body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+        return msg, string(body), ""
+    }
+    var text, html string
+    mr := multipart.NewReader(msg.Body, params["boundary"])
+    for {
+        part, err := mr.NextPart()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatal(err)
+        }
+        body, _ := io.ReadAll(part)
+        switch {
+        case strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"):
+            text = string(body)
+        case strings.HasPrefix(part.Header.Get("Content-Type"), "text/html"):
+            html = string(body)
+        }
+    }
+    return msg, text, html
+}
+
+func TestSMTPSend(t *testing.T) {
+    f := newFakeSMTP(t, false)
+    s := &SMTP{Host: "127.0.0.1", Port: f.port(), From: "Courses <no-reply@courses.example>", Security: SMTPPlain}
+    msg, err := Render("enrollment_confirmation", "es-MX", Sample("enrollment_confirmation"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    msg.To = []string{"ada@example.com", "grace@example.com"}
+    if err := s.Send(context.Background(), msg); err != nil {
+        t.Fatal(err)
+    }
+
+    sent := f.messages()
+    if len(sent) != 1 {
+        t.Fatalf("%d messages sent", len(sent))
+    }
+    env := sent[0]
+    if env.From != "no-reply@courses.example" || strings.Join(env.To, ",") != "ada@example.com,grace@example.com" {
+        t.Fatalf("envelope from %q to %v", env.From, env.To)
+    }
+    header, text, html := parse(t, env.Data)
+    subject, _ := new(mime.WordDecoder).DecodeHeader(header.Header.Get("Subject"))
+    if subject != "Te has inscrito en Go Basics" {
+        t.Errorf("subject %q", subject)
+    }
+    if !strings.HasSuffix(header.Header.Get("Message-ID"), "@courses.example>") {
+        t.Errorf("message id %q", header.Header.Get("Message-ID"))
+    }
+    if !strings.Contains(text, "Hola Ada:") || !strings.Contains(text, "https://courses.example.com/courses/1") {
+        t.Errorf("text part:\n%s", text)
+    }
+    if !strings.Contains(html, "Ada") {
+        t.Errorf("html part:\n%s", html)
+    }
+}
+
+func TestSMTPStartTLSAndAuth(t *testing.T) {
+    f := newFakeSMTP(t, true)
+    s := &SMTP{
+        Host: "127.0.0.1", Port: f.port(), From: "no-reply@courses.example",
+        Username: "mailer", Password: "secret", Security: SMTPStartTLS, TLSConfig: f.clientTLS(),
+    }
+    if err := s.Send(context.Background(), Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hello\n"}); err != nil {
+        t.Fatal(err)
+    }
+    sent := f.messages()
+    if len(sent) != 1 || !sent[0].TLS || sent[0].Auth != "mailer:secret" {
+        t.Fatalf("sent %+v, want one authenticated message over TLS", sent)
+    }
+    _, text, _ := parse(t, sent[0].Data)
+    if text != "Hello\n" {
+        t.Fatalf("text %q", text)
+    }
+
+    s.Password = "wrong"
+    err := s.Send(context.Background(), Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hello\n"})
+    if !Rejected(err) {
+        t.Fatalf("bad credentials: %v, want a permanent rejection", err)
+    }
+}
+
+func TestSMTPRejections(t *testing.T) {
+    f := newFakeSMTP(t, false)
+    s := &SMTP{Host: "127.0.0.1", Port: f.port(), From: "no-reply@courses.example", Security: SMTPPlain}
+
+    err := s.Send(context.Background(), Message{To: []string{"nobody@reject.example"}, Subject: "Hi", Text: "x"})
+    if !Rejected(err) {
+        t.Fatalf("refused recipient: %v, want a permanent rejection", err)
+    }
+    err = s.Send(context.Background(), Message{To: []string{"Ada <ada@example.com>\r\nBcc: everyone@example.com"}, Subject: "Hi", Text: "x"})
+    if !errors.Is(err, ErrInvalidAddress) {
+        t.Fatalf("header injection: %v, want ErrInvalidAddress", err)
+    }
+    if len(f.messages()) != 0 {
+        t.Fatal("a rejected message was delivered")
+    }
+
+    closed, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    s.Port = closed.Addr().(*net.TCPAddr).Port
+    closed.Close()
+    s.Timeout = time.Second
+    if err := s.Send(context.Background(), Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "x"}); err == nil || Rejected(err) {
+        t.Fatalf("unreachable relay: %v, want a retryable error", err)
+    }
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+    for locale, want := range map[string]string{
+        "":      "You're enrolled in Go Basics",
+        "en-GB": "You're enrolled in Go Basics",
+        "es":    "Te has inscrito en Go Basics",
+        "es_AR": "Te has inscrito en Go Basics",
+        "de":    "You're enrolled in Go Basics",
+    } {
+        msg, err := Render("enrollment_confirmation", locale, Sample("enrollment_confirmation"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if msg.Subject != want {
+            t.Errorf("locale %q: subject %q, want %q", locale, msg.Subject, want)
+        }
+    }
+    if _, err := Render("nope", "en", nil); !errors.Is(err, ErrUnknownTemplate) {
+        t.Fatalf("unknown template: %v", err)
+    }
+}
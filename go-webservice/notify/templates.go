@@ -0,0 +1,140 @@
+package notify
+
+import (
+    "bytes"
+    "embed"
+    "errors"
+    "fmt"
+    htmltemplate "html/template"
+    "io/fs"
+    "path"
+    "sort"
+    "strings"
+    texttemplate "text/template"
+)
+
+// DefaultLocale is used when a template has no variant for the requested
+// locale or its language.
+const DefaultLocale = "en"
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Templates live in templates/<name>.<locale>.txt, which defines the
+// "subject" and "text" blocks, with an optional <name>.<locale>.html body.
+//
+//go:embed templates
+var templateFS embed.FS
+
+type variant struct {
+    text *texttemplate.Template
+    html *htmltemplate.Template
+}
+
+// templates maps name -> locale -> variant.
+var templates = mustParse(templateFS)
+
+// samples hold example data for previews.
+var samples = map[string]map[string]interface{}{
+    "enrollment_confirmation": {
+        "Name":        "Ada",
+        "CourseTitle": "Go Basics",
+        "CourseURL":   "https://courses.example.com/courses/1",
+    },
+}
+
+func mustParse(fsys fs.FS) map[string]map[string]variant {
+    parsed := make(map[string]map[string]variant)
+    files, err := fs.Glob(fsys, "templates/*.txt")
+    if err != nil {
+        panic(err)
+    }
+    for _, file := range files {
+        base := strings.TrimSuffix(path.Base(file), ".txt")
+        name, locale, ok := strings.Cut(base, ".")
+        if !ok {
+            panic("notify: template file without locale: " + file)
+        }
+        var v variant
+        v.text = texttemplate.Must(texttemplate.New(base).Option("missingkey=error").ParseFS(fsys, file))
+        htmlFile := "templates/" + base + ".html"
+        if _, err := fs.Stat(fsys, htmlFile); err == nil {
+            v.html = htmltemplate.Must(htmltemplate.New(path.Base(htmlFile)).Option("missingkey=error").ParseFS(fsys, htmlFile))
+        }
+        if parsed[name] == nil {
+            parsed[name] = make(map[string]variant)
+        }
+        parsed[name][locale] = v
+    }
+    return parsed
+}
+
+// TemplateInfo describes an available template.
+type TemplateInfo struct {
+    Name    string   `json:"name"`
+    Locales []string `json:"locales"`
+}
+
+func Templates() []TemplateInfo {
+    infos := make([]TemplateInfo, 0, len(templates))
+    for name, variants := range templates {
+        info := TemplateInfo{Name: name}
+        for locale := range variants {
+            info.Locales = append(info.Locales, locale)
+        }
+        sort.Strings(info.Locales)
+        infos = append(infos, info)
+    }
+    sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+    return infos
+}
+
+// Sample returns example data for a template, for previews.
+func Sample(name string) map[string]interface{} {
+    return samples[name]
+}
+
+// Render fills in a template for locale, falling back from "pt-BR" to
+// "pt" to DefaultLocale. The returned message has no recipients.
+func Render(name, locale string, data interface{}) (Message, error) {
+    variants, ok := templates[name]
+    if !ok {
+        return Message{}, fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+    }
+    v, ok := pick(variants, locale)
+    if !ok {
+        return Message{}, fmt.Errorf("%w: %q has no %s variant", ErrUnknownTemplate, name, DefaultLocale)
+    }
+    var msg Message
+    var buf bytes.Buffer
+    if err := v.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+        return msg, err
+    }
+    msg.Subject = strings.TrimSpace(buf.String())
+    buf.Reset()
+    if err := v.text.ExecuteTemplate(&buf, "text", data); err != nil {
+        return msg, err
+    }
+    msg.Text = strings.TrimSpace(buf.String()) + "\n"
+    if v.html != nil {
+        buf.Reset()
+        if err := v.html.Execute(&buf, data); err != nil {
+            return msg, err
+        }
+        msg.HTML = buf.String()
+    }
+    return msg, nil
+}
+
+func pick(variants map[string]variant, locale string) (variant, bool) {
+    locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+    candidates := []string{locale}
+    if lang, _, ok := strings.Cut(locale, "-"); ok {
+        candidates = append(candidates, lang)
+    }
+    for _, l := range append(candidates, DefaultLocale) {
+        if v, ok := variants[l]; ok {
+            return v, true
+        }
+    }
+    return variant{}, false
+}
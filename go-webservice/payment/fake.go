@@ -0,0 +1,90 @@
+package payment
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "go-webservice/webhook"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+const (
+    HeaderFakeTimestamp = "X-Fake-Payment-Timestamp"
+    HeaderFakeSignature = "X-Fake-Payment-Signature"
+)
+
+var ErrUnknownPayment = errors.New("unknown payment")
+
+// notifications older than this are rejected as replays
+const maxNotificationAge = 5 * time.Minute
+
+// Fake is an in-process provider for development and tests. Payments stay
+// pending until Complete is called, which produces the signed notification
+// a real provider would post back.
+type Fake struct {
+    Secret string
+
+    mu       sync.Mutex
+    payments map[string]Payment
+}
+
+func NewFake(secret string) *Fake {
+    return &Fake{Secret: secret, payments: make(map[string]Payment)}
+}
+
+func (f *Fake) CreatePayment(ctx context.Context, p Payment) (Intent, error) {
+    buf := make([]byte, 12)
+    if _, err := rand.Read(buf); err != nil {
+        return Intent{}, err
+    }
+    id := "fake_" + hex.EncodeToString(buf)
+    f.mu.Lock()
+    f.payments[id] = p
+    f.mu.Unlock()
+    return Intent{ID: id, Status: StatusPending, CheckoutURL: "/payments/fake/" + id}, nil
+}
+
+// Complete settles a payment and returns the notification for it.
+func (f *Fake) Complete(id string, succeeded bool) (http.Header, []byte, error) {
+    f.mu.Lock()
+    p, ok := f.payments[id]
+    f.mu.Unlock()
+    if !ok {
+        return nil, nil, ErrUnknownPayment
+    }
+    result := Result{PaymentID: id, Reference: p.Reference, Amount: p.Amount, Currency: p.Currency, Status: StatusSucceeded}
+    if !succeeded {
+        result.Status = StatusFailed
+        result.Reason = "card declined"
+    }
+    body, err := json.Marshal(result)
+    if err != nil {
+        return nil, nil, err
+    }
+    ts := time.Now().Unix()
+    header := http.Header{}
+    header.Set("Content-Type", "application/json")
+    header.Set(HeaderFakeTimestamp, strconv.FormatInt(ts, 10))
+    header.Set(HeaderFakeSignature, webhook.Sign(f.Secret, ts, body))
+    return header, body, nil
+}
+
+func (f *Fake) ParseNotification(header http.Header, body []byte) (Result, error) {
+    ts, err := strconv.ParseInt(header.Get(HeaderFakeTimestamp), 10, 64)
+    if err != nil || time.Since(time.Unix(ts, 0)) > maxNotificationAge {
+        return Result{}, ErrInvalidNotification
+    }
+    if !webhook.Verify(f.Secret, ts, body, header.Get(HeaderFakeSignature)) {
+        return Result{}, ErrInvalidNotification
+    }
+    var result Result
+    if err := json.Unmarshal(body, &result); err != nil {
+        return Result{}, ErrInvalidNotification
+    }
+    return result, nil
+}
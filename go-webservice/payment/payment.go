@@ -0,0 +1,52 @@
+// Package payment abstracts the payment provider used at checkout. The
+// provider takes a payment for an order and later reports the outcome in a
+// signed notification, which is what grants the purchase.
+package payment
+
+import (
+    "context"
+    "errors"
+    "net/http"
+)
+
+const (
+    StatusPending   = "pending"
+    StatusSucceeded = "succeeded"
+    StatusFailed    = "failed"
+)
+
+var ErrInvalidNotification = errors.New("invalid payment notification")
+
+// Payment is a request to charge Amount minor units of Currency. Reference
+// is our order ID and comes back in the notification.
+type Payment struct {
+    Reference   string
+    Amount      int64
+    Currency    string
+    Description string
+}
+
+// Intent is the provider's handle on a payment. The client completes it at
+// CheckoutURL.
+type Intent struct {
+    ID          string `json:"id"`
+    Status      string `json:"status"`
+    CheckoutURL string `json:"checkout_url,omitempty"`
+}
+
+// Result is the outcome a provider reports for a payment.
+type Result struct {
+    PaymentID string `json:"payment_id"`
+    Reference string `json:"reference"`
+    Amount    int64  `json:"amount"`
+    Currency  string `json:"currency"`
+    Status    string `json:"status"`
+    Reason    string `json:"reason,omitempty"`
+}
+
+type Provider interface {
+    CreatePayment(ctx context.Context, p Payment) (Intent, error)
+    // ParseNotification verifies a notification sent by the provider and
+    // returns the result it reports, or ErrInvalidNotification.
+    ParseNotification(header http.Header, body []byte) (Result, error)
+}
@@ -0,0 +1,32 @@
+package replica
+
+import "gorm.io/gorm"
+
+// Plugin marks the statement's session as written after every create,
+// update, delete and raw statement on the primary, so the session's later
+// reads see the change. Install it on the primary only.
+type Plugin struct{}
+
+func (Plugin) Name() string {
+    return "replica"
+}
+
+func (Plugin) Initialize(db *gorm.DB) error {
+    cb := db.Callback()
+    if err := cb.Create().After("gorm:create").Register("replica:create", markWritten); err != nil {
+        return err
+    }
+    if err := cb.Update().After("gorm:update").Register("replica:update", markWritten); err != nil {
+        return err
+    }
+    if err := cb.Delete().After("gorm:delete").Register("replica:delete", markWritten); err != nil {
+        return err
+    }
+    return cb.Raw().After("gorm:raw").Register("replica:raw", markWritten)
+}
+
+func markWritten(db *gorm.DB) {
+    if db.Statement.Context != nil {
+        MarkWritten(db.Statement.Context)
+    }
+}
@@ -0,0 +1,133 @@
+package replica
+
+import (
+    "context"
+    "log"
+    "sync/atomic"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// Router hands out connections for reads. Reads go round robin to the
+// healthy replicas and fall back to the primary when there are none, or
+// when the caller's session has already written (see WithSession). Writes
+// always use the primary directly.
+type Router struct {
+    primary  *gorm.DB
+    replicas []*node
+    next     atomic.Uint32
+}
+
+type node struct {
+    index   int
+    db      *gorm.DB
+    healthy atomic.Bool
+}
+
+// PingTimeout bounds each health check of a replica.
+const PingTimeout = 2 * time.Second
+
+// New returns a router over primary and replicas. Replicas start healthy;
+// one is taken out of rotation when a ping fails, either from Check or
+// after a failed query, and put back by the next successful Check.
+func New(primary *gorm.DB, replicas ...*gorm.DB) (*Router, error) {
+    r := &Router{primary: primary}
+    for i, db := range replicas {
+        n := &node{index: i, db: db}
+        n.healthy.Store(true)
+        cb := db.Callback()
+        if err := cb.Query().After("gorm:query").Register("replica:health", n.afterQuery); err != nil {
+            return nil, err
+        }
+        if err := cb.Row().After("gorm:row").Register("replica:health", n.afterQuery); err != nil {
+            return nil, err
+        }
+        r.replicas = append(r.replicas, n)
+    }
+    return r, nil
+}
+
+// Primary returns the primary connection.
+func (r *Router) Primary() *gorm.DB {
+    return r.primary
+}
+
+// Read returns the connection a read-only query in ctx should use.
+func (r *Router) Read(ctx context.Context) *gorm.DB {
+    if len(r.replicas) == 0 || Wrote(ctx) {
+        return r.primary
+    }
+    start := r.next.Add(1)
+    for i := range r.replicas {
+        n := r.replicas[(int(start)+i)%len(r.replicas)]
+        if n.healthy.Load() {
+            return n.db
+        }
+    }
+    return r.primary
+}
+
+// Healthy returns how many replicas are in rotation and how many there are.
+func (r *Router) Healthy() (healthy, total int) {
+    for _, n := range r.replicas {
+        if n.healthy.Load() {
+            healthy++
+        }
+    }
+    return healthy, len(r.replicas)
+}
+
+// Check pings every replica and updates its place in the rotation.
+func (r *Router) Check(ctx context.Context) {
+    for _, n := range r.replicas {
+        err := n.ping(ctx)
+        if was := n.healthy.Swap(err == nil); was != (err == nil) {
+            if err != nil {
+                log.Printf("replica %d: out of rotation: %v", n.index, err)
+            } else {
+                log.Printf("replica %d: back in rotation", n.index)
+            }
+        }
+    }
+}
+
+// Run checks the replicas every interval until ctx is done.
+func (r *Router) Run(ctx context.Context, interval time.Duration) {
+    if len(r.replicas) == 0 {
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            r.Check(ctx)
+        }
+    }
+}
+
+func (n *node) ping(ctx context.Context) error {
+    sqlDB, err := n.db.DB()
+    if err != nil {
+        return err
+    }
+    ctx, cancel := context.WithTimeout(ctx, PingTimeout)
+    defer cancel()
+    return sqlDB.PingContext(ctx)
+}
+
+// afterQuery takes the replica out of rotation when a query fails because
+// the replica itself is unreachable, rather than because of the query.
+func (n *node) afterQuery(db *gorm.DB) {
+    if db.Error == nil || db.Error == gorm.ErrRecordNotFound || !n.healthy.Load() {
+        return
+    }
+    if err := n.ping(context.Background()); err != nil {
+        if n.healthy.Swap(false) {
+            log.Printf("replica %d: out of rotation: %v", n.index, err)
+        }
+    }
+}
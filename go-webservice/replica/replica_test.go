@@ -0,0 +1,182 @@
+package replica
+
+import (
+    "context"
+    "path/filepath"
+    "testing"
+
+    "github.com/glebarez/sqlite"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+// name is a row naming the database it is stored in, so a read shows
+// which database served it.
+type name struct {
+    ID   int
+    Name string
+}
+
+func open(t *testing.T, label string) *gorm.DB {
+    t.Helper()
+    db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), label+".db")), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := db.AutoMigrate(&name{}); err != nil {
+        t.Fatal(err)
+    }
+    if err := db.Create(&name{ID: 1, Name: label}).Error; err != nil {
+        t.Fatal(err)
+    }
+    sqlDB, _ := db.DB()
+    t.Cleanup(func() { sqlDB.Close() })
+    return db
+}
+
+func served(t *testing.T, ctx context.Context, db *gorm.DB) string {
+    t.Helper()
+    var n name
+    if err := db.WithContext(ctx).First(&n, 1).Error; err != nil {
+        t.Fatalf("read: %v", err)
+    }
+    return n.Name
+}
+
+func closeDB(t *testing.T, db *gorm.DB) {
+    t.Helper()
+    sqlDB, err := db.DB()
+    if err != nil {
+        t.Fatal(err)
+    }
+    sqlDB.Close()
+}
+
+func newRouter(t *testing.T) (*Router, *gorm.DB, []*gorm.DB) {
+    t.Helper()
+    primary := open(t, "primary")
+    if err := primary.Use(Plugin{}); err != nil {
+        t.Fatal(err)
+    }
+    replicas := []*gorm.DB{open(t, "replica-0"), open(t, "replica-1")}
+    r, err := New(primary, replicas...)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return r, primary, replicas
+}
+
+func TestReadRoundRobin(t *testing.T) {
+    r, _, _ := newRouter(t)
+    ctx := context.Background()
+    seen := map[string]int{}
+    for i := 0; i < 4; i++ {
+        seen[served(t, ctx, r.Read(ctx))]++
+    }
+    if seen["replica-0"] != 2 || seen["replica-1"] != 2 {
+        t.Fatalf("reads went to %v, want two to each replica", seen)
+    }
+}
+
+func TestNoReplicas(t *testing.T) {
+    primary := open(t, "primary")
+    r, err := New(primary)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if r.Read(context.Background()) != primary {
+        t.Fatal("read without replicas did not use the primary")
+    }
+    if healthy, total := r.Healthy(); healthy != 0 || total != 0 {
+        t.Fatalf("Healthy() = %d, %d", healthy, total)
+    }
+    // Run returns at once when there is nothing to check
+    r.Run(context.Background(), 0)
+}
+
+func TestReadYourWrites(t *testing.T) {
+    r, primary, _ := newRouter(t)
+    ctx := WithSession(context.Background())
+    if WithSession(ctx) != ctx {
+        t.Fatal("nested WithSession started a new session")
+    }
+    if got := served(t, ctx, r.Read(ctx)); got == "primary" {
+        t.Fatal("session read the primary before writing")
+    }
+
+    if err := primary.WithContext(ctx).Create(&name{ID: 2, Name: "written"}).Error; err != nil {
+        t.Fatal(err)
+    }
+    if !Wrote(ctx) {
+        t.Fatal("write did not mark the session")
+    }
+    for i := 0; i < 3; i++ {
+        if got := served(t, ctx, r.Read(ctx)); got != "primary" {
+            t.Fatalf("read after write served by %s", got)
+        }
+    }
+
+    // other sessions, and callers outside any, still use the replicas
+    other := WithSession(context.Background())
+    if got := served(t, other, r.Read(other)); got == "primary" {
+        t.Fatal("another session was pinned to the primary")
+    }
+    MarkWritten(context.Background())
+    if Wrote(context.Background()) {
+        t.Fatal("MarkWritten outside a session had an effect")
+    }
+}
+
+func TestRawWritesMarkSession(t *testing.T) {
+    r, primary, _ := newRouter(t)
+    ctx := WithSession(context.Background())
+    if err := primary.WithContext(ctx).Exec("UPDATE names SET name = ? WHERE id = 1", "renamed").Error; err != nil {
+        t.Fatal(err)
+    }
+    if got := served(t, ctx, r.Read(ctx)); got != "renamed" {
+        t.Fatalf("read after a raw write served %q", got)
+    }
+}
+
+func TestReplicaDown(t *testing.T) {
+    r, _, replicas := newRouter(t)
+    ctx := context.Background()
+    closeDB(t, replicas[0])
+
+    r.Check(ctx)
+    if healthy, total := r.Healthy(); healthy != 1 || total != 2 {
+        t.Fatalf("Healthy() = %d, %d after one replica went down", healthy, total)
+    }
+    for i := 0; i < 3; i++ {
+        if got := served(t, ctx, r.Read(ctx)); got != "replica-1" {
+            t.Fatalf("read served by %s, want the healthy replica", got)
+        }
+    }
+
+    closeDB(t, replicas[1])
+    r.Check(ctx)
+    if got := served(t, ctx, r.Read(ctx)); got != "primary" {
+        t.Fatalf("read served by %s with every replica down", got)
+    }
+}
+
+func TestFailedQueryTakesReplicaOut(t *testing.T) {
+    r, _, replicas := newRouter(t)
+
+    // a query error caused by the query leaves the replica in rotation
+    var n name
+    if err := replicas[0].Raw("SELECT * FROM missing").Scan(&n).Error; err == nil {
+        t.Fatal("query on a missing table succeeded")
+    }
+    if healthy, _ := r.Healthy(); healthy != 2 {
+        t.Fatalf("%d healthy replicas after a bad query, want 2", healthy)
+    }
+
+    closeDB(t, replicas[0])
+    if err := replicas[0].First(&n, 1).Error; err == nil {
+        t.Fatal("query on a closed replica succeeded")
+    }
+    if healthy, _ := r.Healthy(); healthy != 1 {
+        t.Fatalf("%d healthy replicas after a failed query, want 1", healthy)
+    }
+}
@@ -0,0 +1,36 @@
+package replica
+
+import (
+    "context"
+    "sync/atomic"
+)
+
+type sessionKey struct{}
+
+type session struct {
+    wrote atomic.Bool
+}
+
+// WithSession starts a read-your-writes scope, normally one request. Once a
+// statement in the scope writes to the primary, later reads in the same
+// scope go to the primary too, so they see the write even when replicas lag.
+func WithSession(ctx context.Context) context.Context {
+    if _, ok := ctx.Value(sessionKey{}).(*session); ok {
+        return ctx
+    }
+    return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// Wrote reports whether the session in ctx has written to the primary.
+func Wrote(ctx context.Context) bool {
+    s, ok := ctx.Value(sessionKey{}).(*session)
+    return ok && s.wrote.Load()
+}
+
+// MarkWritten pins the rest of the session in ctx to the primary. Plugin
+// calls it after every write; it is a no-op outside a session.
+func MarkWritten(ctx context.Context) {
+    if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+        s.wrote.Store(true)
+    }
+}
@@ -1,20 +1,155 @@
 package router
 
 import (
+    "time"
+
     "github.com/gin-gonic/gin"
     "go-webservice/controller"
     "go-webservice/middleware"
+    "go-webservice/model"
+)
+
+// v1 is frozen: it keeps its response shapes until the sunset date, and
+// new fields only go into v2. Unversioned /api paths are v1.
+var (
+    v1DeprecatedAt = time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+    v1Sunset       = time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// courseRoutes are the handlers whose request and response bodies differ
+// between API versions. Everything else is shared.
+type courseRoutes struct {
+    list, get, create, update, patch, publish gin.HandlerFunc
+}
+
+var (
+    v1Courses = courseRoutes{
+        list:    controller.GetCourses,
+        get:     controller.GetCourse,
+        create:  controller.CreateCourse,
+        update:  controller.UpdateCourse,
+        patch:   controller.PatchCourse,
+        publish: controller.PublishCourse,
+    }
+    v2Courses = courseRoutes{
+        list:    controller.GetCoursesV2,
+        get:     controller.GetCourseV2,
+        create:  controller.CreateCourseV2,
+        update:  controller.UpdateCourseV2,
+        patch:   controller.PatchCourseV2,
+        publish: controller.PublishCourseV2,
+    }
 )
 
 func SetupRouter() *gin.Engine {
     r := gin.Default()
-    r.Use(middleware.AuthMiddleware())
+    r.Use(middleware.SecurityHeaders(middleware.SecurityConfigFromEnv()))
+    r.Use(middleware.CORS(middleware.CORSConfigFromEnv()))
+    r.Use(middleware.ReadYourWrites())
 
-    api := r.Group("/api")
+    // Signed media links carry their own credential.
+    r.GET("/media/:id", controller.DownloadMedia)
+    // Payment providers sign their notifications.
+    r.POST("/payments/webhook", controller.PaymentNotification)
+
+    authed := r.Group("", middleware.NoStore(), middleware.AuthMiddleware(), middleware.TenantMiddleware(),
+        middleware.FeatureFlags(), middleware.Transaction())
+    read := middleware.RequireScope(model.ScopeCoursesRead)
+
+    v1 := middleware.Deprecated(v1DeprecatedAt, v1Sunset, "/api/v2")
+    registerAPI(authed.Group("/api", v1), v1Courses)
+    registerAPI(authed.Group("/api/v1", v1), v1Courses)
+    registerAPI(authed.Group("/api/v2"), v2Courses)
+
+    // The fake provider's checkout page, for the user paying.
+    authed.POST("/payments/fake/:id", middleware.RequireScope(model.ScopeOrdersWrite), controller.CompleteFakePayment)
+
+    authed.GET("/graphql", read, controller.GraphQL)
+    authed.POST("/graphql", read, controller.GraphQL)
+
+    return r
+}
+
+func registerAPI(api *gin.RouterGroup, courses courseRoutes) {
+    read := middleware.RequireScope(model.ScopeCoursesRead)
     {
-        api.GET("/courses", controller.GetCourses)
-        api.GET("/courses/:id", controller.GetCourse)
+        api.GET("/courses", read, courses.list)
+        api.GET("/courses/search", read, controller.SearchCourses)
+        api.GET("/courses/stream", read, controller.StreamCourses)
+        api.GET("/courses/export", read, controller.ExportCourses)
+        api.GET("/courses/:id", read, courses.get)
+        api.GET("/courses/:id/modules", read, controller.GetModules)
+        api.GET("/courses/:id/media", read, controller.GetMedia)
+        api.GET("/courses/:id/media/:mediaId/url", read, controller.GetMediaURL)
+        api.GET("/courses/:id/reviews", read, controller.GetReviews)
+        api.GET("/features", controller.GetFeatures)
     }
 
-    return r
-}
\ No newline at end of file
+    reviews := api.Group("/courses/:id/reviews", middleware.RequireScope(model.ScopeReviewsWrite))
+    {
+        reviews.POST("", controller.CreateReview)
+        reviews.PUT("/:reviewId", controller.UpdateReview)
+        reviews.DELETE("/:reviewId", controller.DeleteReview)
+        reviews.POST("/:reviewId/flag", controller.FlagReview)
+    }
+
+    orders := api.Group("", middleware.RequireScope(model.ScopeOrdersWrite))
+    {
+        orders.POST("/checkout", controller.Checkout)
+        orders.GET("/orders", controller.GetOrders)
+        orders.GET("/orders/:id", controller.GetOrder)
+        orders.GET("/enrollments", controller.GetEnrollments)
+    }
+
+    admin := api.Group("", middleware.AdminOnly())
+    {
+        write := admin.Group("", middleware.RequireScope(model.ScopeCoursesWrite))
+        write.POST("/courses", courses.create)
+        write.POST("/courses/import", controller.ImportCourses)
+        write.PUT("/courses/:id", courses.update)
+        write.PATCH("/courses/:id", courses.patch)
+        write.DELETE("/courses/:id", controller.DeleteCourse)
+        write.POST("/courses/:id/publish", courses.publish)
+        write.POST("/courses/:id/modules", controller.CreateModule)
+        write.POST("/courses/:id/media", controller.UploadMedia)
+        write.DELETE("/courses/:id/media/:mediaId", controller.DeleteMedia)
+        write.PUT("/courses/:id/price", controller.SetCoursePrice)
+        write.GET("/reviews/flagged", controller.GetFlaggedReviews)
+        write.PUT("/reviews/:reviewId/moderation", controller.ModerateReview)
+
+        hooks := admin.Group("/webhooks", middleware.RequireScope(model.ScopeWebhooksManage))
+        hooks.GET("", controller.GetWebhooks)
+        hooks.POST("", controller.CreateWebhook)
+        hooks.DELETE("/:id", controller.DeleteWebhook)
+        hooks.GET("/dead-letters", controller.GetDeadLetters)
+        hooks.POST("/deliveries/:id/retry", controller.RetryDelivery)
+
+        admin.GET("/cache/stats", controller.GetCacheStats)
+
+        coupons := admin.Group("/coupons", middleware.RequireScope(model.ScopeCoursesWrite))
+        coupons.GET("", controller.GetCoupons)
+        coupons.POST("", controller.CreateCoupon)
+        coupons.DELETE("/:id", controller.DeactivateCoupon)
+
+        flags := admin.Group("/flags", middleware.OperatorOnly(), middleware.RequireScope(model.ScopeFlagsManage))
+        flags.GET("", controller.GetFlags)
+        flags.PUT("/:key", controller.SetFlag)
+        flags.DELETE("/:key", controller.DeleteFlag)
+
+        emails := admin.Group("/emails")
+        emails.GET("/templates", controller.GetEmailTemplates)
+        emails.POST("/preview", controller.PreviewEmail)
+        emails.POST("/send", controller.SendEmail)
+
+        keys := admin.Group("/keys", middleware.RequireScope(model.ScopeKeysManage))
+        keys.GET("", controller.GetAPIKeys)
+        keys.POST("", controller.CreateAPIKey)
+        keys.POST("/:id/rotate", controller.RotateAPIKey)
+        keys.DELETE("/:id", controller.RevokeAPIKey)
+
+        jobs := admin.Group("/jobs", middleware.RequireScope(model.ScopeJobsManage))
+        jobs.GET("", controller.GetJobs)
+        jobs.GET("/:id", controller.GetJob)
+        jobs.POST("/:id/retry", controller.RetryJob)
+    }
+}
@@ -0,0 +1,266 @@
+package search
+
+import (
+    "errors"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "math"
+    "sort"
+    "sync"
+
+    "gorm.io/gorm"
+)
+
+var errIndexUnavailable = errors.New("search index unavailable")
+
+// Memory is an inverted index per tenant, built from the database on the
+// first search and then kept current through Index and Remove. All query
+// words must match (after stemming); when none do, words within a small
+// edit distance of an indexed term are tried instead.
+type Memory struct {
+    mu      sync.Mutex
+    tenants map[string]*memoryIndex
+}
+
+type memoryIndex struct {
+    mu       sync.RWMutex
+    failed   bool
+    courses  map[string]model.Course
+    postings map[string]map[string]posting
+}
+
+// posting counts the occurrences of a term in one course.
+type posting struct {
+    title, description int
+}
+
+func NewMemory() *Memory {
+    return &Memory{tenants: make(map[string]*memoryIndex)}
+}
+
+func (m *Memory) Search(tx *gorm.DB, q string, limit int) (Results, error) {
+    results := Results{Query: q, Hits: []Hit{}}
+    tenantID, ok := tenant.FromContext(tx.Statement.Context)
+    if !ok {
+        return results, tenant.ErrNoTenant
+    }
+    idx, err := m.load(tx, tenantID)
+    if err != nil {
+        return results, err
+    }
+    idx.mu.RLock()
+    defer idx.mu.RUnlock()
+    if idx.failed {
+        return results, errIndexUnavailable
+    }
+
+    words := unique(terms(q))
+    if len(words) == 0 {
+        return results, nil
+    }
+    expansions := make([]map[string]float64, len(words))
+    for i, w := range words {
+        expansions[i] = map[string]float64{w: 1}
+    }
+    scores := idx.score(expansions)
+    if len(scores) == 0 {
+        results.Fuzzy = true
+        expansions = idx.fuzzy(words)
+        scores = idx.score(expansions)
+    }
+
+    ids := make([]string, 0, len(scores))
+    for id := range scores {
+        ids = append(ids, id)
+    }
+    sort.Slice(ids, func(i, j int) bool {
+        if scores[ids[i]] != scores[ids[j]] {
+            return scores[ids[i]] > scores[ids[j]]
+        }
+        return ids[i] < ids[j]
+    })
+    if len(ids) > limit {
+        ids = ids[:limit]
+    }
+
+    matched := make(map[string]bool)
+    for _, exp := range expansions {
+        for term := range exp {
+            matched[term] = true
+        }
+    }
+    for _, id := range ids {
+        course := idx.courses[id]
+        results.Hits = append(results.Hits, Hit{Course: course, Rank: scores[id], Snippet: highlight(course, matched)})
+    }
+    return results, nil
+}
+
+func (m *Memory) Index(tenantID string, course model.Course) {
+    idx := m.loaded(tenantID)
+    if idx == nil {
+        return
+    }
+    idx.mu.Lock()
+    defer idx.mu.Unlock()
+    idx.remove(course.ID)
+    idx.add(course)
+}
+
+func (m *Memory) Remove(tenantID, courseID string) {
+    idx := m.loaded(tenantID)
+    if idx == nil {
+        return
+    }
+    idx.mu.Lock()
+    defer idx.mu.Unlock()
+    idx.remove(courseID)
+}
+
+func (m *Memory) Rebuild(tx *gorm.DB) error {
+    tenantID, ok := tenant.FromContext(tx.Statement.Context)
+    if !ok {
+        return tenant.ErrNoTenant
+    }
+    m.mu.Lock()
+    delete(m.tenants, tenantID)
+    m.mu.Unlock()
+    _, err := m.load(tx, tenantID)
+    return err
+}
+
+// loaded returns the tenant's index, or nil if it has not been built yet,
+// in which case the next load will read the change from the database.
+func (m *Memory) loaded(tenantID string) *memoryIndex {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.tenants[tenantID]
+}
+
+// load builds the tenant's index on first use. The index is registered and
+// write-locked before the courses are read, so a write committed while
+// loading is applied after the load rather than lost.
+func (m *Memory) load(tx *gorm.DB, tenantID string) (*memoryIndex, error) {
+    m.mu.Lock()
+    if idx, ok := m.tenants[tenantID]; ok {
+        m.mu.Unlock()
+        return idx, nil
+    }
+    idx := &memoryIndex{
+        courses:  make(map[string]model.Course),
+        postings: make(map[string]map[string]posting),
+    }
+    idx.mu.Lock()
+    defer idx.mu.Unlock()
+    m.tenants[tenantID] = idx
+    m.mu.Unlock()
+
+    var courses []model.Course
+    if err := tx.Find(&courses).Error; err != nil {
+        idx.failed = true
+        m.mu.Lock()
+        delete(m.tenants, tenantID)
+        m.mu.Unlock()
+        return nil, err
+    }
+    for _, course := range courses {
+        idx.add(course)
+    }
+    return idx, nil
+}
+
+func (idx *memoryIndex) add(course model.Course) {
+    idx.courses[course.ID] = course
+    counts := make(map[string]posting)
+    for _, t := range terms(course.Title) {
+        p := counts[t]
+        p.title++
+        counts[t] = p
+    }
+    for _, t := range terms(course.Description) {
+        p := counts[t]
+        p.description++
+        counts[t] = p
+    }
+    for term, p := range counts {
+        if idx.postings[term] == nil {
+            idx.postings[term] = make(map[string]posting)
+        }
+        idx.postings[term][course.ID] = p
+    }
+}
+
+func (idx *memoryIndex) remove(id string) {
+    course, ok := idx.courses[id]
+    if !ok {
+        return
+    }
+    delete(idx.courses, id)
+    for _, term := range terms(course.Title + " " + course.Description) {
+        delete(idx.postings[term], id)
+        if len(idx.postings[term]) == 0 {
+            delete(idx.postings, term)
+        }
+    }
+}
+
+// score ranks the courses that match every query word. Each word is given
+// as a set of acceptable terms with a weight; a course scores the best of
+// them, weighted by how rare the term is.
+func (idx *memoryIndex) score(words []map[string]float64) map[string]float64 {
+    var scores map[string]float64
+    n := float64(len(idx.courses))
+    for i, exp := range words {
+        best := make(map[string]float64)
+        for term, weight := range exp {
+            post := idx.postings[term]
+            idf := math.Log(1 + n/float64(len(post)))
+            for id, p := range post {
+                s := weight * idf * (titleWeight*float64(p.title) + descriptionWeight*float64(p.description))
+                if s > best[id] {
+                    best[id] = s
+                }
+            }
+        }
+        if i == 0 {
+            scores = best
+            continue
+        }
+        for id := range scores {
+            if s, ok := best[id]; ok {
+                scores[id] += s
+            } else {
+                delete(scores, id)
+            }
+        }
+    }
+    return scores
+}
+
+// fuzzy expands each word to the indexed terms within its typo budget,
+// weighting closer terms higher.
+func (idx *memoryIndex) fuzzy(words []string) []map[string]float64 {
+    expansions := make([]map[string]float64, len(words))
+    for i, w := range words {
+        expansions[i] = make(map[string]float64)
+        budget := maxTypos(len(w))
+        for term := range idx.postings {
+            if d := distance(w, term); d <= budget {
+                expansions[i][term] = 1 / float64(1+d)
+            }
+        }
+    }
+    return expansions
+}
+
+func unique(words []string) []string {
+    seen := make(map[string]bool)
+    var out []string
+    for _, w := range words {
+        if !seen[w] {
+            seen[w] = true
+            out = append(out, w)
+        }
+    }
+    return out
+}
@@ -0,0 +1,186 @@
+package search
+
+import (
+    "context"
+    "path/filepath"
+    "testing"
+
+    "github.com/glebarez/sqlite"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+    t.Helper()
+    db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "search.db")), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := db.Use(tenant.Plugin{}); err != nil {
+        t.Fatal(err)
+    }
+    if err := db.AutoMigrate(&model.Course{}); err != nil {
+        t.Fatal(err)
+    }
+    return db
+}
+
+func in(db *gorm.DB, tenantID string) *gorm.DB {
+    return db.WithContext(tenant.WithTenant(context.Background(), tenantID))
+}
+
+func addCourse(t *testing.T, db *gorm.DB, tenantID, id, title, description string) model.Course {
+    t.Helper()
+    course := model.Course{ID: id, Title: title, Description: description}
+    if err := in(db, tenantID).Create(&course).Error; err != nil {
+        t.Fatal(err)
+    }
+    return course
+}
+
+func search(t *testing.T, m *Memory, db *gorm.DB, tenantID, q string) Results {
+    t.Helper()
+    results, err := m.Search(in(db, tenantID), q, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return results
+}
+
+func hitIDs(results Results) []string {
+    var out []string
+    for _, h := range results.Hits {
+        out = append(out, h.Course.ID)
+    }
+    return out
+}
+
+func equal(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func TestMemoryRanking(t *testing.T) {
+    db := openDB(t)
+    addCourse(t, db, "acme", "desc", "Concurrency", "Goroutines and channels in golang")
+    addCourse(t, db, "acme", "title", "Golang basics", "Types and functions")
+    addCourse(t, db, "acme", "both", "Golang testing", "Testing golang code with tables")
+    addCourse(t, db, "globex", "other", "Golang for globex", "")
+    m := NewMemory()
+
+    tests := []struct {
+        q   string
+        ids []string
+    }{
+        // title matches outweigh description matches
+        {"golang", []string{"both", "title", "desc"}},
+        // every word must match, after stemming and dropping stop words
+        {"golang and tables", []string{"both"}},
+        {"tested", []string{"both"}},
+        {"channel", []string{"desc"}},
+        {"the", nil},
+    }
+    for _, tt := range tests {
+        results := search(t, m, db, "acme", tt.q)
+        if got := hitIDs(results); !equal(got, tt.ids) || results.Fuzzy {
+            t.Errorf("search %q = %v (fuzzy %v), want %v", tt.q, got, results.Fuzzy, tt.ids)
+        }
+    }
+    if results := search(t, m, db, "acme", "python"); len(results.Hits) != 0 || !results.Fuzzy {
+        t.Errorf("search without matches = %+v, want no hits after the fuzzy retry", results)
+    }
+}
+
+func TestMemorySnippets(t *testing.T) {
+    db := openDB(t)
+    addCourse(t, db, "acme", "1", "Web <b>basics</b>", "")
+    addCourse(t, db, "acme", "2", "Golang", "An introduction to writing golang services & tools")
+    m := NewMemory()
+
+    if hits := search(t, m, db, "acme", "basics").Hits; len(hits) != 1 ||
+        hits[0].Snippet != "Web &lt;b&gt;<mark>basics</mark>&lt;/b&gt;" {
+        t.Fatalf("title snippet %+v", hits)
+    }
+    if hits := search(t, m, db, "acme", "service").Hits; len(hits) != 1 ||
+        hits[0].Snippet != "… to writing golang <mark>services</mark> &amp; tools" {
+        t.Fatalf("description snippet %+v", hits)
+    }
+}
+
+func TestMemoryTypoTolerance(t *testing.T) {
+    db := openDB(t)
+    addCourse(t, db, "acme", "1", "Kubernetes operations", "Deploying containers")
+    addCourse(t, db, "acme", "2", "Go", "")
+    m := NewMemory()
+
+    tests := []struct {
+        q     string
+        ids   []string
+        fuzzy bool
+    }{
+        {"kubernetes", []string{"1"}, false},
+        {"kubernetse", []string{"1"}, true},
+        {"kubernets containres", []string{"1"}, true},
+        // short words get no typo budget
+        {"og", nil, true},
+        // too many edits
+        {"kbrnts", nil, true},
+    }
+    for _, tt := range tests {
+        results := search(t, m, db, "acme", tt.q)
+        if got := hitIDs(results); !equal(got, tt.ids) || results.Fuzzy != tt.fuzzy {
+            t.Errorf("search %q = %v (fuzzy %v), want %v (fuzzy %v)", tt.q, got, results.Fuzzy, tt.ids, tt.fuzzy)
+        }
+    }
+    hits := search(t, m, db, "acme", "operatoins").Hits
+    if len(hits) != 1 || hits[0].Snippet != "Kubernetes <mark>operations</mark>" {
+        t.Fatalf("fuzzy hits %+v, want the corrected term highlighted", hits)
+    }
+}
+
+func TestMemoryStaysCurrent(t *testing.T) {
+    db := openDB(t)
+    addCourse(t, db, "acme", "1", "Rust", "")
+    m := NewMemory()
+    if got := hitIDs(search(t, m, db, "acme", "rust")); !equal(got, []string{"1"}) {
+        t.Fatalf("initial load found %v", got)
+    }
+
+    added := addCourse(t, db, "acme", "2", "Rust in production", "")
+    m.Index("acme", added)
+    m.Remove("acme", "1")
+    if got := hitIDs(search(t, m, db, "acme", "rust")); !equal(got, []string{"2"}) {
+        t.Fatalf("after index and remove found %v", got)
+    }
+    if got := hitIDs(search(t, m, db, "globex", "rust")); len(got) != 0 {
+        t.Fatalf("other tenant found %v", got)
+    }
+
+    // a write the index was not told about only shows after a rebuild
+    addCourse(t, db, "acme", "3", "Rust macros", "")
+    if got := hitIDs(search(t, m, db, "acme", "macro")); len(got) != 0 {
+        t.Fatalf("unindexed course found: %v", got)
+    }
+    if err := m.Rebuild(in(db, "acme")); err != nil {
+        t.Fatal(err)
+    }
+    if got := hitIDs(search(t, m, db, "acme", "rust")); !equal(got, []string{"1", "2", "3"}) {
+        t.Fatalf("after rebuild found %v", got)
+    }
+}
+
+func TestMemoryNeedsTenant(t *testing.T) {
+    db := openDB(t)
+    if _, err := NewMemory().Search(db, "go", 10); err != tenant.ErrNoTenant {
+        t.Fatalf("search without tenant: %v", err)
+    }
+}
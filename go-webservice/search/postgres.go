@@ -0,0 +1,124 @@
+package search
+
+import (
+    "go-webservice/model"
+    "log"
+
+    "gorm.io/gorm"
+)
+
+// Postgres searches a generated, weighted tsvector column. When the query
+// matches nothing it retries with trigram word similarity to absorb typos,
+// if the pg_trgm extension is installed.
+type Postgres struct{}
+
+const (
+    tsQuery    = "websearch_to_tsquery('english', ?)"
+    similarity = 0.3
+)
+
+var postgresMigrations = []string{
+    `ALTER TABLE courses ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED`,
+    `CREATE INDEX IF NOT EXISTS idx_courses_search ON courses USING GIN (search)`,
+}
+
+// Creating pg_trgm needs the CREATE privilege on the database, and
+// superuser before Postgres 13. Without it search still works but has no
+// typo-tolerant fallback, so failing to create it is not fatal.
+const trgmMigration = `CREATE EXTENSION IF NOT EXISTS pg_trgm`
+
+func migratePostgres(db *gorm.DB) error {
+    for _, stmt := range postgresMigrations {
+        if err := db.Exec(stmt).Error; err != nil {
+            return err
+        }
+    }
+    // in a (nested) transaction of its own, as a failed statement would
+    // abort the one it runs in
+    err := db.Transaction(func(tx *gorm.DB) error {
+        return tx.Exec(trgmMigration).Error
+    })
+    if err != nil {
+        log.Println("search: cannot create pg_trgm, typo-tolerant search is disabled:", err)
+    }
+    return nil
+}
+
+func trigramsAvailable(tx *gorm.DB) (bool, error) {
+    var ok bool
+    err := tx.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&ok).Error
+    return ok, err
+}
+
+// headline highlights matches in the description, or the title when there
+// is no description. The text is HTML-escaped first so only the <mark>
+// tags in the snippet are markup.
+const headline = `ts_headline('english',
+    replace(replace(replace(coalesce(nullif(description, ''), title), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+    ` + tsQuery + `, 'StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=10, MaxFragments=2')`
+
+type row struct {
+    model.Course `gorm:"embedded"`
+    Rank         float64
+    Snippet      string
+}
+
+func (Postgres) Search(tx *gorm.DB, q string, limit int) (Results, error) {
+    results := Results{Query: q, Hits: []Hit{}}
+    var rows []row
+    err := tx.Model(&model.Course{}).
+        Select("courses.*, ts_rank(search, "+tsQuery+") AS rank, "+headline+" AS snippet", q, q).
+        Where("search @@ "+tsQuery, q).
+        Order("rank DESC").Order("id").
+        Limit(limit).
+        Scan(&rows).Error
+    if err != nil {
+        return results, err
+    }
+    if len(rows) == 0 {
+        ok, err := trigramsAvailable(tx)
+        if err != nil || !ok {
+            return results, err
+        }
+        results.Fuzzy = true
+        document := "coalesce(title, '') || ' ' || coalesce(description, '')"
+        err = tx.Model(&model.Course{}).
+            Select("courses.*, word_similarity(?, "+document+") AS rank", q).
+            Where("word_similarity(?, "+document+") >= ?", q, similarity).
+            Order("rank DESC").Order("id").
+            Limit(limit).
+            Scan(&rows).Error
+        if err != nil {
+            return results, err
+        }
+    }
+    for _, r := range rows {
+        snippet := r.Snippet
+        if results.Fuzzy {
+            snippet = highlight(r.Course, nil)
+        }
+        results.Hits = append(results.Hits, Hit{Course: r.Course, Rank: r.Rank, Snippet: snippet})
+    }
+    return results, nil
+}
+
+// Index and Remove are no-ops: the search column is generated by Postgres
+// on every write.
+func (Postgres) Index(string, model.Course) {}
+
+func (Postgres) Remove(string, string) {}
+
+// Rebuild recomputes the search column, for example after a change to the
+// text search configuration, and rebuilds its index. The column belongs to
+// the courses table, so this covers all tenants, not only the one of tx.
+func (Postgres) Rebuild(tx *gorm.DB) error {
+    return tx.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec("ALTER TABLE courses DROP COLUMN IF EXISTS search").Error; err != nil {
+            return err
+        }
+        return migratePostgres(tx)
+    })
+}
@@ -0,0 +1,61 @@
+// Package search ranks courses against a free-text query. Postgres uses
+// its built-in full-text search; other databases fall back to an in-memory
+// inverted index so the API behaves the same in local runs and tests.
+package search
+
+import (
+    "go-webservice/model"
+
+    "gorm.io/gorm"
+)
+
+// Title matches count for more than description matches. The values are
+// Postgres' default weights for the A and B labels.
+const (
+    titleWeight       = 1.0
+    descriptionWeight = 0.4
+)
+
+type Hit struct {
+    Course  model.Course `json:"course"`
+    Rank    float64      `json:"rank"`
+    Snippet string       `json:"snippet"`
+}
+
+// Results holds the hits for a query. Fuzzy is set when nothing matched
+// exactly and the hits come from the typo-tolerant fallback.
+type Results struct {
+    Query string `json:"query"`
+    Fuzzy bool   `json:"fuzzy"`
+    Hits  []Hit  `json:"results"`
+}
+
+type Engine interface {
+    // Search runs q against the courses visible to tx, which carries the
+    // tenant scope.
+    Search(tx *gorm.DB, q string, limit int) (Results, error)
+    // Index and Remove keep the engine in step with committed writes.
+    Index(tenantID string, course model.Course)
+    Remove(tenantID, courseID string)
+    // Rebuild discards what is indexed for the tenant of tx and indexes
+    // its courses again. An engine whose index is not kept per tenant may
+    // rebuild it for every tenant; Postgres does.
+    Rebuild(tx *gorm.DB) error
+}
+
+// New returns the engine suited to db's dialect.
+func New(db *gorm.DB) Engine {
+    if db.Dialector.Name() == "postgres" {
+        return Postgres{}
+    }
+    return NewMemory()
+}
+
+// Migrate adds the search column and indexes on Postgres. It does nothing
+// on other databases.
+func Migrate(db *gorm.DB) error {
+    if db.Dialector.Name() != "postgres" {
+        return nil
+    }
+    return migratePostgres(db)
+}
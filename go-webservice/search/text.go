@@ -0,0 +1,171 @@
+package search
+
+import (
+    "go-webservice/model"
+    "html"
+    "strings"
+    "unicode"
+)
+
+const snippetWords = 25
+
+var stopWords = map[string]bool{
+    "a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+    "by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+    "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "with": true,
+}
+
+type token struct {
+    term       string
+    start, end int
+}
+
+// tokenize splits text into words and normalises each to a search term.
+// Stop words keep their position but have an empty term.
+func tokenize(text string) []token {
+    var tokens []token
+    start := -1
+    for i, r := range text + " " {
+        word := unicode.IsLetter(r) || unicode.IsDigit(r)
+        switch {
+        case word && start < 0:
+            start = i
+        case !word && start >= 0:
+            tokens = append(tokens, token{term: normalize(text[start:i]), start: start, end: i})
+            start = -1
+        }
+    }
+    return tokens
+}
+
+func terms(text string) []string {
+    var out []string
+    for _, t := range tokenize(text) {
+        if t.term != "" {
+            out = append(out, t.term)
+        }
+    }
+    return out
+}
+
+func normalize(word string) string {
+    word = strings.ToLower(word)
+    if stopWords[word] {
+        return ""
+    }
+    return stem(word)
+}
+
+// stem strips a few common English suffixes so that "courses" finds
+// "course". It is deliberately crude; Postgres does the real stemming.
+func stem(w string) string {
+    switch {
+    case len(w) > 5 && strings.HasSuffix(w, "ing"):
+        return w[:len(w)-3]
+    case len(w) > 4 && strings.HasSuffix(w, "ies"):
+        return w[:len(w)-3] + "y"
+    case len(w) > 4 && strings.HasSuffix(w, "ed"):
+        return w[:len(w)-2]
+    case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss"):
+        return w[:len(w)-1]
+    }
+    return w
+}
+
+// highlight returns an HTML snippet of the course description (or title
+// when the description is empty) around the first word in matched, with
+// matched words wrapped in <mark>.
+func highlight(course model.Course, matched map[string]bool) string {
+    text := course.Description
+    tokens := tokenize(text)
+    if len(tokens) == 0 || (len(matched) > 0 && firstMatch(tokens, matched) < 0 && firstMatch(tokenize(course.Title), matched) >= 0) {
+        text = course.Title
+        tokens = tokenize(text)
+    }
+    if len(tokens) == 0 {
+        return ""
+    }
+
+    from := 0
+    if i := firstMatch(tokens, matched); i > 3 {
+        from = i - 3
+    }
+    to := from + snippetWords
+    if to > len(tokens) {
+        to = len(tokens)
+    }
+
+    var b strings.Builder
+    if from > 0 {
+        b.WriteString("… ")
+    }
+    pos := tokens[from].start
+    for _, t := range tokens[from:to] {
+        b.WriteString(html.EscapeString(text[pos:t.start]))
+        word := html.EscapeString(text[t.start:t.end])
+        if t.term != "" && matched[t.term] {
+            word = "<mark>" + word + "</mark>"
+        }
+        b.WriteString(word)
+        pos = t.end
+    }
+    if to < len(tokens) {
+        b.WriteString(" …")
+    } else {
+        b.WriteString(html.EscapeString(text[pos:]))
+    }
+    return b.String()
+}
+
+func firstMatch(tokens []token, matched map[string]bool) int {
+    for i, t := range tokens {
+        if t.term != "" && matched[t.term] {
+            return i
+        }
+    }
+    return -1
+}
+
+// distance is the Levenshtein edit distance between a and b.
+func distance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    prev := make([]int, len(rb)+1)
+    cur := make([]int, len(rb)+1)
+    for j := range prev {
+        prev[j] = j
+    }
+    for i := 1; i <= len(ra); i++ {
+        cur[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+    if b < a {
+        a = b
+    }
+    if c < a {
+        a = c
+    }
+    return a
+}
+
+// maxTypos is how many edits a query term of length n may be from an
+// indexed term and still count as a fuzzy match.
+func maxTypos(n int) int {
+    switch {
+    case n < 4:
+        return 0
+    case n < 8:
+        return 1
+    }
+    return 2
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "go-webservice/config"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "log"
+    "strings"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+const (
+    apiKeyPrefix     = "gwk_"
+    lastUsedInterval = time.Minute
+)
+
+var (
+    ErrAPIKeyNotFound = errors.New("api key not found")
+    ErrInvalidAPIKey  = errors.New("invalid api key")
+    ErrInvalidScope   = errors.New("invalid scope")
+    ErrGrantExceeded  = errors.New("cannot grant more than the caller holds")
+)
+
+// Grant is what the caller issuing a key holds. A key may not carry a role
+// or scope its issuer lacks, or a key with keys:manage could mint an admin
+// key with every scope. Nil Scopes means all scopes, as for bearer tokens.
+type Grant struct {
+    Role   string
+    Scopes []string
+}
+
+// FullGrant is held by operators using the admin CLI.
+var FullGrant = Grant{Role: "admin"}
+
+func (g Grant) check(role string, scopes []string) error {
+    if role == "admin" && g.Role != "admin" {
+        return fmt.Errorf("%w: role %s", ErrGrantExceeded, role)
+    }
+    if g.Scopes == nil {
+        return nil
+    }
+    for _, s := range scopes {
+        if !contains(g.Scopes, s) {
+            return fmt.Errorf("%w: scope %s", ErrGrantExceeded, s)
+        }
+    }
+    return nil
+}
+
+// IsAPIKey reports whether a credential looks like an API key rather than a
+// bearer token.
+func IsAPIKey(raw string) bool {
+    return strings.HasPrefix(raw, apiKeyPrefix)
+}
+
+// CreateAPIKey issues a new key on behalf of a caller holding by. The
+// returned plaintext key is shown once and cannot be recovered afterwards.
+func CreateAPIKey(ctx context.Context, by Grant, name, role string, scopes []string, expiresAt *time.Time) (model.APIKey, string, error) {
+    for _, s := range scopes {
+        if !validScope(s) {
+            return model.APIKey{}, "", fmt.Errorf("%w: %s", ErrInvalidScope, s)
+        }
+    }
+    if role == "" {
+        role = "user"
+    }
+    if err := by.check(role, scopes); err != nil {
+        return model.APIKey{}, "", err
+    }
+    prefix, err := randomString(6)
+    if err != nil {
+        return model.APIKey{}, "", err
+    }
+    secret, err := randomString(24)
+    if err != nil {
+        return model.APIKey{}, "", err
+    }
+    key := model.APIKey{
+        Name:       name,
+        Prefix:     apiKeyPrefix + prefix,
+        SecretHash: hashSecret(secret),
+        Role:       role,
+        Scopes:     strings.Join(scopes, ","),
+        ExpiresAt:  expiresAt,
+    }
+    if err := db(ctx).Create(&key).Error; err != nil {
+        return model.APIKey{}, "", err
+    }
+    return key, key.Prefix + "." + secret, nil
+}
+
+func GetAllAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+    var keys []model.APIKey
+    err := db(ctx).Order("id").Find(&keys).Error
+    return keys, err
+}
+
+func RevokeAPIKey(ctx context.Context, id string) error {
+    now := time.Now().UTC()
+    res := db(ctx).Model(&model.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrAPIKeyNotFound
+    }
+    return nil
+}
+
+// RotateAPIKey issues a replacement with the same name, role and scopes
+// and lets the old key keep working for the grace period so clients can
+// switch over without downtime. As the caller gets the new secret, it must
+// hold everything the key does.
+func RotateAPIKey(ctx context.Context, by Grant, id string, grace time.Duration) (model.APIKey, string, error) {
+    var old model.APIKey
+    err := db(ctx).First(&old, "id = ? AND revoked_at IS NULL", id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return model.APIKey{}, "", ErrAPIKeyNotFound
+    }
+    if err != nil {
+        return model.APIKey{}, "", err
+    }
+
+    key, raw, err := CreateAPIKey(ctx, by, old.Name, old.Role, old.ScopeList(), old.ExpiresAt)
+    if err != nil {
+        return model.APIKey{}, "", err
+    }
+    cutoff := time.Now().UTC().Add(grace)
+    if old.ExpiresAt == nil || cutoff.Before(*old.ExpiresAt) {
+        err = db(ctx).Model(&old).Update("expires_at", cutoff).Error
+    }
+    return key, raw, err
+}
+
+// AuthenticateAPIKey resolves a plaintext key to the stored key. The key
+// itself determines the tenant, so the lookup runs unscoped.
+func AuthenticateAPIKey(ctx context.Context, raw string) (model.APIKey, error) {
+    prefix, secret, ok := strings.Cut(raw, ".")
+    if !ok || !IsAPIKey(prefix) {
+        return model.APIKey{}, ErrInvalidAPIKey
+    }
+    var key model.APIKey
+    if err := db(tenant.System(ctx)).First(&key, "prefix = ?", prefix).Error; err != nil {
+        return model.APIKey{}, ErrInvalidAPIKey
+    }
+    if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 {
+        return model.APIKey{}, ErrInvalidAPIKey
+    }
+    now := time.Now().UTC()
+    if !key.Active(now) {
+        return model.APIKey{}, ErrInvalidAPIKey
+    }
+    // only write last_used_at once per interval to keep auth cheap. The
+    // write bypasses the request's unit of work and read-your-writes
+    // session: it is bookkeeping the request never reads back, and would
+    // otherwise send all of the request's reads to the primary.
+    if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedInterval {
+        key.LastUsedAt = &now
+        err := config.DB.WithContext(tenant.System(context.Background())).Model(&key).Update("last_used_at", now).Error
+        if err != nil {
+            log.Println("api key: record last use:", err)
+        }
+    }
+    return key, nil
+}
+
+func validScope(scope string) bool {
+    return contains(model.Scopes, scope)
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+// The secret has 192 bits of entropy, so a plain SHA-256 is enough; a slow
+// password hash would only add latency to every request.
+func hashSecret(secret string) string {
+    sum := sha256.Sum256([]byte(secret))
+    return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+    buf := make([]byte, n)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(buf), nil
+}
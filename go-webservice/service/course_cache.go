@@ -0,0 +1,54 @@
+package service
+
+import (
+    "context"
+    "go-webservice/cache"
+    "go-webservice/config"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "go-webservice/txn"
+    "time"
+)
+
+// cached reads key for the current tenant through the course cache.
+// Requests without a tenant bypass it, and so do reads inside a unit of
+// work, which must see its uncommitted writes and must not cache them.
+func cached[T any](ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+    tenantID, ok := tenant.FromContext(ctx)
+    if _, inUnit := txn.From(ctx); !ok || inUnit {
+        return load(ctx)
+    }
+    return cache.Fetch(ctx, config.Cache, tenantID+":"+key, load)
+}
+
+// courseChanged refreshes the search index and drops cached reads of a
+// course once the write commits.
+func courseChanged(ctx context.Context, course model.Course) {
+    txn.AfterCommit(ctx, func(ctx context.Context) {
+        config.Search.Index(course.TenantID, course)
+    })
+    invalidateCourse(ctx, course)
+}
+
+func courseRemoved(ctx context.Context, course model.Course) {
+    txn.AfterCommit(ctx, func(ctx context.Context) {
+        config.Search.Remove(course.TenantID, course.ID)
+    })
+    invalidateCourse(ctx, course)
+}
+
+func invalidateCourse(ctx context.Context, course model.Course) {
+    txn.AfterCommit(ctx, func(ctx context.Context) {
+        config.Cache.Invalidate(ctx, course.TenantID+":courses", course.TenantID+":course:"+course.ID)
+    })
+}
+
+// CacheTTL is how long a course read may be served from the cache, and so
+// how long clients may reuse it.
+func CacheTTL() time.Duration {
+    return config.Cache.TTL
+}
+
+func CacheStats() cache.Stats {
+    return config.Cache.Stats()
+}
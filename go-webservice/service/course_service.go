@@ -1,23 +1,296 @@
 package service
 
 import (
+    "context"
     "errors"
     "go-webservice/model"
+    "sort"
+    "strings"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+var (
+    ErrCourseNotFound = errors.New("course not found")
+    ErrInvalidSort    = errors.New("sort must be created_at, title or rating, with a leading - for descending")
 )
 
-var courses = []model.Course{
-    {ID: "1", Title: "Go Basics", Description: "Learn Go"},
+// CourseSort orders course lists by created_at (the zero value), title or
+// rating, ascending unless Desc. Rating sorts by average, then by number
+// of reviews.
+type CourseSort struct {
+    Field string
+    Desc  bool
+}
+
+func ParseCourseSort(s string) (CourseSort, error) {
+    var cs CourseSort
+    if strings.HasPrefix(s, "-") {
+        cs.Desc = true
+        s = s[1:]
+    }
+    switch s {
+    case "created_at", "title", "rating":
+        cs.Field = s
+    case "":
+        if cs.Desc {
+            return cs, ErrInvalidSort
+        }
+    default:
+        return cs, ErrInvalidSort
+    }
+    return cs, nil
 }
 
-func GetAllCourses() []model.Course {
-    return courses
+func (cs CourseSort) apply(q *gorm.DB) *gorm.DB {
+    dir := ""
+    if cs.Desc {
+        dir = " desc"
+    }
+    switch cs.Field {
+    case "title":
+        q = q.Order("title" + dir)
+    case "rating":
+        q = q.Order("rating_average" + dir).Order("rating_count" + dir)
+    default:
+        q = q.Order("created_at" + dir)
+    }
+    return q.Order("id")
 }
 
-func GetCourseByID(id string) (model.Course, error) {
-    for _, course := range courses {
-        if course.ID == id {
-            return course, nil
+// SortCourses orders a loaded list the way apply orders a query.
+func SortCourses(courses []model.Course, cs CourseSort) {
+    sort.SliceStable(courses, func(i, j int) bool {
+        a, b := courses[i], courses[j]
+        if cs.Desc {
+            a, b = b, a
         }
+        switch cs.Field {
+        case "title":
+            if a.Title != b.Title {
+                return a.Title < b.Title
+            }
+        case "rating":
+            if a.RatingAverage != b.RatingAverage {
+                return a.RatingAverage < b.RatingAverage
+            }
+            if a.RatingCount != b.RatingCount {
+                return a.RatingCount < b.RatingCount
+            }
+        default:
+            if !a.CreatedAt.Equal(b.CreatedAt) {
+                return a.CreatedAt.Before(b.CreatedAt)
+            }
+        }
+        return courses[i].ID < courses[j].ID
+    })
+}
+
+func GetAllCourses(ctx context.Context) ([]model.Course, error) {
+    courses, err := cached(ctx, "courses", func(ctx context.Context) ([]model.Course, error) {
+        var courses []model.Course
+        err := db(ctx).Order("created_at").Find(&courses).Error
+        return courses, err
+    })
+    if courses == nil && err == nil {
+        courses = []model.Course{}
+    }
+    return courses, err
+}
+
+// GetCoursesPage returns one page of courses in the given order together
+// with the total number of courses. With columns, only those columns are
+// loaded and the other fields are left zero.
+func GetCoursesPage(ctx context.Context, limit, offset int, cs CourseSort, columns ...string) ([]model.Course, int64, error) {
+    var total int64
+    if err := readDB(ctx).Model(&model.Course{}).Count(&total).Error; err != nil {
+        return nil, 0, err
     }
-    return model.Course{}, errors.New("course not found")
-}
\ No newline at end of file
+    q := cs.apply(readDB(ctx)).Limit(limit).Offset(offset)
+    if len(columns) > 0 {
+        q = q.Select(columns)
+    }
+    var courses []model.Course
+    err := q.Find(&courses).Error
+    return courses, total, err
+}
+
+func GetCourseByID(ctx context.Context, id string) (model.Course, error) {
+    return cached(ctx, "course:"+id, func(ctx context.Context) (model.Course, error) {
+        var course model.Course
+        err := findCourse(db(ctx), id, &course)
+        return course, err
+    })
+}
+
+// GetCourseColumns loads only the given columns of a course, bypassing the
+// cache, which holds whole rows. Without columns it is GetCourseByID.
+func GetCourseColumns(ctx context.Context, id string, columns []string) (model.Course, error) {
+    if len(columns) == 0 {
+        return GetCourseByID(ctx, id)
+    }
+    var course model.Course
+    err := findCourse(db(ctx).Select(columns), id, &course)
+    return course, err
+}
+
+// GetCoursesByIDs loads several courses in one query. Missing IDs are
+// simply absent from the result.
+func GetCoursesByIDs(ctx context.Context, ids []string) ([]model.Course, error) {
+    var courses []model.Course
+    err := readDB(ctx).Where("id IN ?", ids).Find(&courses).Error
+    return courses, err
+}
+
+func CreateCourse(ctx context.Context, input model.Course) (model.Course, error) {
+    course := model.Course{
+        ID:          uuid.NewString(),
+        Title:       input.Title,
+        Description: input.Description,
+        AuthorID:    input.AuthorID,
+    }
+    var event *model.OutboxEvent
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(&course).Error; err != nil {
+            return err
+        }
+        var err error
+        event, err = recordEvent(tx, model.EventCourseCreated, course.ID, course)
+        return err
+    })
+    if err == nil {
+        publish(ctx, event)
+        courseChanged(ctx, course)
+    }
+    return course, err
+}
+
+func UpdateCourse(ctx context.Context, id string, input model.Course) (model.Course, error) {
+    var course model.Course
+    var event *model.OutboxEvent
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findCourse(tx, id, &course); err != nil {
+            return err
+        }
+        course.Title = input.Title
+        course.Description = input.Description
+        if err := tx.Model(&course).Select("title", "description").Updates(&course).Error; err != nil {
+            return err
+        }
+        var err error
+        event, err = recordEvent(tx, model.EventCourseUpdated, course.ID, course)
+        return err
+    })
+    if err == nil {
+        publish(ctx, event)
+        courseChanged(ctx, course)
+    }
+    return course, err
+}
+
+// PatchCourse applies a partial update to the stored course. apply gets
+// the course as it is now and returns it changed; an error from apply
+// leaves the course untouched and is returned as is. The row is locked
+// while apply runs so concurrent patches don't lose each other's changes.
+func PatchCourse(ctx context.Context, id string, apply func(model.Course) (model.Course, error)) (model.Course, error) {
+    var course model.Course
+    var event *model.OutboxEvent
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        q := tx
+        if tx.Dialector.Name() == "postgres" {
+            q = q.Clauses(clause.Locking{Strength: "UPDATE"})
+        }
+        if err := findCourse(q, id, &course); err != nil {
+            return err
+        }
+        patched, err := apply(course)
+        if err != nil {
+            return err
+        }
+        if patched.Title == course.Title && patched.Description == course.Description {
+            return nil
+        }
+        course.Title = patched.Title
+        course.Description = patched.Description
+        if err := tx.Model(&course).Select("title", "description").Updates(&course).Error; err != nil {
+            return err
+        }
+        event, err = recordEvent(tx, model.EventCourseUpdated, course.ID, course)
+        return err
+    })
+    if err == nil && event != nil {
+        publish(ctx, event)
+        courseChanged(ctx, course)
+    }
+    return course, err
+}
+
+func PublishCourse(ctx context.Context, id string) (model.Course, error) {
+    var course model.Course
+    var event *model.OutboxEvent
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findCourse(tx, id, &course); err != nil {
+            return err
+        }
+        if course.Published {
+            return nil
+        }
+        course.Published = true
+        if err := tx.Model(&course).Select("published").Updates(&course).Error; err != nil {
+            return err
+        }
+        var err error
+        event, err = recordEvent(tx, model.EventCoursePublished, course.ID, course)
+        return err
+    })
+    if err == nil && event != nil {
+        publish(ctx, event)
+        courseChanged(ctx, course)
+    }
+    return course, err
+}
+
+func DeleteCourse(ctx context.Context, id string) error {
+    var event *model.OutboxEvent
+    var blobKeys []string
+    var course model.Course
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findCourse(tx, id, &course); err != nil {
+            return err
+        }
+        if err := tx.Where("course_id = ?", course.ID).Delete(&model.Module{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Where("course_id = ?", course.ID).Delete(&model.Review{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Model(&model.Media{}).Where("course_id = ?", course.ID).Distinct().Pluck("storage_key", &blobKeys).Error; err != nil {
+            return err
+        }
+        if err := tx.Where("course_id = ?", course.ID).Delete(&model.Media{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(&course).Error; err != nil {
+            return err
+        }
+        var err error
+        event, err = recordEvent(tx, model.EventCourseDeleted, course.ID, course)
+        return err
+    })
+    if err == nil {
+        publish(ctx, event)
+        courseRemoved(ctx, course)
+        releaseBlobs(ctx, blobKeys)
+    }
+    return err
+}
+
+func findCourse(tx *gorm.DB, id string, course *model.Course) error {
+    err := tx.First(course, "id = ?", id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return ErrCourseNotFound
+    }
+    return err
+}
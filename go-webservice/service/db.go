@@ -0,0 +1,45 @@
+package service
+
+import (
+    "context"
+    "go-webservice/config"
+    "go-webservice/txn"
+
+    "gorm.io/gorm"
+)
+
+// db returns the shared connection bound to ctx. The tenant plugin reads the
+// tenant from ctx, so every query made through it is scoped automatically.
+// Inside a unit of work it returns the unit's transaction, and a
+// Transaction call on it becomes a savepoint.
+func db(ctx context.Context) *gorm.DB {
+    if u, ok := txn.From(ctx); ok {
+        return u.DB(ctx)
+    }
+    return config.DB.WithContext(ctx)
+}
+
+// readDB returns a connection for queries that only read, bound to ctx. It
+// may be a replica that lags the primary, unless the request has already
+// written (see replica.WithSession) or its unit of work has begun. Reads
+// that decide a write, and reads that fill the cache, use db instead.
+func readDB(ctx context.Context) *gorm.DB {
+    if u, ok := txn.From(ctx); ok && u.Started() {
+        return u.DB(ctx)
+    }
+    return config.Reads.Read(ctx).WithContext(ctx)
+}
+
+// BeginUnit returns ctx carrying a unit of work on the primary. Service
+// calls made with ctx share its transaction until the caller commits or
+// rolls it back.
+func BeginUnit(ctx context.Context) (context.Context, *txn.Unit) {
+    return txn.New(ctx, config.DB)
+}
+
+// InTransaction runs fn in a unit of work: a transaction of its own, or a
+// savepoint when ctx already carries one. fn's changes are rolled back if
+// it returns an error or panics.
+func InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+    return txn.Run(ctx, config.DB, fn)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+    "context"
+    "encoding/json"
+    "go-webservice/events"
+    "go-webservice/model"
+    "go-webservice/txn"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// recordEvent appends a domain event to the outbox using the caller's
+// transaction, so the event is stored if and only if the change commits.
+// The returned event should be handed to publish once the transaction has
+// committed.
+func recordEvent(tx *gorm.DB, eventType, aggregateID string, data interface{}) (*model.OutboxEvent, error) {
+    raw, err := json.Marshal(data)
+    if err != nil {
+        return nil, err
+    }
+    envelope := model.EventEnvelope{
+        ID:         uuid.NewString(),
+        Type:       eventType,
+        OccurredAt: time.Now().UTC(),
+        Data:       raw,
+    }
+    payload, err := json.Marshal(envelope)
+    if err != nil {
+        return nil, err
+    }
+    event := &model.OutboxEvent{
+        EventID:     envelope.ID,
+        Type:        eventType,
+        AggregateID: aggregateID,
+        Payload:     string(payload),
+        CreatedAt:   envelope.OccurredAt,
+    }
+    return event, tx.Create(event).Error
+}
+
+// publish pushes a committed event to live subscribers such as the SSE
+// change feed. A nil event (nothing changed) is ignored.
+func publish(ctx context.Context, event *model.OutboxEvent) {
+    if event == nil {
+        return
+    }
+    txn.AfterCommit(ctx, func(context.Context) {
+        events.Default.Publish(event.TenantID, event.Type, []byte(event.Payload))
+    })
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "go-webservice/config"
+    "go-webservice/flags"
+    "go-webservice/model"
+    "go-webservice/txn"
+    "log"
+    "strings"
+    "time"
+)
+
+var ErrFlagNotFound = errors.New("flag has no runtime definition")
+
+// FlagEnabled reports whether a feature flag is on for the caller, as
+// stored in ctx by the feature flag middleware.
+func FlagEnabled(ctx context.Context, key string) bool {
+    return config.Flags.Enabled(ctx, key)
+}
+
+// EvaluateFlags returns the value of every flag for the caller.
+func EvaluateFlags(ctx context.Context) map[string]bool {
+    return config.Flags.Evaluate(ctx)
+}
+
+func GetFlags() []flags.Entry {
+    return config.Flags.All()
+}
+
+// SetFlag stores a runtime definition of a flag, overriding the flags
+// file, and applies it to this instance as soon as it commits. Other
+// instances pick it up on their next refresh.
+func SetFlag(ctx context.Context, f flags.Flag, updatedBy string) (flags.Entry, error) {
+    if err := flags.Validate(f); err != nil {
+        return flags.Entry{}, err
+    }
+    row := model.FeatureFlag{
+        Key:         f.Key,
+        Description: f.Description,
+        Enabled:     f.Enabled,
+        Rollout:     f.Rollout,
+        Users:       strings.Join(f.Users, ","),
+        Roles:       strings.Join(f.Roles, ","),
+        Tenants:     strings.Join(f.Tenants, ","),
+        UpdatedBy:   updatedBy,
+    }
+    if err := db(ctx).Save(&row).Error; err != nil {
+        return flags.Entry{}, err
+    }
+    refreshAfterCommit(ctx)
+    return flags.Entry{Flag: toFlag(row), Source: flags.SourceDatabase}, nil
+}
+
+// DeleteFlag drops a runtime definition, so the flags file applies again.
+func DeleteFlag(ctx context.Context, key string) error {
+    res := db(ctx).Delete(&model.FeatureFlag{}, "key = ?", key)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrFlagNotFound
+    }
+    refreshAfterCommit(ctx)
+    return nil
+}
+
+// refreshAfterCommit applies a flag change to this instance once it
+// commits. A failed refresh is retried by WatchFlags.
+func refreshAfterCommit(ctx context.Context) {
+    txn.AfterCommit(ctx, func(ctx context.Context) {
+        if err := RefreshFlags(ctx); err != nil {
+            log.Println("feature flags:", err)
+        }
+    })
+}
+
+// RefreshFlags reloads the runtime definitions from the database.
+func RefreshFlags(ctx context.Context) error {
+    var rows []model.FeatureFlag
+    if err := db(ctx).Find(&rows).Error; err != nil {
+        return err
+    }
+    defs := make([]flags.Flag, len(rows))
+    for i, row := range rows {
+        defs[i] = toFlag(row)
+    }
+    config.Flags.SetDatabase(defs)
+    return nil
+}
+
+// WatchFlags refreshes the runtime definitions every interval until ctx
+// is cancelled, so changes made through another instance take effect.
+func WatchFlags(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        if err := RefreshFlags(ctx); err != nil {
+            log.Println("feature flags:", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+func toFlag(row model.FeatureFlag) flags.Flag {
+    return flags.Flag{
+        Key:         row.Key,
+        Description: row.Description,
+        Enabled:     row.Enabled,
+        Rollout:     row.Rollout,
+        Users:       splitList(row.Users),
+        Roles:       splitList(row.Roles),
+        Tenants:     splitList(row.Tenants),
+    }
+}
+
+func splitList(s string) []string {
+    var list []string
+    for _, v := range strings.Split(s, ",") {
+        if v = strings.TrimSpace(v); v != "" {
+            list = append(list, v)
+        }
+    }
+    return list
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+    "bufio"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "go-webservice/model"
+    "io"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// ImportColumns is the CSV header used by both import and export.
+var ImportColumns = []string{"external_id", "title", "description", "published"}
+
+var ErrImportInvalid = errors.New("import contains invalid rows")
+
+type ImportRow struct {
+    Line        int    `json:"-"`
+    ID          string `json:"id"`
+    ExternalID  string `json:"external_id"`
+    Title       string `json:"title"`
+    Description string `json:"description"`
+    Published   bool   `json:"published"`
+}
+
+type RowError struct {
+    Line       int    `json:"line"`
+    ExternalID string `json:"external_id,omitempty"`
+    Field      string `json:"field,omitempty"`
+    Message    string `json:"message"`
+}
+
+type ImportReport struct {
+    DryRun  bool       `json:"dry_run"`
+    Total   int        `json:"total"`
+    Created int        `json:"created"`
+    Updated int        `json:"updated"`
+    Errors  []RowError `json:"errors"`
+}
+
+// ParseCSV reads rows with an ImportColumns header. Lines are counted from
+// 1 including the header, matching what a spreadsheet shows. Malformed
+// lines are reported as row errors; the error is for a body that could not
+// be read to the end, such as one over a size limit.
+func ParseCSV(r io.Reader) ([]ImportRow, []RowError, error) {
+    reader := csv.NewReader(r)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    var parseErr *csv.ParseError
+    if err != nil && err != io.EOF && !errors.As(err, &parseErr) {
+        return nil, nil, err
+    }
+    if err != nil {
+        return nil, []RowError{{Line: 1, Message: "missing header: " + err.Error()}}, nil
+    }
+    index := map[string]int{}
+    for i, name := range header {
+        index[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    for _, required := range []string{"external_id", "title"} {
+        if _, ok := index[required]; !ok {
+            return nil, []RowError{{Line: 1, Field: required, Message: "missing column"}}, nil
+        }
+    }
+
+    var rows []ImportRow
+    var errs []RowError
+    for line := 2; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if errors.As(err, &parseErr) {
+            errs = append(errs, RowError{Line: line, Message: err.Error()})
+            continue
+        }
+        if err != nil {
+            // the reader fails the same way on every call from now on
+            return nil, nil, err
+        }
+        get := func(name string) string {
+            if i, ok := index[name]; ok && i < len(record) {
+                return strings.TrimSpace(record[i])
+            }
+            return ""
+        }
+        row := ImportRow{Line: line, ExternalID: get("external_id"), Title: get("title"), Description: get("description")}
+        if p := get("published"); p != "" {
+            published, err := strconv.ParseBool(p)
+            if err != nil {
+                errs = append(errs, RowError{Line: line, ExternalID: row.ExternalID, Field: "published", Message: "must be true or false"})
+                continue
+            }
+            row.Published = published
+        }
+        rows = append(rows, row)
+    }
+    return rows, errs, nil
+}
+
+// ParseNDJSON reads one JSON object per line; blank lines are skipped. As
+// with ParseCSV, the error is for a body that could not be read.
+func ParseNDJSON(r io.Reader) ([]ImportRow, []RowError, error) {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64<<10), 1<<20)
+    var rows []ImportRow
+    var errs []RowError
+    line := 0
+    for scanner.Scan() {
+        line++
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" {
+            continue
+        }
+        var row ImportRow
+        if err := json.Unmarshal([]byte(text), &row); err != nil {
+            errs = append(errs, RowError{Line: line, Message: "invalid JSON: " + err.Error()})
+            continue
+        }
+        row.Line = line
+        row.ExternalID = strings.TrimSpace(row.ExternalID)
+        if row.ExternalID == "" {
+            // an NDJSON export of a course created through the API
+            row.ExternalID = strings.TrimSpace(row.ID)
+        }
+        row.Title = strings.TrimSpace(row.Title)
+        rows = append(rows, row)
+    }
+    if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+        errs = append(errs, RowError{Line: line + 1, Message: err.Error()})
+    } else if err != nil {
+        return nil, nil, err
+    }
+    return rows, errs, nil
+}
+
+func validateRows(rows []ImportRow) []RowError {
+    var errs []RowError
+    seen := map[string]int{}
+    for _, row := range rows {
+        switch {
+        case row.ExternalID == "":
+            errs = append(errs, RowError{Line: row.Line, Field: "external_id", Message: "is required"})
+        case seen[row.ExternalID] != 0:
+            errs = append(errs, RowError{Line: row.Line, ExternalID: row.ExternalID, Field: "external_id",
+                Message: fmt.Sprintf("duplicate of line %d", seen[row.ExternalID])})
+        default:
+            seen[row.ExternalID] = row.Line
+        }
+        if row.Title == "" {
+            errs = append(errs, RowError{Line: row.Line, ExternalID: row.ExternalID, Field: "title", Message: "is required"})
+        }
+    }
+    return errs
+}
+
+// ImportCourses upserts rows by external ID in a single transaction. If any
+// row is invalid nothing is written and ErrImportInvalid is returned with
+// the report. A dry run validates and counts but always rolls back.
+func ImportCourses(ctx context.Context, rows []ImportRow, parseErrs []RowError, dryRun bool) (ImportReport, error) {
+    report := ImportReport{DryRun: dryRun, Total: len(rows) + len(parseErrs)}
+    report.Errors = append(append([]RowError{}, parseErrs...), validateRows(rows)...)
+    if len(report.Errors) > 0 {
+        sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+        return report, ErrImportInvalid
+    }
+
+    var events []*model.OutboxEvent
+    var courses []model.Course
+    errDryRun := errors.New("dry run")
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        for _, row := range rows {
+            course, event, created, err := upsertCourse(tx, row)
+            if err != nil {
+                return fmt.Errorf("line %d: %w", row.Line, err)
+            }
+            courses = append(courses, course)
+            if created {
+                report.Created++
+            } else {
+                report.Updated++
+            }
+            events = append(events, event)
+        }
+        if dryRun {
+            return errDryRun
+        }
+        return nil
+    })
+    if dryRun && errors.Is(err, errDryRun) {
+        return report, nil
+    }
+    if err != nil {
+        return report, err
+    }
+    for _, event := range events {
+        publish(ctx, event)
+    }
+    for _, course := range courses {
+        courseChanged(ctx, course)
+    }
+    return report, nil
+}
+
+// upsertCourse matches row by external ID, falling back to the ID of a
+// course that has none: ExportRecord writes the ID in that case, so that
+// courses created through the API survive an export and import. Such a
+// course takes the ID as its external ID from then on.
+func upsertCourse(tx *gorm.DB, row ImportRow) (model.Course, *model.OutboxEvent, bool, error) {
+    var course model.Course
+    err := tx.Where("external_id = ?", row.ExternalID).Limit(1).Find(&course).Error
+    if err == nil && course.ID == "" {
+        err = tx.Where("id = ? AND external_id IS NULL", row.ExternalID).Limit(1).Find(&course).Error
+    }
+    if err != nil {
+        return course, nil, false, err
+    }
+    if course.ID == "" {
+        externalID := row.ExternalID
+        course = model.Course{
+            ID:          uuid.NewString(),
+            ExternalID:  &externalID,
+            Title:       row.Title,
+            Description: row.Description,
+            Published:   row.Published,
+        }
+        if err := tx.Create(&course).Error; err != nil {
+            return course, nil, false, err
+        }
+        event, err := recordEvent(tx, model.EventCourseCreated, course.ID, course)
+        return course, event, true, err
+    }
+
+    externalID := row.ExternalID
+    course.ExternalID = &externalID
+    course.Title = row.Title
+    course.Description = row.Description
+    course.Published = row.Published
+    if err := tx.Model(&course).Select("external_id", "title", "description", "published").Updates(&course).Error; err != nil {
+        return course, nil, false, err
+    }
+    event, err := recordEvent(tx, model.EventCourseUpdated, course.ID, course)
+    return course, event, false, err
+}
+
+// ExportRecord returns course as a CSV record in ImportColumns order, so
+// an export can be imported again. Courses without an external ID are
+// keyed by their ID, which import falls back to.
+func ExportRecord(course model.Course) []string {
+    externalID := course.ID
+    if course.ExternalID != nil {
+        externalID = *course.ExternalID
+    }
+    return []string{externalID, course.Title, course.Description, strconv.FormatBool(course.Published)}
+}
+
+// ExportCourses calls fn for every course in creation order, reading rows
+// from the database one at a time so the catalog is never held in memory.
+func ExportCourses(ctx context.Context, fn func(model.Course) error) error {
+    tx := readDB(ctx)
+    rows, err := tx.Model(&model.Course{}).Order("created_at").Order("id").Rows()
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var course model.Course
+        if err := tx.ScanRows(rows, &course); err != nil {
+            return err
+        }
+        if err := fn(course); err != nil {
+            return err
+        }
+    }
+    return rows.Err()
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+    "errors"
+    "io"
+    "strings"
+    "testing"
+    "time"
+)
+
+// failingReader returns some data, then the same error on every read, as
+// a body over its size limit or from a client that went away does.
+type failingReader struct {
+    data io.Reader
+    err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+    n, err := r.data.Read(p)
+    if err == io.EOF {
+        return n, r.err
+    }
+    return n, err
+}
+
+func TestParseStopsOnReadError(t *testing.T) {
+    broken := errors.New("connection reset")
+    parsers := map[string]func(io.Reader) ([]ImportRow, []RowError, error){
+        "csv":    ParseCSV,
+        "ndjson": ParseNDJSON,
+    }
+    bodies := map[string]string{
+        "csv":    "external_id,title\na,Alpha\nb,\"Beta",
+        "ndjson": `{"external_id": "a", "title": "Alpha"}` + "\n" + `{"external_id": "b"`,
+    }
+    for name, parse := range parsers {
+        done := make(chan error, 1)
+        go func() {
+            _, _, err := parse(&failingReader{data: strings.NewReader(bodies[name]), err: broken})
+            done <- err
+        }()
+        select {
+        case err := <-done:
+            if !errors.Is(err, broken) {
+                t.Errorf("%s: error %v, want the read error", name, err)
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatalf("%s: still parsing a reader that keeps failing", name)
+        }
+    }
+}
+
+func TestParseCSVReportsMalformedLines(t *testing.T) {
+    rows, errs, err := ParseCSV(strings.NewReader("external_id,title\na,\"Alpha\nb,Beta\n"))
+    if err != nil || len(rows) != 0 || len(errs) != 1 {
+        t.Fatalf("rows %+v, row errors %+v, error %v; want one row error", rows, errs, err)
+    }
+    rows, errs, err = ParseCSV(strings.NewReader("external_id,title\na,Al\"pha\nb,Beta\n"))
+    if err != nil || len(rows) != 1 || rows[0].ExternalID != "b" || len(errs) != 1 || errs[0].Line != 2 {
+        t.Fatalf("rows %+v, row errors %+v, error %v; want line 2 rejected and b kept", rows, errs, err)
+    }
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "go-webservice/jobs"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+const (
+    JobImportCourses = "courses.import"
+    JobCleanup       = "maintenance.cleanup"
+)
+
+// finished jobs and dispatched outbox events are kept this long
+const retention = 7 * 24 * time.Hour
+
+var (
+    ErrJobNotFound  = errors.New("job not found")
+    ErrJobNotFailed = errors.New("only failed jobs can be retried")
+)
+
+// importJob is the payload of an asynchronous import.
+type importJob struct {
+    Rows      []queuedRow `json:"rows"`
+    ParseErrs []RowError  `json:"parse_errors"`
+    DryRun    bool        `json:"dry_run"`
+}
+
+// queuedRow keeps the line number, which ImportRow leaves out of JSON, so
+// the report points at the right lines.
+type queuedRow struct {
+    Line int `json:"line"`
+    ImportRow
+}
+
+func init() {
+    jobs.Register(JobImportCourses, func(ctx context.Context, p importJob) (interface{}, error) {
+        rows := make([]ImportRow, len(p.Rows))
+        for i, row := range p.Rows {
+            rows[i] = row.ImportRow
+            rows[i].Line = row.Line
+        }
+        report, err := ImportCourses(ctx, rows, p.ParseErrs, p.DryRun)
+        if errors.Is(err, ErrImportInvalid) {
+            // the rows will not get any better on a retry
+            return report, jobs.Permanent(err)
+        }
+        return report, err
+    })
+    jobs.Register(JobCleanup, func(ctx context.Context, _ struct{}) (interface{}, error) {
+        return Cleanup(ctx, time.Now().UTC().Add(-retention))
+    })
+    jobs.Schedule("cleanup", "@daily", JobCleanup, struct{}{})
+}
+
+// EnqueueImport queues an import to run in the background. The report is
+// stored as the job's result.
+func EnqueueImport(ctx context.Context, rows []ImportRow, parseErrs []RowError, dryRun bool) (model.Job, error) {
+    payload := importJob{Rows: make([]queuedRow, len(rows)), ParseErrs: parseErrs, DryRun: dryRun}
+    for i, row := range rows {
+        payload.Rows[i] = queuedRow{Line: row.Line, ImportRow: row}
+    }
+    return jobs.Enqueue(db(ctx), JobImportCourses, payload)
+}
+
+// CleanupResult counts the rows removed by Cleanup.
+type CleanupResult struct {
+    Jobs   int64 `json:"jobs"`
+    Events int64 `json:"events"`
+}
+
+// Cleanup deletes succeeded jobs and dispatched outbox events older than
+// before, across every tenant.
+func Cleanup(ctx context.Context, before time.Time) (CleanupResult, error) {
+    var result CleanupResult
+    tx := db(tenant.System(ctx))
+    res := tx.Where("status = ? AND finished_at < ?", model.JobSucceeded, before).Delete(&model.Job{})
+    if res.Error != nil {
+        return result, res.Error
+    }
+    result.Jobs = res.RowsAffected
+    res = tx.Where("dispatched_at < ?", before).Delete(&model.OutboxEvent{})
+    result.Events = res.RowsAffected
+    return result, res.Error
+}
+
+// GetJobs lists the tenant's jobs, newest first, optionally filtered by
+// status and type.
+func GetJobs(ctx context.Context, status, jobType string, limit int) ([]model.Job, error) {
+    q := db(ctx).Order("id desc").Limit(limit)
+    if status != "" {
+        q = q.Where("status = ?", status)
+    }
+    if jobType != "" {
+        q = q.Where("type = ?", jobType)
+    }
+    jobList := []model.Job{}
+    err := q.Find(&jobList).Error
+    return jobList, err
+}
+
+func GetJob(ctx context.Context, id string) (model.Job, error) {
+    var job model.Job
+    err := db(ctx).First(&job, "id = ?", id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return job, ErrJobNotFound
+    }
+    return job, err
+}
+
+// RetryJob queues a failed job again with a fresh attempt budget.
+func RetryJob(ctx context.Context, id string) (model.Job, error) {
+    job, err := GetJob(ctx, id)
+    if err != nil {
+        return job, err
+    }
+    if job.Status != model.JobFailed {
+        return job, ErrJobNotFailed
+    }
+    job.Status = model.JobQueued
+    job.Attempts = 0
+    job.RunAt = time.Now().UTC()
+    job.FinishedAt = nil
+    err = db(ctx).Model(&job).Select("status", "attempts", "run_at", "finished_at").Updates(&job).Error
+    return job, err
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "go-webservice/config"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "go-webservice/txn"
+    "io"
+    "log"
+    "mime"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strconv"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+var (
+    ErrMediaNotFound        = errors.New("media not found")
+    ErrInvalidMediaKind     = errors.New("kind must be thumbnail or attachment")
+    ErrMediaTooLarge        = errors.New("file is too large")
+    ErrUnsupportedMediaType = errors.New("file type is not allowed")
+)
+
+type mediaPolicy struct {
+    maxSize int64
+    types   map[string]bool
+}
+
+var mediaPolicies = map[string]mediaPolicy{
+    model.MediaThumbnail: {
+        maxSize: 5 << 20,
+        types:   map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true},
+    },
+    model.MediaAttachment: {
+        maxSize: 50 << 20,
+        types: map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true,
+            "application/pdf": true, "application/zip": true, "text/plain": true},
+    },
+}
+
+// MaxMediaSize is the largest upload any media kind accepts.
+const MaxMediaSize = 50 << 20
+
+// UploadMedia stores a file for a course. The content type is sniffed from
+// the data rather than trusted from the client, and the file is spooled to
+// disk while hashing so the size limit holds without buffering in memory.
+func UploadMedia(ctx context.Context, courseID, kind, filename string, r io.Reader) (model.Media, error) {
+    policy, ok := mediaPolicies[kind]
+    if !ok {
+        return model.Media{}, ErrInvalidMediaKind
+    }
+    if _, err := GetCourseByID(ctx, courseID); err != nil {
+        return model.Media{}, err
+    }
+
+    head := make([]byte, 512)
+    n, err := io.ReadFull(r, head)
+    if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+        return model.Media{}, err
+    }
+    head = head[:n]
+    contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+    if !policy.types[contentType] {
+        return model.Media{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+    }
+
+    tmp, err := os.CreateTemp("", "media-*")
+    if err != nil {
+        return model.Media{}, err
+    }
+    defer os.Remove(tmp.Name())
+    defer tmp.Close()
+
+    hash := sha256.New()
+    size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(io.MultiReader(bytes.NewReader(head), r), policy.maxSize+1))
+    if err != nil {
+        return model.Media{}, err
+    }
+    if size > policy.maxSize {
+        return model.Media{}, ErrMediaTooLarge
+    }
+    sum := hex.EncodeToString(hash.Sum(nil))
+    tenantID, _ := tenant.FromContext(ctx)
+    key := tenantID + "/" + sum
+
+    media := model.Media{
+        ID:          uuid.NewString(),
+        CourseID:    courseID,
+        Kind:        kind,
+        Filename:    filepath.Base(filename),
+        ContentType: contentType,
+        Size:        size,
+        SHA256:      sum,
+        StorageKey:  key,
+    }
+    // the blob row stays locked until the media row referencing it commits,
+    // so cleanup of the last previous reference cannot delete it meanwhile
+    err = txn.Run(ctx, config.DB, func(ctx context.Context) error {
+        stored, err := lockBlob(ctx, key)
+        if err != nil {
+            return err
+        }
+        if !stored {
+            if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+                return err
+            }
+            if err := config.Blobs.Put(ctx, key, tmp, size, contentType); err != nil {
+                return err
+            }
+        }
+        return db(ctx).Create(&media).Error
+    })
+    if err != nil {
+        return model.Media{}, err
+    }
+    return media, nil
+}
+
+func GetMediaList(ctx context.Context, courseID string) ([]model.Media, error) {
+    if _, err := GetCourseByID(ctx, courseID); err != nil {
+        return nil, err
+    }
+    var media []model.Media
+    err := readDB(ctx).Where("course_id = ?", courseID).Order("created_at").Find(&media).Error
+    return media, err
+}
+
+func GetMedia(ctx context.Context, courseID, id string) (model.Media, error) {
+    var media model.Media
+    err := db(ctx).First(&media, "id = ? AND course_id = ?", id, courseID).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return model.Media{}, ErrMediaNotFound
+    }
+    return media, err
+}
+
+func DeleteMedia(ctx context.Context, courseID, id string) error {
+    media, err := GetMedia(ctx, courseID, id)
+    if err != nil {
+        return err
+    }
+    if err := db(ctx).Delete(&media).Error; err != nil {
+        return err
+    }
+    releaseBlobs(ctx, []string{media.StorageKey})
+    return nil
+}
+
+// OpenMedia returns a media record and a reader for its content.
+func OpenMedia(ctx context.Context, id string) (model.Media, io.ReadCloser, error) {
+    var media model.Media
+    err := db(ctx).First(&media, "id = ?", id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return model.Media{}, nil, ErrMediaNotFound
+    }
+    if err != nil {
+        return model.Media{}, nil, err
+    }
+    body, err := config.Blobs.Get(ctx, media.StorageKey)
+    return media, body, err
+}
+
+// releaseBlobs deletes blobs no longer referenced by any media row once
+// the owning transaction commits; a failure only leaves an orphaned blob
+// behind, so it is logged rather than returned.
+func releaseBlobs(ctx context.Context, keys []string) {
+    txn.AfterCommit(ctx, func(ctx context.Context) {
+        deleteUnreferenced(ctx, keys)
+    })
+}
+
+func deleteUnreferenced(ctx context.Context, keys []string) {
+    for _, key := range keys {
+        key := key
+        err := txn.Run(ctx, config.DB, func(ctx context.Context) error {
+            if _, err := lockBlob(ctx, key); err != nil {
+                return err
+            }
+            var refs int64
+            if err := db(ctx).Model(&model.Media{}).Where("storage_key = ?", key).Count(&refs).Error; err != nil || refs > 0 {
+                return err
+            }
+            if err := db(ctx).Delete(&model.MediaBlob{Key: key}).Error; err != nil {
+                return err
+            }
+            return config.Blobs.Delete(ctx, key)
+        })
+        if err != nil {
+            log.Println("media: delete blob", key, err)
+        }
+    }
+}
+
+// lockBlob locks the MediaBlob row for key until the unit of work in ctx
+// ends, creating it if needed, and reports whether it already existed,
+// that is whether the blob is stored. Postgres locks the row itself; on
+// other databases the insert takes the database write lock.
+func lockBlob(ctx context.Context, key string) (bool, error) {
+    tx := db(ctx)
+    created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.MediaBlob{Key: key})
+    if created.Error != nil {
+        return false, created.Error
+    }
+    if tx.Dialector.Name() == "postgres" {
+        var blob model.MediaBlob
+        err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&blob, "key = ?", key).Error
+        if err != nil {
+            return false, err
+        }
+    }
+    return created.RowsAffected == 0, nil
+}
+
+// SignMediaURL returns a download path for media that is valid without
+// other credentials until it expires.
+func SignMediaURL(ctx context.Context, media model.Media, ttl time.Duration) (string, time.Time) {
+    tenantID, _ := tenant.FromContext(ctx)
+    expires := time.Now().Add(ttl).Truncate(time.Second)
+    q := url.Values{}
+    q.Set("tenant", tenantID)
+    q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+    q.Set("sig", mediaSignature(tenantID, media.ID, expires.Unix()))
+    return "/media/" + media.ID + "?" + q.Encode(), expires
+}
+
+// VerifyMediaURL checks the query of a signed download URL.
+func VerifyMediaURL(tenantID, id, expires, sig string) bool {
+    exp, err := strconv.ParseInt(expires, 10, 64)
+    if err != nil || time.Now().Unix() > exp {
+        return false
+    }
+    return hmac.Equal([]byte(sig), []byte(mediaSignature(tenantID, id, exp)))
+}
+
+func mediaSignature(tenantID, id string, expires int64) string {
+    mac := hmac.New(sha256.New, config.MediaURLSecret)
+    fmt.Fprintf(mac, "%s\n%s\n%d", tenantID, id, expires)
+    return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+    "context"
+    "go-webservice/model"
+
+    "github.com/google/uuid"
+)
+
+func GetModules(ctx context.Context, courseID string) ([]model.Module, error) {
+    if _, err := GetCourseByID(ctx, courseID); err != nil {
+        return nil, err
+    }
+    var modules []model.Module
+    err := readDB(ctx).Where("course_id = ?", courseID).Order("position").Find(&modules).Error
+    return modules, err
+}
+
+// GetModulesByCourseIDs loads the modules of several courses in one query.
+func GetModulesByCourseIDs(ctx context.Context, courseIDs []string) ([]model.Module, error) {
+    var modules []model.Module
+    err := readDB(ctx).Where("course_id IN ?", courseIDs).Order("course_id").Order("position").Find(&modules).Error
+    return modules, err
+}
+
+// CreateModule appends a module to the end of a course.
+func CreateModule(ctx context.Context, courseID string, input model.Module) (model.Module, error) {
+    if _, err := GetCourseByID(ctx, courseID); err != nil {
+        return model.Module{}, err
+    }
+    var last model.Module
+    err := db(ctx).Where("course_id = ?", courseID).Order("position desc").Limit(1).Find(&last).Error
+    if err != nil {
+        return model.Module{}, err
+    }
+    module := model.Module{
+        ID:       uuid.NewString(),
+        CourseID: courseID,
+        Title:    input.Title,
+        Position: last.Position + 1,
+    }
+    err = db(ctx).Create(&module).Error
+    return module, err
+}
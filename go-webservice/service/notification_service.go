@@ -0,0 +1,52 @@
+package service
+
+import (
+    "context"
+    "go-webservice/config"
+    "go-webservice/jobs"
+    "go-webservice/model"
+    "go-webservice/notify"
+
+    "gorm.io/gorm"
+)
+
+const JobSendEmail = "email.send"
+
+func init() {
+    jobs.Register(JobSendEmail, func(ctx context.Context, msg notify.Message) (interface{}, error) {
+        err := config.Notifier.Send(ctx, msg)
+        if notify.Rejected(err) {
+            // a 5xx from the relay means the address or message is bad
+            return nil, jobs.Permanent(err)
+        }
+        return nil, err
+    })
+}
+
+// SendEmail renders a template and queues it for delivery, so callers never
+// wait on the mail server. Rendering happens now so that template and data
+// errors reach the caller. Pass the caller's transaction as tx to send only
+// if it commits, or nil to queue straight away.
+func SendEmail(ctx context.Context, tx *gorm.DB, to []string, template, locale string, data interface{}) (model.Job, error) {
+    if err := notify.ValidateAddresses(to); err != nil {
+        return model.Job{}, err
+    }
+    msg, err := notify.Render(template, locale, data)
+    if err != nil {
+        return model.Job{}, err
+    }
+    msg.To = to
+    if tx == nil {
+        tx = db(ctx)
+    }
+    return jobs.Enqueue(tx, JobSendEmail, msg, jobs.MaxAttempts(8))
+}
+
+// PreviewEmail renders a template without sending it. Without data the
+// template's sample data is used.
+func PreviewEmail(template, locale string, data map[string]interface{}) (notify.Message, error) {
+    if data == nil {
+        data = notify.Sample(template)
+    }
+    return notify.Render(template, locale, data)
+}
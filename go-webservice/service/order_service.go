@@ -0,0 +1,444 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "go-webservice/config"
+    "go-webservice/jobs"
+    "go-webservice/model"
+    "go-webservice/notify"
+    "go-webservice/payment"
+    "go-webservice/tenant"
+    "go-webservice/txn"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+var (
+    ErrInvalidPrice      = errors.New("price must be zero or more, with a three-letter currency code")
+    ErrNotForSale        = errors.New("course is not published")
+    ErrAlreadyEnrolled   = errors.New("already enrolled in this course")
+    ErrOrderNotFound     = errors.New("order not found")
+    ErrCouponNotFound    = errors.New("coupon not found")
+    ErrCouponInvalid     = errors.New("coupon is not valid for this order")
+    ErrCouponExists      = errors.New("coupon code already exists")
+    ErrInvalidCoupon     = errors.New("percent coupons take 1-100, fixed coupons a positive amount and a currency")
+    ErrPaymentMismatch   = errors.New("payment does not match the order")
+    ErrPaymentFailed     = errors.New("payment provider error")
+    ErrAnonymousCheckout = errors.New("checkout needs an identified user")
+    ErrPaymentsDisabled  = errors.New("no payment provider is configured")
+)
+
+// pending orders not paid within this time are expired and give back
+// their coupon use
+const orderExpiry = 24 * time.Hour
+
+const JobExpireOrders = "orders.expire"
+
+func init() {
+    jobs.Register(JobExpireOrders, func(ctx context.Context, _ struct{}) (interface{}, error) {
+        return ExpireOrders(ctx, time.Now().UTC().Add(-orderExpiry))
+    })
+    jobs.Schedule("expire-orders", "@hourly", JobExpireOrders, struct{}{})
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// SetCoursePrice sets the price in minor units of currency; 0 makes the
+// course free. Existing orders keep the price they were placed at.
+func SetCoursePrice(ctx context.Context, id string, amount int64, currency string) (model.Course, error) {
+    currency = strings.ToUpper(currency)
+    if amount < 0 || (amount > 0 && !currencyCode.MatchString(currency)) {
+        return model.Course{}, ErrInvalidPrice
+    }
+    if amount == 0 {
+        currency = ""
+    }
+    var course model.Course
+    var event *model.OutboxEvent
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findCourse(tx, id, &course); err != nil {
+            return err
+        }
+        course.Price = amount
+        course.Currency = currency
+        if err := tx.Model(&course).Select("price", "currency").Updates(&course).Error; err != nil {
+            return err
+        }
+        var err error
+        event, err = recordEvent(tx, model.EventCourseUpdated, course.ID, course)
+        return err
+    })
+    if err == nil {
+        publish(ctx, event)
+        courseChanged(ctx, course)
+    }
+    return course, err
+}
+
+// CreateCoupon stores a coupon; codes are case-insensitive and unique per
+// tenant.
+func CreateCoupon(ctx context.Context, input model.Coupon) (model.Coupon, error) {
+    coupon := model.Coupon{
+        Code:      strings.ToUpper(strings.TrimSpace(input.Code)),
+        Kind:      input.Kind,
+        Value:     input.Value,
+        Currency:  strings.ToUpper(input.Currency),
+        ExpiresAt: input.ExpiresAt,
+        MaxUses:   input.MaxUses,
+        Active:    true,
+    }
+    switch {
+    case coupon.Kind == model.CouponPercent && coupon.Value >= 1 && coupon.Value <= 100:
+        coupon.Currency = ""
+    case coupon.Kind == model.CouponFixed && coupon.Value > 0 && currencyCode.MatchString(coupon.Currency):
+    default:
+        return coupon, ErrInvalidCoupon
+    }
+    var count int64
+    if err := db(ctx).Model(&model.Coupon{}).Where("code = ?", coupon.Code).Count(&count).Error; err != nil {
+        return coupon, err
+    }
+    if count > 0 {
+        return coupon, ErrCouponExists
+    }
+    err := db(ctx).Create(&coupon).Error
+    return coupon, err
+}
+
+func GetCoupons(ctx context.Context) ([]model.Coupon, error) {
+    coupons := []model.Coupon{}
+    err := db(ctx).Order("id").Find(&coupons).Error
+    return coupons, err
+}
+
+// DeactivateCoupon stops a coupon being used for new orders.
+func DeactivateCoupon(ctx context.Context, id string) error {
+    res := db(ctx).Model(&model.Coupon{}).Where("id = ?", id).Update("active", false)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrCouponNotFound
+    }
+    return nil
+}
+
+// Checkout places an order for a course at its current price, less any
+// coupon. A free order enrolls the user at once; otherwise a payment is
+// started with the provider and the user is enrolled when it reports the
+// payment succeeded. The intent is empty for free orders.
+//
+// Checkout commits on its own, outside any unit of work in ctx: the order
+// must exist before the provider can report on it, and a failed payment is
+// recorded even though the caller sees an error.
+func Checkout(ctx context.Context, userID, courseID, couponCode, email, locale string) (model.Order, payment.Intent, error) {
+    if userID == "" {
+        return model.Order{}, payment.Intent{}, ErrAnonymousCheckout
+    }
+    if email == "" && notify.ValidateAddresses([]string{userID}) == nil {
+        email = userID
+    }
+    ctx = txn.Without(ctx)
+    order := model.Order{
+        ID:       uuid.NewString(),
+        UserID:   userID,
+        CourseID: courseID,
+        Email:    email,
+        Locale:   locale,
+        Status:   model.OrderPending,
+    }
+    var course model.Course
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findCourse(tx, courseID, &course); err != nil {
+            return err
+        }
+        if !course.Published {
+            return ErrNotForSale
+        }
+        if enrolled, err := isEnrolled(tx, userID, courseID); err != nil || enrolled {
+            if err == nil {
+                err = ErrAlreadyEnrolled
+            }
+            return err
+        }
+        order.Currency = course.Currency
+        order.ListPrice = course.Price
+        if couponCode != "" {
+            if err := applyCoupon(tx, &order, couponCode); err != nil {
+                return err
+            }
+        }
+        order.Total = order.ListPrice - order.Discount
+        if order.Total == 0 {
+            return completeOrder(tx, &order)
+        }
+        if config.Payments == nil {
+            return ErrPaymentsDisabled
+        }
+        return tx.Create(&order).Error
+    })
+    if err != nil || order.Status == model.OrderPaid {
+        return order, payment.Intent{}, err
+    }
+
+    // the provider is called outside the transaction so a slow provider
+    // does not hold locks
+    intent, err := config.Payments.CreatePayment(ctx, payment.Payment{
+        Reference:   order.ID,
+        Amount:      order.Total,
+        Currency:    order.Currency,
+        Description: course.Title,
+    })
+    if err != nil {
+        failErr := db(ctx).Transaction(func(tx *gorm.DB) error {
+            return failOrder(tx, &order, err.Error())
+        })
+        return order, intent, errors.Join(fmt.Errorf("%w: %v", ErrPaymentFailed, err), failErr)
+    }
+    order.PaymentID = intent.ID
+    err = db(ctx).Model(&order).Update("payment_id", intent.ID).Error
+    return order, intent, err
+}
+
+// applyCoupon takes one use of the coupon and sets the order's discount.
+// The use is taken with a conditional update so concurrent orders cannot
+// exceed MaxUses.
+func applyCoupon(tx *gorm.DB, order *model.Order, code string) error {
+    var coupon model.Coupon
+    err := tx.Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).Limit(1).Find(&coupon).Error
+    if err != nil {
+        return err
+    }
+    now := time.Now()
+    switch {
+    case coupon.ID == 0:
+        return ErrCouponNotFound
+    case !coupon.Active, coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt):
+        return ErrCouponInvalid
+    case coupon.Kind == model.CouponFixed && coupon.Currency != order.Currency:
+        return ErrCouponInvalid
+    }
+    res := tx.Model(&model.Coupon{}).Where("id = ? AND (max_uses = 0 OR uses < max_uses)", coupon.ID).
+        UpdateColumn("uses", gorm.Expr("uses + 1"))
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrCouponInvalid
+    }
+    order.CouponID = &coupon.ID
+    order.CouponCode = coupon.Code
+    order.Discount = discount(coupon, order.ListPrice)
+    return nil
+}
+
+// discount rounds percentages to the nearest minor unit and never takes
+// more than the price.
+func discount(coupon model.Coupon, price int64) int64 {
+    var d int64
+    switch coupon.Kind {
+    case model.CouponPercent:
+        d = (price*coupon.Value + 50) / 100
+    case model.CouponFixed:
+        d = coupon.Value
+    }
+    if d > price {
+        d = price
+    }
+    return d
+}
+
+// HandlePaymentNotification verifies a notification from the payment
+// provider and settles the order it is about. Notifications are not tied
+// to a request tenant, so the order is found across tenants and then
+// updated in its own. Repeated notifications are harmless.
+func HandlePaymentNotification(ctx context.Context, header http.Header, body []byte) (model.Order, error) {
+    if config.Payments == nil {
+        return model.Order{}, ErrPaymentsDisabled
+    }
+    result, err := config.Payments.ParseNotification(header, body)
+    if err != nil {
+        return model.Order{}, err
+    }
+    var order model.Order
+    err = db(tenant.System(ctx)).Where("id = ? AND payment_id = ?", result.Reference, result.PaymentID).Limit(1).Find(&order).Error
+    if err != nil {
+        return order, err
+    }
+    if order.ID == "" {
+        return order, ErrOrderNotFound
+    }
+    ctx = tenant.WithTenant(ctx, order.TenantID)
+    err = db(ctx).Transaction(func(tx *gorm.DB) error {
+        // re-read inside the transaction: a concurrent notification may
+        // have settled the order already
+        if err := tx.First(&order, "id = ?", order.ID).Error; err != nil {
+            return err
+        }
+        if order.Status != model.OrderPending {
+            return nil
+        }
+        switch {
+        case result.Status == payment.StatusFailed:
+            return failOrder(tx, &order, result.Reason)
+        case result.Status != payment.StatusSucceeded:
+            return nil
+        case result.Amount != order.Total || result.Currency != order.Currency:
+            return ErrPaymentMismatch
+        }
+        return completeOrder(tx, &order)
+    })
+    return order, err
+}
+
+// completeOrder marks the order paid, enrolls the user and queues the
+// enrollment confirmation in the same transaction. Orders are created here
+// when they are free and so never pending.
+func completeOrder(tx *gorm.DB, order *model.Order) error {
+    now := time.Now().UTC()
+    order.Status = model.OrderPaid
+    order.PaidAt = &now
+    if order.CreatedAt.IsZero() {
+        if err := tx.Create(order).Error; err != nil {
+            return err
+        }
+    } else if err := tx.Model(order).Select("status", "paid_at").Updates(order).Error; err != nil {
+        return err
+    }
+    enrolled, err := isEnrolled(tx, order.UserID, order.CourseID)
+    if err != nil || enrolled {
+        // paid twice, e.g. two checkouts completed in parallel; refunds
+        // are handled with the provider
+        return err
+    }
+    if err := tx.Create(&model.Enrollment{UserID: order.UserID, CourseID: order.CourseID, OrderID: order.ID}).Error; err != nil {
+        return err
+    }
+    return confirmEnrollment(tx, order)
+}
+
+// confirmEnrollment emails the order's address, if it has one, a link to
+// the course.
+func confirmEnrollment(tx *gorm.DB, order *model.Order) error {
+    if order.Email == "" {
+        return nil
+    }
+    var course model.Course
+    if err := findCourse(tx, order.CourseID, &course); err != nil {
+        return err
+    }
+    name, _, _ := strings.Cut(order.Email, "@")
+    _, err := SendEmail(tx.Statement.Context, tx, []string{order.Email}, "enrollment_confirmation", order.Locale,
+        map[string]interface{}{
+            "Name":        name,
+            "CourseTitle": course.Title,
+            "CourseURL":   config.SiteURL + "/courses/" + url.PathEscape(course.ID),
+        })
+    return err
+}
+
+// failOrder marks the order failed and gives back its coupon use.
+func failOrder(tx *gorm.DB, order *model.Order, reason string) error {
+    order.Status = model.OrderFailed
+    order.FailReason = reason
+    if err := tx.Model(order).Select("status", "fail_reason").Updates(order).Error; err != nil {
+        return err
+    }
+    return releaseCoupon(tx, order)
+}
+
+func releaseCoupon(tx *gorm.DB, order *model.Order) error {
+    if order.CouponID == nil {
+        return nil
+    }
+    return tx.Model(&model.Coupon{}).Where("id = ? AND uses > 0", *order.CouponID).
+        UpdateColumn("uses", gorm.Expr("uses - 1")).Error
+}
+
+// ExpireOrders fails the orders, in every tenant, still pending since
+// before, giving back their coupon uses. A payment the provider reports
+// after that is not settled and is refunded with the provider.
+func ExpireOrders(ctx context.Context, before time.Time) (int, error) {
+    var stale []model.Order
+    err := db(tenant.System(ctx)).Where("status = ? AND created_at < ?", model.OrderPending, before).
+        Find(&stale).Error
+    if err != nil {
+        return 0, err
+    }
+    expired := 0
+    for _, order := range stale {
+        order := order
+        err := db(tenant.WithTenant(ctx, order.TenantID)).Transaction(func(tx *gorm.DB) error {
+            // a notification may have settled the order since it was listed
+            res := tx.Model(&order).Where("status = ?", model.OrderPending).
+                Select("status", "fail_reason").
+                Updates(model.Order{Status: model.OrderFailed, FailReason: "expired"})
+            if res.Error != nil || res.RowsAffected == 0 {
+                return res.Error
+            }
+            expired++
+            return releaseCoupon(tx, &order)
+        })
+        if err != nil {
+            return expired, err
+        }
+    }
+    return expired, nil
+}
+
+func isEnrolled(tx *gorm.DB, userID, courseID string) (bool, error) {
+    var count int64
+    err := tx.Model(&model.Enrollment{}).Where("user_id = ? AND course_id = ?", userID, courseID).Count(&count).Error
+    return count > 0, err
+}
+
+// GetOrders lists a user's orders, newest first.
+func GetOrders(ctx context.Context, userID string) ([]model.Order, error) {
+    orders := []model.Order{}
+    err := readDB(ctx).Where("user_id = ?", userID).Order("created_at desc").Find(&orders).Error
+    return orders, err
+}
+
+// GetOrder returns an order if it belongs to userID, or to anyone when
+// admin is set.
+func GetOrder(ctx context.Context, id, userID string, admin bool) (model.Order, error) {
+    var order model.Order
+    q := readDB(ctx).Where("id = ?", id)
+    if !admin {
+        q = q.Where("user_id = ?", userID)
+    }
+    err := q.First(&order).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return order, ErrOrderNotFound
+    }
+    return order, err
+}
+
+// GetOrderByPayment returns the order a payment is for if it belongs to
+// userID, or to anyone when admin is set.
+func GetOrderByPayment(ctx context.Context, paymentID, userID string, admin bool) (model.Order, error) {
+    var order model.Order
+    q := db(ctx).Where("payment_id = ?", paymentID)
+    if !admin {
+        q = q.Where("user_id = ?", userID)
+    }
+    err := q.First(&order).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return order, ErrOrderNotFound
+    }
+    return order, err
+}
+
+func GetEnrollments(ctx context.Context, userID string) ([]model.Enrollment, error) {
+    enrollments := []model.Enrollment{}
+    err := readDB(ctx).Where("user_id = ?", userID).Order("created_at").Find(&enrollments).Error
+    return enrollments, err
+}
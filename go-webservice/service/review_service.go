@@ -0,0 +1,247 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "go-webservice/model"
+    "strings"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+var (
+    ErrReviewNotFound = errors.New("review not found")
+    ErrReviewExists   = errors.New("you have already reviewed this course")
+    ErrInvalidRating  = errors.New("rating must be between 1 and 5")
+    ErrAnonymous      = errors.New("reviews need an identified user")
+    ErrNotReviewOwner = errors.New("only the author can change a review")
+)
+
+// ReviewInput is what a learner submits.
+type ReviewInput struct {
+    Rating int
+    Body   string
+}
+
+// GetReviews lists the visible reviews of a course, newest first.
+func GetReviews(ctx context.Context, courseID string, limit, offset int) ([]model.Review, error) {
+    if _, err := GetCourseByID(ctx, courseID); err != nil {
+        return nil, err
+    }
+    reviews := []model.Review{}
+    err := readDB(ctx).Where("course_id = ? AND hidden = ?", courseID, false).
+        Order("created_at desc").Order("id").Limit(limit).Offset(offset).Find(&reviews).Error
+    return reviews, err
+}
+
+// GetFlaggedReviews lists reviews that users have flagged and no admin has
+// dealt with yet, most flagged first.
+func GetFlaggedReviews(ctx context.Context) ([]model.Review, error) {
+    reviews := []model.Review{}
+    err := db(ctx).Where("flags > 0").Order("flags desc").Order("created_at").Find(&reviews).Error
+    return reviews, err
+}
+
+// CreateReview adds userID's review of a course. A user reviews a course
+// once; later changes go through UpdateReview.
+func CreateReview(ctx context.Context, courseID, userID string, input ReviewInput) (model.Review, error) {
+    if err := validateReview(userID, input); err != nil {
+        return model.Review{}, err
+    }
+    review := model.Review{
+        ID:       uuid.NewString(),
+        CourseID: courseID,
+        UserID:   userID,
+        Rating:   input.Rating,
+        Body:     strings.TrimSpace(input.Body),
+    }
+    var course model.Course
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findCourse(tx, courseID, &course); err != nil {
+            return err
+        }
+        var count int64
+        err := tx.Model(&model.Review{}).Where("course_id = ? AND user_id = ?", courseID, userID).Count(&count).Error
+        if err != nil {
+            return err
+        }
+        if count > 0 {
+            return ErrReviewExists
+        }
+        if err := tx.Create(&review).Error; err != nil {
+            return err
+        }
+        return adjustRating(tx, courseID, 1, review.Rating)
+    })
+    if err == nil {
+        invalidateCourse(ctx, course)
+    } else if reviewed(ctx, courseID, userID) {
+        // lost a race with the same user's other request
+        err = ErrReviewExists
+    }
+    return review, err
+}
+
+// UpdateReview changes the rating and text of the caller's own review.
+func UpdateReview(ctx context.Context, courseID, reviewID, userID string, input ReviewInput) (model.Review, error) {
+    if err := validateReview(userID, input); err != nil {
+        return model.Review{}, err
+    }
+    var review model.Review
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findReview(tx, courseID, reviewID, &review); err != nil {
+            return err
+        }
+        if review.UserID != userID {
+            return ErrNotReviewOwner
+        }
+        old := review.Rating
+        review.Rating = input.Rating
+        review.Body = strings.TrimSpace(input.Body)
+        if err := tx.Model(&review).Select("rating", "body").Updates(&review).Error; err != nil {
+            return err
+        }
+        if review.Hidden {
+            return nil
+        }
+        return adjustRating(tx, courseID, 0, review.Rating-old)
+    })
+    if err == nil {
+        invalidateCourse(ctx, model.Course{ID: courseID, TenantID: review.TenantID})
+    }
+    return review, err
+}
+
+// DeleteReview removes a review. Authors can delete their own; admins can
+// delete any.
+func DeleteReview(ctx context.Context, courseID, reviewID, userID string, admin bool) error {
+    var review model.Review
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findReview(tx, courseID, reviewID, &review); err != nil {
+            return err
+        }
+        if !admin && review.UserID != userID {
+            return ErrNotReviewOwner
+        }
+        if err := tx.Delete(&review).Error; err != nil {
+            return err
+        }
+        if review.Hidden {
+            return nil
+        }
+        return adjustRating(tx, courseID, -1, -review.Rating)
+    })
+    if err == nil {
+        invalidateCourse(ctx, model.Course{ID: courseID, TenantID: review.TenantID})
+    }
+    return err
+}
+
+// FlagReview records a user's report of a review for moderation. Each
+// user counts once; flagging the same review again changes nothing.
+func FlagReview(ctx context.Context, courseID, reviewID, userID string) (model.Review, error) {
+    if userID == "" {
+        return model.Review{}, ErrAnonymous
+    }
+    var review model.Review
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := findReview(tx, courseID, reviewID, &review); err != nil {
+            return err
+        }
+        var count int64
+        err := tx.Model(&model.ReviewFlag{}).Where("review_id = ? AND user_id = ?", reviewID, userID).Count(&count).Error
+        if err != nil || count > 0 {
+            return err
+        }
+        if err := tx.Create(&model.ReviewFlag{ReviewID: reviewID, UserID: userID}).Error; err != nil {
+            return err
+        }
+        review.Flags++
+        return tx.Model(&review).UpdateColumn("flags", gorm.Expr("flags + 1")).Error
+    })
+    if err != nil && flagged(ctx, reviewID, userID) {
+        // lost a race with the same user's other request, which counted
+        err = nil
+    }
+    return review, err
+}
+
+// ModerateReview hides or restores a review and clears its flags. Hiding
+// takes the review out of the course's rating; restoring puts it back.
+func ModerateReview(ctx context.Context, reviewID string, hidden bool) (model.Review, error) {
+    var review model.Review
+    err := db(ctx).Transaction(func(tx *gorm.DB) error {
+        err := tx.First(&review, "id = ?", reviewID).Error
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return ErrReviewNotFound
+        }
+        if err != nil {
+            return err
+        }
+        changed := review.Hidden != hidden
+        review.Hidden = hidden
+        review.Flags = 0
+        if err := tx.Model(&review).Select("hidden", "flags").Updates(&review).Error; err != nil {
+            return err
+        }
+        if err := tx.Where("review_id = ?", review.ID).Delete(&model.ReviewFlag{}).Error; err != nil {
+            return err
+        }
+        switch {
+        case !changed:
+            return nil
+        case hidden:
+            return adjustRating(tx, review.CourseID, -1, -review.Rating)
+        default:
+            return adjustRating(tx, review.CourseID, 1, review.Rating)
+        }
+    })
+    if err == nil {
+        invalidateCourse(ctx, model.Course{ID: review.CourseID, TenantID: review.TenantID})
+    }
+    return review, err
+}
+
+// adjustRating applies a change in review count and rating sum to the
+// course's aggregates in one statement, so concurrent reviews cannot lose
+// updates. UpdateColumns leaves updated_at alone: the course itself did
+// not change.
+func adjustRating(tx *gorm.DB, courseID string, count, sum int) error {
+    return tx.Model(&model.Course{}).Where("id = ?", courseID).UpdateColumns(map[string]interface{}{
+        "rating_count": gorm.Expr("rating_count + ?", count),
+        "rating_sum":   gorm.Expr("rating_sum + ?", sum),
+        "rating_average": gorm.Expr("CASE WHEN rating_count + ? = 0 THEN 0 ELSE (rating_sum + ?) * 1.0 / (rating_count + ?) END",
+            count, sum, count),
+    }).Error
+}
+
+func reviewed(ctx context.Context, courseID, userID string) bool {
+    var count int64
+    db(ctx).Model(&model.Review{}).Where("course_id = ? AND user_id = ?", courseID, userID).Count(&count)
+    return count > 0
+}
+
+func flagged(ctx context.Context, reviewID, userID string) bool {
+    var count int64
+    db(ctx).Model(&model.ReviewFlag{}).Where("review_id = ? AND user_id = ?", reviewID, userID).Count(&count)
+    return count > 0
+}
+
+func validateReview(userID string, input ReviewInput) error {
+    if userID == "" {
+        return ErrAnonymous
+    }
+    if input.Rating < 1 || input.Rating > 5 {
+        return ErrInvalidRating
+    }
+    return nil
+}
+
+func findReview(tx *gorm.DB, courseID, reviewID string, review *model.Review) error {
+    err := tx.First(review, "id = ? AND course_id = ?", reviewID, courseID).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return ErrReviewNotFound
+    }
+    return err
+}
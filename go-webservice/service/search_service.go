@@ -0,0 +1,24 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "go-webservice/config"
+    "go-webservice/search"
+)
+
+var ErrEmptyQuery = errors.New("query must not be empty")
+
+// SearchCourses returns the courses best matching q, most relevant first.
+func SearchCourses(ctx context.Context, q string, limit int) (search.Results, error) {
+    if q == "" {
+        return search.Results{}, ErrEmptyQuery
+    }
+    return config.Search.Search(db(ctx), q, limit)
+}
+
+// ReindexCourses rebuilds the search index for the current tenant (on
+// Postgres, for every tenant).
+func ReindexCourses(ctx context.Context) error {
+    return config.Search.Rebuild(db(ctx))
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "go-webservice/model"
+    "go-webservice/tenant"
+
+    "gorm.io/gorm"
+)
+
+var ErrTenantNotFound = errors.New("tenant not found")
+
+func GetTenant(ctx context.Context, id string) (model.Tenant, error) {
+    var t model.Tenant
+    err := db(ctx).First(&t, "id = ?", id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return model.Tenant{}, ErrTenantNotFound
+    }
+    return t, err
+}
+
+func CreateTenant(ctx context.Context, id, name string) (model.Tenant, error) {
+    if !tenant.ValidSlug(id) {
+        return model.Tenant{}, tenant.ErrInvalidTenant
+    }
+    t := model.Tenant{ID: id, Name: name}
+    err := db(ctx).Create(&t).Error
+    return t, err
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "go-webservice/model"
+    "go-webservice/webhook"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+var (
+    ErrWebhookNotFound  = errors.New("webhook not found")
+    ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// RegisterWebhook stores a new webhook. If no secret is supplied a random
+// one is generated; it is returned here and never exposed again. The URL
+// must pass webhook.ValidateURL.
+func RegisterWebhook(ctx context.Context, url, events, secret string) (model.Webhook, error) {
+    if err := webhook.ValidateURL(ctx, url); err != nil {
+        return model.Webhook{}, err
+    }
+    if secret == "" {
+        buf := make([]byte, 32)
+        if _, err := rand.Read(buf); err != nil {
+            return model.Webhook{}, err
+        }
+        secret = hex.EncodeToString(buf)
+    }
+    hook := model.Webhook{URL: url, Events: events, Secret: secret, Active: true}
+    err := db(ctx).Create(&hook).Error
+    return hook, err
+}
+
+func GetAllWebhooks(ctx context.Context) ([]model.Webhook, error) {
+    var hooks []model.Webhook
+    err := db(ctx).Order("id").Find(&hooks).Error
+    return hooks, err
+}
+
+func DeleteWebhook(ctx context.Context, id string) error {
+    res := db(ctx).Delete(&model.Webhook{}, "id = ?", id)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrWebhookNotFound
+    }
+    return nil
+}
+
+// GetDeadLetters lists deliveries that exhausted their retries.
+func GetDeadLetters(ctx context.Context) ([]model.WebhookDelivery, error) {
+    var deliveries []model.WebhookDelivery
+    err := db(ctx).Where("status = ?", model.DeliveryDead).Order("updated_at desc").Find(&deliveries).Error
+    return deliveries, err
+}
+
+// RetryDelivery moves a dead delivery back to the pending queue with a
+// fresh attempt budget.
+func RetryDelivery(ctx context.Context, id string) (model.WebhookDelivery, error) {
+    var delivery model.WebhookDelivery
+    err := db(ctx).First(&delivery, "id = ?", id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return delivery, ErrDeliveryNotFound
+    }
+    if err != nil {
+        return delivery, err
+    }
+    delivery.Status = model.DeliveryPending
+    delivery.Attempts = 0
+    delivery.NextAttemptAt = time.Now().UTC()
+    err = db(ctx).Model(&delivery).Select("status", "attempts", "next_attempt_at").Updates(&delivery).Error
+    return delivery, err
+}
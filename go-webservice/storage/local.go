@@ -0,0 +1,79 @@
+package storage
+
+import (
+    "context"
+    "errors"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// LocalStore keeps blobs as files under Root.
+type LocalStore struct {
+    Root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+    if err := os.MkdirAll(root, 0o755); err != nil {
+        return nil, err
+    }
+    return &LocalStore{Root: root}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+    clean := filepath.Clean("/" + key)
+    if clean == "/" || strings.Contains(key, "..") {
+        return "", errors.New("invalid blob key")
+    }
+    return filepath.Join(s.Root, filepath.FromSlash(clean)), nil
+}
+
+// Put writes to a temporary file first so readers never see a partial blob.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    p, err := s.path(key)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := io.Copy(tmp, r); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+    p, err := s.path(key)
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Open(p)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, ErrNotFound
+    }
+    return f, err
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+    p, err := s.path(key)
+    if err != nil {
+        return err
+    }
+    err = os.Remove(p)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    return err
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+)
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Store talks to any S3-compatible service (AWS, MinIO, a local fake)
+// with path-style URLs and Signature Version 4, using only net/http.
+type S3Store struct {
+    Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+    Bucket    string
+    Region    string
+    AccessKey string
+    SecretKey string
+    Client    *http.Client
+    Now       func() time.Time
+}
+
+func NewS3Store(endpoint, bucket, region, accessKey, secretKey string) *S3Store {
+    if region == "" {
+        region = "us-east-1"
+    }
+    return &S3Store{
+        Endpoint:  strings.TrimRight(endpoint, "/"),
+        Bucket:    bucket,
+        Region:    region,
+        AccessKey: accessKey,
+        SecretKey: secretKey,
+        Client:    &http.Client{Timeout: 5 * time.Minute},
+        Now:       time.Now,
+    }
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    req, err := s.newRequest(ctx, http.MethodPut, key, r)
+    if err != nil {
+        return err
+    }
+    req.ContentLength = size
+    if contentType != "" {
+        req.Header.Set("Content-Type", contentType)
+    }
+    resp, err := s.do(req)
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+    req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := s.do(req)
+    if err != nil {
+        return nil, err
+    }
+    return resp.Body, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+    req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+    if err != nil {
+        return err
+    }
+    resp, err := s.do(req)
+    if err == ErrNotFound {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    return nil
+}
+
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+    path := "/" + escapePath(s.Bucket) + "/" + escapePath(key)
+    req, err := http.NewRequestWithContext(ctx, method, s.Endpoint+path, body)
+    if err != nil {
+        return nil, err
+    }
+    req.URL.RawPath = path
+    s.sign(req, unsignedPayload)
+    return req, nil
+}
+
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+    resp, err := s.Client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    if resp.StatusCode == http.StatusNotFound {
+        resp.Body.Close()
+        return nil, ErrNotFound
+    }
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+        resp.Body.Close()
+        return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)
+    }
+    return resp, nil
+}
+
+// sign adds a SigV4 Authorization header covering host and the x-amz-*
+// headers.
+func (s *S3Store) sign(req *http.Request, payloadHash string) {
+    now := s.Now().UTC()
+    amzDate := now.Format("20060102T150405Z")
+    day := now.Format("20060102")
+    req.Header.Set("X-Amz-Date", amzDate)
+    req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+    headers := map[string]string{
+        "host":                 req.URL.Host,
+        "x-amz-content-sha256": payloadHash,
+        "x-amz-date":           amzDate,
+    }
+    names := make([]string, 0, len(headers))
+    for name := range headers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var canonicalHeaders strings.Builder
+    for _, name := range names {
+        canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+    }
+    signedHeaders := strings.Join(names, ";")
+
+    canonicalRequest := strings.Join([]string{
+        req.Method,
+        req.URL.EscapedPath(),
+        req.URL.Query().Encode(),
+        canonicalHeaders.String(),
+        signedHeaders,
+        payloadHash,
+    }, "\n")
+    scope := day + "/" + s.Region + "/s3/aws4_request"
+    stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+    key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+    key = hmacSHA256(key, s.Region)
+    key = hmacSHA256(key, "s3")
+    key = hmacSHA256(key, "aws4_request")
+    signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+    req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+        s.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+    sum := sha256.Sum256([]byte(data))
+    return hex.EncodeToString(sum[:])
+}
+
+// escapePath percent-encodes everything except unreserved characters and
+// slashes, as SigV4 requires for S3 object keys.
+func escapePath(p string) string {
+    var b strings.Builder
+    for i := 0; i < len(p); i++ {
+        c := p[i]
+        if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+            'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+            b.WriteByte(c)
+            continue
+        }
+        fmt.Fprintf(&b, "%%%02X", c)
+    }
+    return b.String()
+}
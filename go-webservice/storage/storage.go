@@ -0,0 +1,17 @@
+package storage
+
+import (
+    "context"
+    "errors"
+    "io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files. Keys are slash separated paths chosen by
+// the caller; implementations must not interpret them beyond that.
+type BlobStore interface {
+    Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+    Get(ctx context.Context, key string) (io.ReadCloser, error)
+    Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io"
+    "strings"
+    "testing"
+)
+
+// testBlobStore checks the behaviour every BlobStore must have.
+func testBlobStore(t *testing.T, store BlobStore) {
+    ctx := context.Background()
+    key := "courses/1/media/intro video.mp4"
+    data := []byte("not really a video")
+
+    if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "video/mp4"); err != nil {
+        t.Fatalf("put: %v", err)
+    }
+    rc, err := store.Get(ctx, key)
+    if err != nil {
+        t.Fatalf("get: %v", err)
+    }
+    got, err := io.ReadAll(rc)
+    rc.Close()
+    if err != nil || !bytes.Equal(got, data) {
+        t.Fatalf("get returned %q, %v; want %q", got, err, data)
+    }
+
+    replaced := "shorter"
+    if err := store.Put(ctx, key, strings.NewReader(replaced), int64(len(replaced)), "video/mp4"); err != nil {
+        t.Fatalf("overwrite: %v", err)
+    }
+    rc, err = store.Get(ctx, key)
+    if err != nil {
+        t.Fatalf("get after overwrite: %v", err)
+    }
+    got, _ = io.ReadAll(rc)
+    rc.Close()
+    if string(got) != replaced {
+        t.Fatalf("get after overwrite returned %q, want %q", got, replaced)
+    }
+
+    if err := store.Delete(ctx, key); err != nil {
+        t.Fatalf("delete: %v", err)
+    }
+    if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+        t.Fatalf("get after delete: %v, want ErrNotFound", err)
+    }
+    if err := store.Delete(ctx, key); err != nil {
+        t.Fatalf("deleting a missing blob: %v", err)
+    }
+}
+
+func TestLocalStore(t *testing.T) {
+    store, err := NewLocalStore(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    testBlobStore(t, store)
+
+    for _, key := range []string{"", "/", "../outside", "a/../../b"} {
+        if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, ""); err == nil {
+            t.Errorf("put %q: want an invalid key error", key)
+        }
+    }
+}
@@ -0,0 +1,101 @@
+package tenant
+
+import (
+    "errors"
+    "reflect"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+const field = "TenantID"
+
+var ErrUpsert = errors.New("upserts are not allowed on tenant scoped models")
+
+// Plugin adds a tenant_id condition to every query, update and delete on a
+// model that has a TenantID field, and stamps TenantID on every insert. The
+// tenant comes from the statement context (see WithTenant); a statement on
+// a scoped model without one fails instead of touching other tenants' rows.
+type Plugin struct{}
+
+func (Plugin) Name() string {
+    return "tenant"
+}
+
+func (Plugin) Initialize(db *gorm.DB) error {
+    cb := db.Callback()
+    if err := cb.Create().Before("gorm:create").Register("tenant:create", stampTenant); err != nil {
+        return err
+    }
+    if err := cb.Query().Before("gorm:query").Register("tenant:query", scopeTenant); err != nil {
+        return err
+    }
+    if err := cb.Update().Before("gorm:update").Register("tenant:update", scopeTenant); err != nil {
+        return err
+    }
+    if err := cb.Delete().Before("gorm:delete").Register("tenant:delete", scopeTenant); err != nil {
+        return err
+    }
+    return cb.Row().Before("gorm:row").Register("tenant:row", scopeTenant)
+}
+
+func scoped(db *gorm.DB) bool {
+    return db.Statement.Schema != nil && db.Statement.Schema.LookUpField(field) != nil
+}
+
+func scopeTenant(db *gorm.DB) {
+    if db.Error != nil || !scoped(db) || IsSystem(db.Statement.Context) {
+        return
+    }
+    id, ok := FromContext(db.Statement.Context)
+    if !ok {
+        db.AddError(ErrNoTenant)
+        return
+    }
+    column := db.Statement.Schema.LookUpField(field).DBName
+    db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+        clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: column}, Value: id},
+    }})
+}
+
+func stampTenant(db *gorm.DB) {
+    if db.Error != nil || !scoped(db) {
+        return
+    }
+    if _, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+        // ON CONFLICT DO UPDATE could overwrite a row owned by another tenant
+        db.AddError(ErrUpsert)
+        return
+    }
+    if IsSystem(db.Statement.Context) {
+        if !hasTenant(db) {
+            db.AddError(ErrNoTenant)
+        }
+        return
+    }
+    id, ok := FromContext(db.Statement.Context)
+    if !ok {
+        db.AddError(ErrNoTenant)
+        return
+    }
+    db.Statement.SetColumn(field, id, true)
+}
+
+// hasTenant reports whether every row being created already names its tenant.
+func hasTenant(db *gorm.DB) bool {
+    f := db.Statement.Schema.LookUpField(field)
+    rv := db.Statement.ReflectValue
+    switch rv.Kind() {
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < rv.Len(); i++ {
+            if _, zero := f.ValueOf(db.Statement.Context, reflect.Indirect(rv.Index(i))); zero {
+                return false
+            }
+        }
+        return true
+    case reflect.Struct:
+        _, zero := f.ValueOf(db.Statement.Context, rv)
+        return !zero
+    }
+    return false
+}
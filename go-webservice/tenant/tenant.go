@@ -0,0 +1,49 @@
+package tenant
+
+import (
+    "context"
+    "errors"
+    "regexp"
+)
+
+// Default is the tenant used for requests that do not name one, and owns
+// every row created before multi-tenancy was introduced.
+const Default = "default"
+
+var (
+    ErrNoTenant      = errors.New("no tenant in context")
+    ErrInvalidTenant = errors.New("invalid tenant")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type tenantKey struct{}
+type systemKey struct{}
+
+// WithTenant scopes every database call made with ctx to the given tenant.
+func WithTenant(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, tenantKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+    id, ok := ctx.Value(tenantKey{}).(string)
+    return id, ok && id != ""
+}
+
+// System marks ctx as belonging to a background process that works across
+// tenants, such as the webhook dispatcher. Queries made with it are not
+// scoped, and rows it creates must carry an explicit TenantID.
+func System(ctx context.Context) context.Context {
+    return context.WithValue(ctx, systemKey{}, true)
+}
+
+func IsSystem(ctx context.Context) bool {
+    system, _ := ctx.Value(systemKey{}).(bool)
+    return system
+}
+
+// ValidSlug reports whether id is usable as a tenant identifier, which
+// also makes it safe to take from a subdomain.
+func ValidSlug(id string) bool {
+    return slugPattern.MatchString(id)
+}
@@ -0,0 +1,162 @@
+package tenant_test
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+    "go-webservice/apitest"
+    "go-webservice/config"
+    "go-webservice/middleware"
+    "go-webservice/model"
+    "go-webservice/search"
+    "go-webservice/tenant"
+)
+
+// setup serves the API from a fresh database with tenants acme and globex
+// next to the default one.
+func setup(t *testing.T) http.Handler {
+    t.Helper()
+    s := apitest.New(t)
+    s.Tenant("acme")
+    s.Tenant("globex")
+    return s.Handler
+}
+
+func token(t *testing.T, role, tenantID string) string {
+    t.Helper()
+    signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+        "sub":    role + "@" + tenantID,
+        "role":   role,
+        "tenant": tenantID,
+        "exp":    time.Now().Add(time.Hour).Unix(),
+    }).SignedString([]byte(apitest.Secret))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return "Bearer " + signed
+}
+
+func do(r http.Handler, method, path, auth, body string, opts ...func(*http.Request)) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, path, strings.NewReader(body))
+    req.Header.Set("Authorization", auth)
+    if body != "" {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    for _, opt := range opts {
+        opt(req)
+    }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func wantStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+    t.Helper()
+    if w.Code != status {
+        t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body)
+    }
+}
+
+func TestCoursesAreIsolatedByTenant(t *testing.T) {
+    r := setup(t)
+    acme := token(t, "admin", "acme")
+    globex := token(t, "admin", "globex")
+
+    w := do(r, "POST", "/api/courses", acme, `{"title": "Acme onboarding"}`)
+    wantStatus(t, w, http.StatusCreated)
+    var theirs model.Course
+    json.Unmarshal(w.Body.Bytes(), &theirs)
+
+    var list []model.Course
+    w = do(r, "GET", "/api/courses", globex, "")
+    wantStatus(t, w, http.StatusOK)
+    json.Unmarshal(w.Body.Bytes(), &list)
+    if len(list) != 0 {
+        t.Fatalf("globex lists %v, want nothing", list)
+    }
+    wantStatus(t, do(r, "GET", "/api/courses/"+theirs.ID, globex, ""), http.StatusNotFound)
+    wantStatus(t, do(r, "PUT", "/api/courses/"+theirs.ID, globex, `{"title": "Taken"}`), http.StatusNotFound)
+    wantStatus(t, do(r, "DELETE", "/api/courses/"+theirs.ID, globex, ""), http.StatusNotFound)
+    wantStatus(t, do(r, "GET", "/api/courses/1", acme, ""), http.StatusNotFound)
+
+    list = nil
+    w = do(r, "GET", "/api/courses", acme, "")
+    json.Unmarshal(w.Body.Bytes(), &list)
+    if len(list) != 1 || list[0].ID != theirs.ID || list[0].Title != "Acme onboarding" {
+        t.Fatalf("acme lists %v, want only its own course", list)
+    }
+
+    var found search.Results
+    w = do(r, "GET", "/api/courses/search?q=onboarding", globex, "")
+    wantStatus(t, w, http.StatusOK)
+    json.Unmarshal(w.Body.Bytes(), &found)
+    if len(found.Hits) != 0 {
+        t.Fatalf("globex search found %+v", found.Hits)
+    }
+    w = do(r, "GET", "/api/courses/search?q=onboarding", acme, "")
+    json.Unmarshal(w.Body.Bytes(), &found)
+    if len(found.Hits) != 1 || found.Hits[0].Course.ID != theirs.ID {
+        t.Fatalf("acme search found %+v, want its course", found.Hits)
+    }
+
+    // the default tenant still only sees the seeded course
+    list = nil
+    w = do(r, "GET", "/api/courses", token(t, "user", tenant.Default), "")
+    json.Unmarshal(w.Body.Bytes(), &list)
+    if len(list) != 1 || list[0].ID != "1" {
+        t.Fatalf("default tenant lists %v", list)
+    }
+}
+
+func TestUnknownTenant(t *testing.T) {
+    r := setup(t)
+    wantStatus(t, do(r, "GET", "/api/courses", token(t, "user", "ghost"), ""), http.StatusNotFound)
+    wantStatus(t, do(r, "GET", "/api/courses", token(t, "user", "Not A Slug"), ""), http.StatusForbidden)
+}
+
+func TestTokenMustMatchSubdomain(t *testing.T) {
+    r := setup(t)
+    old := middleware.TenantBaseDomain
+    middleware.TenantBaseDomain = "example.com"
+    t.Cleanup(func() { middleware.TenantBaseDomain = old })
+
+    host := func(h string) func(*http.Request) {
+        return func(req *http.Request) { req.Host = h }
+    }
+    acme := token(t, "user", "acme")
+    wantStatus(t, do(r, "GET", "/api/courses", acme, "", host("acme.example.com")), http.StatusOK)
+    wantStatus(t, do(r, "GET", "/api/courses", acme, "", host("globex.example.com:8080")), http.StatusForbidden)
+    wantStatus(t, do(r, "GET", "/api/courses", acme, "", host("localhost")), http.StatusOK)
+}
+
+func TestQueriesNeedATenant(t *testing.T) {
+    setup(t)
+    acme := tenant.WithTenant(context.Background(), "acme")
+    if err := config.DB.WithContext(acme).Create(&model.Course{ID: "a", Title: "Acme"}).Error; err != nil {
+        t.Fatal(err)
+    }
+
+    var courses []model.Course
+    err := config.DB.WithContext(context.Background()).Find(&courses).Error
+    if !errors.Is(err, tenant.ErrNoTenant) {
+        t.Fatalf("query without a tenant: %v, want ErrNoTenant", err)
+    }
+    if err := config.DB.WithContext(context.Background()).Create(&model.Course{ID: "x", Title: "x"}).Error; !errors.Is(err, tenant.ErrNoTenant) {
+        t.Fatalf("insert without a tenant: %v, want ErrNoTenant", err)
+    }
+
+    var n int64
+    if err := config.DB.WithContext(tenant.System(context.Background())).Model(&model.Course{}).Count(&n).Error; err != nil {
+        t.Fatal(err)
+    }
+    if n != 2 {
+        t.Fatalf("system context counts %d courses, want both tenants' 2", n)
+    }
+}
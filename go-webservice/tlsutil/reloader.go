@@ -0,0 +1,151 @@
+// Package tlsutil serves TLS from certificate files that may be replaced
+// while the server runs, as cert-manager or certbot do on renewal.
+package tlsutil
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "log"
+    "os"
+    "sync"
+    "time"
+)
+
+// Reloader holds the current server certificate and, for mutual TLS, the
+// pool of CAs trusted to sign client certificates. Watch re-reads them
+// when any of the files changes; a broken update is logged and the last
+// good certificate stays in use.
+type Reloader struct {
+    CertFile, KeyFile string
+    // ClientCAFile enables client certificate verification when set.
+    ClientCAFile string
+
+    mu        sync.RWMutex
+    cert      *tls.Certificate
+    clientCAs *x509.CertPool
+    stamps    map[string]time.Time
+}
+
+// NewReloader loads the files once and fails if they are unusable.
+func NewReloader(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+    r := &Reloader{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile}
+    if err := r.Reload(); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+
+func (r *Reloader) Reload() error {
+    stamps, err := r.modTimes()
+    if err != nil {
+        return err
+    }
+    cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+    if err != nil {
+        return err
+    }
+    var pool *x509.CertPool
+    if r.ClientCAFile != "" {
+        pem, err := os.ReadFile(r.ClientCAFile)
+        if err != nil {
+            return err
+        }
+        pool = x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return errors.New("no certificates in " + r.ClientCAFile)
+        }
+    }
+    r.mu.Lock()
+    r.cert, r.clientCAs, r.stamps = &cert, pool, stamps
+    r.mu.Unlock()
+    return nil
+}
+
+// Watch polls the files every interval and reloads when one changes. It
+// returns when ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        stamps, err := r.modTimes()
+        if err != nil || !r.changed(stamps) {
+            continue
+        }
+        if err := r.Reload(); err != nil {
+            // remember the broken files so they are not retried until
+            // they change again
+            r.mu.Lock()
+            r.stamps = stamps
+            r.mu.Unlock()
+            log.Println("tls: keeping current certificate:", err)
+            continue
+        }
+        log.Println("tls: certificate reloaded")
+    }
+}
+
+// Certificate returns the certificate currently being served.
+func (r *Reloader) Certificate() *tls.Certificate {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.cert
+}
+
+// ServerConfig returns a TLS configuration that picks up reloaded files on
+// every handshake and offers HTTP/2. clientAuth only matters when a client
+// CA file is set.
+func (r *Reloader) ServerConfig(clientAuth tls.ClientAuthType) *tls.Config {
+    base := &tls.Config{
+        MinVersion: tls.VersionTLS12,
+        NextProtos: []string{"h2", "http/1.1"},
+    }
+    cfg := base.Clone()
+    cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+        return r.Certificate(), nil
+    }
+    cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+        r.mu.RLock()
+        defer r.mu.RUnlock()
+        c := base.Clone()
+        c.Certificates = []tls.Certificate{*r.cert}
+        if r.clientCAs != nil {
+            c.ClientCAs = r.clientCAs
+            c.ClientAuth = clientAuth
+        }
+        return c, nil
+    }
+    return cfg
+}
+
+func (r *Reloader) modTimes() (map[string]time.Time, error) {
+    stamps := make(map[string]time.Time)
+    for _, name := range []string{r.CertFile, r.KeyFile, r.ClientCAFile} {
+        if name == "" {
+            continue
+        }
+        info, err := os.Stat(name)
+        if err != nil {
+            return nil, err
+        }
+        stamps[name] = info.ModTime()
+    }
+    return stamps, nil
+}
+
+func (r *Reloader) changed(stamps map[string]time.Time) bool {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    for name, t := range stamps {
+        if !t.Equal(r.stamps[name]) {
+            return true
+        }
+    }
+    return false
+}
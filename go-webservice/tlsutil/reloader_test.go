@@ -0,0 +1,255 @@
+package tlsutil
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "math/big"
+    "net"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// authority is a throwaway CA that issues certificates for the tests.
+type authority struct {
+    cert *x509.Certificate
+    key  *ecdsa.PrivateKey
+    pem  []byte
+}
+
+var serial int64
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return key
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+    key := newKey(t)
+    serial++
+    tmpl := &x509.Certificate{
+        SerialNumber:          big.NewInt(serial),
+        Subject:               pkix.Name{CommonName: name},
+        NotBefore:             time.Now().Add(-time.Hour),
+        NotAfter:              time.Now().Add(time.Hour),
+        IsCA:                  true,
+        BasicConstraintsValid: true,
+        KeyUsage:              x509.KeyUsageCertSign,
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    cert, _ := x509.ParseCertificate(der)
+    return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (a *authority) pool() *x509.CertPool {
+    p := x509.NewCertPool()
+    p.AddCert(a.cert)
+    return p
+}
+
+// issue returns a PEM certificate and key for name, usable by servers for
+// localhost and by clients.
+func (a *authority) issue(t *testing.T, name string) (certPEM, keyPEM []byte) {
+    key := newKey(t)
+    serial++
+    tmpl := &x509.Certificate{
+        SerialNumber: big.NewInt(serial),
+        Subject:      pkix.Name{CommonName: name},
+        DNSNames:     []string{"localhost"},
+        NotBefore:    time.Now().Add(-time.Hour),
+        NotAfter:     time.Now().Add(time.Hour),
+        KeyUsage:     x509.KeyUsageDigitalSignature,
+        ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    keyDER, err := x509.MarshalECPrivateKey(key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+        pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func write(t *testing.T, path string, data []byte, mtime time.Time) {
+    if err := os.WriteFile(path, data, 0o600); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chtimes(path, mtime, mtime); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// serve accepts TLS connections with cfg, writing one byte to each after
+// the handshake.
+func serve(t *testing.T, cfg *tls.Config) string {
+    ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { ln.Close() })
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go func() {
+                defer conn.Close()
+                if conn.(*tls.Conn).Handshake() == nil {
+                    conn.Write([]byte{1})
+                }
+            }()
+        }
+    }()
+    return ln.Addr().String()
+}
+
+// connect completes a handshake and the first read, where TLS 1.3 clients
+// learn that the server rejected their certificate.
+func connect(addr string, cfg *tls.Config) (tls.ConnectionState, error) {
+    conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, cfg)
+    if err != nil {
+        return tls.ConnectionState{}, err
+    }
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(5 * time.Second))
+    if _, err := conn.Read(make([]byte, 1)); err != nil {
+        return tls.ConnectionState{}, err
+    }
+    return conn.ConnectionState(), nil
+}
+
+func TestReloaderServesRenewedCertificate(t *testing.T) {
+    ca := newAuthority(t, "test CA")
+    dir := t.TempDir()
+    certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+    start := time.Now().Add(-time.Minute)
+    cert, key := ca.issue(t, "first")
+    write(t, certFile, cert, start)
+    write(t, keyFile, key, start)
+
+    r, err := NewReloader(certFile, keyFile, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr := serve(t, r.ServerConfig(tls.NoClientCert))
+    client := &tls.Config{RootCAs: ca.pool(), ServerName: "localhost", NextProtos: []string{"h2", "http/1.1"}}
+
+    state, err := connect(addr, client)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if name := state.PeerCertificates[0].Subject.CommonName; name != "first" {
+        t.Fatalf("served %q", name)
+    }
+    if state.NegotiatedProtocol != "h2" {
+        t.Fatalf("negotiated %q, want h2", state.NegotiatedProtocol)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go r.Watch(ctx, 10*time.Millisecond)
+
+    // a half-written renewal is not picked up...
+    write(t, certFile, []byte("garbage"), start.Add(time.Second))
+    time.Sleep(50 * time.Millisecond)
+    if state, err := connect(addr, client); err != nil || state.PeerCertificates[0].Subject.CommonName != "first" {
+        t.Fatalf("after a broken renewal: %v", err)
+    }
+
+    // ...but the finished one is
+    cert, key = ca.issue(t, "second")
+    write(t, keyFile, key, start.Add(2*time.Second))
+    write(t, certFile, cert, start.Add(2*time.Second))
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        state, err := connect(addr, client)
+        if err == nil && state.PeerCertificates[0].Subject.CommonName == "second" {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("renewed certificate never served: %v", err)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestNewReloaderRejectsBrokenFiles(t *testing.T) {
+    dir := t.TempDir()
+    certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+    write(t, certFile, []byte("not a cert"), time.Now())
+    write(t, keyFile, []byte("not a key"), time.Now())
+    if _, err := NewReloader(certFile, keyFile, ""); err == nil {
+        t.Fatal("loaded a broken certificate")
+    }
+    if _, err := NewReloader(filepath.Join(dir, "missing.crt"), keyFile, ""); err == nil {
+        t.Fatal("loaded a missing certificate")
+    }
+}
+
+func TestMutualTLS(t *testing.T) {
+    serverCA, clientCA := newAuthority(t, "server CA"), newAuthority(t, "client CA")
+    dir := t.TempDir()
+    certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+    cert, key := serverCA.issue(t, "server")
+    write(t, certFile, cert, time.Now())
+    write(t, keyFile, key, time.Now())
+    write(t, caFile, clientCA.pem, time.Now())
+
+    r, err := NewReloader(certFile, keyFile, caFile)
+    if err != nil {
+        t.Fatal(err)
+    }
+    clientCert, clientKey := clientCA.issue(t, "billing-service")
+    pair, err := tls.X509KeyPair(clientCert, clientKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    strangerCert, strangerKey := newAuthority(t, "other CA").issue(t, "stranger")
+    stranger, _ := tls.X509KeyPair(strangerCert, strangerKey)
+
+    for _, tt := range []struct {
+        name   string
+        auth   tls.ClientAuthType
+        cert   *tls.Certificate
+        wantOK bool
+    }{
+        {"required, with certificate", tls.RequireAndVerifyClientCert, &pair, true},
+        {"required, without certificate", tls.RequireAndVerifyClientCert, nil, false},
+        {"required, untrusted certificate", tls.RequireAndVerifyClientCert, &stranger, false},
+        {"optional, without certificate", tls.VerifyClientCertIfGiven, nil, true},
+        {"optional, untrusted certificate", tls.VerifyClientCertIfGiven, &stranger, false},
+    } {
+        t.Run(tt.name, func(t *testing.T) {
+            addr := serve(t, r.ServerConfig(tt.auth))
+            client := &tls.Config{RootCAs: serverCA.pool(), ServerName: "localhost"}
+            // present the certificate even when the server asks for another CA
+            client.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+                if tt.cert == nil {
+                    return &tls.Certificate{}, nil
+                }
+                return tt.cert, nil
+            }
+            _, err := connect(addr, client)
+            if ok := err == nil; ok != tt.wantOK {
+                t.Fatalf("connected %v (%v), want %v", ok, err, tt.wantOK)
+            }
+        })
+    }
+}
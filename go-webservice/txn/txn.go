@@ -0,0 +1,187 @@
+// Package txn carries a unit of work in a context: one database
+// transaction shared by every statement made with that context, and the
+// side effects that must wait until it commits.
+package txn
+
+import (
+    "context"
+    "errors"
+    "sync"
+
+    "gorm.io/gorm"
+)
+
+var ErrDone = errors.New("unit of work already finished")
+
+type unitKey struct{}
+
+// Unit is a transaction that begins on first use, so a request that never
+// touches the database, or only reaches it after a slow upload, does not
+// hold a connection for longer than it needs.
+type Unit struct {
+    db *gorm.DB
+
+    mu    sync.Mutex
+    tx    *gorm.DB
+    err   error
+    done  bool
+    hooks []func()
+}
+
+// New returns ctx carrying a unit of work on db. The caller must finish it
+// with Commit or Rollback.
+func New(ctx context.Context, db *gorm.DB) (context.Context, *Unit) {
+    u := &Unit{db: db}
+    return context.WithValue(ctx, unitKey{}, u), u
+}
+
+// From returns the unit of work in ctx.
+func From(ctx context.Context) (*Unit, bool) {
+    u, ok := ctx.Value(unitKey{}).(*Unit)
+    return u, ok && u != nil
+}
+
+// Without returns ctx detached from its unit of work, for work that must
+// commit on its own whatever happens to the caller's transaction.
+func Without(ctx context.Context) context.Context {
+    if _, ok := From(ctx); !ok {
+        return ctx
+    }
+    return context.WithValue(ctx, unitKey{}, (*Unit)(nil))
+}
+
+// DB returns the unit's transaction bound to ctx, beginning it if needed.
+// If it cannot begin, the returned handle carries the error, so the first
+// statement made with it fails.
+func (u *Unit) DB(ctx context.Context) *gorm.DB {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    switch {
+    case u.done:
+        db := u.db.WithContext(ctx)
+        db.AddError(ErrDone)
+        return db
+    case u.tx == nil && u.err == nil:
+        tx := u.db.WithContext(ctx).Begin()
+        if tx.Error != nil {
+            u.err = tx.Error
+        } else {
+            u.tx = tx
+        }
+    }
+    if u.err != nil {
+        db := u.db.WithContext(ctx)
+        db.AddError(u.err)
+        return db
+    }
+    return u.tx.WithContext(ctx)
+}
+
+// Started reports whether the unit has begun its transaction.
+func (u *Unit) Started() bool {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    return u.tx != nil
+}
+
+// AfterCommit runs fn once the outermost unit of work in ctx commits, and
+// drops it if the unit rolls back. Without a unit, fn runs at once. fn gets
+// ctx without the unit, since its transaction is over by then.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+    u, ok := From(ctx)
+    if !ok {
+        fn(ctx)
+        return
+    }
+    detached := Without(ctx)
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    u.hooks = append(u.hooks, func() { fn(detached) })
+}
+
+// Commit commits the transaction, if it began, then runs the after-commit
+// hooks. A unit whose transaction failed to begin reports that error.
+func (u *Unit) Commit() error {
+    u.mu.Lock()
+    if u.done {
+        u.mu.Unlock()
+        return ErrDone
+    }
+    u.done = true
+    tx, err, hooks := u.tx, u.err, u.hooks
+    u.hooks = nil
+    u.mu.Unlock()
+
+    if err != nil {
+        return err
+    }
+    if tx != nil {
+        if err := tx.Commit().Error; err != nil {
+            return err
+        }
+    }
+    for _, fn := range hooks {
+        fn()
+    }
+    return nil
+}
+
+// Rollback abandons the transaction and its after-commit hooks. It is safe
+// to call after Commit, where it does nothing.
+func (u *Unit) Rollback() error {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    if u.done {
+        return nil
+    }
+    u.done = true
+    u.hooks = nil
+    if u.tx == nil {
+        return nil
+    }
+    return u.tx.Rollback().Error
+}
+
+// Run calls fn inside a unit of work. Outside one it begins a unit on db,
+// commits it when fn returns nil and rolls it back when fn fails or
+// panics. Inside one, fn runs in a savepoint of the enclosing transaction:
+// its failure rolls back only its own changes and hooks, and its success
+// leaves the outer unit to commit.
+func Run(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) (err error) {
+    parent, ok := From(ctx)
+    if !ok {
+        ctx, u := New(ctx, db)
+        defer func() {
+            if r := recover(); r != nil {
+                u.Rollback()
+                panic(r)
+            }
+        }()
+        if err := fn(ctx); err != nil {
+            u.Rollback()
+            return err
+        }
+        return u.Commit()
+    }
+
+    tx := parent.DB(ctx)
+    if tx.Error != nil {
+        return tx.Error
+    }
+    child := &Unit{db: db}
+    err = tx.Transaction(func(sp *gorm.DB) error {
+        child.tx = sp
+        return fn(context.WithValue(ctx, unitKey{}, child))
+    })
+    if err != nil {
+        return err
+    }
+    child.mu.Lock()
+    hooks := child.hooks
+    child.done = true
+    child.mu.Unlock()
+    parent.mu.Lock()
+    defer parent.mu.Unlock()
+    parent.hooks = append(parent.hooks, hooks...)
+    return nil
+}
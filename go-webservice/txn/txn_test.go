@@ -0,0 +1,230 @@
+package txn
+
+import (
+    "context"
+    "errors"
+    "path/filepath"
+    "testing"
+
+    "github.com/glebarez/sqlite"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+type item struct {
+    ID   uint
+    Name string
+}
+
+func open(t *testing.T) *gorm.DB {
+    t.Helper()
+    dsn := filepath.Join(t.TempDir(), "txn.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+    db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := db.AutoMigrate(&item{}); err != nil {
+        t.Fatal(err)
+    }
+    sqlDB, _ := db.DB()
+    t.Cleanup(func() { sqlDB.Close() })
+    return db
+}
+
+// names lists the committed items, as another connection sees them.
+func names(t *testing.T, db *gorm.DB) []string {
+    t.Helper()
+    var items []item
+    if err := db.Order("id").Find(&items).Error; err != nil {
+        t.Fatal(err)
+    }
+    var names []string
+    for _, it := range items {
+        names = append(names, it.Name)
+    }
+    return names
+}
+
+func insert(t *testing.T, ctx context.Context, name string) {
+    t.Helper()
+    u, _ := From(ctx)
+    if err := u.DB(ctx).Create(&item{Name: name}).Error; err != nil {
+        t.Fatalf("insert %s: %v", name, err)
+    }
+}
+
+func TestCommit(t *testing.T) {
+    db := open(t)
+    ctx, u := New(context.Background(), db)
+    if u.Started() {
+        t.Fatal("unit began before it was used")
+    }
+    var ran []string
+    AfterCommit(ctx, func(ctx context.Context) {
+        if _, ok := From(ctx); ok {
+            t.Error("after-commit hook got the finished unit")
+        }
+        ran = append(ran, "hook")
+    })
+    insert(t, ctx, "a")
+    if !u.Started() {
+        t.Fatal("unit did not begin on first use")
+    }
+    if got := names(t, db); len(got) != 0 || len(ran) != 0 {
+        t.Fatalf("before commit: items %v, hooks %v", got, ran)
+    }
+    if err := u.Commit(); err != nil {
+        t.Fatal(err)
+    }
+    if got := names(t, db); len(got) != 1 || len(ran) != 1 {
+        t.Fatalf("after commit: items %v, hooks %v", got, ran)
+    }
+
+    if err := u.Commit(); !errors.Is(err, ErrDone) {
+        t.Errorf("second Commit: %v, want ErrDone", err)
+    }
+    if err := u.Rollback(); err != nil {
+        t.Errorf("Rollback after Commit: %v", err)
+    }
+    if err := u.DB(ctx).Create(&item{Name: "late"}).Error; !errors.Is(err, ErrDone) {
+        t.Errorf("write after Commit: %v, want ErrDone", err)
+    }
+}
+
+func TestRollback(t *testing.T) {
+    db := open(t)
+    ctx, u := New(context.Background(), db)
+    ran := false
+    AfterCommit(ctx, func(context.Context) { ran = true })
+    insert(t, ctx, "a")
+    if err := u.Rollback(); err != nil {
+        t.Fatal(err)
+    }
+    if got := names(t, db); len(got) != 0 || ran {
+        t.Fatalf("after rollback: items %v, hook ran %v", got, ran)
+    }
+}
+
+func TestUnusedUnit(t *testing.T) {
+    db := open(t)
+    ctx, u := New(context.Background(), db)
+    ran := false
+    AfterCommit(ctx, func(context.Context) { ran = true })
+    if err := u.Commit(); err != nil {
+        t.Fatal(err)
+    }
+    if u.Started() || !ran {
+        t.Fatalf("started %v, hook ran %v; want no transaction and the hook run", u.Started(), ran)
+    }
+}
+
+func TestWithoutUnit(t *testing.T) {
+    ran := false
+    AfterCommit(context.Background(), func(context.Context) { ran = true })
+    if !ran {
+        t.Fatal("hook outside a unit did not run at once")
+    }
+
+    ctx, _ := New(context.Background(), open(t))
+    detached := Without(ctx)
+    if _, ok := From(detached); ok {
+        t.Fatal("Without kept the unit")
+    }
+    ran = false
+    AfterCommit(detached, func(context.Context) { ran = true })
+    if !ran {
+        t.Fatal("hook on a detached context did not run at once")
+    }
+    if bg := context.Background(); Without(bg) != bg {
+        t.Fatal("Without changed a context with no unit")
+    }
+}
+
+func TestRun(t *testing.T) {
+    db := open(t)
+    err := Run(context.Background(), db, func(ctx context.Context) error {
+        insert(t, ctx, "kept")
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    failed := errors.New("failed")
+    err = Run(context.Background(), db, func(ctx context.Context) error {
+        insert(t, ctx, "dropped")
+        return failed
+    })
+    if !errors.Is(err, failed) {
+        t.Fatalf("Run: %v, want fn's error", err)
+    }
+    func() {
+        defer func() {
+            if r := recover(); r != "boom" {
+                t.Errorf("recovered %v, want the panic passed on", r)
+            }
+        }()
+        Run(context.Background(), db, func(ctx context.Context) error {
+            insert(t, ctx, "panicked")
+            panic("boom")
+        })
+    }()
+    if got := names(t, db); len(got) != 1 || got[0] != "kept" {
+        t.Fatalf("items %v, want only the committed one", got)
+    }
+}
+
+func TestSavepoints(t *testing.T) {
+    db := open(t)
+    ctx, u := New(context.Background(), db)
+    var ran []string
+    insert(t, ctx, "outer")
+
+    err := Run(ctx, db, func(ctx context.Context) error {
+        insert(t, ctx, "inner-failed")
+        AfterCommit(ctx, func(context.Context) { ran = append(ran, "inner-failed") })
+        return errors.New("failed")
+    })
+    if err == nil {
+        t.Fatal("failed savepoint reported success")
+    }
+    err = Run(ctx, db, func(ctx context.Context) error {
+        insert(t, ctx, "inner")
+        AfterCommit(ctx, func(context.Context) { ran = append(ran, "inner") })
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := names(t, db); len(got) != 0 || len(ran) != 0 {
+        t.Fatalf("savepoint committed early: items %v, hooks %v", got, ran)
+    }
+
+    if err := u.Commit(); err != nil {
+        t.Fatal(err)
+    }
+    got := names(t, db)
+    if len(got) != 2 || got[0] != "outer" || got[1] != "inner" {
+        t.Fatalf("items %v, want outer and inner", got)
+    }
+    if len(ran) != 1 || ran[0] != "inner" {
+        t.Fatalf("hooks %v, want only the successful savepoint's", ran)
+    }
+}
+
+func TestSavepointRolledBackWithOuter(t *testing.T) {
+    db := open(t)
+    ctx, u := New(context.Background(), db)
+    ran := false
+    err := Run(ctx, db, func(ctx context.Context) error {
+        insert(t, ctx, "inner")
+        AfterCommit(ctx, func(context.Context) { ran = true })
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    u.Rollback()
+    if got := names(t, db); len(got) != 0 || ran {
+        t.Fatalf("after outer rollback: items %v, hook ran %v", got, ran)
+    }
+}
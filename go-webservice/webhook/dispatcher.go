@@ -0,0 +1,266 @@
+package webhook
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// Dispatcher moves events from the outbox into per-webhook deliveries and
+// sends them, retrying failures with exponential backoff until MaxAttempts
+// is reached, after which the delivery is marked dead. Several dispatchers
+// can share the tables: on Postgres outbox rows and deliveries are claimed
+// with SELECT ... FOR UPDATE SKIP LOCKED, as jobs are.
+//
+// Deliveries are not jobs. Each one already records its attempts, status
+// code, next attempt and dead-letter state for the webhook endpoints, and
+// a job per delivery would keep a second, diverging copy of that state and
+// add a row to the jobs table for every event and receiver. The outbox
+// already keeps fan-out off the request path, which is what jobs are for.
+type Dispatcher struct {
+    DB           *gorm.DB
+    Client       *http.Client
+    PollInterval time.Duration
+    BatchSize    int
+    MaxAttempts  int
+    BaseBackoff  time.Duration
+    MaxBackoff   time.Duration
+    // ClaimTimeout is how long claimed deliveries are held back from other
+    // dispatchers, in case this one dies before recording the attempts. It
+    // should cover sending a whole batch.
+    ClaimTimeout time.Duration
+    Now          func() time.Time
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+    return &Dispatcher{
+        DB:           db,
+        Client:       NewClient(10 * time.Second),
+        PollInterval: 2 * time.Second,
+        BatchSize:    100,
+        MaxAttempts:  8,
+        BaseBackoff:  5 * time.Second,
+        MaxBackoff:   time.Hour,
+        ClaimTimeout: 30 * time.Minute,
+        Now:          func() time.Time { return time.Now().UTC() },
+    }
+}
+
+// Run polls until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+    ticker := time.NewTicker(d.PollInterval)
+    defer ticker.Stop()
+    for {
+        if err := d.RunOnce(ctx); err != nil {
+            log.Println("webhook dispatcher:", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// RunOnce fans out pending outbox events and attempts every delivery that
+// is due. It is exported so tests can drive the dispatcher step by step.
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+    // the dispatcher serves every tenant; rows it creates carry the
+    // tenant of the event they came from
+    ctx = tenant.System(ctx)
+    if err := d.fanOut(ctx); err != nil {
+        return err
+    }
+    return d.deliverDue(ctx)
+}
+
+func (d *Dispatcher) fanOut(ctx context.Context) error {
+    return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var events []model.OutboxEvent
+        q := tx.Where("dispatched_at IS NULL").Order("id").Limit(d.BatchSize)
+        if tx.Dialector.Name() == "postgres" {
+            q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+        }
+        if err := q.Find(&events).Error; err != nil || len(events) == 0 {
+            return err
+        }
+        var hooks []model.Webhook
+        if err := tx.Where("active = ?", true).Find(&hooks).Error; err != nil {
+            return err
+        }
+        now := d.Now()
+        for _, event := range events {
+            // the condition keeps databases without row locks from fanning
+            // out an event twice
+            res := tx.Model(&event).Where("dispatched_at IS NULL").Update("dispatched_at", now)
+            if res.Error != nil {
+                return res.Error
+            }
+            if res.RowsAffected == 0 {
+                continue
+            }
+            for _, hook := range hooks {
+                if hook.TenantID != event.TenantID || !hook.Wants(event.Type) {
+                    continue
+                }
+                delivery := model.WebhookDelivery{
+                    TenantID:      event.TenantID,
+                    WebhookID:     hook.ID,
+                    EventID:       event.EventID,
+                    EventType:     event.Type,
+                    Payload:       event.Payload,
+                    Status:        model.DeliveryPending,
+                    NextAttemptAt: now,
+                }
+                if err := tx.Create(&delivery).Error; err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    })
+}
+
+// deliverDue sends the deliveries it claims. Any it does not get to, because
+// ctx ends or the database fails, are due again once their claim expires.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+    claimedAt := d.Now()
+    deliveries, err := d.claim(ctx, claimedAt)
+    if err != nil {
+        return err
+    }
+    db := d.DB.WithContext(ctx)
+    for _, delivery := range deliveries {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        if !d.Now().Before(claimedAt.Add(d.ClaimTimeout)) {
+            // the rest may have been claimed by another dispatcher by now
+            return nil
+        }
+        var hook model.Webhook
+        err := db.First(&hook, "id = ? AND tenant_id = ?", delivery.WebhookID, delivery.TenantID).Error
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            // webhook removed since fan-out; nothing left to deliver to
+            delivery.Status = model.DeliveryDead
+            delivery.LastError = "webhook no longer registered"
+            if err := db.Model(&delivery).Select("status", "last_error").Updates(&delivery).Error; err != nil {
+                return err
+            }
+            continue
+        }
+        if err != nil {
+            return err
+        }
+        status, sendErr := d.send(ctx, hook, delivery)
+        d.record(&delivery, status, sendErr)
+        err = db.Model(&delivery).
+            Select("status", "attempts", "last_status_code", "last_error", "next_attempt_at", "delivered_at").
+            Updates(&delivery).Error
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// claim takes up to BatchSize due deliveries and moves their next attempt
+// ClaimTimeout ahead, so no other dispatcher picks them up while they are
+// being sent.
+func (d *Dispatcher) claim(ctx context.Context, now time.Time) ([]model.WebhookDelivery, error) {
+    var claimed []model.WebhookDelivery
+    err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var due []model.WebhookDelivery
+        q := tx.Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+            Order("next_attempt_at").Limit(d.BatchSize)
+        if tx.Dialector.Name() == "postgres" {
+            q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+        }
+        if err := q.Find(&due).Error; err != nil {
+            return err
+        }
+        for _, delivery := range due {
+            // as in fanOut, the condition stands in for the row lock
+            res := tx.Model(&model.WebhookDelivery{}).
+                Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, model.DeliveryPending, now).
+                Update("next_attempt_at", now.Add(d.ClaimTimeout))
+            if res.Error != nil {
+                return res.Error
+            }
+            if res.RowsAffected == 1 {
+                claimed = append(claimed, delivery)
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return claimed, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, hook model.Webhook, delivery model.WebhookDelivery) (int, error) {
+    body := []byte(delivery.Payload)
+    ts := d.Now().Unix()
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set(HeaderEvent, delivery.EventType)
+    req.Header.Set(HeaderID, delivery.EventID)
+    req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+    req.Header.Set(HeaderSignature, Sign(hook.Secret, ts, body))
+
+    resp, err := d.Client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+    }
+    return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(delivery *model.WebhookDelivery, status int, err error) {
+    now := d.Now()
+    delivery.Attempts++
+    delivery.LastStatusCode = status
+    if err == nil {
+        delivery.Status = model.DeliveryDelivered
+        delivery.LastError = ""
+        delivery.DeliveredAt = &now
+        return
+    }
+    delivery.LastError = err.Error()
+    if delivery.Attempts >= d.MaxAttempts {
+        delivery.Status = model.DeliveryDead
+        return
+    }
+    delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+}
+
+// backoff doubles the delay for each failed attempt, capped at MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+    delay := d.BaseBackoff
+    for i := 1; i < attempts; i++ {
+        delay *= 2
+        if delay >= d.MaxBackoff {
+            return d.MaxBackoff
+        }
+    }
+    return delay
+}
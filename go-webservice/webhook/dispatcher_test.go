@@ -0,0 +1,276 @@
+package webhook
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/glebarez/sqlite"
+    "go-webservice/model"
+    "go-webservice/tenant"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+const secret = "whsec"
+
+// receiver is a webhook endpoint answering with status and recording what
+// it was sent.
+type receiver struct {
+    *httptest.Server
+    mu       sync.Mutex
+    status   int
+    requests []*http.Request
+    bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+    r := &receiver{status: status}
+    r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        body, _ := io.ReadAll(req.Body)
+        r.mu.Lock()
+        r.requests = append(r.requests, req)
+        r.bodies = append(r.bodies, body)
+        status := r.status
+        r.mu.Unlock()
+        w.WriteHeader(status)
+    }))
+    t.Cleanup(r.Close)
+    return r
+}
+
+func (r *receiver) hits() int {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return len(r.requests)
+}
+
+// testDispatcher returns a dispatcher over a fresh database, sending with
+// the receiver's client, whose clock only moves when the test moves it.
+func testDispatcher(t *testing.T, r *receiver) (*Dispatcher, *time.Time) {
+    t.Helper()
+    dsn := filepath.Join(t.TempDir(), "webhooks.db") + "?_pragma=busy_timeout(5000)"
+    db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := db.Use(tenant.Plugin{}); err != nil {
+        t.Fatal(err)
+    }
+    if err := db.AutoMigrate(&model.OutboxEvent{}, &model.Webhook{}, &model.WebhookDelivery{}); err != nil {
+        t.Fatal(err)
+    }
+    now := time.Date(2026, time.January, 14, 10, 0, 0, 0, time.UTC)
+    d := NewDispatcher(db)
+    d.Client = r.Client()
+    d.Now = func() time.Time { return now }
+    d.MaxAttempts = 3
+    d.BaseBackoff = 10 * time.Second
+    d.MaxBackoff = 15 * time.Second
+    return d, &now
+}
+
+func in(d *Dispatcher, tenantID string) *gorm.DB {
+    return d.DB.WithContext(tenant.WithTenant(context.Background(), tenantID))
+}
+
+func register(t *testing.T, d *Dispatcher, tenantID, url, events string) model.Webhook {
+    t.Helper()
+    hook := model.Webhook{URL: url, Secret: secret, Events: events, Active: true}
+    if err := in(d, tenantID).Create(&hook).Error; err != nil {
+        t.Fatal(err)
+    }
+    return hook
+}
+
+func emit(t *testing.T, d *Dispatcher, tenantID, eventID, eventType string) {
+    t.Helper()
+    event := model.OutboxEvent{EventID: eventID, Type: eventType, Payload: `{"id":"` + eventID + `"}`}
+    if err := in(d, tenantID).Create(&event).Error; err != nil {
+        t.Fatal(err)
+    }
+}
+
+func deliveries(t *testing.T, d *Dispatcher) []model.WebhookDelivery {
+    t.Helper()
+    var all []model.WebhookDelivery
+    if err := d.DB.WithContext(tenant.System(context.Background())).Order("id").Find(&all).Error; err != nil {
+        t.Fatal(err)
+    }
+    return all
+}
+
+func runOnce(t *testing.T, d *Dispatcher) {
+    t.Helper()
+    if err := d.RunOnce(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestDeliverySigned(t *testing.T) {
+    r := newReceiver(t, http.StatusNoContent)
+    d, now := testDispatcher(t, r)
+    register(t, d, "acme", r.URL, "course.created")
+    register(t, d, "acme", r.URL, "course.deleted")
+    register(t, d, "globex", r.URL, "")
+    emit(t, d, "acme", "evt-1", "course.created")
+
+    runOnce(t, d)
+    if r.hits() != 1 {
+        t.Fatalf("receiver got %d requests, want 1 for the one subscribed acme webhook", r.hits())
+    }
+    req, body := r.requests[0], r.bodies[0]
+    ts, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+    if err != nil || ts != now.Unix() {
+        t.Fatalf("timestamp header %q", req.Header.Get(HeaderTimestamp))
+    }
+    if !Verify(secret, ts, body, req.Header.Get(HeaderSignature)) {
+        t.Fatalf("signature %q does not verify", req.Header.Get(HeaderSignature))
+    }
+    if Verify("other", ts, body, req.Header.Get(HeaderSignature)) || Verify(secret, ts+1, body, req.Header.Get(HeaderSignature)) {
+        t.Fatal("signature verifies with another secret or timestamp")
+    }
+    if req.Header.Get(HeaderEvent) != "course.created" || req.Header.Get(HeaderID) != "evt-1" || string(body) != `{"id":"evt-1"}` {
+        t.Fatalf("request %v with body %s", req.Header, body)
+    }
+
+    got := deliveries(t, d)
+    if len(got) != 1 || got[0].Status != model.DeliveryDelivered || got[0].Attempts != 1 ||
+        got[0].LastStatusCode != http.StatusNoContent || got[0].DeliveredAt == nil {
+        t.Fatalf("deliveries %+v", got)
+    }
+    runOnce(t, d)
+    if r.hits() != 1 || len(deliveries(t, d)) != 1 {
+        t.Fatal("delivered event sent again")
+    }
+}
+
+func TestRetryBackoffAndDeadLetter(t *testing.T) {
+    r := newReceiver(t, http.StatusServiceUnavailable)
+    d, now := testDispatcher(t, r)
+    register(t, d, "acme", r.URL, "")
+    emit(t, d, "acme", "evt-1", "course.created")
+
+    steps := []struct {
+        advance time.Duration
+        hits    int
+        status  string
+        next    time.Duration
+    }{
+        {0, 1, model.DeliveryPending, 10 * time.Second},
+        {5 * time.Second, 1, model.DeliveryPending, 5 * time.Second},
+        // doubled to 20s, capped at MaxBackoff
+        {5 * time.Second, 2, model.DeliveryPending, 15 * time.Second},
+        {15 * time.Second, 3, model.DeliveryDead, 0},
+        {time.Hour, 3, model.DeliveryDead, 0},
+    }
+    for i, step := range steps {
+        *now = now.Add(step.advance)
+        runOnce(t, d)
+        got := deliveries(t, d)[0]
+        if r.hits() != step.hits || got.Status != step.status {
+            t.Fatalf("step %d: %d requests, status %s; want %d, %s", i, r.hits(), got.Status, step.hits, step.status)
+        }
+        if step.next != 0 && !got.NextAttemptAt.Equal(now.Add(step.next)) {
+            t.Fatalf("step %d: next attempt at %s, want %s", i, got.NextAttemptAt, now.Add(step.next))
+        }
+    }
+    dead := deliveries(t, d)[0]
+    if dead.Attempts != 3 || dead.LastStatusCode != http.StatusServiceUnavailable || dead.LastError == "" {
+        t.Fatalf("dead delivery %+v", dead)
+    }
+}
+
+func TestRemovedWebhookDeadLetters(t *testing.T) {
+    r := newReceiver(t, http.StatusOK)
+    d, _ := testDispatcher(t, r)
+    hook := register(t, d, "acme", r.URL, "")
+    emit(t, d, "acme", "evt-1", "course.created")
+    if err := d.fanOut(tenant.System(context.Background())); err != nil {
+        t.Fatal(err)
+    }
+    if err := in(d, "acme").Delete(&hook).Error; err != nil {
+        t.Fatal(err)
+    }
+
+    runOnce(t, d)
+    got := deliveries(t, d)[0]
+    if r.hits() != 0 || got.Status != model.DeliveryDead || got.LastError != "webhook no longer registered" {
+        t.Fatalf("delivery %+v after %d requests", got, r.hits())
+    }
+}
+
+func TestClaimedDeliveriesAreSkipped(t *testing.T) {
+    r := newReceiver(t, http.StatusOK)
+    d, now := testDispatcher(t, r)
+    register(t, d, "acme", r.URL, "")
+    emit(t, d, "acme", "evt-1", "course.created")
+    ctx := tenant.System(context.Background())
+    if err := d.fanOut(ctx); err != nil {
+        t.Fatal(err)
+    }
+    other := *d
+
+    if claimed, err := d.claim(ctx, *now); err != nil || len(claimed) != 1 {
+        t.Fatalf("first claim: %v, %v", claimed, err)
+    }
+    if claimed, err := other.claim(ctx, *now); err != nil || len(claimed) != 0 {
+        t.Fatalf("second dispatcher claimed %v, %v", claimed, err)
+    }
+    // the first dispatcher died before recording the attempt
+    *now = now.Add(d.ClaimTimeout)
+    if claimed, err := other.claim(ctx, *now); err != nil || len(claimed) != 1 {
+        t.Fatalf("expired claim not taken over: %v, %v", claimed, err)
+    }
+}
+
+func TestValidateURL(t *testing.T) {
+    tests := []struct {
+        url string
+        ok  bool
+    }{
+        {"https://93.184.216.34/hooks", true},
+        {"http://[2606:2800:220:1::]:8080/hooks", true},
+        {"ftp://93.184.216.34/hooks", false},
+        {"https:///hooks", false},
+        {"not a url", false},
+        {"http://127.0.0.1:8080/hooks", false},
+        {"http://[::1]/hooks", false},
+        {"http://[::ffff:127.0.0.1]/hooks", false},
+        {"http://0.0.0.0/hooks", false},
+        {"http://10.1.2.3/hooks", false},
+        {"http://172.16.0.1/hooks", false},
+        {"http://192.168.1.1/hooks", false},
+        {"http://[fd00::1]/hooks", false},
+        {"http://169.254.169.254/latest/meta-data", false},
+        {"http://[fe80::1]/hooks", false},
+        {"http://localhost/hooks", false},
+    }
+    for _, tt := range tests {
+        err := ValidateURL(context.Background(), tt.url)
+        if tt.ok && err != nil {
+            t.Errorf("ValidateURL(%q) = %v", tt.url, err)
+        }
+        if !tt.ok && !errors.Is(err, ErrInvalidURL) {
+            t.Errorf("ValidateURL(%q) = %v, want ErrInvalidURL", tt.url, err)
+        }
+    }
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+    r := newReceiver(t, http.StatusOK)
+    _, err := NewClient(time.Second).Post(r.URL, "application/json", nil)
+    if !errors.Is(err, ErrInvalidURL) {
+        t.Fatalf("post to %s: %v, want ErrInvalidURL", r.URL, err)
+    }
+    if r.hits() != 0 {
+        t.Fatal("request reached a loopback receiver")
+    }
+}
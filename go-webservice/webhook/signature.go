@@ -0,0 +1,36 @@
+package webhook
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "strconv"
+    "strings"
+)
+
+const (
+    HeaderEvent     = "X-Webhook-Event"
+    HeaderID        = "X-Webhook-ID"
+    HeaderTimestamp = "X-Webhook-Timestamp"
+    HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the value of the signature header for a payload. The HMAC
+// covers "<timestamp>.<body>" so a captured request cannot be replayed with
+// a different timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+    mac.Write([]byte("."))
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header produced by Sign. Receivers should also
+// reject timestamps that are too old.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+    if !strings.HasPrefix(signature, "sha256=") {
+        return false
+    }
+    return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
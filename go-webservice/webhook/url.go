@@ -0,0 +1,77 @@
+package webhook
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "syscall"
+    "time"
+)
+
+var ErrInvalidURL = errors.New("invalid webhook URL")
+
+// ValidateURL checks that a webhook target is an http or https URL whose
+// host resolves only to public addresses. Webhooks are registered by
+// tenants, so letting them point at loopback, link-local or private
+// addresses would let any admin make the service probe its own network.
+func ValidateURL(ctx context.Context, raw string) error {
+    u, err := url.Parse(raw)
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return fmt.Errorf("%w: scheme must be http or https", ErrInvalidURL)
+    }
+    host := u.Hostname()
+    if host == "" {
+        return fmt.Errorf("%w: host is required", ErrInvalidURL)
+    }
+    if ip := net.ParseIP(host); ip != nil {
+        if !public(ip) {
+            return fmt.Errorf("%w: %s is not a public address", ErrInvalidURL, host)
+        }
+        return nil
+    }
+    addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+    if err != nil {
+        return fmt.Errorf("%w: cannot resolve %s", ErrInvalidURL, host)
+    }
+    for _, addr := range addrs {
+        if !public(addr.IP) {
+            return fmt.Errorf("%w: %s resolves to %s, which is not a public address", ErrInvalidURL, host, addr.IP)
+        }
+    }
+    return nil
+}
+
+// NewClient returns an HTTP client that refuses to connect to the
+// addresses ValidateURL rejects. The check at registration alone is not
+// enough: DNS for a valid host can later be changed to point inwards.
+func NewClient(timeout time.Duration) *http.Client {
+    dialer := &net.Dialer{
+        Timeout: 5 * time.Second,
+        Control: func(network, address string, _ syscall.RawConn) error {
+            host, _, err := net.SplitHostPort(address)
+            if err != nil {
+                return err
+            }
+            if ip := net.ParseIP(host); ip == nil || !public(ip) {
+                return fmt.Errorf("%w: %s is not a public address", ErrInvalidURL, host)
+            }
+            return nil
+        },
+    }
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.Proxy = nil
+    transport.DialContext = dialer.DialContext
+    return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func public(ip net.IP) bool {
+    return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+        !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+        !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}